@@ -16,6 +16,7 @@ func NewBuilderCommand(dockerCli command.Cli) *cobra.Command {
 		RunE:  command.ShowHelp(dockerCli.Err()),
 	}
 	cmd.AddCommand(
+		NewDiskUsageCommand(dockerCli),
 		NewPruneCommand(dockerCli),
 	)
 	return cmd
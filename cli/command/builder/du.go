@@ -0,0 +1,78 @@
+package builder
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/yuyangjack/dockercli/cli"
+	"github.com/yuyangjack/dockercli/cli/command"
+	"github.com/yuyangjack/dockercli/cli/command/formatter"
+	units "github.com/docker/go-units"
+	"github.com/spf13/cobra"
+)
+
+type diskUsageOptions struct {
+	quiet   bool
+	noTrunc bool
+	format  string
+}
+
+// NewDiskUsageCommand returns a new cobra command for `builder du`
+func NewDiskUsageCommand(dockerCli command.Cli) *cobra.Command {
+	var options diskUsageOptions
+
+	cmd := &cobra.Command{
+		Use:   "du [OPTIONS]",
+		Short: "Show build cache disk usage",
+		Args:  cli.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDiskUsage(dockerCli, options)
+		},
+		Annotations: map[string]string{"version": "1.39"},
+	}
+
+	flags := cmd.Flags()
+	flags.BoolVarP(&options.quiet, "quiet", "q", false, "Only display build cache IDs")
+	flags.BoolVar(&options.noTrunc, "no-trunc", false, "Don't truncate output")
+	flags.StringVar(&options.format, "format", "", "Pretty-print build cache records using a Go template")
+
+	return cmd
+}
+
+func runDiskUsage(dockerCli command.Cli, options diskUsageOptions) error {
+	du, err := dockerCli.Client().DiskUsage(context.Background())
+	if err != nil {
+		return err
+	}
+
+	format := options.format
+	if len(format) == 0 {
+		format = formatter.TableFormatKey
+	}
+	duCtx := formatter.Context{
+		Output: dockerCli.Out(),
+		Format: formatter.NewBuildCacheFormat(format, options.quiet),
+		Trunc:  !options.noTrunc,
+	}
+	if err := formatter.BuildCacheWrite(duCtx, du.BuildCache); err != nil {
+		return err
+	}
+	if options.quiet || format != formatter.TableFormatKey {
+		return nil
+	}
+
+	// Records shared with other records are counted once, and the
+	// records in use cannot be reclaimed
+	var total, reclaimable int64
+	for _, bc := range du.BuildCache {
+		if bc.Shared {
+			continue
+		}
+		total += bc.Size
+		if !bc.InUse {
+			reclaimable += bc.Size
+		}
+	}
+	fmt.Fprintf(dockerCli.Out(), "\nTotal:\t\t%s\nReclaimable:\t%s\n", units.HumanSize(float64(total)), units.HumanSize(float64(reclaimable)))
+	return nil
+}
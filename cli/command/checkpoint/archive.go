@@ -0,0 +1,239 @@
+package checkpoint
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/yuyangjack/moby/api/types"
+	"github.com/yuyangjack/moby/api/types/container"
+	"github.com/yuyangjack/moby/client"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// metadataFile is the archive entry holding the container metadata. It is
+	// always the first entry so that importers can create the container
+	// before the checkpoint data is streamed.
+	metadataFile = "metadata.json"
+	// checkpointPrefix is the archive directory holding the checkpoint data.
+	checkpointPrefix = "checkpoint"
+
+	// helperImage is used to read and write checkpoint data on the daemon
+	// host through a bind mount. The helper container is never started.
+	helperImage = "busybox:latest"
+	// helperTarget is where the checkpoint directory is mounted in the helper.
+	helperTarget = "/checkpoints"
+)
+
+// archiveMetadata describes the container a checkpoint archive was taken from.
+type archiveMetadata struct {
+	Checkpoint string
+	Name       string
+	Image      string
+	Config     *container.Config
+	HostConfig *container.HostConfig
+}
+
+// ImportOptions holds options for importing a checkpoint archive.
+type ImportOptions struct {
+	// Name is the name of the container to create. If empty, the name of
+	// the exported container is used.
+	Name string
+	// CheckpointDir is a custom checkpoint storage directory on the daemon.
+	CheckpointDir string
+}
+
+// checkpointParentDir returns the directory on the daemon host that holds the
+// checkpoints of a container.
+func checkpointParentDir(ctx context.Context, apiClient client.APIClient, containerID, checkpointDir string) (string, error) {
+	if checkpointDir != "" {
+		return checkpointDir, nil
+	}
+	info, err := apiClient.Info(ctx)
+	if err != nil {
+		return "", err
+	}
+	if info.DockerRootDir == "" {
+		return "", errors.New("unable to determine the daemon's root directory; use --checkpoint-dir")
+	}
+	return path.Join(filepath.ToSlash(info.DockerRootDir), "containers", containerID, "checkpoints"), nil
+}
+
+// withHelper creates a helper container with hostDir bind-mounted at
+// helperTarget, calls fn with its ID and removes it again.
+func withHelper(ctx context.Context, apiClient client.APIClient, hostDir string, fn func(id string) error) error {
+	config := &container.Config{Image: helperImage, Cmd: []string{"true"}}
+	hostConfig := &container.HostConfig{Binds: []string{hostDir + ":" + helperTarget}}
+
+	helper, err := apiClient.ContainerCreate(ctx, config, hostConfig, nil, "")
+	if client.IsErrNotFound(err) {
+		var resp io.ReadCloser
+		if resp, err = apiClient.ImageCreate(ctx, helperImage, types.ImageCreateOptions{}); err != nil {
+			return err
+		}
+		_, err = io.Copy(ioutil.Discard, resp)
+		resp.Close()
+		if err != nil {
+			return err
+		}
+		helper, err = apiClient.ContainerCreate(ctx, config, hostConfig, nil, "")
+	}
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := apiClient.ContainerRemove(ctx, helper.ID, types.ContainerRemoveOptions{Force: true}); err != nil {
+			logrus.Debugf("Error removing checkpoint helper container %s: %s", helper.ID, err)
+		}
+	}()
+	return fn(helper.ID)
+}
+
+// Export writes a portable archive of a checkpoint and the metadata of its
+// container to out.
+func Export(ctx context.Context, apiClient client.APIClient, containerID, checkpointID, checkpointDir string, out io.Writer) error {
+	c, err := apiClient.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return err
+	}
+	dir, err := checkpointParentDir(ctx, apiClient, c.ID, checkpointDir)
+	if err != nil {
+		return err
+	}
+
+	tw := tar.NewWriter(out)
+	metadata, err := json.Marshal(archiveMetadata{
+		Checkpoint: checkpointID,
+		Name:       strings.TrimPrefix(c.Name, "/"),
+		Image:      c.Config.Image,
+		Config:     c.Config,
+		HostConfig: c.HostConfig,
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: metadataFile, Mode: 0644, Size: int64(len(metadata))}); err != nil {
+		return err
+	}
+	if _, err := tw.Write(metadata); err != nil {
+		return err
+	}
+
+	err = withHelper(ctx, apiClient, dir, func(id string) error {
+		content, _, err := apiClient.CopyFromContainer(ctx, id, path.Join(helperTarget, checkpointID))
+		if err != nil {
+			return err
+		}
+		defer content.Close()
+		return copyEntries(tar.NewReader(content), tw, checkpointID, checkpointPrefix)
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to read checkpoint %s", checkpointID)
+	}
+	return tw.Close()
+}
+
+// Import creates a container from an archive written by Export and restores
+// the checkpoint data next to it. It returns the ID of the new container and
+// the name of the checkpoint to start it from.
+func Import(ctx context.Context, apiClient client.APIClient, in io.Reader, opts ImportOptions) (string, string, error) {
+	tr := tar.NewReader(in)
+	hdr, err := tr.Next()
+	if err != nil {
+		return "", "", errors.Wrap(err, "invalid checkpoint archive")
+	}
+	if hdr.Name != metadataFile {
+		return "", "", errors.Errorf("invalid checkpoint archive: expected %s, found %s", metadataFile, hdr.Name)
+	}
+	var metadata archiveMetadata
+	if err := json.NewDecoder(tr).Decode(&metadata); err != nil {
+		return "", "", errors.Wrap(err, "invalid checkpoint archive metadata")
+	}
+	if metadata.Checkpoint == "" || metadata.Config == nil {
+		return "", "", errors.New("invalid checkpoint archive metadata")
+	}
+
+	name := opts.Name
+	if name == "" {
+		name = metadata.Name
+	}
+	created, err := apiClient.ContainerCreate(ctx, metadata.Config, metadata.HostConfig, nil, name)
+	if client.IsErrNotFound(err) {
+		return "", "", errors.Wrapf(err, "image %s must be present on the daemon to import the checkpoint", metadata.Image)
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	if err := restoreCheckpoint(ctx, apiClient, tr, created.ID, metadata.Checkpoint, opts.CheckpointDir); err != nil {
+		// Do not leave a container that cannot be started from its checkpoint
+		if rmErr := apiClient.ContainerRemove(ctx, created.ID, types.ContainerRemoveOptions{Force: true}); rmErr != nil {
+			return "", "", errors.Wrapf(err, "failed to remove container %s (%v)", created.ID, rmErr)
+		}
+		return "", "", err
+	}
+	return created.ID, metadata.Checkpoint, nil
+}
+
+// restoreCheckpoint copies the checkpoint data of an archive next to the
+// container containerID
+func restoreCheckpoint(ctx context.Context, apiClient client.APIClient, tr *tar.Reader, containerID, checkpointID, checkpointDir string) error {
+	dir, err := checkpointParentDir(ctx, apiClient, containerID, checkpointDir)
+	if err != nil {
+		return err
+	}
+	err = withHelper(ctx, apiClient, dir, func(id string) error {
+		pr, pw := io.Pipe()
+		go func() {
+			tw := tar.NewWriter(pw)
+			err := copyEntries(tr, tw, checkpointPrefix, checkpointID)
+			if err == nil {
+				err = tw.Close()
+			}
+			pw.CloseWithError(err)
+		}()
+		defer pr.Close()
+		return apiClient.CopyToContainer(ctx, id, helperTarget, pr, types.CopyToContainerOptions{})
+	})
+	return errors.Wrapf(err, "failed to restore checkpoint %s", checkpointID)
+}
+
+// copyEntries copies every entry below the "from" directory of tr to tw,
+// renaming that directory to "to". Entries outside "from" are skipped.
+func copyEntries(tr *tar.Reader, tw *tar.Writer, from, to string) error {
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := path.Clean(hdr.Name)
+		switch {
+		case name == from:
+			name = to
+		case strings.HasPrefix(name, from+"/"):
+			name = to + strings.TrimPrefix(name, from)
+		default:
+			continue
+		}
+		if hdr.Typeflag == tar.TypeDir {
+			name += "/"
+		}
+		hdr.Name = name
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return err
+		}
+	}
+}
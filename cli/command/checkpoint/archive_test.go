@@ -0,0 +1,147 @@
+package checkpoint
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/yuyangjack/moby/api/types"
+	"github.com/yuyangjack/moby/api/types/container"
+	"github.com/pkg/errors"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func checkpointTar(t *testing.T, entries map[string]string) io.ReadCloser {
+	buf := new(bytes.Buffer)
+	tw := tar.NewWriter(buf)
+	assert.NilError(t, tw.WriteHeader(&tar.Header{Name: "ckpt/", Typeflag: tar.TypeDir, Mode: 0755}))
+	for name, content := range entries {
+		assert.NilError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(content))}))
+		_, err := tw.Write([]byte(content))
+		assert.NilError(t, err)
+	}
+	assert.NilError(t, tw.Close())
+	return ioutil.NopCloser(buf)
+}
+
+func readTar(t *testing.T, r io.Reader) map[string]string {
+	entries := map[string]string{}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return entries
+		}
+		assert.NilError(t, err)
+		content, err := ioutil.ReadAll(tr)
+		assert.NilError(t, err)
+		entries[hdr.Name] = string(content)
+	}
+}
+
+func TestCheckpointExportImport(t *testing.T) {
+	var helperBinds []string
+	source := &fakeClient{
+		containerInspectFunc: func(container string) (types.ContainerJSON, error) {
+			return types.ContainerJSON{
+				ContainerJSONBase: &types.ContainerJSONBase{ID: "abc", Name: "/web", HostConfig: &container.HostConfig{}},
+				Config:            &container.Config{Image: "nginx"},
+			}, nil
+		},
+		containerCreateFunc: func(config *container.Config, hostConfig *container.HostConfig, name string) (container.ContainerCreateCreatedBody, error) {
+			helperBinds = hostConfig.Binds
+			return container.ContainerCreateCreatedBody{ID: "helper"}, nil
+		},
+		copyFromFunc: func(container, srcPath string) (io.ReadCloser, types.ContainerPathStat, error) {
+			assert.Check(t, is.Equal("/checkpoints/ckpt", srcPath))
+			return checkpointTar(t, map[string]string{"ckpt/pages-1.img": "pages"}), types.ContainerPathStat{}, nil
+		},
+	}
+
+	archive := new(bytes.Buffer)
+	assert.NilError(t, Export(context.Background(), source, "web", "ckpt", "", archive))
+	assert.Check(t, is.DeepEqual([]string{"/var/lib/docker/containers/abc/checkpoints:/checkpoints"}, helperBinds))
+
+	var (
+		createdName  string
+		createdImage string
+		restored     map[string]string
+	)
+	destination := &fakeClient{
+		containerCreateFunc: func(config *container.Config, hostConfig *container.HostConfig, name string) (container.ContainerCreateCreatedBody, error) {
+			if name == "" {
+				helperBinds = hostConfig.Binds
+				return container.ContainerCreateCreatedBody{ID: "helper"}, nil
+			}
+			createdName, createdImage = name, config.Image
+			return container.ContainerCreateCreatedBody{ID: "def"}, nil
+		},
+		copyToFunc: func(container, dstPath string, content io.Reader) error {
+			assert.Check(t, is.Equal("/checkpoints", dstPath))
+			restored = readTar(t, content)
+			return nil
+		},
+	}
+
+	containerID, checkpointID, err := Import(context.Background(), destination, archive, ImportOptions{})
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal("def", containerID))
+	assert.Check(t, is.Equal("ckpt", checkpointID))
+	assert.Check(t, is.Equal("web", createdName))
+	assert.Check(t, is.Equal("nginx", createdImage))
+	assert.Check(t, is.DeepEqual([]string{"/var/lib/docker/containers/def/checkpoints:/checkpoints"}, helperBinds))
+	assert.Check(t, is.DeepEqual(map[string]string{"ckpt/": "", "ckpt/pages-1.img": "pages"}, restored))
+}
+
+func TestCheckpointImportInvalidArchive(t *testing.T) {
+	buf := new(bytes.Buffer)
+	tw := tar.NewWriter(buf)
+	assert.NilError(t, tw.WriteHeader(&tar.Header{Name: "foo", Mode: 0644}))
+	assert.NilError(t, tw.Close())
+
+	_, _, err := Import(context.Background(), &fakeClient{}, buf, ImportOptions{})
+	assert.ErrorContains(t, err, "expected metadata.json")
+}
+
+func TestCheckpointImportRemovesContainerOnFailure(t *testing.T) {
+	source := &fakeClient{
+		containerInspectFunc: func(container string) (types.ContainerJSON, error) {
+			return types.ContainerJSON{
+				ContainerJSONBase: &types.ContainerJSONBase{ID: "abc", Name: "/web", HostConfig: &container.HostConfig{}},
+				Config:            &container.Config{Image: "nginx"},
+			}, nil
+		},
+		copyFromFunc: func(container, srcPath string) (io.ReadCloser, types.ContainerPathStat, error) {
+			return checkpointTar(t, map[string]string{"ckpt/pages-1.img": "pages"}), types.ContainerPathStat{}, nil
+		},
+	}
+	archive := new(bytes.Buffer)
+	assert.NilError(t, Export(context.Background(), source, "web", "ckpt", "", archive))
+
+	var removed []string
+	destination := &fakeClient{
+		containerCreateFunc: func(config *container.Config, hostConfig *container.HostConfig, name string) (container.ContainerCreateCreatedBody, error) {
+			if name == "" {
+				return container.ContainerCreateCreatedBody{ID: "helper"}, nil
+			}
+			return container.ContainerCreateCreatedBody{ID: "def"}, nil
+		},
+		copyToFunc: func(container, dstPath string, content io.Reader) error {
+			return errors.New("no space left on device")
+		},
+		containerRemoveFunc: func(container string, options types.ContainerRemoveOptions) error {
+			assert.Check(t, options.Force)
+			removed = append(removed, container)
+			return nil
+		},
+	}
+
+	containerID, _, err := Import(context.Background(), destination, archive, ImportOptions{})
+	assert.Check(t, is.Error(err, "failed to restore checkpoint ckpt: no space left on device"))
+	assert.Check(t, is.Equal("", containerID))
+	assert.Check(t, is.DeepEqual([]string{"helper", "def"}, removed))
+}
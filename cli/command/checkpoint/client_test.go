@@ -2,8 +2,13 @@ package checkpoint
 
 import (
 	"context"
+	"io"
+	"io/ioutil"
+	"strings"
 
 	"github.com/yuyangjack/moby/api/types"
+	"github.com/yuyangjack/moby/api/types/container"
+	"github.com/yuyangjack/moby/api/types/network"
 	"github.com/yuyangjack/moby/client"
 )
 
@@ -12,6 +17,11 @@ type fakeClient struct {
 	checkpointCreateFunc func(container string, options types.CheckpointCreateOptions) error
 	checkpointDeleteFunc func(container string, options types.CheckpointDeleteOptions) error
 	checkpointListFunc   func(container string, options types.CheckpointListOptions) ([]types.Checkpoint, error)
+	containerInspectFunc func(container string) (types.ContainerJSON, error)
+	containerCreateFunc  func(config *container.Config, hostConfig *container.HostConfig, name string) (container.ContainerCreateCreatedBody, error)
+	copyFromFunc         func(container, srcPath string) (io.ReadCloser, types.ContainerPathStat, error)
+	copyToFunc           func(container, dstPath string, content io.Reader) error
+	containerRemoveFunc  func(container string, options types.ContainerRemoveOptions) error
 }
 
 func (cli *fakeClient) CheckpointCreate(ctx context.Context, container string, options types.CheckpointCreateOptions) error {
@@ -34,3 +44,42 @@ func (cli *fakeClient) CheckpointList(ctx context.Context, container string, opt
 	}
 	return []types.Checkpoint{}, nil
 }
+
+func (cli *fakeClient) ContainerInspect(ctx context.Context, container string) (types.ContainerJSON, error) {
+	if cli.containerInspectFunc != nil {
+		return cli.containerInspectFunc(container)
+	}
+	return types.ContainerJSON{}, nil
+}
+
+func (cli *fakeClient) Info(ctx context.Context) (types.Info, error) {
+	return types.Info{DockerRootDir: "/var/lib/docker"}, nil
+}
+
+func (cli *fakeClient) ContainerCreate(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, containerName string) (container.ContainerCreateCreatedBody, error) {
+	if cli.containerCreateFunc != nil {
+		return cli.containerCreateFunc(config, hostConfig, containerName)
+	}
+	return container.ContainerCreateCreatedBody{ID: "helper"}, nil
+}
+
+func (cli *fakeClient) ContainerRemove(ctx context.Context, container string, options types.ContainerRemoveOptions) error {
+	if cli.containerRemoveFunc != nil {
+		return cli.containerRemoveFunc(container, options)
+	}
+	return nil
+}
+
+func (cli *fakeClient) CopyFromContainer(ctx context.Context, container, srcPath string) (io.ReadCloser, types.ContainerPathStat, error) {
+	if cli.copyFromFunc != nil {
+		return cli.copyFromFunc(container, srcPath)
+	}
+	return ioutil.NopCloser(strings.NewReader("")), types.ContainerPathStat{}, nil
+}
+
+func (cli *fakeClient) CopyToContainer(ctx context.Context, container, dstPath string, content io.Reader, options types.CopyToContainerOptions) error {
+	if cli.copyToFunc != nil {
+		return cli.copyToFunc(container, dstPath, content)
+	}
+	return nil
+}
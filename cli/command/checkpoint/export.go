@@ -0,0 +1,59 @@
+package checkpoint
+
+import (
+	"context"
+	"os"
+
+	"github.com/yuyangjack/dockercli/cli"
+	"github.com/yuyangjack/dockercli/cli/command"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+type exportOptions struct {
+	container     string
+	checkpoint    string
+	checkpointDir string
+	output        string
+}
+
+func newExportCommand(dockerCli command.Cli) *cobra.Command {
+	var opts exportOptions
+
+	cmd := &cobra.Command{
+		Use:   "export [OPTIONS] CONTAINER CHECKPOINT",
+		Short: "Export a checkpoint and its container metadata to a tar archive",
+		Args:  cli.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.container = args[0]
+			opts.checkpoint = args[1]
+			return runExport(dockerCli, opts)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVarP(&opts.output, "output", "o", "", "Write to a file, instead of STDOUT")
+	flags.StringVarP(&opts.checkpointDir, "checkpoint-dir", "", "", "Use a custom checkpoint storage directory")
+
+	return cmd
+}
+
+func runExport(dockerCli command.Cli, opts exportOptions) error {
+	if opts.output == "" {
+		if dockerCli.Out().IsTerminal() {
+			return errors.New("cowardly refusing to save to a terminal. Use the -o flag or redirect")
+		}
+		return Export(context.Background(), dockerCli.Client(), opts.container, opts.checkpoint, opts.checkpointDir, dockerCli.Out())
+	}
+
+	f, err := os.Create(opts.output)
+	if err != nil {
+		return errors.Wrap(err, "failed to create output file")
+	}
+	if err := Export(context.Background(), dockerCli.Client(), opts.container, opts.checkpoint, opts.checkpointDir, f); err != nil {
+		f.Close()
+		os.Remove(opts.output)
+		return err
+	}
+	return f.Close()
+}
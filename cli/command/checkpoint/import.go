@@ -0,0 +1,63 @@
+package checkpoint
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/yuyangjack/dockercli/cli"
+	"github.com/yuyangjack/dockercli/cli/command"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+type importOptions struct {
+	input         string
+	name          string
+	checkpointDir string
+}
+
+func newImportCommand(dockerCli command.Cli) *cobra.Command {
+	var opts importOptions
+
+	cmd := &cobra.Command{
+		Use:   "import [OPTIONS]",
+		Short: "Create a container and its checkpoint from a tar archive",
+		Args:  cli.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runImport(dockerCli, opts)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVarP(&opts.input, "input", "i", "", "Read from tar archive file, instead of STDIN")
+	flags.StringVar(&opts.name, "name", "", "Assign a name to the container")
+	flags.StringVarP(&opts.checkpointDir, "checkpoint-dir", "", "", "Use a custom checkpoint storage directory")
+
+	return cmd
+}
+
+func runImport(dockerCli command.Cli, opts importOptions) error {
+	var input io.Reader = dockerCli.In()
+	if opts.input != "" {
+		file, err := os.Open(opts.input)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		input = file
+	} else if dockerCli.In().IsTerminal() {
+		return errors.New("requested import from STDIN, but STDIN is a terminal. Use the -i flag or redirect")
+	}
+
+	containerID, checkpointID, err := Import(context.Background(), dockerCli.Client(), input, ImportOptions{
+		Name:          opts.name,
+		CheckpointDir: opts.checkpointDir,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(dockerCli.Out(), "%s %s\n", containerID, checkpointID)
+	return nil
+}
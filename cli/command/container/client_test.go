@@ -7,6 +7,7 @@ import (
 	"github.com/yuyangjack/moby/api/types"
 	"github.com/yuyangjack/moby/api/types/container"
 	"github.com/yuyangjack/moby/api/types/network"
+	volumetypes "github.com/yuyangjack/moby/api/types/volume"
 	"github.com/yuyangjack/moby/client"
 )
 
@@ -15,6 +16,7 @@ type fakeClient struct {
 	inspectFunc         func(string) (types.ContainerJSON, error)
 	execInspectFunc     func(execID string) (types.ContainerExecInspect, error)
 	execCreateFunc      func(container string, config types.ExecConfig) (types.IDResponse, error)
+	execAttachFunc      func(execID string, config types.ExecStartCheck) (types.HijackedResponse, error)
 	createContainerFunc func(config *container.Config,
 		hostConfig *container.HostConfig,
 		networkingConfig *network.NetworkingConfig,
@@ -29,7 +31,12 @@ type fakeClient struct {
 	containerListFunc       func(types.ContainerListOptions) ([]types.Container, error)
 	containerExportFunc     func(string) (io.ReadCloser, error)
 	containerExecResizeFunc func(id string, options types.ResizeOptions) error
+	checkpointCreateFunc    func(container string, options types.CheckpointCreateOptions) error
+	containerRemoveFunc     func(container string, options types.ContainerRemoveOptions) error
+	copyToContainerFunc     func(container, dstPath string, content io.Reader) error
+	volumeCreateFunc        func(options volumetypes.VolumeCreateBody) (types.Volume, error)
 	Version                 string
+	daemonHost              string
 }
 
 func (f *fakeClient) ContainerList(_ context.Context, options types.ContainerListOptions) ([]types.Container, error) {
@@ -143,3 +150,42 @@ func (f *fakeClient) ContainerExecResize(_ context.Context, id string, options t
 	}
 	return nil
 }
+
+func (f *fakeClient) CheckpointCreate(_ context.Context, container string, options types.CheckpointCreateOptions) error {
+	if f.checkpointCreateFunc != nil {
+		return f.checkpointCreateFunc(container, options)
+	}
+	return nil
+}
+
+func (f *fakeClient) ContainerRemove(_ context.Context, container string, options types.ContainerRemoveOptions) error {
+	if f.containerRemoveFunc != nil {
+		return f.containerRemoveFunc(container, options)
+	}
+	return nil
+}
+
+func (f *fakeClient) CopyToContainer(_ context.Context, container, dstPath string, content io.Reader, _ types.CopyToContainerOptions) error {
+	if f.copyToContainerFunc != nil {
+		return f.copyToContainerFunc(container, dstPath, content)
+	}
+	return nil
+}
+
+func (f *fakeClient) DaemonHost() string {
+	return f.daemonHost
+}
+
+func (f *fakeClient) ContainerExecAttach(_ context.Context, execID string, config types.ExecStartCheck) (types.HijackedResponse, error) {
+	if f.execAttachFunc != nil {
+		return f.execAttachFunc(execID, config)
+	}
+	return types.HijackedResponse{}, nil
+}
+
+func (f *fakeClient) VolumeCreate(_ context.Context, options volumetypes.VolumeCreateBody) (types.Volume, error) {
+	if f.volumeCreateFunc != nil {
+		return f.volumeCreateFunc(options)
+	}
+	return types.Volume{}, nil
+}
@@ -22,8 +22,10 @@ func NewContainerCommand(dockerCli command.Cli) *cobra.Command {
 		NewDiffCommand(dockerCli),
 		NewExecCommand(dockerCli),
 		NewExportCommand(dockerCli),
+		NewHealthCommand(dockerCli),
 		NewKillCommand(dockerCli),
 		NewLogsCommand(dockerCli),
+		NewMigrateCommand(dockerCli),
 		NewPauseCommand(dockerCli),
 		NewPortCommand(dockerCli),
 		NewRenameCommand(dockerCli),
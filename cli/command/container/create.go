@@ -65,7 +65,12 @@ func runCreate(dockerCli command.Cli, flags *pflag.FlagSet, opts *createOptions,
 		reportError(dockerCli.Err(), "create", err.Error(), true)
 		return cli.StatusError{StatusCode: 125}
 	}
-	response, err := createContainer(context.Background(), dockerCli, containerConfig, opts)
+	ctx := context.Background()
+	warnOnRemoteBinds(dockerCli, containerConfig.HostConfig, dockerCli.Err())
+	if _, err := prepareSyncMounts(ctx, dockerCli, containerConfig.HostConfig); err != nil {
+		return err
+	}
+	response, err := createContainer(ctx, dockerCli, containerConfig, opts)
 	if err != nil {
 		return err
 	}
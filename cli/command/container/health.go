@@ -0,0 +1,281 @@
+package container
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/yuyangjack/dockercli/cli"
+	"github.com/yuyangjack/dockercli/cli/command"
+	"github.com/yuyangjack/dockercli/cli/command/formatter"
+	"github.com/yuyangjack/moby/api/types"
+	"github.com/yuyangjack/moby/api/types/container"
+	"github.com/yuyangjack/moby/pkg/stdcopy"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// healthPollInterval is how often the health state is inspected with --follow.
+	healthPollInterval = time.Second
+	// defaultProbeTimeout mirrors the daemon's default health check timeout.
+	defaultProbeTimeout = 30 * time.Second
+	// maxProbeOutputLen mirrors the daemon, which keeps at most this many
+	// bytes of probe output.
+	maxProbeOutputLen = 4096
+)
+
+type healthOptions struct {
+	container string
+	format    string
+	noTrunc   bool
+	follow    bool
+	test      bool
+}
+
+// NewHealthCommand creates a new cobra.Command for `docker container health`
+func NewHealthCommand(dockerCli command.Cli) *cobra.Command {
+	var opts healthOptions
+
+	cmd := &cobra.Command{
+		Use:   "health [OPTIONS] CONTAINER",
+		Short: "Show the health check state and probe log of a container",
+		Args:  cli.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.container = args[0]
+			if opts.test {
+				return runHealthTest(dockerCli, opts)
+			}
+			return runHealth(dockerCli, opts)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&opts.format, "format", "", "Pretty-print probe results using a Go template")
+	flags.BoolVar(&opts.noTrunc, "no-trunc", false, "Don't truncate output")
+	flags.BoolVarP(&opts.follow, "follow", "f", false, "Follow new probe results")
+	flags.BoolVar(&opts.test, "test", false, "Run the configured health check once and report the result")
+
+	return cmd
+}
+
+func runHealth(dockerCli command.Cli, opts healthOptions) error {
+	ctx := context.Background()
+	state, err := inspectHealth(ctx, dockerCli, opts.container)
+	if err != nil {
+		return err
+	}
+	health := state.Health
+
+	format := opts.format
+	if len(format) == 0 {
+		format = formatter.TableFormatKey
+	}
+	if format == formatter.TableFormatKey {
+		fmt.Fprintf(dockerCli.Out(), "Status:         %s\n", health.Status)
+		fmt.Fprintf(dockerCli.Out(), "Failing streak: %d\n\n", health.FailingStreak)
+	}
+	healthCtx := formatter.Context{
+		Output: dockerCli.Out(),
+		Format: formatter.NewHealthFormat(format),
+		Trunc:  !opts.noTrunc,
+	}
+	if err := formatter.HealthWrite(healthCtx, health.Log); err != nil {
+		return err
+	}
+	if !opts.follow {
+		return nil
+	}
+
+	// Subsequent results are written without repeating the table header.
+	if healthCtx.Format.IsTable() {
+		healthCtx.Format = formatter.Format(healthCtx.Format[len(formatter.TableFormatKey):])
+	}
+	status := health.Status
+	last := lastProbeStart(health.Log)
+	for state.Running {
+		time.Sleep(healthPollInterval)
+		state, err = inspectHealth(ctx, dockerCli, opts.container)
+		if err != nil {
+			return err
+		}
+		health = state.Health
+		if health.Status != status {
+			fmt.Fprintf(dockerCli.Err(), "Status changed: %s -> %s (failing streak: %d)\n", status, health.Status, health.FailingStreak)
+			status = health.Status
+		}
+		var results []*types.HealthcheckResult
+		for _, result := range health.Log {
+			if result.Start.After(last) {
+				results = append(results, result)
+			}
+		}
+		if len(results) == 0 {
+			continue
+		}
+		if err := formatter.HealthWrite(healthCtx, results); err != nil {
+			return err
+		}
+		last = lastProbeStart(results)
+	}
+	// No probe runs once the container stopped
+	fmt.Fprintf(dockerCli.Err(), "Container %s is not running\n", opts.container)
+	return nil
+}
+
+// inspectHealth returns the state of a container, whose health is set
+func inspectHealth(ctx context.Context, dockerCli command.Cli, containerID string) (*types.ContainerState, error) {
+	c, err := dockerCli.Client().ContainerInspect(ctx, containerID)
+	if err != nil {
+		return nil, err
+	}
+	if c.State == nil || c.State.Health == nil {
+		return nil, errors.Errorf("container %s has no health check", containerID)
+	}
+	return c.State, nil
+}
+
+func lastProbeStart(results []*types.HealthcheckResult) time.Time {
+	var last time.Time
+	for _, result := range results {
+		if result.Start.After(last) {
+			last = result.Start
+		}
+	}
+	return last
+}
+
+// probeCommand returns the command the daemon runs for a health check, or an
+// error if the container has no health check configured.
+func probeCommand(healthcheck *container.HealthConfig, platform string) ([]string, error) {
+	if healthcheck == nil || len(healthcheck.Test) == 0 || healthcheck.Test[0] == "NONE" {
+		return nil, errors.New("no health check is configured")
+	}
+	switch healthcheck.Test[0] {
+	case "CMD":
+		if len(healthcheck.Test) < 2 {
+			return nil, errors.New("health check command is empty")
+		}
+		return healthcheck.Test[1:], nil
+	case "CMD-SHELL":
+		if len(healthcheck.Test) < 2 {
+			return nil, errors.New("health check command is empty")
+		}
+		if platform == "windows" {
+			return []string{"cmd", "/S", "/C", healthcheck.Test[1]}, nil
+		}
+		return []string{"/bin/sh", "-c", healthcheck.Test[1]}, nil
+	default:
+		return nil, errors.Errorf("unknown health check type %q", healthcheck.Test[0])
+	}
+}
+
+// runHealthTest runs the configured health check once through exec and
+// reports the result the way the daemon would record it.
+func runHealthTest(dockerCli command.Cli, opts healthOptions) error {
+	ctx := context.Background()
+	client := dockerCli.Client()
+
+	c, err := client.ContainerInspect(ctx, opts.container)
+	if err != nil {
+		return err
+	}
+	if c.State == nil || !c.State.Running {
+		return errors.Errorf("container %s is not running", opts.container)
+	}
+	cmd, err := probeCommand(c.Config.Healthcheck, c.Platform)
+	if err != nil {
+		return errors.Wrapf(err, "container %s", opts.container)
+	}
+	timeout := c.Config.Healthcheck.Timeout
+	if timeout == 0 {
+		timeout = defaultProbeTimeout
+	}
+
+	result, err := runProbe(ctx, dockerCli, c.ID, cmd, timeout)
+	if err != nil {
+		return err
+	}
+
+	state := types.Healthy
+	if result.ExitCode != 0 {
+		state = types.Unhealthy
+	}
+	out := dockerCli.Out()
+	fmt.Fprintf(out, "Command:   %q\n", cmd)
+	fmt.Fprintf(out, "Duration:  %s\n", result.End.Sub(result.Start).Round(time.Millisecond))
+	fmt.Fprintf(out, "Exit code: %d\n", result.ExitCode)
+	fmt.Fprintf(out, "Result:    %s\n", state)
+	fmt.Fprintf(out, "Output:\n%s\n", result.Output)
+	if result.ExitCode != 0 {
+		return cli.StatusError{StatusCode: 1}
+	}
+	return nil
+}
+
+func runProbe(ctx context.Context, dockerCli command.Cli, containerID string, cmd []string, timeout time.Duration) (*types.HealthcheckResult, error) {
+	client := dockerCli.Client()
+	execConfig := types.ExecConfig{
+		Cmd:          cmd,
+		AttachStdout: true,
+		AttachStderr: true,
+	}
+	response, err := client.ContainerExecCreate(ctx, containerID, execConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &types.HealthcheckResult{Start: time.Now()}
+	resp, err := client.ContainerExecAttach(ctx, response.ID, types.ExecStartCheck{})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Close()
+
+	output := new(bytes.Buffer)
+	done := make(chan error, 1)
+	go func() {
+		_, err := stdcopy.StdCopy(output, output, resp.Reader)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		result.End = time.Now()
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+	case <-time.After(timeout):
+		result.End = time.Now()
+		result.ExitCode = -1
+		result.Output = fmt.Sprintf("Health check exceeded timeout (%v)", timeout)
+		return result, nil
+	}
+
+	// The exit code is recorded shortly after the output streams are closed.
+	var inspect types.ContainerExecInspect
+	for i := 0; i < 10; i++ {
+		if inspect, err = client.ContainerExecInspect(ctx, response.ID); err != nil {
+			return nil, err
+		}
+		if !inspect.Running {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	result.Output = output.String()
+	if inspect.Running {
+		// The probe closed its output but did not exit: fail it, as the
+		// daemon fails a probe that does not exit before its timeout.
+		result.ExitCode = -1
+		result.Output += "Health check closed its output but did not exit"
+	} else {
+		result.ExitCode = inspect.ExitCode
+	}
+	if len(result.Output) > maxProbeOutputLen {
+		result.Output = result.Output[:maxProbeOutputLen]
+	}
+	return result, nil
+}
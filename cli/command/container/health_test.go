@@ -0,0 +1,140 @@
+package container
+
+import (
+	"bufio"
+	"context"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yuyangjack/dockercli/internal/test"
+	"github.com/yuyangjack/moby/api/types"
+	"github.com/yuyangjack/moby/api/types/container"
+	"github.com/pkg/errors"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestProbeCommand(t *testing.T) {
+	testCases := []struct {
+		test          []string
+		platform      string
+		expected      []string
+		expectedError string
+	}{
+		{test: []string{"CMD", "curl", "-f", "http://localhost"}, expected: []string{"curl", "-f", "http://localhost"}},
+		{test: []string{"CMD-SHELL", "curl -f http://localhost || exit 1"}, expected: []string{"/bin/sh", "-c", "curl -f http://localhost || exit 1"}},
+		{test: []string{"CMD-SHELL", "exit 0"}, platform: "windows", expected: []string{"cmd", "/S", "/C", "exit 0"}},
+		{test: []string{"NONE"}, expectedError: "no health check is configured"},
+		{test: []string{"CMD"}, expectedError: "health check command is empty"},
+		{test: []string{"FOO", "bar"}, expectedError: `unknown health check type "FOO"`},
+	}
+	for _, tc := range testCases {
+		cmd, err := probeCommand(&container.HealthConfig{Test: tc.test}, tc.platform)
+		if tc.expectedError != "" {
+			assert.Check(t, is.Error(err, tc.expectedError))
+			continue
+		}
+		assert.NilError(t, err)
+		assert.Check(t, is.DeepEqual(tc.expected, cmd))
+	}
+}
+
+func TestRunHealthNoHealthCheck(t *testing.T) {
+	cli := test.NewFakeCli(&fakeClient{
+		inspectFunc: func(string) (types.ContainerJSON, error) {
+			return types.ContainerJSON{ContainerJSONBase: &types.ContainerJSONBase{State: &types.ContainerState{}}}, nil
+		},
+	})
+	cmd := NewHealthCommand(cli)
+	cmd.SetArgs([]string{"foo"})
+	cmd.SetOutput(ioutil.Discard)
+	assert.ErrorContains(t, cmd.Execute(), "container foo has no health check")
+}
+
+func TestRunHealth(t *testing.T) {
+	start := time.Date(2019, time.May, 1, 10, 0, 0, 0, time.UTC)
+	cli := test.NewFakeCli(&fakeClient{
+		inspectFunc: func(string) (types.ContainerJSON, error) {
+			return types.ContainerJSON{ContainerJSONBase: &types.ContainerJSONBase{State: &types.ContainerState{
+				Health: &types.Health{
+					Status:        types.Unhealthy,
+					FailingStreak: 3,
+					Log: []*types.HealthcheckResult{
+						{Start: start, End: start.Add(time.Second), ExitCode: 1, Output: "refused"},
+					},
+				},
+			}}}, nil
+		},
+	})
+	cmd := NewHealthCommand(cli)
+	cmd.SetArgs([]string{"--format", "{{.ExitCode}} {{.Output}}", "foo"})
+	assert.NilError(t, cmd.Execute())
+	assert.Check(t, is.Equal("1 refused\n", cli.OutBuffer().String()))
+}
+
+func TestRunProbeStillRunning(t *testing.T) {
+	cli := test.NewFakeCli(&fakeClient{
+		execCreateFunc: func(string, types.ExecConfig) (types.IDResponse, error) {
+			return types.IDResponse{ID: "exec"}, nil
+		},
+		execAttachFunc: func(string, types.ExecStartCheck) (types.HijackedResponse, error) {
+			conn, _ := net.Pipe()
+			return types.HijackedResponse{Conn: conn, Reader: bufio.NewReader(strings.NewReader(""))}, nil
+		},
+		execInspectFunc: func(string) (types.ContainerExecInspect, error) {
+			return types.ContainerExecInspect{Running: true}, nil
+		},
+	})
+	result, err := runProbe(context.Background(), cli, "foo", []string{"true"}, time.Minute)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(-1, result.ExitCode))
+	assert.Check(t, is.Equal("Health check closed its output but did not exit", result.Output))
+}
+
+func TestRunHealthFollowStopsWithContainer(t *testing.T) {
+	start := time.Date(2019, time.May, 1, 10, 0, 0, 0, time.UTC)
+	first := &types.HealthcheckResult{Start: start, End: start.Add(time.Second), ExitCode: 1, Output: "refused"}
+	second := &types.HealthcheckResult{Start: start.Add(time.Minute), End: start.Add(time.Minute + time.Second), ExitCode: 0, Output: "ok"}
+	states := []*types.ContainerState{
+		{Running: true, Health: &types.Health{Status: types.Unhealthy, FailingStreak: 1, Log: []*types.HealthcheckResult{first}}},
+		{Running: false, Health: &types.Health{Status: types.Healthy, Log: []*types.HealthcheckResult{first, second}}},
+	}
+	cli := test.NewFakeCli(&fakeClient{
+		inspectFunc: func(string) (types.ContainerJSON, error) {
+			state := states[0]
+			if len(states) > 1 {
+				states = states[1:]
+			}
+			return types.ContainerJSON{ContainerJSONBase: &types.ContainerJSONBase{State: state}}, nil
+		},
+	})
+	cmd := NewHealthCommand(cli)
+	cmd.SetArgs([]string{"--follow", "--format", "{{.ExitCode}} {{.Output}}", "foo"})
+	assert.NilError(t, cmd.Execute())
+	assert.Check(t, is.Equal("1 refused\n0 ok\n", cli.OutBuffer().String()))
+	assert.Check(t, is.Contains(cli.ErrBuffer().String(), "Status changed: unhealthy -> healthy"))
+	assert.Check(t, is.Contains(cli.ErrBuffer().String(), "Container foo is not running"))
+}
+
+func TestRunHealthFollowInspectError(t *testing.T) {
+	inspected := false
+	cli := test.NewFakeCli(&fakeClient{
+		inspectFunc: func(string) (types.ContainerJSON, error) {
+			if inspected {
+				return types.ContainerJSON{}, errors.New("No such container: foo")
+			}
+			inspected = true
+			return types.ContainerJSON{ContainerJSONBase: &types.ContainerJSONBase{State: &types.ContainerState{
+				Running: true,
+				Health:  &types.Health{Status: types.Starting},
+			}}}, nil
+		},
+	})
+	cmd := NewHealthCommand(cli)
+	cmd.SetArgs([]string{"--follow", "foo"})
+	cmd.SetOutput(ioutil.Discard)
+	assert.Check(t, is.Error(cmd.Execute(), "No such container: foo"))
+}
@@ -0,0 +1,182 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/yuyangjack/dockercli/cli"
+	"github.com/yuyangjack/dockercli/cli/command"
+	"github.com/yuyangjack/dockercli/cli/command/checkpoint"
+	cliconfig "github.com/yuyangjack/dockercli/cli/config"
+	cliflags "github.com/yuyangjack/dockercli/cli/flags"
+	"github.com/yuyangjack/moby/api/types"
+	"github.com/yuyangjack/moby/client"
+	"github.com/docker/go-connections/tlsconfig"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+type migrateOptions struct {
+	container     string
+	to            string
+	toTLS         bool
+	toTLSVerify   bool
+	toTLSOptions  tlsconfig.Options
+	name          string
+	checkpoint    string
+	checkpointDir string
+	keep          bool
+}
+
+// NewMigrateCommand creates a new cobra.Command for `docker container migrate`
+func NewMigrateCommand(dockerCli command.Cli) *cobra.Command {
+	var opts migrateOptions
+
+	cmd := &cobra.Command{
+		Use:   "migrate [OPTIONS] CONTAINER",
+		Short: "Move a running container to another daemon using a checkpoint",
+		Args:  cli.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.container = args[0]
+			if opts.to == "" {
+				return errors.New("a destination daemon must be specified with --to")
+			}
+			return runMigrate(dockerCli, opts)
+		},
+		Annotations: map[string]string{
+			"experimental": "",
+			"ostype":       "linux",
+			"version":      "1.25",
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&opts.to, "to", "", "Daemon socket to migrate the container to")
+	flags.BoolVar(&opts.toTLS, "to-tls", false, "Use TLS to connect to the destination daemon; implied by --to-tlsverify")
+	flags.BoolVar(&opts.toTLSVerify, "to-tlsverify", false, "Use TLS and verify the destination daemon")
+	certPath := os.Getenv("DOCKER_CERT_PATH")
+	if certPath == "" {
+		certPath = cliconfig.Dir()
+	}
+	flags.StringVar(&opts.toTLSOptions.CAFile, "to-tlscacert", filepath.Join(certPath, cliflags.DefaultCaFile), "Trust certs of the destination daemon signed only by this CA")
+	flags.StringVar(&opts.toTLSOptions.CertFile, "to-tlscert", filepath.Join(certPath, cliflags.DefaultCertFile), "Path to the TLS certificate file for the destination daemon")
+	flags.StringVar(&opts.toTLSOptions.KeyFile, "to-tlskey", filepath.Join(certPath, cliflags.DefaultKeyFile), "Path to the TLS key file for the destination daemon")
+	flags.StringVar(&opts.name, "name", "", "Name of the container on the destination (default: same name)")
+	flags.StringVar(&opts.checkpoint, "checkpoint", "", "Name of the checkpoint to create (default: generated)")
+	flags.StringVar(&opts.checkpointDir, "checkpoint-dir", "", "Use a custom checkpoint storage directory on both daemons")
+	flags.BoolVar(&opts.keep, "keep", false, "Keep the stopped source container instead of removing it")
+
+	return cmd
+}
+
+func runMigrate(dockerCli command.Cli, opts migrateOptions) error {
+	destination, err := command.NewAPIClientFromFlags(destinationOptions(opts), dockerCli.ConfigFile())
+	if err != nil {
+		return errors.Wrapf(err, "failed to connect to %s", opts.to)
+	}
+	defer destination.Close()
+	return migrate(context.Background(), dockerCli, destination, opts)
+}
+
+// destinationOptions returns the options of the client of the destination
+// daemon, which uses TLS as the --tls and --tlsverify options of the CLI do
+func destinationOptions(opts migrateOptions) *cliflags.CommonOptions {
+	common := &cliflags.CommonOptions{Hosts: []string{opts.to}}
+	if opts.toTLS || opts.toTLSVerify {
+		tlsOptions := opts.toTLSOptions
+		tlsOptions.InsecureSkipVerify = !opts.toTLSVerify
+		// The client certificate is optional, as with --tlscert and --tlskey
+		if _, err := os.Stat(tlsOptions.CertFile); os.IsNotExist(err) {
+			tlsOptions.CertFile = ""
+		}
+		if _, err := os.Stat(tlsOptions.KeyFile); os.IsNotExist(err) {
+			tlsOptions.KeyFile = ""
+		}
+		common.TLS = true
+		common.TLSVerify = opts.toTLSVerify
+		common.TLSOptions = &tlsOptions
+	}
+	return common
+}
+
+// migrate checkpoints the container on the daemon of dockerCli, and restores
+// it on the destination daemon
+func migrate(ctx context.Context, dockerCli command.Cli, destination client.APIClient, opts migrateOptions) error {
+	source := dockerCli.Client()
+	checkpointID := opts.checkpoint
+	if checkpointID == "" {
+		checkpointID = fmt.Sprintf("migrate-%d", time.Now().Unix())
+	}
+	err := source.CheckpointCreate(ctx, opts.container, types.CheckpointCreateOptions{
+		CheckpointID:  checkpointID,
+		CheckpointDir: opts.checkpointDir,
+		Exit:          true,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to checkpoint source container")
+	}
+	fmt.Fprintf(dockerCli.Err(), "Checkpoint %s created on the source daemon\n", checkpointID)
+
+	containerID, err := transferCheckpoint(ctx, source, destination, opts, checkpointID)
+	if err != nil {
+		return restoreSource(ctx, dockerCli, opts, checkpointID, err)
+	}
+
+	err = destination.ContainerStart(ctx, containerID, types.ContainerStartOptions{
+		CheckpointID:  checkpointID,
+		CheckpointDir: opts.checkpointDir,
+	})
+	if err != nil {
+		err = errors.Wrapf(err, "failed to restore container %s on %s", containerID, opts.to)
+		if rmErr := destination.ContainerRemove(ctx, containerID, types.ContainerRemoveOptions{Force: true}); rmErr != nil {
+			fmt.Fprintf(dockerCli.Err(), "WARNING: failed to remove container %s on %s: %s\n", containerID, opts.to, rmErr)
+		}
+		return restoreSource(ctx, dockerCli, opts, checkpointID, err)
+	}
+
+	if !opts.keep {
+		if err := source.ContainerRemove(ctx, opts.container, types.ContainerRemoveOptions{}); err != nil {
+			fmt.Fprintf(dockerCli.Err(), "WARNING: failed to remove source container: %s\n", err)
+		}
+	}
+	fmt.Fprintln(dockerCli.Out(), containerID)
+	return nil
+}
+
+// restoreSource starts the source container again from the checkpoint of a
+// migration that failed with err, as the checkpoint stopped it, so that a
+// failed migration does not stop the service
+func restoreSource(ctx context.Context, dockerCli command.Cli, opts migrateOptions, checkpointID string, err error) error {
+	restoreErr := dockerCli.Client().ContainerStart(ctx, opts.container, types.ContainerStartOptions{
+		CheckpointID:  checkpointID,
+		CheckpointDir: opts.checkpointDir,
+	})
+	if restoreErr != nil {
+		return errors.Wrapf(err, "the source container is left stopped, as restoring it from checkpoint %s failed (%v)", checkpointID, restoreErr)
+	}
+	fmt.Fprintf(dockerCli.Err(), "Source container %s restored from checkpoint %s\n", opts.container, checkpointID)
+	return err
+}
+
+// transferCheckpoint streams a checkpoint archive from the source to the
+// destination daemon and returns the ID of the created container.
+func transferCheckpoint(ctx context.Context, source, destination client.APIClient, opts migrateOptions, checkpointID string) (string, error) {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(checkpoint.Export(ctx, source, opts.container, checkpointID, opts.checkpointDir, pw))
+	}()
+	defer pr.Close()
+
+	containerID, _, err := checkpoint.Import(ctx, destination, pr, checkpoint.ImportOptions{
+		Name:          opts.name,
+		CheckpointDir: opts.checkpointDir,
+	})
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to transfer checkpoint to %s", opts.to)
+	}
+	return containerID, nil
+}
@@ -0,0 +1,162 @@
+package container
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/yuyangjack/dockercli/internal/test"
+	"github.com/yuyangjack/moby/api/types"
+	"github.com/yuyangjack/moby/api/types/container"
+	"github.com/yuyangjack/moby/api/types/network"
+	"github.com/docker/go-connections/tlsconfig"
+	"github.com/pkg/errors"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	"gotest.tools/fs"
+)
+
+// migrationSource returns a fake source daemon of a migration of the
+// container web, which records the calls of the migration in calls
+func migrationSource(t *testing.T, calls *[]string) *fakeClient {
+	return &fakeClient{
+		checkpointCreateFunc: func(container string, options types.CheckpointCreateOptions) error {
+			assert.Check(t, options.Exit)
+			*calls = append(*calls, "checkpoint "+container+" "+options.CheckpointID)
+			return nil
+		},
+		inspectFunc: func(string) (types.ContainerJSON, error) {
+			return types.ContainerJSON{
+				ContainerJSONBase: &types.ContainerJSONBase{ID: "abc", Name: "/web", HostConfig: &container.HostConfig{}},
+				Config:            &container.Config{Image: "nginx"},
+			}, nil
+		},
+		infoFunc: func() (types.Info, error) {
+			return types.Info{DockerRootDir: "/var/lib/docker"}, nil
+		},
+		containerCopyFromFunc: func(container, srcPath string) (io.ReadCloser, types.ContainerPathStat, error) {
+			buf := new(bytes.Buffer)
+			tw := tar.NewWriter(buf)
+			assert.Check(t, tw.WriteHeader(&tar.Header{Name: "ckpt/", Typeflag: tar.TypeDir, Mode: 0755}))
+			assert.Check(t, tw.Close())
+			return ioutil.NopCloser(buf), types.ContainerPathStat{}, nil
+		},
+		containerStartFunc: func(container string, options types.ContainerStartOptions) error {
+			*calls = append(*calls, "start "+container+" "+options.CheckpointID)
+			return nil
+		},
+		containerRemoveFunc: func(container string, options types.ContainerRemoveOptions) error {
+			if container != "" {
+				*calls = append(*calls, "remove "+container)
+			}
+			return nil
+		},
+	}
+}
+
+// migrationDestination returns a fake destination daemon of a migration,
+// where copyErr is the error of the copy of the checkpoint
+func migrationDestination(calls *[]string, copyErr error) *fakeClient {
+	return &fakeClient{
+		createContainerFunc: func(config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, name string) (container.ContainerCreateCreatedBody, error) {
+			if name == "" {
+				return container.ContainerCreateCreatedBody{ID: "helper"}, nil
+			}
+			*calls = append(*calls, "create "+name)
+			return container.ContainerCreateCreatedBody{ID: "def"}, nil
+		},
+		infoFunc: func() (types.Info, error) {
+			return types.Info{DockerRootDir: "/var/lib/docker"}, nil
+		},
+		copyToContainerFunc: func(container, dstPath string, content io.Reader) error {
+			ioutil.ReadAll(content)
+			return copyErr
+		},
+		containerStartFunc: func(container string, options types.ContainerStartOptions) error {
+			*calls = append(*calls, "restore "+container+" "+options.CheckpointID)
+			return nil
+		},
+		containerRemoveFunc: func(container string, options types.ContainerRemoveOptions) error {
+			if container != "helper" {
+				*calls = append(*calls, "remove destination "+container)
+			}
+			return nil
+		},
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	var sourceCalls, destinationCalls []string
+	cli := test.NewFakeCli(migrationSource(t, &sourceCalls))
+	opts := migrateOptions{container: "web", to: "tcp://destination:2376", checkpoint: "ckpt"}
+
+	assert.NilError(t, migrate(context.Background(), cli, migrationDestination(&destinationCalls, nil), opts))
+	assert.Check(t, is.DeepEqual([]string{"checkpoint web ckpt", "remove web"}, sourceCalls))
+	assert.Check(t, is.DeepEqual([]string{"create web", "restore def ckpt"}, destinationCalls))
+	assert.Check(t, is.Equal("def\n", cli.OutBuffer().String()))
+}
+
+func TestMigrateTransferFailureRestoresSource(t *testing.T) {
+	var sourceCalls, destinationCalls []string
+	cli := test.NewFakeCli(migrationSource(t, &sourceCalls))
+	opts := migrateOptions{container: "web", to: "tcp://destination:2376", checkpoint: "ckpt"}
+
+	err := migrate(context.Background(), cli, migrationDestination(&destinationCalls, errors.New("no space left on device")), opts)
+	assert.Check(t, is.ErrorContains(err, "failed to transfer checkpoint to tcp://destination:2376"))
+	assert.Check(t, is.DeepEqual([]string{"checkpoint web ckpt", "start web ckpt"}, sourceCalls))
+	assert.Check(t, is.DeepEqual([]string{"create web", "remove destination def"}, destinationCalls))
+	assert.Check(t, is.Contains(cli.ErrBuffer().String(), "Source container web restored from checkpoint ckpt"))
+}
+
+func TestMigrateDestinationStartFailureRestoresSource(t *testing.T) {
+	var sourceCalls, destinationCalls []string
+	cli := test.NewFakeCli(migrationSource(t, &sourceCalls))
+	destination := migrationDestination(&destinationCalls, nil)
+	destination.containerStartFunc = func(container string, options types.ContainerStartOptions) error {
+		destinationCalls = append(destinationCalls, "restore "+container+" "+options.CheckpointID)
+		return errors.New("criu failed")
+	}
+	opts := migrateOptions{container: "web", to: "tcp://destination:2376", checkpoint: "ckpt"}
+
+	err := migrate(context.Background(), cli, destination, opts)
+	assert.Check(t, is.Error(err, "failed to restore container def on tcp://destination:2376: criu failed"))
+	assert.Check(t, is.DeepEqual([]string{"checkpoint web ckpt", "start web ckpt"}, sourceCalls))
+	assert.Check(t, is.DeepEqual([]string{"create web", "restore def ckpt", "remove destination def"}, destinationCalls))
+	assert.Check(t, is.Contains(cli.ErrBuffer().String(), "Source container web restored from checkpoint ckpt"))
+}
+
+func TestMigrateSourceLeftStopped(t *testing.T) {
+	var sourceCalls, destinationCalls []string
+	source := migrationSource(t, &sourceCalls)
+	source.containerStartFunc = func(string, types.ContainerStartOptions) error {
+		return errors.New("no such checkpoint")
+	}
+	cli := test.NewFakeCli(source)
+	opts := migrateOptions{container: "web", to: "tcp://destination:2376", checkpoint: "ckpt"}
+
+	err := migrate(context.Background(), cli, migrationDestination(&destinationCalls, errors.New("no space left on device")), opts)
+	assert.Check(t, is.ErrorContains(err, "the source container is left stopped, as restoring it from checkpoint ckpt failed (no such checkpoint)"))
+}
+
+func TestMigrateDestinationOptions(t *testing.T) {
+	dir := fs.NewDir(t, "migrate-certs", fs.WithFile("ca.pem", ""))
+	defer dir.Remove()
+	tlsOptions := tlsconfig.Options{CAFile: dir.Join("ca.pem"), CertFile: dir.Join("cert.pem"), KeyFile: dir.Join("key.pem")}
+
+	common := destinationOptions(migrateOptions{to: "tcp://destination:2375", toTLSOptions: tlsOptions})
+	assert.Check(t, is.DeepEqual([]string{"tcp://destination:2375"}, common.Hosts))
+	assert.Check(t, is.Nil(common.TLSOptions))
+
+	common = destinationOptions(migrateOptions{to: "tcp://destination:2376", toTLSVerify: true, toTLSOptions: tlsOptions})
+	assert.Check(t, common.TLS)
+	assert.Assert(t, common.TLSOptions != nil)
+	// The missing client certificate is not used
+	assert.Check(t, is.DeepEqual(tlsconfig.Options{CAFile: dir.Join("ca.pem")}, *common.TLSOptions))
+
+	common = destinationOptions(migrateOptions{to: "tcp://destination:2376", toTLS: true, toTLSOptions: tlsOptions})
+	assert.Assert(t, common.TLSOptions != nil)
+	assert.Check(t, common.TLSOptions.InsecureSkipVerify)
+}
@@ -126,6 +126,7 @@ func runContainer(dockerCli command.Cli, opts *runOptions, copts *containerOptio
 
 	warnOnOomKillDisable(*hostConfig, stderr)
 	warnOnLocalhostDNS(*hostConfig, stderr)
+	warnOnRemoteBinds(dockerCli, hostConfig, stderr)
 
 	config.ArgsEscaped = false
 
@@ -159,6 +160,12 @@ func runContainer(dockerCli command.Cli, opts *runOptions, copts *containerOptio
 	ctx, cancelFun := context.WithCancel(context.Background())
 	defer cancelFun()
 
+	syncMounts, err := prepareSyncMounts(ctx, dockerCli, hostConfig)
+	if err != nil {
+		reportError(stderr, "run", err.Error(), true)
+		return runStartContainerErr(err)
+	}
+
 	createResponse, err := createContainer(ctx, dockerCli, containerConfig, &opts.createOptions)
 	if err != nil {
 		reportError(stderr, "run", err.Error(), true)
@@ -215,6 +222,16 @@ func runContainer(dockerCli command.Cli, opts *runOptions, copts *containerOptio
 		return runStartContainerErr(err)
 	}
 
+	if opts.detach {
+		for _, sm := range syncMounts {
+			if sm.watch {
+				fmt.Fprintf(stderr, "WARNING: sync-watch is ignored for %s in detached mode.\n", sm.source)
+			}
+		}
+	} else {
+		watchSyncMounts(ctx, dockerCli, createResponse.ID, syncMounts)
+	}
+
 	if (config.AttachStdin || config.AttachStdout || config.AttachStderr) && config.Tty && dockerCli.Out().IsTerminal() {
 		if err := MonitorTtySize(ctx, dockerCli, createResponse.ID, false); err != nil {
 			fmt.Fprintln(stderr, "Error monitoring TTY size:", err)
@@ -0,0 +1,363 @@
+package container
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/yuyangjack/dockercli/cli/command"
+	"github.com/yuyangjack/dockercli/cli/compose/loader"
+	"github.com/yuyangjack/dockercli/opts"
+	"github.com/yuyangjack/moby/api/types"
+	"github.com/yuyangjack/moby/api/types/container"
+	"github.com/yuyangjack/moby/api/types/filters"
+	"github.com/yuyangjack/moby/api/types/mount"
+	volumetypes "github.com/yuyangjack/moby/api/types/volume"
+	apiclient "github.com/yuyangjack/moby/client"
+	"github.com/yuyangjack/moby/pkg/archive"
+	"github.com/yuyangjack/moby/pkg/stringid"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// syncHelperImage is the image of the helper containers that empty sync
+	// volumes and remove files from them, and through which they are populated.
+	syncHelperImage = "busybox:latest"
+	// syncHelperTarget is where the volume is mounted in the helper container.
+	syncHelperTarget = "/sync"
+	// syncWatchInterval is how often a watched directory is scanned for changes.
+	syncWatchInterval = time.Second
+)
+
+// syncMount is a `--mount type=sync` that has been converted to a volume mount.
+type syncMount struct {
+	source string
+	target string
+	volume string
+	watch  bool
+}
+
+// isRemoteDaemon returns true if the daemon at host cannot see the local
+// filesystem, i.e. it is reached through ssh or a non-loopback tcp address.
+func isRemoteDaemon(host string) bool {
+	u, err := url.Parse(host)
+	if err != nil {
+		return false
+	}
+	switch u.Scheme {
+	case "unix", "npipe", "fd":
+		return false
+	case "ssh":
+		return true
+	}
+	hostname := u.Hostname()
+	if hostname == "" || hostname == "localhost" {
+		return false
+	}
+	if ip := net.ParseIP(hostname); ip != nil && ip.IsLoopback() {
+		return false
+	}
+	return true
+}
+
+// warnOnRemoteBinds prints a warning for every bind mount when the daemon is
+// remote, because the source then refers to a path on the daemon host.
+func warnOnRemoteBinds(dockerCli command.Cli, hostConfig *container.HostConfig, stderr io.Writer) {
+	host := dockerCli.Client().DaemonHost()
+	if !isRemoteDaemon(host) {
+		return
+	}
+	var sources []string
+	for _, bind := range hostConfig.Binds {
+		if parsed, err := parseBindSource(bind); err == nil && parsed != "" {
+			sources = append(sources, parsed)
+		}
+	}
+	for _, m := range hostConfig.Mounts {
+		if m.Type == mount.TypeBind {
+			sources = append(sources, m.Source)
+		}
+	}
+	for _, source := range sources {
+		fmt.Fprintf(stderr, "WARNING: the Docker daemon at %s is remote; bind mount source %q refers to a path on the daemon host. "+
+			"Use '--mount type=sync,source=<local dir>,target=<path>' to copy a local directory instead.\n", host, source)
+	}
+}
+
+// parseBindSource returns the host path of a `-v` bind specification, or an
+// empty string if the specification is a volume.
+func parseBindSource(bind string) (string, error) {
+	parsed, err := loader.ParseVolume(bind)
+	if err != nil {
+		return "", err
+	}
+	if parsed.Type != string(mount.TypeBind) {
+		return "", nil
+	}
+	return parsed.Source, nil
+}
+
+// syncVolumeName returns a stable volume name for a source and target, so
+// that running the same command again reuses the volume.
+func syncVolumeName(source, target string) string {
+	sum := sha256.Sum256([]byte(source + ":" + target))
+	return "sync-" + hex.EncodeToString(sum[:])[:12]
+}
+
+// prepareSyncMounts replaces every sync mount in hostConfig with a volume
+// mount, creating the volume and copying the local directory into it.
+func prepareSyncMounts(ctx context.Context, dockerCli command.Cli, hostConfig *container.HostConfig) ([]syncMount, error) {
+	var synced []syncMount
+	for i, m := range hostConfig.Mounts {
+		if m.Type != opts.MountTypeSync {
+			continue
+		}
+		fi, err := os.Stat(m.Source)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid sync mount source")
+		}
+		if !fi.IsDir() {
+			return nil, errors.Errorf("sync mount source %s is not a directory", m.Source)
+		}
+
+		sm := syncMount{
+			source: m.Source,
+			target: m.Target,
+			volume: syncVolumeName(m.Source, m.Target),
+		}
+		volReq := volumetypes.VolumeCreateBody{Name: sm.volume}
+		if m.VolumeOptions != nil {
+			sm.watch = m.VolumeOptions.Labels[opts.SyncWatchLabel] == "true"
+			volReq.Labels = m.VolumeOptions.Labels
+			if m.VolumeOptions.DriverConfig != nil {
+				volReq.Driver = m.VolumeOptions.DriverConfig.Name
+				volReq.DriverOpts = m.VolumeOptions.DriverConfig.Options
+			}
+		}
+		// The volume of a previous run is reused, but it is emptied first,
+		// which must not happen under a container that uses it.
+		if err := checkSyncVolumeUnused(ctx, dockerCli, sm.volume); err != nil {
+			return nil, err
+		}
+		if _, err := dockerCli.Client().VolumeCreate(ctx, volReq); err != nil {
+			return nil, err
+		}
+		if err := populateSyncVolume(ctx, dockerCli, sm); err != nil {
+			return nil, err
+		}
+
+		hostConfig.Mounts[i] = mount.Mount{
+			Type:        mount.TypeVolume,
+			Source:      sm.volume,
+			Target:      m.Target,
+			ReadOnly:    m.ReadOnly,
+			Consistency: m.Consistency,
+		}
+		synced = append(synced, sm)
+	}
+	return synced, nil
+}
+
+// checkSyncVolumeUnused returns an error if a container uses the sync
+// volume.
+func checkSyncVolumeUnused(ctx context.Context, dockerCli command.Cli, volume string) error {
+	containers, err := dockerCli.Client().ContainerList(ctx, types.ContainerListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("volume", volume)),
+	})
+	if err != nil {
+		return err
+	}
+	if len(containers) > 0 {
+		return errors.Errorf("sync volume %s is in use by container %s: remove the container first", volume, stringid.TruncateID(containers[0].ID))
+	}
+	return nil
+}
+
+// populateSyncVolume empties the volume and copies the contents of the local
+// directory into it.
+func populateSyncVolume(ctx context.Context, dockerCli command.Cli, sm syncMount) error {
+	clearCmd := []string{"find", syncHelperTarget, "-mindepth", "1", "-delete"}
+	return withSyncHelper(ctx, dockerCli, sm.volume, clearCmd, func(helperID string) error {
+		return copySyncFiles(ctx, dockerCli, helperID, sm.source, syncHelperTarget, nil)
+	})
+}
+
+// withSyncHelper creates a helper container with the volume mounted at
+// syncHelperTarget, runs cmd in it if it is set, calls fn with its ID if it
+// is set, and removes it again.
+func withSyncHelper(ctx context.Context, dockerCli command.Cli, volume string, cmd []string, fn func(helperID string) error) error {
+	client := dockerCli.Client()
+	config := &container.Config{Image: syncHelperImage, Cmd: cmd}
+	hostConfig := &container.HostConfig{
+		Mounts: []mount.Mount{{Type: mount.TypeVolume, Source: volume, Target: syncHelperTarget}},
+	}
+
+	helper, err := client.ContainerCreate(ctx, config, hostConfig, nil, "")
+	if err != nil {
+		if !apiclient.IsErrNotFound(err) {
+			return err
+		}
+		if err := pullImage(ctx, dockerCli, syncHelperImage, "", dockerCli.Err()); err != nil {
+			return err
+		}
+		if helper, err = client.ContainerCreate(ctx, config, hostConfig, nil, ""); err != nil {
+			return err
+		}
+	}
+	defer func() {
+		if err := client.ContainerRemove(ctx, helper.ID, types.ContainerRemoveOptions{Force: true}); err != nil {
+			logrus.Debugf("Error removing sync helper container %s: %s", helper.ID, err)
+		}
+	}()
+
+	if len(cmd) > 0 {
+		if err := runSyncHelper(ctx, dockerCli, helper.ID); err != nil {
+			return errors.Wrapf(err, "failed to run %s in sync volume %s", cmd[0], volume)
+		}
+	}
+	if fn == nil {
+		return nil
+	}
+	return fn(helper.ID)
+}
+
+// runSyncHelper starts a helper container and waits for its command to
+// exit successfully.
+func runSyncHelper(ctx context.Context, dockerCli command.Cli, helperID string) error {
+	client := dockerCli.Client()
+	resultC, errC := client.ContainerWait(ctx, helperID, container.WaitConditionNextExit)
+	if err := client.ContainerStart(ctx, helperID, types.ContainerStartOptions{}); err != nil {
+		return err
+	}
+	select {
+	case result := <-resultC:
+		if result.Error != nil {
+			return errors.New(result.Error.Message)
+		}
+		if result.StatusCode != 0 {
+			return errors.Errorf("exit status %d", result.StatusCode)
+		}
+		return nil
+	case err := <-errC:
+		return err
+	}
+}
+
+// copySyncFiles streams files from the local source directory to dest in
+// the container. If files is non-empty only those relative paths are sent.
+func copySyncFiles(ctx context.Context, dockerCli command.Cli, containerID, source, dest string, files []string) error {
+	content, err := archive.TarWithOptions(source, &archive.TarOptions{IncludeFiles: files})
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	return dockerCli.Client().CopyToContainer(ctx, containerID, dest, content, types.CopyToContainerOptions{})
+}
+
+// removeSyncFiles deletes the given relative paths from the sync volume.
+func removeSyncFiles(ctx context.Context, dockerCli command.Cli, volume string, files []string) error {
+	cmd := []string{"rm", "-rf", "--"}
+	for _, f := range files {
+		cmd = append(cmd, path.Join(syncHelperTarget, filepath.ToSlash(f)))
+	}
+	return withSyncHelper(ctx, dockerCli, volume, cmd, nil)
+}
+
+// snapshotDir returns the modification time of every file below root, keyed
+// by path relative to root.
+func snapshotDir(root string) (map[string]time.Time, error) {
+	snapshot := make(map[string]time.Time)
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == root || info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		snapshot[rel] = info.ModTime()
+		return nil
+	})
+	return snapshot, err
+}
+
+// diffSnapshots returns the files that were added or modified, and the files
+// that were removed, between two snapshots.
+func diffSnapshots(before, after map[string]time.Time) (changed, removed []string) {
+	for p, mtime := range after {
+		if prev, ok := before[p]; !ok || !prev.Equal(mtime) {
+			changed = append(changed, p)
+		}
+	}
+	for p := range before {
+		if _, ok := after[p]; !ok {
+			removed = append(removed, p)
+		}
+	}
+	sort.Strings(changed)
+	sort.Strings(removed)
+	return changed, removed
+}
+
+// watchSyncMounts keeps the watched sync mounts of a running container up to
+// date with their local directories until ctx is cancelled.
+func watchSyncMounts(ctx context.Context, dockerCli command.Cli, containerID string, mounts []syncMount) {
+	for _, sm := range mounts {
+		if !sm.watch {
+			continue
+		}
+		go watchSyncMount(ctx, dockerCli, containerID, sm)
+	}
+}
+
+func watchSyncMount(ctx context.Context, dockerCli command.Cli, containerID string, sm syncMount) {
+	last, err := snapshotDir(sm.source)
+	if err != nil {
+		fmt.Fprintf(dockerCli.Err(), "Error watching %s: %s\n", sm.source, err)
+		return
+	}
+	ticker := time.NewTicker(syncWatchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		current, err := snapshotDir(sm.source)
+		if err != nil {
+			fmt.Fprintf(dockerCli.Err(), "WARNING: failed to scan %s: %s\n", sm.source, err)
+			continue
+		}
+		changed, removed := diffSnapshots(last, current)
+		if len(changed) > 0 {
+			if err := copySyncFiles(ctx, dockerCli, containerID, sm.source, sm.target, changed); err != nil {
+				fmt.Fprintf(dockerCli.Err(), "WARNING: failed to sync %s to %s: %s\n", sm.source, sm.target, err)
+				continue
+			}
+		}
+		if len(removed) > 0 {
+			if err := removeSyncFiles(ctx, dockerCli, sm.volume, removed); err != nil {
+				fmt.Fprintf(dockerCli.Err(), "WARNING: failed to remove files from %s: %s\n", sm.target, err)
+				continue
+			}
+		}
+		last = current
+	}
+}
@@ -0,0 +1,188 @@
+package container
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yuyangjack/dockercli/internal/test"
+	"github.com/yuyangjack/dockercli/opts"
+	"github.com/yuyangjack/moby/api/types"
+	"github.com/yuyangjack/moby/api/types/container"
+	"github.com/yuyangjack/moby/api/types/mount"
+	"github.com/yuyangjack/moby/api/types/network"
+	volumetypes "github.com/yuyangjack/moby/api/types/volume"
+	"gotest.tools/fs"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestIsRemoteDaemon(t *testing.T) {
+	testCases := []struct {
+		host     string
+		expected bool
+	}{
+		{host: "unix:///var/run/docker.sock", expected: false},
+		{host: "npipe:////./pipe/docker_engine", expected: false},
+		{host: "tcp://localhost:2375", expected: false},
+		{host: "tcp://127.0.0.1:2375", expected: false},
+		{host: "tcp://[::1]:2375", expected: false},
+		{host: "tcp://10.0.0.5:2376", expected: true},
+		{host: "ssh://user@remote", expected: true},
+		{host: "", expected: false},
+	}
+	for _, tc := range testCases {
+		assert.Check(t, is.Equal(tc.expected, isRemoteDaemon(tc.host)), tc.host)
+	}
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	t0 := time.Unix(0, 0)
+	t1 := time.Unix(1, 0)
+	before := map[string]time.Time{"a": t0, "b": t0, "c": t0}
+	after := map[string]time.Time{"a": t0, "b": t1, "d": t1}
+
+	changed, removed := diffSnapshots(before, after)
+	assert.Check(t, is.DeepEqual([]string{"b", "d"}, changed))
+	assert.Check(t, is.DeepEqual([]string{"c"}, removed))
+}
+
+func TestSyncVolumeNameIsStable(t *testing.T) {
+	name := syncVolumeName("/home/user/src", "/app")
+	assert.Check(t, is.Equal(name, syncVolumeName("/home/user/src", "/app")))
+	assert.Check(t, name != syncVolumeName("/home/user/src", "/other"))
+	assert.Check(t, is.Len(name, len("sync-")+12))
+}
+
+func TestWarnOnRemoteBindsSkipsVolumes(t *testing.T) {
+	cli := test.NewFakeCli(&fakeClient{daemonHost: "tcp://10.0.0.5:2376"})
+	hostConfig := &container.HostConfig{
+		Binds: []string{"data:/data", "/home/user/src:/app"},
+		Mounts: []mount.Mount{
+			{Type: mount.TypeVolume, Source: "cache", Target: "/cache"},
+			{Type: mount.TypeBind, Source: "/etc/app", Target: "/etc/app"},
+		},
+	}
+
+	warnOnRemoteBinds(cli, hostConfig, cli.ErrBuffer())
+	warnings := strings.Split(strings.TrimSpace(cli.ErrBuffer().String()), "\n")
+	assert.Assert(t, is.Len(warnings, 2))
+	assert.Check(t, is.Contains(warnings[0], `bind mount source "/home/user/src"`))
+	assert.Check(t, is.Contains(warnings[1], `bind mount source "/etc/app"`))
+}
+
+func TestWarnOnRemoteBindsLocalDaemon(t *testing.T) {
+	cli := test.NewFakeCli(&fakeClient{daemonHost: "unix:///var/run/docker.sock"})
+	hostConfig := &container.HostConfig{Binds: []string{"/home/user/src:/app"}}
+
+	warnOnRemoteBinds(cli, hostConfig, cli.ErrBuffer())
+	assert.Check(t, is.Equal("", cli.ErrBuffer().String()))
+}
+
+// syncHelperClient returns a fake client whose helper containers exit with
+// status, which records the calls of the sync helpers in calls
+func syncHelperClient(t *testing.T, calls *[]string, status int64) *fakeClient {
+	return &fakeClient{
+		volumeCreateFunc: func(options volumetypes.VolumeCreateBody) (types.Volume, error) {
+			*calls = append(*calls, "create volume "+options.Name)
+			return types.Volume{Name: options.Name}, nil
+		},
+		createContainerFunc: func(config *container.Config, hostConfig *container.HostConfig, _ *network.NetworkingConfig, _ string) (container.ContainerCreateCreatedBody, error) {
+			assert.Check(t, is.Equal(syncHelperImage, config.Image))
+			assert.Check(t, is.Len(hostConfig.Mounts, 1))
+			*calls = append(*calls, "create helper "+strings.Join(config.Cmd, " "))
+			return container.ContainerCreateCreatedBody{ID: "helper"}, nil
+		},
+		waitFunc: func(string) (<-chan container.ContainerWaitOKBody, <-chan error) {
+			resultC := make(chan container.ContainerWaitOKBody, 1)
+			resultC <- container.ContainerWaitOKBody{StatusCode: status}
+			return resultC, make(chan error)
+		},
+		containerStartFunc: func(container string, _ types.ContainerStartOptions) error {
+			*calls = append(*calls, "start "+container)
+			return nil
+		},
+		copyToContainerFunc: func(container, dstPath string, content io.Reader) error {
+			_, err := ioutil.ReadAll(content)
+			assert.Check(t, err)
+			*calls = append(*calls, "copy "+container+" "+dstPath)
+			return nil
+		},
+		containerRemoveFunc: func(container string, _ types.ContainerRemoveOptions) error {
+			*calls = append(*calls, "remove "+container)
+			return nil
+		},
+	}
+}
+
+func TestPrepareSyncMounts(t *testing.T) {
+	dir := fs.NewDir(t, "sync-source", fs.WithFile("index.js", "console.log(1)"))
+	defer dir.Remove()
+	var calls []string
+	client := syncHelperClient(t, &calls, 0)
+	client.containerListFunc = func(options types.ContainerListOptions) ([]types.Container, error) {
+		assert.Check(t, options.All)
+		assert.Check(t, is.DeepEqual([]string{syncVolumeName(dir.Path(), "/app")}, options.Filters.Get("volume")))
+		return nil, nil
+	}
+	cli := test.NewFakeCli(client)
+	hostConfig := &container.HostConfig{Mounts: []mount.Mount{
+		{Type: mount.TypeBind, Source: "/etc/app", Target: "/etc/app"},
+		{Type: opts.MountTypeSync, Source: dir.Path(), Target: "/app", ReadOnly: true},
+	}}
+
+	synced, err := prepareSyncMounts(context.Background(), cli, hostConfig)
+	assert.NilError(t, err)
+	volume := syncVolumeName(dir.Path(), "/app")
+	assert.Assert(t, is.Len(synced, 1))
+	assert.Check(t, is.Equal(syncMount{source: dir.Path(), target: "/app", volume: volume}, synced[0]))
+	assert.Check(t, is.DeepEqual(mount.Mount{Type: mount.TypeVolume, Source: volume, Target: "/app", ReadOnly: true}, hostConfig.Mounts[1]))
+	// The volume is emptied, then populated through the same helper
+	assert.Check(t, is.DeepEqual([]string{
+		"create volume " + volume,
+		"create helper find /sync -mindepth 1 -delete",
+		"start helper",
+		"copy helper /sync",
+		"remove helper",
+	}, calls))
+}
+
+func TestPrepareSyncMountsVolumeInUse(t *testing.T) {
+	dir := fs.NewDir(t, "sync-source")
+	defer dir.Remove()
+	var calls []string
+	client := syncHelperClient(t, &calls, 0)
+	client.containerListFunc = func(types.ContainerListOptions) ([]types.Container, error) {
+		return []types.Container{{ID: "0123456789abcdef"}}, nil
+	}
+	cli := test.NewFakeCli(client)
+	hostConfig := &container.HostConfig{Mounts: []mount.Mount{{Type: opts.MountTypeSync, Source: dir.Path(), Target: "/app"}}}
+
+	_, err := prepareSyncMounts(context.Background(), cli, hostConfig)
+	assert.Check(t, is.Error(err, "sync volume "+syncVolumeName(dir.Path(), "/app")+" is in use by container 0123456789ab: remove the container first"))
+	assert.Check(t, is.Len(calls, 0))
+}
+
+func TestPopulateSyncVolumeHelperFailure(t *testing.T) {
+	dir := fs.NewDir(t, "sync-source")
+	defer dir.Remove()
+	var calls []string
+	cli := test.NewFakeCli(syncHelperClient(t, &calls, 1))
+
+	err := populateSyncVolume(context.Background(), cli, syncMount{source: dir.Path(), target: "/app", volume: "sync-vol"})
+	assert.Check(t, is.Error(err, "failed to run find in sync volume sync-vol: exit status 1"))
+	// Nothing is copied, and the helper is removed
+	assert.Check(t, is.DeepEqual([]string{"create helper find /sync -mindepth 1 -delete", "start helper", "remove helper"}, calls))
+}
+
+func TestRemoveSyncFiles(t *testing.T) {
+	var calls []string
+	cli := test.NewFakeCli(syncHelperClient(t, &calls, 0))
+
+	assert.NilError(t, removeSyncFiles(context.Background(), cli, "sync-vol", []string{"a.js", filepath.Join("lib", "b.js")}))
+	assert.Check(t, is.DeepEqual([]string{"create helper rm -rf -- /sync/a.js /sync/lib/b.js", "start helper", "remove helper"}, calls))
+}
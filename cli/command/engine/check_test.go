@@ -3,6 +3,7 @@ package engine
 import (
 	"context"
 	"fmt"
+	"io"
 	"testing"
 
 	manifesttypes "github.com/yuyangjack/dockercli/cli/manifest/types"
@@ -56,6 +57,27 @@ func (c testRegistryClient) PutManifest(ctx context.Context, ref reference.Named
 func (c testRegistryClient) GetTags(ctx context.Context, ref reference.Named) ([]string, error) {
 	return c.tags, nil
 }
+func (c testRegistryClient) GetDistributionManifest(ctx context.Context, ref reference.Named) (distribution.Manifest, error) {
+	return nil, nil
+}
+func (c testRegistryClient) CopyBlob(ctx context.Context, source reference.Named, target reference.Named, desc distribution.Descriptor) error {
+	return nil
+}
+func (c testRegistryClient) GetImageConfig(ctx context.Context, ref reference.Named) (manifesttypes.ImageManifest, []byte, error) {
+	return manifesttypes.ImageManifest{}, nil, nil
+}
+func (c testRegistryClient) GetCatalog(ctx context.Context, domain string) ([]string, error) {
+	return nil, nil
+}
+func (c testRegistryClient) DeleteManifest(ctx context.Context, ref reference.Canonical) error {
+	return nil
+}
+func (c testRegistryClient) GetBlob(ctx context.Context, ref reference.Named, dgst digest.Digest) (io.ReadCloser, error) {
+	return nil, nil
+}
+func (c testRegistryClient) PutBlob(ctx context.Context, ref reference.Named, mediaType string, content []byte) (distribution.Descriptor, error) {
+	return distribution.Descriptor{}, nil
+}
 
 func TestCheckForUpdatesNoCurrentVersion(t *testing.T) {
 	isRoot = func() bool { return true }
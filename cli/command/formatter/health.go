@@ -0,0 +1,85 @@
+package formatter
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yuyangjack/moby/api/types"
+)
+
+const (
+	defaultHealthTableFormat = "table {{.Start}}\t{{.Duration}}\t{{.ExitCode}}\t{{.Output}}"
+
+	endHeader      = "END"
+	durationHeader = "DURATION"
+	exitCodeHeader = "EXIT CODE"
+	outputHeader   = "OUTPUT"
+)
+
+// NewHealthFormat returns a format for rendering health check results
+func NewHealthFormat(source string) Format {
+	switch source {
+	case TableFormatKey:
+		return defaultHealthTableFormat
+	}
+	return Format(source)
+}
+
+// HealthWrite writes formatted health check results using the Context
+func HealthWrite(ctx Context, results []*types.HealthcheckResult) error {
+	render := func(format func(subContext subContext) error) error {
+		for _, result := range results {
+			if err := format(&healthContext{trunc: ctx.Trunc, r: result}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	healthCtx := &healthContext{}
+	healthCtx.header = map[string]string{
+		"Start":    startHeader,
+		"End":      endHeader,
+		"Duration": durationHeader,
+		"ExitCode": exitCodeHeader,
+		"Output":   outputHeader,
+	}
+	return ctx.Write(healthCtx, render)
+}
+
+type healthContext struct {
+	HeaderContext
+	trunc bool
+	r     *types.HealthcheckResult
+}
+
+func (c *healthContext) MarshalJSON() ([]byte, error) {
+	return marshalJSON(c)
+}
+
+func (c *healthContext) Start() string {
+	return c.r.Start.Format(time.RFC3339)
+}
+
+func (c *healthContext) End() string {
+	return c.r.End.Format(time.RFC3339)
+}
+
+func (c *healthContext) Duration() string {
+	if c.r.End.IsZero() {
+		return ""
+	}
+	return c.r.End.Sub(c.r.Start).Round(time.Millisecond).String()
+}
+
+func (c *healthContext) ExitCode() string {
+	return strconv.Itoa(c.r.ExitCode)
+}
+
+func (c *healthContext) Output() string {
+	output := strings.TrimSpace(c.r.Output)
+	if c.trunc {
+		return Ellipsis(strings.Replace(output, "\n", " ", -1), 60)
+	}
+	return output
+}
@@ -0,0 +1,46 @@
+package formatter
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/yuyangjack/moby/api/types"
+	"gotest.tools/assert"
+)
+
+func TestHealthContextFormatWrite(t *testing.T) {
+	start := time.Date(2019, time.May, 1, 10, 0, 0, 0, time.UTC)
+	results := []*types.HealthcheckResult{
+		{Start: start, End: start.Add(120 * time.Millisecond), ExitCode: 0, Output: "ok\n"},
+		{Start: start.Add(30 * time.Second), End: start.Add(35 * time.Second), ExitCode: 1, Output: "connection\nrefused"},
+	}
+
+	cases := []struct {
+		context  Context
+		expected string
+	}{
+		{
+			Context{Format: NewHealthFormat(TableFormatKey), Trunc: true},
+			`START                  DURATION            EXIT CODE           OUTPUT
+2019-05-01T10:00:00Z   120ms               0                   ok
+2019-05-01T10:00:30Z   5s                  1                   connection refused
+`,
+		},
+		{
+			Context{Format: NewHealthFormat("{{.ExitCode}}: {{.Output}}")},
+			`0: ok
+1: connection
+refused
+`,
+		},
+	}
+
+	for _, testcase := range cases {
+		out := bytes.NewBufferString("")
+		testcase.context.Output = out
+		err := HealthWrite(testcase.context, results)
+		assert.NilError(t, err)
+		assert.Equal(t, out.String(), testcase.expected)
+	}
+}
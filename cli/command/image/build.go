@@ -18,6 +18,7 @@ import (
 	"github.com/yuyangjack/dockercli/cli"
 	"github.com/yuyangjack/dockercli/cli/command"
 	"github.com/yuyangjack/dockercli/cli/command/image/build"
+	cliconfig "github.com/yuyangjack/dockercli/cli/config"
 	"github.com/yuyangjack/dockercli/opts"
 	"github.com/yuyangjack/distribution/reference"
 	"github.com/yuyangjack/moby/api"
@@ -72,7 +73,13 @@ type buildOptions struct {
 	platform       string
 	untrusted      bool
 	secrets        []string
+	secretsFrom    []string
 	ssh            []string
+	outputs        []string
+	cacheTo        []string
+	printContext   bool
+	git            build.GitOptions
+	gitCache       bool
 }
 
 // dockerfileFromStdin returns true when the user specified that the Dockerfile
@@ -95,9 +102,78 @@ func newBuildOptions() buildOptions {
 		ulimits:    opts.NewUlimitOpt(&ulimits),
 		labels:     opts.NewListOpts(opts.ValidateEnv),
 		extraHosts: opts.NewListOpts(opts.ValidateExtraHost),
+		git:        build.DefaultGitOptions(),
 	}
 }
 
+// gitOptions returns the options of the clone of a git context
+func (o buildOptions) gitOptions() build.GitOptions {
+	gitOpts := o.git
+	if o.gitCache {
+		gitOpts.CacheDir = filepath.Join(cliconfig.Dir(), "git-contexts")
+	}
+	return gitOpts
+}
+
+// gitOptionsSet returns true when the clone of a git context is customized
+// by options that only the client supports, including its cache
+func (o buildOptions) gitOptionsSet() bool {
+	return o.git != build.DefaultGitOptions() || o.gitCache
+}
+
+// BuildImageOptions are the options of an image built by another command,
+// such as `docker stack build`
+type BuildImageOptions struct {
+	Context    string
+	Dockerfile string
+	Tags       []string
+	BuildArgs  []string
+	Labels     []string
+	CacheFrom  []string
+	Network    string
+	Target     string
+	Pull       bool
+	NoCache    bool
+	Quiet      bool
+	Untrusted  bool
+}
+
+// BuildImage builds an image the same way as `docker build`, using BuildKit
+// if it is enabled
+func BuildImage(dockerCli command.Cli, buildOpts BuildImageOptions) error {
+	options := newBuildOptions()
+	options.context = buildOpts.Context
+	options.dockerfileName = buildOpts.Dockerfile
+	for _, tag := range buildOpts.Tags {
+		if err := options.tags.Set(tag); err != nil {
+			return err
+		}
+	}
+	for _, arg := range buildOpts.BuildArgs {
+		if err := options.buildArgs.Set(arg); err != nil {
+			return err
+		}
+	}
+	for _, label := range buildOpts.Labels {
+		if err := options.labels.Set(label); err != nil {
+			return err
+		}
+	}
+	options.cacheFrom = buildOpts.CacheFrom
+	options.networkMode = buildOpts.Network
+	if options.networkMode == "" {
+		options.networkMode = "default"
+	}
+	options.target = buildOpts.Target
+	options.pull = buildOpts.Pull
+	options.noCache = buildOpts.NoCache
+	options.quiet = buildOpts.Quiet
+	options.untrusted = buildOpts.Untrusted
+	options.rm = true
+	options.progress = "auto"
+	return runBuild(dockerCli, options)
+}
+
 // NewBuildCommand creates a new `docker build` command
 func NewBuildCommand(dockerCli command.Cli) *cobra.Command {
 	options := newBuildOptions()
@@ -134,7 +210,10 @@ func NewBuildCommand(dockerCli command.Cli) *cobra.Command {
 	flags.BoolVar(&options.forceRm, "force-rm", false, "Always remove intermediate containers")
 	flags.BoolVarP(&options.quiet, "quiet", "q", false, "Suppress the build output and print image ID on success")
 	flags.BoolVar(&options.pull, "pull", false, "Always attempt to pull a newer version of the image")
-	flags.StringSliceVar(&options.cacheFrom, "cache-from", []string{}, "Images to consider as cache sources")
+	flags.StringSliceVar(&options.cacheFrom, "cache-from", []string{}, "Images or caches to consider as cache sources (e.g. user/app:cache, type=local,src=path/to/dir)")
+	flags.StringArrayVar(&options.cacheTo, "cache-to", []string{}, "Cache export destinations (only if BuildKit enabled) (format: type=inline|type=registry,ref=image|type=local,dest=path)")
+	flags.SetAnnotation("cache-to", "version", []string{"1.39"})
+	flags.SetAnnotation("cache-to", "buildkit", nil)
 	flags.BoolVar(&options.compress, "compress", false, "Compress the build context using gzip")
 	flags.SetAnnotation("compress", "no-buildkit", nil)
 
@@ -160,13 +239,31 @@ func NewBuildCommand(dockerCli command.Cli) *cobra.Command {
 	flags.StringVar(&options.progress, "progress", "auto", "Set type of progress output (auto, plain, tty). Use plain to show container output")
 	flags.SetAnnotation("progress", "buildkit", nil)
 
-	flags.StringArrayVar(&options.secrets, "secret", []string{}, "Secret file to expose to the build (only if BuildKit enabled): id=mysecret,src=/local/secret")
+	flags.StringArrayVar(&options.secrets, "secret", []string{}, "Secret file or environment variable to expose to the build (only if BuildKit enabled): id=mysecret,src=/local/secret or id=mysecret,env=VAR")
 	flags.SetAnnotation("secret", "version", []string{"1.39"})
 	flags.SetAnnotation("secret", "buildkit", nil)
 
+	flags.StringArrayVar(&options.secretsFrom, "secret-from", []string{}, "Registry credentials of the credentials store to expose to the build as a secret (only if BuildKit enabled) (format: id=mysecret,registry=host[,field=username|password|auth])")
+	flags.SetAnnotation("secret-from", "version", []string{"1.39"})
+	flags.SetAnnotation("secret-from", "buildkit", nil)
+
 	flags.StringArrayVar(&options.ssh, "ssh", []string{}, "SSH agent socket or keys to expose to the build (only if BuildKit enabled) (format: default|<id>[=<socket>|<key>[,<key>]])")
 	flags.SetAnnotation("ssh", "version", []string{"1.39"})
 	flags.SetAnnotation("ssh", "buildkit", nil)
+
+	flags.StringArrayVarP(&options.outputs, "output", "o", []string{}, "Output destination of the build result instead of an image (only if BuildKit enabled) (format: type=local,dest=path|type=tar,dest=file)")
+	flags.SetAnnotation("output", "version", []string{"1.40"})
+	flags.SetAnnotation("output", "buildkit", nil)
+
+	flags.StringVar(&options.git.Ref, "git-ref", "", "Branch, tag or commit of a git context, instead of the one of the URL")
+	flags.StringVar(&options.git.Subdir, "git-subdir", "", "Directory of a git context used as context, instead of the one of the URL")
+	flags.IntVar(&options.git.Depth, "git-depth", 1, "Number of commits fetched for a git context (0 to fetch the whole history)")
+	flags.BoolVar(&options.git.Submodules, "git-submodules", true, "Initialize the submodules of a git context")
+	flags.BoolVar(&options.git.KeepGitDir, "git-keep-dir", false, "Keep the .git directory in the build context of a git context")
+	flags.StringVar(&options.git.SSH, "git-ssh", "", "Clone a git context with a private key, or \"default\" to forward the SSH agent")
+	flags.BoolVar(&options.gitCache, "git-cache", false, "Keep the clone of a git context in the configuration directory for later builds of the same commit")
+
+	flags.BoolVar(&options.printContext, "print-context", false, "Print the files of the build context and the paths excluded by the ignore file, without building")
 	return cmd
 }
 
@@ -188,6 +285,9 @@ func (out *lastProgressOutput) WriteProgress(prog progress.Progress) error {
 
 // nolint: gocyclo
 func runBuild(dockerCli command.Cli, options buildOptions) error {
+	if options.printContext {
+		return runPrintContext(dockerCli, options)
+	}
 	buildkitEnabled, err := command.BuildKitEnabled(dockerCli.ServerInfo())
 	if err != nil {
 		return err
@@ -195,12 +295,25 @@ func runBuild(dockerCli command.Cli, options buildOptions) error {
 	if buildkitEnabled {
 		return runBuildBuildKit(dockerCli, options)
 	}
+	if len(options.outputs) > 0 {
+		return errors.New("--output is only supported with BuildKit: set DOCKER_BUILDKIT=1 to enable it")
+	}
+	if len(options.cacheTo) > 0 {
+		return errors.New("--cache-to is only supported with BuildKit: set DOCKER_BUILDKIT=1 to enable it")
+	}
+	cacheFrom, err := parseCacheSpecs(joinCacheFields(options.cacheFrom), cacheFromAttrs)
+	if err != nil {
+		return errors.Wrapf(err, "could not parse cache sources: %v", options.cacheFrom)
+	}
+	cacheRefs, err := cacheFromRefs(cacheFrom)
+	if err != nil {
+		return err
+	}
 
 	var (
 		buildCtx      io.ReadCloser
 		dockerfileCtx io.ReadCloser
 		contextDir    string
-		tempDir       string
 		relDockerfile string
 		progBuff      io.Writer
 		buildBuff     io.Writer
@@ -246,8 +359,21 @@ func runBuild(dockerCli command.Cli, options buildOptions) error {
 			}
 			defer dockerfileCtx.Close()
 		}
+	case urlutil.IsGitURL(specifiedContext) && options.gitOptionsSet():
+		// The options of the clone are only supported by the client
+		var gitCtx *build.GitContext
+		gitCtx, err = build.GetContextFromGitRepository(specifiedContext, options.dockerfileName, options.gitOptions())
+		if err == nil {
+			defer gitCtx.Close()
+			contextDir, relDockerfile = gitCtx.Dir, gitCtx.Dockerfile
+		}
 	case urlutil.IsGitURL(specifiedContext):
+		var tempDir string
 		tempDir, relDockerfile, err = build.GetContextFromGitURL(specifiedContext, options.dockerfileName)
+		if err == nil {
+			defer os.RemoveAll(tempDir)
+			contextDir = tempDir
+		}
 	case urlutil.IsURL(specifiedContext):
 		buildCtx, relDockerfile, err = build.GetContextFromURL(progBuff, specifiedContext, options.dockerfileName)
 	default:
@@ -261,14 +387,9 @@ func runBuild(dockerCli command.Cli, options buildOptions) error {
 		return errors.Errorf("unable to prepare context: %s", err)
 	}
 
-	if tempDir != "" {
-		defer os.RemoveAll(tempDir)
-		contextDir = tempDir
-	}
-
 	// read from a directory into tar archive
 	if buildCtx == nil && !options.stream {
-		excludes, err := build.ReadDockerignore(contextDir)
+		excludes, _, err := build.ReadBuildDockerignore(contextDir, contextDockerfile(contextDir, relDockerfile, options))
 		if err != nil {
 			return err
 		}
@@ -365,7 +486,7 @@ func runBuild(dockerCli command.Cli, options buildOptions) error {
 		syncDone := make(chan error) // used to signal first progress reporting completed.
 		// progress would also send errors but don't need it here as errors
 		// are handled by session.Run() and ImageBuild()
-		if err := addDirToSession(s, contextDir, progressOutput, syncDone); err != nil {
+		if err := addDirToSession(s, contextDir, contextDockerfile(contextDir, relDockerfile, options), progressOutput, syncDone); err != nil {
 			return err
 		}
 
@@ -387,6 +508,7 @@ func runBuild(dockerCli command.Cli, options buildOptions) error {
 	buildOptions := imageBuildOptions(dockerCli, options)
 	buildOptions.Version = types.BuilderV1
 	buildOptions.Dockerfile = relDockerfile
+	buildOptions.CacheFrom = cacheRefs
 	buildOptions.AuthConfigs = authConfigs
 	buildOptions.RemoteContext = remote
 
@@ -479,6 +601,16 @@ func isLocalDir(c string) bool {
 	return err == nil
 }
 
+// contextDockerfile returns the path of the Dockerfile at relDockerfile from
+// the context directory contextDir, or an empty path if the Dockerfile is
+// read from stdin
+func contextDockerfile(contextDir, relDockerfile string, options buildOptions) string {
+	if options.dockerfileFromStdin() || relDockerfile == "" {
+		return ""
+	}
+	return filepath.Join(contextDir, relDockerfile)
+}
+
 type translatorFunc func(context.Context, reference.NamedTagged) (reference.Canonical, error)
 
 // validateTag checks if the given image name can be resolved.
@@ -493,6 +625,26 @@ func validateTag(rawRepo string) (string, error) {
 
 var dockerfileFromLinePattern = regexp.MustCompile(`(?i)^[\s]*FROM[ \f\r\t\v]+(?P<image>[^ \f\r\t\v\n#]+)`)
 
+// DockerfileBaseImages returns the images that the stages of a Dockerfile
+// are built from
+func DockerfileBaseImages(dockerfile io.Reader) ([]reference.Named, error) {
+	var images []reference.Named
+	scanner := bufio.NewScanner(dockerfile)
+	for scanner.Scan() {
+		matches := dockerfileFromLinePattern.FindStringSubmatch(scanner.Text())
+		if matches == nil || matches[1] == api.NoBaseImageSpecifier {
+			continue
+		}
+		// Images set with build arguments, such as $BASE, cannot be resolved
+		ref, err := reference.ParseNormalizedNamed(matches[1])
+		if err != nil {
+			continue
+		}
+		images = append(images, ref)
+	}
+	return images, scanner.Err()
+}
+
 // resolvedTag records the repository, tag, and resolved digest reference
 // from a Dockerfile rewrite.
 type resolvedTag struct {
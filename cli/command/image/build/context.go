@@ -81,6 +81,135 @@ func ValidateContextDirectory(srcPath string, excludes []string) error {
 	})
 }
 
+// ContextFile is a file of a context directory that is sent to the daemon
+type ContextFile struct {
+	Path string
+	Size int64
+}
+
+// ExcludedPath is a path of a context directory that is excluded from the
+// build context by Rule, a pattern of the ignore file
+type ExcludedPath struct {
+	Path  string
+	Rule  string
+	IsDir bool
+}
+
+// ContextContent is the content of the build context of a context directory
+type ContextContent struct {
+	Files    []ContextFile
+	Excluded []ExcludedPath
+}
+
+// InspectContextDirectory returns the files of the context directory that
+// are sent to the daemon, and the paths that excludes exclude from the build
+// context with the rule that excludes them. Excluded directories are only
+// walked if excludes has exceptions, like when the context is archived, and
+// the paths in an excluded directory are not listed.
+func InspectContextDirectory(srcPath string, excludes []string) (ContextContent, error) {
+	var content ContextContent
+	contextRoot, err := getContextRoot(srcPath)
+	if err != nil {
+		return content, err
+	}
+	pm, err := fileutils.NewPatternMatcher(excludes)
+	if err != nil {
+		return content, err
+	}
+	rules, err := newIgnoreRules(excludes)
+	if err != nil {
+		return content, err
+	}
+
+	var excludedDirs []string
+	err = filepath.Walk(contextRoot, func(filePath string, f os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsPermission(err) {
+				return errors.Errorf("can't stat '%s'", filePath)
+			}
+			return err
+		}
+		relFilePath, err := filepath.Rel(contextRoot, filePath)
+		if err != nil {
+			return err
+		}
+		if relFilePath == "." {
+			return nil
+		}
+		skip, err := pm.Matches(relFilePath)
+		if err != nil {
+			return err
+		}
+		if skip {
+			if !inDirs(relFilePath, excludedDirs) {
+				content.Excluded = append(content.Excluded, ExcludedPath{
+					Path:  filepath.ToSlash(relFilePath),
+					Rule:  excludingRule(rules, relFilePath),
+					IsDir: f.IsDir(),
+				})
+			}
+			if f.IsDir() {
+				if !pm.Exclusions() {
+					return filepath.SkipDir
+				}
+				excludedDirs = append(excludedDirs, relFilePath)
+			}
+			return nil
+		}
+		if !f.IsDir() {
+			content.Files = append(content.Files, ContextFile{Path: filepath.ToSlash(relFilePath), Size: f.Size()})
+		}
+		return nil
+	})
+	return content, err
+}
+
+// ignoreRule is a pattern of an ignore file, with a matcher of the pattern
+// alone
+type ignoreRule struct {
+	pattern   string
+	exception bool
+	matcher   *fileutils.PatternMatcher
+}
+
+func newIgnoreRules(excludes []string) ([]ignoreRule, error) {
+	var rules []ignoreRule
+	for _, pattern := range excludes {
+		exception := strings.HasPrefix(pattern, "!")
+		matcher, err := fileutils.NewPatternMatcher([]string{strings.TrimPrefix(pattern, "!")})
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, ignoreRule{pattern: pattern, exception: exception, matcher: matcher})
+	}
+	return rules, nil
+}
+
+// excludingRule returns the pattern that excludes path: the last pattern
+// that matches it, unless it is an exception
+func excludingRule(rules []ignoreRule, path string) string {
+	var rule string
+	for _, r := range rules {
+		if match, _ := r.matcher.Matches(path); match {
+			rule = r.pattern
+			if r.exception {
+				rule = ""
+			}
+		}
+	}
+	return rule
+}
+
+// inDirs returns whether path is in one of the directories dirs
+func inDirs(path string, dirs []string) bool {
+	for _, dir := range dirs {
+		if strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
 // DetectArchiveReader detects whether the input stream is an archive or a
 // Dockerfile and returns a buffered version of input, safe to consume in lieu
 // of input. If an archive is detected, isArchive is set to true, and to false
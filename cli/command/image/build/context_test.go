@@ -14,6 +14,7 @@ import (
 	"github.com/yuyangjack/moby/pkg/archive"
 	"gotest.tools/assert"
 	is "gotest.tools/assert/cmp"
+	"gotest.tools/fs"
 )
 
 const dockerfileContents = "FROM busybox"
@@ -297,3 +298,27 @@ func TestIsArchive(t *testing.T) {
 		assert.Check(t, is.Equal(testcase.expected, IsArchive(testcase.header)), testcase.doc)
 	}
 }
+
+func TestInspectContextDirectory(t *testing.T) {
+	dir := fs.NewDir(t, "builder-context-test",
+		fs.WithFile("Dockerfile", dockerfileContents),
+		fs.WithFile("app.log", "log"),
+		fs.WithDir("src", fs.WithFile("main.go", "package main")),
+		fs.WithDir("node_modules", fs.WithDir("lib", fs.WithFile("index.js", "module"))),
+		fs.WithDir("logs", fs.WithFile("keep.log", "keep"), fs.WithFile("old.log", "old")),
+	)
+	defer dir.Remove()
+
+	content, err := InspectContextDirectory(dir.Path(), []string{"node_modules", "*.log", "logs", "!logs/keep.log"})
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual([]ContextFile{
+		{Path: "Dockerfile", Size: int64(len(dockerfileContents))},
+		{Path: "logs/keep.log", Size: 4},
+		{Path: "src/main.go", Size: 12},
+	}, content.Files))
+	assert.Check(t, is.DeepEqual([]ExcludedPath{
+		{Path: "app.log", Rule: "*.log"},
+		{Path: "logs", Rule: "logs", IsDir: true},
+		{Path: "node_modules", Rule: "node_modules", IsDir: true},
+	}, content.Excluded))
+}
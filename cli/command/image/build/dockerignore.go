@@ -11,14 +11,43 @@ import (
 // ReadDockerignore reads the .dockerignore file in the context directory and
 // returns the list of paths to exclude
 func ReadDockerignore(contextDir string) ([]string, error) {
-	var excludes []string
+	excludes, err := readIgnoreFile(filepath.Join(contextDir, ".dockerignore"))
+	if os.IsNotExist(err) {
+		return excludes, nil
+	}
+	return excludes, err
+}
 
-	f, err := os.Open(filepath.Join(contextDir, ".dockerignore"))
+// ReadBuildDockerignore reads the ignore file of a build of the Dockerfile at
+// path dockerfile, and returns the list of paths to exclude and the path of
+// the ignore file. The <Dockerfile>.dockerignore file next to the Dockerfile
+// takes precedence over the .dockerignore file in the context directory. The
+// path of the ignore file is empty if the build has none.
+func ReadBuildDockerignore(contextDir, dockerfile string) ([]string, string, error) {
+	if dockerfile != "" {
+		ignoreFile := dockerfile + ".dockerignore"
+		excludes, err := readIgnoreFile(ignoreFile)
+		if !os.IsNotExist(err) {
+			return excludes, ignoreFile, err
+		}
+	}
+	ignoreFile := filepath.Join(contextDir, ".dockerignore")
+	excludes, err := readIgnoreFile(ignoreFile)
 	switch {
 	case os.IsNotExist(err):
-		return excludes, nil
+		return nil, "", nil
 	case err != nil:
-		return nil, err
+		return nil, "", err
+	}
+	return excludes, ignoreFile, nil
+}
+
+func readIgnoreFile(path string) ([]string, error) {
+	var excludes []string
+
+	f, err := os.Open(path)
+	if err != nil {
+		return excludes, err
 	}
 	defer f.Close()
 
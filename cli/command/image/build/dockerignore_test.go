@@ -0,0 +1,39 @@
+package build
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	"gotest.tools/fs"
+)
+
+func TestReadBuildDockerignore(t *testing.T) {
+	dir := fs.NewDir(t, "builder-dockerignore-test",
+		fs.WithFile(".dockerignore", "*.log\n"),
+		fs.WithFile("Dockerfile", dockerfileContents),
+		fs.WithFile("Dockerfile.test", dockerfileContents),
+		fs.WithFile("Dockerfile.test.dockerignore", "docs\n!README.md\n"),
+	)
+	defer dir.Remove()
+
+	excludes, ignoreFile, err := ReadBuildDockerignore(dir.Path(), dir.Join("Dockerfile"))
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual([]string{"*.log"}, excludes))
+	assert.Check(t, is.Equal(dir.Join(".dockerignore"), ignoreFile))
+
+	excludes, ignoreFile, err = ReadBuildDockerignore(dir.Path(), dir.Join("Dockerfile.test"))
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual([]string{"docs", "!README.md"}, excludes))
+	assert.Check(t, is.Equal(dir.Join("Dockerfile.test.dockerignore"), ignoreFile))
+}
+
+func TestReadBuildDockerignoreNoIgnoreFile(t *testing.T) {
+	dir := fs.NewDir(t, "builder-dockerignore-test", fs.WithFile("Dockerfile", dockerfileContents))
+	defer dir.Remove()
+
+	excludes, ignoreFile, err := ReadBuildDockerignore(dir.Path(), "")
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(excludes, 0))
+	assert.Check(t, is.Equal("", ignoreFile))
+}
@@ -0,0 +1,375 @@
+package build
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/yuyangjack/moby/pkg/symlink"
+	"github.com/yuyangjack/moby/pkg/urlutil"
+	"github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+)
+
+// GitSSHAgent is the value of GitOptions.SSH that forwards the SSH agent of
+// SSH_AUTH_SOCK to the clone of the repository
+const GitSSHAgent = "default"
+
+var commitPattern = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// GitOptions are the options of the clone of a git repository used as a
+// build context
+type GitOptions struct {
+	// Ref is the branch, tag or commit to build, instead of the ref of the
+	// fragment of the URL
+	Ref string
+	// Subdir is the directory of the repository used as context, instead of
+	// the directory of the fragment of the URL
+	Subdir string
+	// Depth is the number of commits fetched, or 0 to fetch the whole
+	// history
+	Depth int
+	// Submodules initializes the submodules of the repository
+	Submodules bool
+	// KeepGitDir keeps the .git directory in the build context
+	KeepGitDir bool
+	// SSH is GitSSHAgent to forward the SSH agent, or the path of a private
+	// key used to clone the repository
+	SSH string
+	// CacheDir is the directory of the clones kept for later builds of the
+	// same commit, or empty to clone the repository for each build
+	CacheDir string
+}
+
+// DefaultGitOptions returns the options of a clone that fetches the last
+// commit of the ref and initializes the submodules
+func DefaultGitOptions() GitOptions {
+	return GitOptions{Depth: 1, Submodules: true}
+}
+
+// GitContext is a clone of a git repository used as a build context
+type GitContext struct {
+	// Dir is the absolute path of the context directory in the clone
+	Dir string
+	// Dockerfile is the path of the Dockerfile relative to Dir
+	Dockerfile string
+	// Commit is the commit that is checked out
+	Commit string
+
+	tempDir string
+}
+
+// Close removes the clone, unless it is kept in the cache
+func (c *GitContext) Close() error {
+	if c.tempDir == "" {
+		return nil
+	}
+	return os.RemoveAll(c.tempDir)
+}
+
+// gitRepo is the repository and the fragment of a git URL
+type gitRepo struct {
+	remote string
+	ref    string
+	subdir string
+}
+
+// GetContextFromGitRepository clones the git repository at gitURL, or uses
+// the clone of the same commit in the cache, as context for a `docker build`.
+// The ref and the subdirectory of the fragment of the URL (url#ref:subdir)
+// are overridden by the ones of opts.
+func GetContextFromGitRepository(gitURL, dockerfileName string, opts GitOptions) (*GitContext, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, errors.Wrapf(err, "unable to find 'git'")
+	}
+	repo, err := parseGitURL(gitURL)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Ref != "" {
+		repo.ref = opts.Ref
+	}
+	if opts.Subdir != "" {
+		repo.subdir = opts.Subdir
+	}
+	if err := validateGitRepo(repo); err != nil {
+		return nil, err
+	}
+	env, err := gitEnv(opts.SSH)
+	if err != nil {
+		return nil, err
+	}
+	g := &gitClone{repo: repo, opts: opts, env: env}
+
+	gitCtx := &GitContext{}
+	var root string
+	if opts.CacheDir == "" {
+		if root, err = ioutil.TempDir("", "docker-build-git"); err != nil {
+			return nil, err
+		}
+		gitCtx.tempDir = root
+		gitCtx.Commit, err = g.clone(root)
+	} else {
+		root, gitCtx.Commit, err = g.cachedClone()
+	}
+	if err != nil {
+		gitCtx.Close()
+		return nil, errors.Wrapf(err, "unable to 'git clone' to temporary context directory")
+	}
+
+	gitCtx.Dir, err = symlink.FollowSymlinkInScope(filepath.Join(root, repo.subdir), root)
+	if err != nil {
+		gitCtx.Close()
+		return nil, errors.Wrapf(err, "error setting git context, %q not within git root", repo.subdir)
+	}
+	if fi, err := os.Stat(gitCtx.Dir); err != nil || !fi.IsDir() {
+		gitCtx.Close()
+		return nil, errors.Errorf("error setting git context, not a directory: %s", repo.subdir)
+	}
+	gitCtx.Dockerfile, err = getDockerfileRelPath(gitCtx.Dir, dockerfileName)
+	if err == nil && strings.HasPrefix(gitCtx.Dockerfile, ".."+string(filepath.Separator)) {
+		err = errors.Errorf("the Dockerfile (%s) must be within the build context", dockerfileName)
+	}
+	if err != nil {
+		gitCtx.Close()
+		return nil, err
+	}
+	return gitCtx, nil
+}
+
+// parseGitURL parses a git URL of the form url#ref:subdir, where the ref
+// defaults to master
+func parseGitURL(gitURL string) (gitRepo, error) {
+	var repo gitRepo
+	if !isGitTransport(gitURL) {
+		gitURL = "https://" + gitURL
+	}
+	var fragment string
+	if strings.HasPrefix(gitURL, "git@") {
+		// git@... is not a URL, so it cannot be parsed as one
+		parts := strings.SplitN(gitURL, "#", 2)
+		repo.remote = parts[0]
+		if len(parts) == 2 {
+			fragment = parts[1]
+		}
+	} else {
+		u, err := url.Parse(gitURL)
+		if err != nil {
+			return repo, errors.Wrapf(err, "invalid git URL %q", gitURL)
+		}
+		fragment = u.Fragment
+		u.Fragment = ""
+		repo.remote = u.String()
+	}
+
+	refAndDir := strings.SplitN(fragment, ":", 2)
+	repo.ref = "master"
+	if refAndDir[0] != "" {
+		repo.ref = refAndDir[0]
+	}
+	if len(refAndDir) > 1 {
+		repo.subdir = refAndDir[1]
+	}
+	return repo, validateGitRepo(repo)
+}
+
+// validateGitRepo rejects the refs and subdirectories that start with "-",
+// which git would read as options
+func validateGitRepo(repo gitRepo) error {
+	if strings.HasPrefix(repo.ref, "-") {
+		return errors.Errorf("invalid git ref %q: must not start with '-'", repo.ref)
+	}
+	if strings.HasPrefix(repo.subdir, "-") {
+		return errors.Errorf("invalid git subdirectory %q: must not start with '-'", repo.subdir)
+	}
+	return nil
+}
+
+func isGitTransport(str string) bool {
+	return urlutil.IsURL(str) || strings.HasPrefix(str, "git://") || strings.HasPrefix(str, "git@") || strings.HasPrefix(str, "file://")
+}
+
+// gitEnv returns the environment of the git commands, which use the private
+// key at ssh, or the SSH agent of SSH_AUTH_SOCK if ssh is GitSSHAgent
+func gitEnv(ssh string) ([]string, error) {
+	env := os.Environ()
+	switch ssh {
+	case "":
+	case GitSSHAgent:
+		if os.Getenv("SSH_AUTH_SOCK") == "" {
+			return nil, errors.New("cannot forward the SSH agent to git: SSH_AUTH_SOCK is not set")
+		}
+	default:
+		key, err := filepath.Abs(ssh)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := os.Stat(key); err != nil {
+			return nil, errors.Wrap(err, "invalid SSH key for git")
+		}
+		env = append(env, "GIT_SSH_COMMAND=ssh -i "+strconv.Quote(key)+" -o IdentitiesOnly=yes")
+	}
+	return env, nil
+}
+
+// gitClone clones a repository with the git command
+type gitClone struct {
+	repo gitRepo
+	opts GitOptions
+	env  []string
+}
+
+// cachedClone returns the clone of the commit of the ref in the cache,
+// cloning the repository in the cache if the commit of the ref cannot be
+// resolved or has no clone yet
+func (g *gitClone) cachedClone() (string, string, error) {
+	cacheDir := filepath.Join(g.opts.CacheDir, g.cacheKey())
+	commit := g.resolveCommit()
+	if commit != "" {
+		dir := filepath.Join(cacheDir, commit)
+		if _, err := os.Stat(dir); err == nil {
+			return dir, commit, nil
+		}
+	}
+
+	if err := os.MkdirAll(cacheDir, 0700); err != nil {
+		return "", "", err
+	}
+	tempDir, err := ioutil.TempDir(cacheDir, ".clone-")
+	if err != nil {
+		return "", "", err
+	}
+	commit, err = g.clone(tempDir)
+	if err != nil {
+		os.RemoveAll(tempDir)
+		return "", "", err
+	}
+	dir := filepath.Join(cacheDir, commit)
+	if err := os.Rename(tempDir, dir); err != nil {
+		// Another build cached the same commit in the meantime
+		os.RemoveAll(tempDir)
+		if _, statErr := os.Stat(dir); statErr != nil {
+			return "", "", err
+		}
+	}
+	return dir, commit, nil
+}
+
+// cacheKey is the directory of the cache of the clones of the repository,
+// with the options that change the content of the clones. The depth only
+// changes the content of the .git directory.
+func (g *gitClone) cacheKey() string {
+	key := fmt.Sprintf("%s\x00submodules=%t\x00keep-git-dir=%t", g.repo.remote, g.opts.Submodules, g.opts.KeepGitDir)
+	if g.opts.KeepGitDir {
+		key += fmt.Sprintf("\x00depth=%d", g.opts.Depth)
+	}
+	return digest.FromString(key).Hex()[:16]
+}
+
+// resolveCommit returns the commit of the ref, or an empty string if it
+// cannot be resolved without cloning the repository
+func (g *gitClone) resolveCommit() string {
+	if commitPattern.MatchString(g.repo.ref) {
+		return g.repo.ref
+	}
+	out, err := g.git("", "ls-remote", "--", g.repo.remote, g.repo.ref)
+	if err != nil {
+		return ""
+	}
+	return lsRemoteCommit(string(out), g.repo.ref)
+}
+
+// lsRemoteCommit returns the commit of ref in the output of git ls-remote,
+// where the commit of an annotated tag is the one of its peeled ref
+func lsRemoteCommit(out, ref string) string {
+	commits := make(map[string]string)
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 {
+			commits[fields[1]] = fields[0]
+		}
+	}
+	for _, name := range []string{"refs/tags/" + ref + "^{}", "refs/tags/" + ref, "refs/heads/" + ref, "refs/" + ref, ref} {
+		if commit, ok := commits[name]; ok {
+			return commit
+		}
+	}
+	return ""
+}
+
+// clone clones the ref of the repository in root, and returns the commit
+// that is checked out
+func (g *gitClone) clone(root string) (string, error) {
+	if _, err := g.git(root, "init"); err != nil {
+		return "", err
+	}
+	if _, err := g.git(root, "remote", "add", "origin", g.repo.remote); err != nil {
+		return "", err
+	}
+	fetchArgs := []string{"fetch"}
+	if g.opts.Depth > 0 {
+		fetchArgs = append(fetchArgs, "--depth", strconv.Itoa(g.opts.Depth))
+	}
+	if _, err := g.git(root, append(fetchArgs, "origin", "--", g.repo.ref)...); err != nil {
+		return "", err
+	}
+	if _, err := g.git(root, "checkout", "FETCH_HEAD"); err != nil {
+		return "", err
+	}
+	if g.opts.Submodules {
+		submoduleArgs := []string{"submodule", "update", "--init", "--recursive"}
+		if g.opts.Depth > 0 {
+			submoduleArgs = append(submoduleArgs, "--depth", strconv.Itoa(g.opts.Depth))
+		}
+		if _, err := g.git(root, submoduleArgs...); err != nil {
+			return "", err
+		}
+	}
+	out, err := g.git(root, "rev-parse", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	if !g.opts.KeepGitDir {
+		if err := removeGitDirs(root); err != nil {
+			return "", err
+		}
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func (g *gitClone) git(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = g.env
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return out, errors.Wrapf(err, "failed to run git %s: %s", args[0], out)
+	}
+	return out, nil
+}
+
+// removeGitDirs removes the .git directories of a clone and of its
+// submodules, which are .git files in the submodules
+func removeGitDirs(root string) error {
+	return filepath.Walk(root, func(path string, f os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if f.Name() != ".git" {
+			return nil
+		}
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+		if f.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+}
@@ -0,0 +1,148 @@
+package build
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	"gotest.tools/fs"
+	"gotest.tools/skip"
+)
+
+func TestParseGitURL(t *testing.T) {
+	testCases := []struct {
+		url      string
+		expected gitRepo
+	}{
+		{
+			url:      "github.com/docker/cli",
+			expected: gitRepo{remote: "https://github.com/docker/cli", ref: "master"},
+		},
+		{
+			url:      "https://github.com/docker/cli.git#v1.0:docs",
+			expected: gitRepo{remote: "https://github.com/docker/cli.git", ref: "v1.0", subdir: "docs"},
+		},
+		{
+			url:      "git@github.com:docker/cli.git#:docs",
+			expected: gitRepo{remote: "git@github.com:docker/cli.git", ref: "master", subdir: "docs"},
+		},
+		{
+			url:      "git://github.com/docker/cli#dev",
+			expected: gitRepo{remote: "git://github.com/docker/cli", ref: "dev"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.url, func(t *testing.T) {
+			repo, err := parseGitURL(tc.url)
+			assert.NilError(t, err)
+			assert.Check(t, is.Equal(tc.expected, repo))
+		})
+	}
+}
+
+func TestParseGitURLRejectsOptions(t *testing.T) {
+	_, err := parseGitURL("https://github.com/docker/cli.git#--upload-pack=touch pwned")
+	assert.Check(t, is.ErrorContains(err, "invalid git ref"))
+	_, err = parseGitURL("https://github.com/docker/cli.git#master:--docs")
+	assert.Check(t, is.ErrorContains(err, "invalid git subdirectory"))
+
+	opts := DefaultGitOptions()
+	opts.Ref = "--upload-pack=touch pwned"
+	_, err = GetContextFromGitRepository("https://github.com/docker/cli.git", "", opts)
+	assert.Check(t, is.ErrorContains(err, "invalid git ref"))
+}
+
+func TestLsRemoteCommit(t *testing.T) {
+	out := `1111111111111111111111111111111111111111	HEAD
+2222222222222222222222222222222222222222	refs/heads/master
+3333333333333333333333333333333333333333	refs/tags/v1.0
+4444444444444444444444444444444444444444	refs/tags/v1.0^{}
+`
+	assert.Check(t, is.Equal("1111111111111111111111111111111111111111", lsRemoteCommit(out, "HEAD")))
+	assert.Check(t, is.Equal("2222222222222222222222222222222222222222", lsRemoteCommit(out, "master")))
+	assert.Check(t, is.Equal("4444444444444444444444444444444444444444", lsRemoteCommit(out, "v1.0")))
+	assert.Check(t, is.Equal("", lsRemoteCommit(out, "dev")))
+}
+
+func TestGetContextFromGitRepositoryCache(t *testing.T) {
+	_, err := exec.LookPath("git")
+	skip.If(t, err != nil, "git is not installed")
+
+	repo := fs.NewDir(t, "builder-git-repo", fs.WithDir("app", fs.WithFile("Dockerfile", dockerfileContents)))
+	defer repo.Remove()
+	for _, args := range [][]string{{"init", "-q"}, {"add", "."}, {"commit", "-q", "-m", "initial"}, {"branch", "-f", "master"}} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repo.Path()
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com", "GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com")
+		out, err := cmd.CombinedOutput()
+		assert.NilError(t, err, string(out))
+	}
+	cache := fs.NewDir(t, "builder-git-cache")
+	defer cache.Remove()
+
+	opts := DefaultGitOptions()
+	opts.Subdir = "app"
+	opts.CacheDir = cache.Path()
+	gitCtx, err := GetContextFromGitRepository("file://"+repo.Path(), "", opts)
+	assert.NilError(t, err)
+	assert.Check(t, gitCtx.Close())
+	assert.Check(t, is.Equal(DefaultDockerfileName, gitCtx.Dockerfile))
+	assert.Check(t, is.Equal("app", filepath.Base(gitCtx.Dir)))
+	_, err = os.Stat(filepath.Join(gitCtx.Dir, "..", ".git"))
+	assert.Check(t, os.IsNotExist(err))
+
+	// The clone of the same commit is reused
+	reused, err := GetContextFromGitRepository("file://"+repo.Path()+"#master:app", "", opts)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(gitCtx.Dir, reused.Dir))
+	assert.Check(t, is.Equal(gitCtx.Commit, reused.Commit))
+
+	// A clone without the .git directory is not reused when it is kept
+	opts.KeepGitDir = true
+	kept, err := GetContextFromGitRepository("file://"+repo.Path(), "", opts)
+	assert.NilError(t, err)
+	assert.Check(t, kept.Dir != gitCtx.Dir)
+	assert.Check(t, is.Equal(gitCtx.Commit, kept.Commit))
+	_, err = os.Stat(filepath.Join(kept.Dir, "..", ".git"))
+	assert.Check(t, err)
+}
+
+func TestGitCloneRefIsNotAnOption(t *testing.T) {
+	_, err := exec.LookPath("git")
+	skip.If(t, err != nil, "git is not installed")
+
+	repo := fs.NewDir(t, "builder-git-repo")
+	defer repo.Remove()
+	root := fs.NewDir(t, "builder-git-clone")
+	defer root.Remove()
+	pwned := repo.Join("pwned")
+
+	// A ref that bypasses the validation is passed to git as a ref only
+	g := &gitClone{repo: gitRepo{remote: "file://" + repo.Path(), ref: "--upload-pack=touch " + pwned}, opts: DefaultGitOptions(), env: os.Environ()}
+	_, err = g.clone(root.Path())
+	assert.Check(t, is.ErrorContains(err, "failed to run git fetch"))
+	assert.Check(t, is.Equal("", g.resolveCommit()))
+	_, err = os.Stat(pwned)
+	assert.Check(t, os.IsNotExist(err))
+}
+
+func TestGitCloneCacheKey(t *testing.T) {
+	key := func(opts GitOptions) string {
+		return (&gitClone{repo: gitRepo{remote: "https://example.com/app.git"}, opts: opts}).cacheKey()
+	}
+	defaults := DefaultGitOptions()
+	keepGitDir := defaults
+	keepGitDir.KeepGitDir = true
+	deeper := defaults
+	deeper.Depth = 10
+	deeperKeepGitDir := keepGitDir
+	deeperKeepGitDir.Depth = 10
+
+	assert.Check(t, key(defaults) != key(keepGitDir))
+	assert.Check(t, key(keepGitDir) != key(deeperKeepGitDir))
+	// The depth does not change the content of a clone without .git
+	assert.Check(t, is.Equal(key(defaults), key(deeper)))
+}
@@ -100,6 +100,20 @@ func runBuildBuildKit(dockerCli command.Cli, options buildOptions) error {
 			dockerfileDir = options.context
 		}
 		remote = clientSessionRemote
+	case urlutil.IsGitURL(options.context) && options.gitOptionsSet():
+		// The builder clones git contexts without the options of the
+		// clone, so the repository is cloned by the client and sent as a
+		// local context
+		gitCtx, err := build.GetContextFromGitRepository(options.context, options.dockerfileName, options.gitOptions())
+		if err != nil {
+			return errors.Errorf("unable to prepare context: %s", err)
+		}
+		defer gitCtx.Close()
+		contextDir = gitCtx.Dir
+		dockerfile := filepath.Join(gitCtx.Dir, gitCtx.Dockerfile)
+		dockerfileName = filepath.Base(dockerfile)
+		dockerfileDir = filepath.Dir(dockerfile)
+		remote = clientSessionRemote
 	case urlutil.IsGitURL(options.context):
 		remote = options.context
 	case urlutil.IsURL(options.context):
@@ -118,11 +132,30 @@ func runBuildBuildKit(dockerCli command.Cli, options buildOptions) error {
 	}
 
 	if dockerfileDir != "" {
+		var excludes []string
+		if dockerfileReader == nil {
+			// The builder only reads the .dockerignore file of the context,
+			// so the excludes of a <Dockerfile>.dockerignore file are sent
+			// with the context
+			dockerfile := filepath.Join(dockerfileDir, dockerfileName)
+			if dockerfileName == "" {
+				dockerfile = filepath.Join(dockerfileDir, build.DefaultDockerfileName)
+			}
+			var ignoreFile string
+			excludes, ignoreFile, err = build.ReadBuildDockerignore(contextDir, dockerfile)
+			if err != nil {
+				return err
+			}
+			if ignoreFile != dockerfile+".dockerignore" {
+				excludes = nil
+			}
+		}
 		s.Allow(filesync.NewFSSyncProvider([]filesync.SyncedDir{
 			{
-				Name: "context",
-				Dir:  contextDir,
-				Map:  resetUIDAndGID,
+				Name:     "context",
+				Dir:      contextDir,
+				Map:      resetUIDAndGID,
+				Excludes: excludes,
 			},
 			{
 				Name: "dockerfile",
@@ -132,21 +165,80 @@ func runBuildBuildKit(dockerCli command.Cli, options buildOptions) error {
 	}
 
 	s.Allow(authprovider.NewDockerAuthProvider())
-	if len(options.secrets) > 0 {
-		sp, err := parseSecretSpecs(options.secrets)
+	if len(options.secrets) > 0 || len(options.secretsFrom) > 0 {
+		sources, err := parseSecretSpecs(options.secrets)
 		if err != nil {
 			return errors.Wrapf(err, "could not parse secrets: %v", options.secrets)
 		}
-		s.Allow(sp)
+		fromRegistries, err := parseSecretFromSpecs(options.secretsFrom)
+		if err != nil {
+			return errors.Wrapf(err, "could not parse secrets: %v", options.secretsFrom)
+		}
+		store, err := newSecretStore(dockerCli, sources, fromRegistries)
+		if err != nil {
+			return err
+		}
+		s.Allow(secretsprovider.NewSecretProvider(store))
 	}
 	if len(options.ssh) > 0 {
-		sshp, err := parseSSHSpecs(options.ssh)
+		sshp, stopAgents, err := parseSSHSpecs(dockerCli, options.ssh)
 		if err != nil {
 			return errors.Wrapf(err, "could not parse ssh: %v", options.ssh)
 		}
+		defer stopAgents()
 		s.Allow(sshp)
 	}
 
+	outputs, err := parseOutputs(options.outputs)
+	if err != nil {
+		return errors.Wrapf(err, "could not parse outputs: %v", options.outputs)
+	}
+	cacheFrom, err := parseCacheSpecs(joinCacheFields(options.cacheFrom), cacheFromAttrs)
+	if err != nil {
+		return errors.Wrapf(err, "could not parse cache sources: %v", options.cacheFrom)
+	}
+	cacheTo, err := parseCacheSpecs(options.cacheTo, cacheToAttrs)
+	if err != nil {
+		return errors.Wrapf(err, "could not parse cache destinations: %v", options.cacheTo)
+	}
+	if len(cacheTo) > 0 && len(outputs) > 0 {
+		return errors.New("--cache-to cannot be used with --output: the cache is exported with the image")
+	}
+	cacheRefs, loadedCache, err := importBuildCache(ctx, dockerCli, cacheFrom)
+	// The loaded cache images are removed once the build is done and the
+	// cache is exported
+	defer removeBuildCache(context.Background(), dockerCli, loadedCache)
+	if err != nil {
+		return err
+	}
+
+	progressOut := progressWriter(dockerCli.Out())
+	for i, out := range outputs {
+		dest := out.Attrs["dest"]
+		// The destination is handled by the client, through the session
+		delete(outputs[i].Attrs, "dest")
+		switch {
+		case out.Type == "local":
+			s.Allow(filesync.NewFSSyncTargetDir(dest))
+		case dest == "-":
+			if options.quiet {
+				return errors.New("--quiet cannot be used with an output written to stdout")
+			}
+			if dockerCli.Out().IsTerminal() {
+				return errors.New("refusing to write the output to the terminal: redirect stdout or set dest to a file")
+			}
+			progressOut = dockerCli.Err()
+			s.Allow(filesync.NewFSSyncTarget(os.Stdout))
+		default:
+			f, err := os.Create(dest)
+			if err != nil {
+				return errors.Wrapf(err, "failed to create output file %s", dest)
+			}
+			defer f.Close()
+			s.Allow(filesync.NewFSSyncTarget(f))
+		}
+	}
+
 	eg, ctx := errgroup.WithContext(ctx)
 
 	eg.Go(func() error {
@@ -182,14 +274,22 @@ func runBuildBuildKit(dockerCli command.Cli, options buildOptions) error {
 		buildOptions.RemoteContext = remote
 		buildOptions.SessionID = s.ID()
 		buildOptions.BuildID = buildID
-		return doBuild(ctx, eg, dockerCli, options, buildOptions)
+		buildOptions.Outputs = outputs
+		buildOptions.CacheFrom = cacheRefs
+		if err := setCacheExports(&buildOptions, cacheTo); err != nil {
+			return err
+		}
+		return doBuild(ctx, eg, dockerCli, progressOut, options, buildOptions)
 	})
 
-	return eg.Wait()
+	if err := eg.Wait(); err != nil {
+		return err
+	}
+	return exportBuildCache(context.Background(), dockerCli, cacheTo, options.quiet)
 }
 
 //nolint: gocyclo
-func doBuild(ctx context.Context, eg *errgroup.Group, dockerCli command.Cli, options buildOptions, buildOptions types.ImageBuildOptions) (finalErr error) {
+func doBuild(ctx context.Context, eg *errgroup.Group, dockerCli command.Cli, progressOut io.Writer, options buildOptions, buildOptions types.ImageBuildOptions) (finalErr error) {
 	response, err := dockerCli.Client().ImageBuild(context.Background(), nil, buildOptions)
 	if err != nil {
 		return err
@@ -214,11 +314,13 @@ func doBuild(ctx context.Context, eg *errgroup.Group, dockerCli command.Cli, opt
 		return err
 	}
 
-	displayStatus := func(out *os.File, displayCh chan *client.SolveStatus) {
+	displayStatus := func(out io.Writer, displayCh chan *client.SolveStatus) {
 		var c console.Console
 		// TODO: Handle tty output in non-tty environment.
-		if cons, err := console.ConsoleFromFile(out); err == nil && (options.progress == "auto" || options.progress == "tty") {
-			c = cons
+		if f, ok := out.(*os.File); ok && (options.progress == "auto" || options.progress == "tty") {
+			if cons, err := console.ConsoleFromFile(f); err == nil {
+				c = cons
+			}
 		}
 		// not using shared context to not disrupt display but let is finish reporting errors
 		eg.Go(func() error {
@@ -242,12 +344,12 @@ func doBuild(ctx context.Context, eg *errgroup.Group, dockerCli command.Cli, opt
 					}
 					close(displayCh)
 				}()
-				displayStatus(os.Stderr, displayCh)
+				displayStatus(dockerCli.Err(), displayCh)
 			}
 			return nil
 		})
 	} else {
-		displayStatus(os.Stdout, t.displayCh)
+		displayStatus(progressOut, t.displayCh)
 	}
 	defer close(t.displayCh)
 
@@ -367,31 +469,28 @@ func (t *tracer) write(msg jsonmessage.JSONMessage) {
 	t.displayCh <- &s
 }
 
-func parseSecretSpecs(sl []string) (session.Attachable, error) {
-	fs := make([]secretsprovider.FileSource, 0, len(sl))
+func parseSecretSpecs(sl []string) ([]secretSource, error) {
+	sources := make([]secretSource, 0, len(sl))
 	for _, v := range sl {
 		s, err := parseSecret(v)
 		if err != nil {
 			return nil, err
 		}
-		fs = append(fs, *s)
-	}
-	store, err := secretsprovider.NewFileStore(fs)
-	if err != nil {
-		return nil, err
+		sources = append(sources, *s)
 	}
-	return secretsprovider.NewSecretProvider(store), nil
+	return sources, nil
 }
 
-func parseSecret(value string) (*secretsprovider.FileSource, error) {
+func parseSecret(value string) (*secretSource, error) {
 	csvReader := csv.NewReader(strings.NewReader(value))
 	fields, err := csvReader.Read()
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to parse csv secret")
 	}
 
-	fs := secretsprovider.FileSource{}
+	fs := secretSource{}
 
+	var typ string
 	for _, field := range fields {
 		parts := strings.SplitN(field, "=", 2)
 		key := strings.ToLower(parts[0])
@@ -403,30 +502,182 @@ func parseSecret(value string) (*secretsprovider.FileSource, error) {
 		value := parts[1]
 		switch key {
 		case "type":
-			if value != "file" {
+			if value != "file" && value != "env" {
 				return nil, errors.Errorf("unsupported secret type %q", value)
 			}
+			typ = value
 		case "id":
 			fs.ID = value
 		case "source", "src":
 			fs.FilePath = value
+		case "env":
+			fs.Env = value
 		default:
 			return nil, errors.Errorf("unexpected key '%s' in '%s'", key, field)
 		}
 	}
+	if typ == "env" && fs.Env == "" {
+		fs.Env = fs.ID
+	}
+	if fs.Env != "" && fs.FilePath != "" {
+		return nil, errors.Errorf("secret %s cannot have both a source file and an environment variable", fs.ID)
+	}
 	return &fs, nil
 }
 
-func parseSSHSpecs(sl []string) (session.Attachable, error) {
+func parseSecretFromSpecs(sl []string) ([]registrySecret, error) {
+	secrets := make([]registrySecret, 0, len(sl))
+	for _, v := range sl {
+		s, err := parseSecretFrom(v)
+		if err != nil {
+			return nil, err
+		}
+		secrets = append(secrets, *s)
+	}
+	return secrets, nil
+}
+
+// parseSecretFrom parses a secret read from the credentials of a registry:
+// id=mysecret,registry=host[,field=username|password|auth]
+func parseSecretFrom(value string) (*registrySecret, error) {
+	csvReader := csv.NewReader(strings.NewReader(value))
+	fields, err := csvReader.Read()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse csv secret")
+	}
+
+	rs := registrySecret{Field: "password"}
+	for _, field := range fields {
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 {
+			return nil, errors.Errorf("invalid field '%s' must be a key=value pair", field)
+		}
+		key := strings.ToLower(parts[0])
+		switch key {
+		case "id":
+			rs.ID = parts[1]
+		case "registry":
+			rs.Registry = parts[1]
+		case "field":
+			rs.Field = parts[1]
+		default:
+			return nil, errors.Errorf("unexpected key '%s' in '%s'", key, field)
+		}
+	}
+	switch {
+	case rs.ID == "":
+		return nil, errors.Errorf("id is required for secret %q", value)
+	case rs.Registry == "":
+		return nil, errors.Errorf("registry is required for secret %s", rs.ID)
+	}
+	switch rs.Field {
+	case "username", "password", "auth":
+	default:
+		return nil, errors.Errorf("unsupported field %q for secret %s: must be username, password or auth", rs.Field, rs.ID)
+	}
+	return &rs, nil
+}
+
+// parseOutputs parses the outputs of a build, either a directory for a local
+// output, "-" for a tar written to stdout, or a type=local|tar,dest=path
+// specification
+func parseOutputs(values []string) ([]types.ImageBuildOutput, error) {
+	if len(values) > 1 {
+		return nil, errors.New("only one output can be set")
+	}
+	var outputs []types.ImageBuildOutput
+	for _, value := range values {
+		out, err := parseOutput(value)
+		if err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, out)
+	}
+	return outputs, nil
+}
+
+func parseOutput(value string) (types.ImageBuildOutput, error) {
+	out := types.ImageBuildOutput{Attrs: map[string]string{}}
+	if !strings.Contains(value, "=") {
+		out.Type = "local"
+		if value == "-" {
+			out.Type = "tar"
+		}
+		out.Attrs["dest"] = value
+		return out, nil
+	}
+
+	csvReader := csv.NewReader(strings.NewReader(value))
+	fields, err := csvReader.Read()
+	if err != nil {
+		return out, errors.Wrap(err, "failed to parse csv output")
+	}
+	for _, field := range fields {
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 {
+			return out, errors.Errorf("invalid field '%s' must be a key=value pair", field)
+		}
+		key := strings.ToLower(parts[0])
+		switch key {
+		case "type":
+			out.Type = parts[1]
+		default:
+			out.Attrs[key] = parts[1]
+		}
+	}
+	switch out.Type {
+	case "local", "tar":
+	case "":
+		return out, errors.New("type is required for output")
+	default:
+		return out, errors.Errorf("unsupported output type %q: must be local or tar", out.Type)
+	}
+	if out.Attrs["dest"] == "" {
+		return out, errors.Errorf("dest is required for %s output", out.Type)
+	}
+	return out, nil
+}
+
+// parseSSHSpecs returns the SSH agents exposed to the build, and a function
+// stopping the agents serving the keys protected by a passphrase
+func parseSSHSpecs(dockerCli command.Cli, sl []string) (session.Attachable, func(), error) {
+	var stops []func()
+	stopAll := func() {
+		for _, stop := range stops {
+			stop()
+		}
+	}
 	configs := make([]sshprovider.AgentConfig, 0, len(sl))
 	for _, v := range sl {
 		c, err := parseSSH(v)
 		if err != nil {
-			return nil, err
+			stopAll()
+			return nil, nil, err
+		}
+		keys, err := loadSSHKeys(dockerCli.In(), dockerCli.Err(), c.Paths)
+		if err != nil {
+			stopAll()
+			return nil, nil, err
+		}
+		if keys != nil {
+			// The provider cannot read keys protected by a passphrase, so
+			// the decrypted keys are served by an agent
+			socket, stop, err := serveSSHKeys(keys)
+			if err != nil {
+				stopAll()
+				return nil, nil, err
+			}
+			stops = append(stops, stop)
+			c.Paths = []string{socket}
 		}
 		configs = append(configs, *c)
 	}
-	return sshprovider.NewSSHAgentProvider(configs)
+	sshp, err := sshprovider.NewSSHAgentProvider(configs)
+	if err != nil {
+		stopAll()
+		return nil, nil, err
+	}
+	return sshp, stopAll, nil
 }
 
 func parseSSH(value string) (*sshprovider.AgentConfig, error) {
@@ -439,3 +690,14 @@ func parseSSH(value string) (*sshprovider.AgentConfig, error) {
 	}
 	return &cfg, nil
 }
+
+// progressWriter returns the writer on which the progress of a build is
+// displayed for the output stream out. The progress is written to stdout
+// itself if out is the terminal of stdout, so that it is displayed
+// interactively.
+func progressWriter(out *command.OutStream) io.Writer {
+	if out.IsTerminal() && out.FD() == os.Stdout.Fd() {
+		return os.Stdout
+	}
+	return out
+}
@@ -0,0 +1,280 @@
+package image
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/yuyangjack/dockercli/cli/command"
+	"github.com/yuyangjack/moby/api/types"
+	apiclient "github.com/yuyangjack/moby/client"
+	"github.com/yuyangjack/moby/pkg/jsonmessage"
+	"github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+)
+
+const (
+	// buildCacheIndexFile is the file of a local build cache directory that
+	// records the reference of the cache image
+	buildCacheIndexFile = "index.json"
+	// buildCacheArchiveFile is the `docker save` archive of the cache image
+	// in a local build cache directory
+	buildCacheArchiveFile = "cache.tar"
+	// inlineCacheBuildArg makes BuildKit write the cache metadata in the
+	// config of the image it builds
+	inlineCacheBuildArg = "BUILDKIT_INLINE_CACHE"
+)
+
+// cacheFromAttrs is the attribute required by each type of --cache-from
+var cacheFromAttrs = map[string]string{
+	"registry": "ref",
+	"local":    "src",
+}
+
+// cacheToAttrs is the attribute required by each type of --cache-to
+var cacheToAttrs = map[string]string{
+	"inline":   "",
+	"registry": "ref",
+	"local":    "dest",
+}
+
+// cacheSpec is a source or a destination of the build cache
+type cacheSpec struct {
+	typ   string
+	attrs map[string]string
+}
+
+// buildCacheIndex is the content of the index file of a local build cache
+// directory
+type buildCacheIndex struct {
+	Ref string `json:"ref"`
+}
+
+// joinCacheFields joins the key=value fields of a cache specification that
+// the comma separated --cache-from flag splits, such as "type=local" and
+// "src=dir", back into a single value
+func joinCacheFields(values []string) []string {
+	var joined []string
+	for _, value := range values {
+		n := len(joined)
+		if n > 0 && strings.Contains(value, "=") && !strings.HasPrefix(strings.ToLower(value), "type=") && strings.Contains(joined[n-1], "=") {
+			joined[n-1] += "," + value
+			continue
+		}
+		joined = append(joined, value)
+	}
+	return joined
+}
+
+// parseCacheSpecs parses the values of --cache-from or --cache-to, either an
+// image or a type=<type>,<key>=<value> specification, where required is the
+// attribute required by each supported type
+func parseCacheSpecs(values []string, required map[string]string) ([]cacheSpec, error) {
+	var specs []cacheSpec
+	for _, value := range values {
+		spec, err := parseCacheSpec(value)
+		if err != nil {
+			return nil, err
+		}
+		attr, ok := required[spec.typ]
+		if !ok {
+			return nil, errors.Errorf("unsupported cache type %q", spec.typ)
+		}
+		if attr != "" && spec.attrs[attr] == "" {
+			return nil, errors.Errorf("%s is required for %s cache", attr, spec.typ)
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+func parseCacheSpec(value string) (cacheSpec, error) {
+	spec := cacheSpec{attrs: map[string]string{}}
+	if !strings.Contains(value, "=") {
+		spec.typ = "registry"
+		spec.attrs["ref"] = value
+		return spec, nil
+	}
+
+	csvReader := csv.NewReader(strings.NewReader(value))
+	fields, err := csvReader.Read()
+	if err != nil {
+		return spec, errors.Wrap(err, "failed to parse csv cache")
+	}
+	for _, field := range fields {
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 {
+			return spec, errors.Errorf("invalid field '%s' must be a key=value pair", field)
+		}
+		key := strings.ToLower(parts[0])
+		if key == "type" {
+			spec.typ = parts[1]
+		} else {
+			spec.attrs[key] = parts[1]
+		}
+	}
+	if spec.typ == "" {
+		return spec, errors.Errorf("type is required for cache %q", value)
+	}
+	return spec, nil
+}
+
+// cacheFromRefs returns the images of registry caches, for builds that do
+// not support other types of caches
+func cacheFromRefs(specs []cacheSpec) ([]string, error) {
+	var refs []string
+	for _, spec := range specs {
+		if spec.typ != "registry" {
+			return nil, errors.Errorf("%s cache is only supported with BuildKit: set DOCKER_BUILDKIT=1 to enable it", spec.typ)
+		}
+		refs = append(refs, spec.attrs["ref"])
+	}
+	return refs, nil
+}
+
+// importBuildCache loads the images of local caches in the daemon, and
+// returns the images that the build uses as cache sources, and the ones that
+// were loaded, which must be removed with removeBuildCache once the build
+// is done
+func importBuildCache(ctx context.Context, dockerCli command.Cli, specs []cacheSpec) (refs []string, loaded []string, err error) {
+	for _, spec := range specs {
+		if spec.typ == "registry" {
+			refs = append(refs, spec.attrs["ref"])
+			continue
+		}
+		src := spec.attrs["src"]
+		var index buildCacheIndex
+		if err := readJSONFile(filepath.Join(src, buildCacheIndexFile), &index); err != nil {
+			if os.IsNotExist(err) {
+				// The first build exports the cache that the next ones import
+				fmt.Fprintf(dockerCli.Err(), "WARNING: no build cache found in %s\n", src)
+				continue
+			}
+			return nil, loaded, errors.Wrapf(err, "invalid build cache in %s", src)
+		}
+		if err := loadBuildCache(ctx, dockerCli, filepath.Join(src, buildCacheArchiveFile)); err != nil {
+			return nil, loaded, errors.Wrapf(err, "failed to import build cache from %s", src)
+		}
+		refs = append(refs, index.Ref)
+		loaded = append(loaded, index.Ref)
+	}
+	return refs, loaded, nil
+}
+
+// removeBuildCache removes the tags of the cache images loaded by
+// importBuildCache. Tags that were already removed, such as the one of a
+// local cache that is also exported, are skipped.
+func removeBuildCache(ctx context.Context, dockerCli command.Cli, refs []string) {
+	for _, ref := range refs {
+		removeBuildCacheImage(ctx, dockerCli, ref)
+	}
+}
+
+// removeBuildCacheImage removes the tag of a cache image, which is only
+// tagged to be loaded or saved: the tag must not be left behind
+func removeBuildCacheImage(ctx context.Context, dockerCli command.Cli, ref string) {
+	_, err := dockerCli.Client().ImageRemove(ctx, ref, types.ImageRemoveOptions{})
+	if err != nil && !apiclient.IsErrNotFound(err) {
+		fmt.Fprintf(dockerCli.Err(), "WARNING: failed to remove build cache image %s: %s\n", ref, err)
+	}
+}
+
+func loadBuildCache(ctx context.Context, dockerCli command.Cli, archive string) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	response, err := dockerCli.Client().ImageLoad(ctx, f, true)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if !response.JSON {
+		_, err = ioutil.ReadAll(response.Body)
+		return err
+	}
+	return jsonmessage.DisplayJSONMessagesStream(response.Body, ioutil.Discard, 0, false, nil)
+}
+
+// setCacheExports sets the options of a build that exports its cache to
+// specs: BuildKit writes the cache metadata in the image it builds, which is
+// tagged for each registry or local cache
+func setCacheExports(buildOptions *types.ImageBuildOptions, specs []cacheSpec) error {
+	if len(specs) == 0 {
+		return nil
+	}
+	if buildOptions.BuildArgs == nil {
+		buildOptions.BuildArgs = make(map[string]*string)
+	}
+	inline := "1"
+	buildOptions.BuildArgs[inlineCacheBuildArg] = &inline
+	for _, spec := range specs {
+		switch spec.typ {
+		case "registry":
+			buildOptions.Tags = append(buildOptions.Tags, spec.attrs["ref"])
+		case "local":
+			ref, err := localBuildCacheRef(spec.attrs["dest"])
+			if err != nil {
+				return err
+			}
+			buildOptions.Tags = append(buildOptions.Tags, ref)
+		}
+	}
+	return nil
+}
+
+// exportBuildCache pushes the cache images of registry caches, and saves the
+// cache images of local caches, once the build succeeded. The progress of the
+// pushes is discarded if quiet is set, so that the output of a quiet build is
+// only the image ID.
+func exportBuildCache(ctx context.Context, dockerCli command.Cli, specs []cacheSpec, quiet bool) error {
+	for _, spec := range specs {
+		var err error
+		switch spec.typ {
+		case "registry":
+			err = RunPush(dockerCli, pushOptions{remote: spec.attrs["ref"], untrusted: true, quiet: quiet})
+		case "local":
+			err = saveBuildCache(ctx, dockerCli, spec.attrs["dest"])
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to export %s build cache", spec.typ)
+		}
+	}
+	return nil
+}
+
+func saveBuildCache(ctx context.Context, dockerCli command.Cli, dest string) error {
+	ref, err := localBuildCacheRef(dest)
+	if err != nil {
+		return err
+	}
+	defer removeBuildCacheImage(ctx, dockerCli, ref)
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return err
+	}
+	responseBody, err := dockerCli.Client().ImageSave(ctx, []string{ref})
+	if err != nil {
+		return err
+	}
+	defer responseBody.Close()
+	if err := command.CopyToFile(filepath.Join(dest, buildCacheArchiveFile), responseBody); err != nil {
+		return err
+	}
+	return writeJSONFile(filepath.Join(dest, buildCacheIndexFile), buildCacheIndex{Ref: ref})
+}
+
+// localBuildCacheRef returns the reference of the image of the local build
+// cache in dir, which is tagged in the daemon until it is saved to dir
+func localBuildCacheRef(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	return "docker-build-cache:" + digest.FromString(abs).Hex()[:12], nil
+}
@@ -0,0 +1,192 @@
+package image
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yuyangjack/dockercli/internal/test"
+	"github.com/yuyangjack/moby/api/types"
+	"github.com/google/go-cmp/cmp"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	"gotest.tools/fs"
+)
+
+func TestJoinCacheFields(t *testing.T) {
+	// --cache-from user/app:cache,type=local,src=dir,type=registry,ref=user/app:ci
+	values := []string{"user/app:cache", "type=local", "src=dir", "type=registry", "ref=user/app:ci"}
+	assert.Check(t, is.DeepEqual(
+		[]string{"user/app:cache", "type=local,src=dir", "type=registry,ref=user/app:ci"},
+		joinCacheFields(values),
+	))
+}
+
+func TestParseCacheSpecs(t *testing.T) {
+	testCases := []struct {
+		value         string
+		required      map[string]string
+		expected      cacheSpec
+		expectedError string
+	}{
+		{
+			value:    "user/app:cache",
+			required: cacheFromAttrs,
+			expected: cacheSpec{typ: "registry", attrs: map[string]string{"ref": "user/app:cache"}},
+		},
+		{
+			value:    "type=local,src=/cache",
+			required: cacheFromAttrs,
+			expected: cacheSpec{typ: "local", attrs: map[string]string{"src": "/cache"}},
+		},
+		{
+			value:    "type=inline",
+			required: cacheToAttrs,
+			expected: cacheSpec{typ: "inline", attrs: map[string]string{}},
+		},
+		{
+			value:         "type=inline",
+			required:      cacheFromAttrs,
+			expectedError: `unsupported cache type "inline"`,
+		},
+		{
+			value:         "type=local,src=/cache",
+			required:      cacheToAttrs,
+			expectedError: "dest is required for local cache",
+		},
+		{
+			value:         "type=registry",
+			required:      cacheToAttrs,
+			expectedError: "ref is required for registry cache",
+		},
+		{
+			value:         "src=/cache",
+			required:      cacheFromAttrs,
+			expectedError: `type is required for cache "src=/cache"`,
+		},
+		{
+			value:         "type=local,/cache",
+			required:      cacheFromAttrs,
+			expectedError: "invalid field '/cache' must be a key=value pair",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			specs, err := parseCacheSpecs([]string{tc.value}, tc.required)
+			if tc.expectedError != "" {
+				assert.Error(t, err, tc.expectedError)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Check(t, is.DeepEqual([]cacheSpec{tc.expected}, specs, cmp.AllowUnexported(cacheSpec{})))
+		})
+	}
+}
+
+func TestSetCacheExports(t *testing.T) {
+	buildOptions := types.ImageBuildOptions{Tags: []string{"app"}}
+	specs := []cacheSpec{
+		{typ: "inline", attrs: map[string]string{}},
+		{typ: "registry", attrs: map[string]string{"ref": "user/app:cache"}},
+		{typ: "local", attrs: map[string]string{"dest": "/cache"}},
+	}
+	assert.NilError(t, setCacheExports(&buildOptions, specs))
+	localRef, err := localBuildCacheRef("/cache")
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual([]string{"app", "user/app:cache", localRef}, buildOptions.Tags))
+	assert.Check(t, is.Equal("1", *buildOptions.BuildArgs[inlineCacheBuildArg]))
+}
+
+func TestLocalBuildCache(t *testing.T) {
+	dir := fs.NewDir(t, "build-cache")
+	defer dir.Remove()
+	cacheDir := filepath.Join(dir.Path(), "cache")
+	ref, err := localBuildCacheRef(cacheDir)
+	assert.NilError(t, err)
+
+	var (
+		archive []byte
+		removed []string
+	)
+	cli := test.NewFakeCli(&fakeClient{
+		imageSaveFunc: func(images []string) (io.ReadCloser, error) {
+			assert.Check(t, is.DeepEqual([]string{ref}, images))
+			return ioutil.NopCloser(strings.NewReader("cache archive")), nil
+		},
+		imageRemoveFunc: func(image string, options types.ImageRemoveOptions) ([]types.ImageDeleteResponseItem, error) {
+			if image == "docker-build-cache:removed" {
+				return nil, notFound{image}
+			}
+			removed = append(removed, image)
+			return []types.ImageDeleteResponseItem{{Untagged: image}}, nil
+		},
+		imageLoadFunc: func(input io.Reader, quiet bool) (types.ImageLoadResponse, error) {
+			archive, err = ioutil.ReadAll(input)
+			assert.Check(t, err)
+			return types.ImageLoadResponse{Body: ioutil.NopCloser(&bytes.Buffer{})}, nil
+		},
+	})
+	ctx := context.Background()
+	local := []cacheSpec{{typ: "local", attrs: map[string]string{"src": cacheDir}}}
+
+	// Nothing is imported before the cache is exported for the first time
+	refs, loaded, err := importBuildCache(ctx, cli, local)
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(refs, 0))
+	assert.Check(t, is.Len(loaded, 0))
+	assert.Check(t, is.Contains(cli.ErrBuffer().String(), "WARNING: no build cache found in "+cacheDir))
+
+	assert.NilError(t, exportBuildCache(ctx, cli, []cacheSpec{{typ: "local", attrs: map[string]string{"dest": cacheDir}}}, false))
+	// The tag of the cache image is removed once the image is saved
+	assert.Check(t, is.DeepEqual([]string{ref}, removed))
+	refs, loaded, err = importBuildCache(ctx, cli, append(local, cacheSpec{typ: "registry", attrs: map[string]string{"ref": "user/app:cache"}}))
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual([]string{ref, "user/app:cache"}, refs))
+	assert.Check(t, is.Equal("cache archive", string(archive)))
+
+	// Only the tag of the loaded cache image is removed after the build;
+	// tags that are already removed are skipped without a warning
+	assert.Check(t, is.DeepEqual([]string{ref}, loaded))
+	removed = nil
+	cli.ErrBuffer().Reset()
+	removeBuildCache(ctx, cli, append(loaded, "docker-build-cache:removed"))
+	assert.Check(t, is.DeepEqual([]string{ref}, removed))
+	assert.Check(t, is.Equal("", cli.ErrBuffer().String()))
+}
+
+func TestExportRegistryBuildCache(t *testing.T) {
+	var pushed []string
+	cli := test.NewFakeCli(&fakeClient{
+		imagePushFunc: func(ref string, options types.ImagePushOptions) (io.ReadCloser, error) {
+			pushed = append(pushed, ref)
+			return ioutil.NopCloser(strings.NewReader(`{"status":"Pushed"}` + "\n")), nil
+		},
+	})
+	registry := []cacheSpec{{typ: "registry", attrs: map[string]string{"ref": "user/app:cache"}}}
+
+	assert.NilError(t, exportBuildCache(context.Background(), cli, registry, false))
+	assert.Check(t, is.Equal("Pushed\n", cli.OutBuffer().String()))
+
+	// The output of a quiet build is only the image ID
+	cli.OutBuffer().Reset()
+	assert.NilError(t, exportBuildCache(context.Background(), cli, registry, true))
+	assert.Check(t, is.Equal("", cli.OutBuffer().String()))
+	assert.Check(t, is.DeepEqual([]string{"user/app:cache", "user/app:cache"}, pushed))
+}
+
+func TestRunBuildCacheWithoutBuildKit(t *testing.T) {
+	cli := test.NewFakeCli(&fakeClient{})
+	options := newBuildOptions()
+	options.context = "."
+	options.cacheFrom = []string{"type=local", "src=/cache"}
+	assert.ErrorContains(t, runBuild(cli, options), "local cache is only supported with BuildKit")
+
+	options = newBuildOptions()
+	options.context = "."
+	options.cacheTo = []string{"type=inline"}
+	assert.ErrorContains(t, runBuild(cli, options), "--cache-to is only supported with BuildKit")
+}
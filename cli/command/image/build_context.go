@@ -0,0 +1,121 @@
+package image
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/yuyangjack/dockercli/cli/command"
+	"github.com/yuyangjack/dockercli/cli/command/image/build"
+	"github.com/yuyangjack/moby/pkg/archive"
+	units "github.com/docker/go-units"
+	"github.com/pkg/errors"
+)
+
+// largestDirectoriesCount is the number of directories listed by
+// --print-context as the largest directories of the build context
+const largestDirectoriesCount = 10
+
+// contextDirectory is a directory of a build context, with the size of the
+// files it contains
+type contextDirectory struct {
+	path  string
+	size  int64
+	files int
+}
+
+// runPrintContext prints the files of the build context of a local context
+// directory, the largest directories, and the paths excluded by the ignore
+// file, using the same rules as a build
+func runPrintContext(dockerCli command.Cli, options buildOptions) error {
+	if options.contextFromStdin() || !isLocalDir(options.context) {
+		return errors.New("--print-context is only supported with a local context directory")
+	}
+	contextDir, relDockerfile, err := build.GetContextFromLocalDir(options.context, options.dockerfileName)
+	if err != nil {
+		return errors.Errorf("unable to prepare context: %s", err)
+	}
+	excludes, ignoreFile, err := build.ReadBuildDockerignore(contextDir, contextDockerfile(contextDir, relDockerfile, options))
+	if err != nil {
+		return err
+	}
+	relDockerfile = archive.CanonicalTarNameForPath(relDockerfile)
+	excludes = build.TrimBuildFilesFromExcludes(excludes, relDockerfile, options.dockerfileFromStdin())
+
+	content, err := build.InspectContextDirectory(contextDir, excludes)
+	if err != nil {
+		return errors.Errorf("error checking context: '%s'.", err)
+	}
+	printContext(dockerCli.Out(), contextDir, ignoreFile, content)
+	return nil
+}
+
+func printContext(out io.Writer, contextDir, ignoreFile string, content build.ContextContent) {
+	if ignoreFile == "" {
+		ignoreFile = "none"
+	}
+	fmt.Fprintf(out, "Context:\t%s\nIgnore file:\t%s\n", contextDir, ignoreFile)
+
+	var total int64
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "\nSIZE\tFILE")
+	for _, f := range content.Files {
+		total += f.Size
+		fmt.Fprintf(w, "%s\t%s\n", units.HumanSize(float64(f.Size)), f.Path)
+	}
+
+	if dirs := largestDirectories(content.Files, largestDirectoriesCount); len(dirs) > 0 {
+		fmt.Fprintln(w, "\nSIZE\tFILES\tLARGEST DIRECTORIES")
+		for _, dir := range dirs {
+			fmt.Fprintf(w, "%s\t%d\t%s/\n", units.HumanSize(float64(dir.size)), dir.files, dir.path)
+		}
+	}
+
+	if len(content.Excluded) > 0 {
+		fmt.Fprintln(w, "\nRULE\tEXCLUDED PATH")
+		for _, p := range content.Excluded {
+			excluded := p.Path
+			if p.IsDir {
+				excluded += "/"
+			}
+			fmt.Fprintf(w, "%s\t%s\n", p.Rule, excluded)
+		}
+	}
+	w.Flush()
+
+	fmt.Fprintf(out, "\n%d files, %s sent to the daemon, %d paths excluded\n", len(content.Files), units.HumanSize(float64(total)), len(content.Excluded))
+}
+
+// largestDirectories returns the n directories of a build context with the
+// largest size, counting the files of their subdirectories
+func largestDirectories(files []build.ContextFile, n int) []contextDirectory {
+	byPath := make(map[string]*contextDirectory)
+	for _, f := range files {
+		for dir := path.Dir(f.Path); dir != "." && dir != "/"; dir = path.Dir(dir) {
+			d, ok := byPath[dir]
+			if !ok {
+				d = &contextDirectory{path: dir}
+				byPath[dir] = d
+			}
+			d.size += f.Size
+			d.files++
+		}
+	}
+
+	dirs := make([]contextDirectory, 0, len(byPath))
+	for _, d := range byPath {
+		dirs = append(dirs, *d)
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		if dirs[i].size != dirs[j].size {
+			return dirs[i].size > dirs[j].size
+		}
+		return dirs[i].path < dirs[j].path
+	})
+	if len(dirs) > n {
+		dirs = dirs[:n]
+	}
+	return dirs
+}
@@ -0,0 +1,63 @@
+package image
+
+import (
+	"testing"
+
+	"github.com/yuyangjack/dockercli/cli/command/image/build"
+	"github.com/yuyangjack/dockercli/internal/test"
+	"github.com/google/go-cmp/cmp"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	"gotest.tools/fs"
+)
+
+func TestRunPrintContext(t *testing.T) {
+	dir := fs.NewDir(t, "build-print-context",
+		fs.WithFile(".dockerignore", "*.md\n"),
+		fs.WithFile("Dockerfile", "FROM busybox\n"),
+		fs.WithFile("Dockerfile.docs", "FROM busybox\n"),
+		fs.WithFile("Dockerfile.docs.dockerignore", "src\n"),
+		fs.WithFile("README.md", "readme"),
+		fs.WithDir("src", fs.WithFile("main.go", "package main")),
+	)
+	defer dir.Remove()
+
+	cli := test.NewFakeCli(&fakeClient{})
+	options := newBuildOptions()
+	options.context = dir.Path()
+	options.printContext = true
+	assert.NilError(t, runBuild(cli, options))
+	out := cli.OutBuffer().String()
+	assert.Check(t, is.Contains(out, "Ignore file:\t"+dir.Join(".dockerignore")))
+	assert.Check(t, is.Contains(out, "src/main.go"))
+	assert.Check(t, is.Contains(out, "*.md   README.md\n"))
+	assert.Check(t, is.Contains(out, "5 files, 47B sent to the daemon, 1 paths excluded\n"))
+
+	cli = test.NewFakeCli(&fakeClient{})
+	options.dockerfileName = dir.Join("Dockerfile.docs")
+	assert.NilError(t, runBuild(cli, options))
+	out = cli.OutBuffer().String()
+	assert.Check(t, is.Contains(out, "Ignore file:\t"+dir.Join("Dockerfile.docs.dockerignore")))
+	assert.Check(t, is.Contains(out, "src    src/\n"))
+}
+
+func TestRunPrintContextRemoteContext(t *testing.T) {
+	cli := test.NewFakeCli(&fakeClient{})
+	options := newBuildOptions()
+	options.context = "github.com/docker/no-such-repository"
+	options.printContext = true
+	assert.ErrorContains(t, runBuild(cli, options), "--print-context is only supported with a local context directory")
+}
+
+func TestLargestDirectories(t *testing.T) {
+	files := []build.ContextFile{
+		{Path: "Dockerfile", Size: 10},
+		{Path: "src/main.go", Size: 100},
+		{Path: "src/cmd/cli.go", Size: 200},
+		{Path: "docs/index.md", Size: 50},
+	}
+	assert.Check(t, is.DeepEqual([]contextDirectory{
+		{path: "src", size: 300, files: 2},
+		{path: "src/cmd", size: 200, files: 1},
+	}, largestDirectories(files, 2), cmp.AllowUnexported(contextDirectory{})))
+}
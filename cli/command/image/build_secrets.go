@@ -0,0 +1,240 @@
+package image
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/yuyangjack/dockercli/cli/command"
+	"github.com/yuyangjack/distribution/reference"
+	"github.com/yuyangjack/moby/registry"
+	"github.com/moby/buildkit/session/secrets"
+	"github.com/moby/buildkit/session/secrets/secretsprovider"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// secretSource is a secret of a build read from a file, or from an
+// environment variable if Env is set
+type secretSource struct {
+	ID       string
+	FilePath string
+	Env      string
+}
+
+// registrySecret is a secret of a build read from the credentials of a
+// registry in the credentials store
+type registrySecret struct {
+	ID       string
+	Registry string
+	Field    string
+}
+
+// secretStore is the store of the secrets of a build. The secrets of
+// environment variables and registries are read when the store is created,
+// and the secret files when the build uses them.
+type secretStore struct {
+	files  secrets.SecretStore
+	values map[string][]byte
+}
+
+func newSecretStore(dockerCli command.Cli, sources []secretSource, fromRegistries []registrySecret) (*secretStore, error) {
+	var files []secretsprovider.FileSource
+	values := make(map[string][]byte)
+	ids := make(map[string]bool)
+	addID := func(id string) error {
+		if id == "" {
+			return errors.New("secret missing ID")
+		}
+		if ids[id] {
+			return errors.Errorf("duplicate secret %s", id)
+		}
+		ids[id] = true
+		return nil
+	}
+
+	for _, source := range sources {
+		if err := addID(source.ID); err != nil {
+			return nil, err
+		}
+		if source.Env == "" {
+			files = append(files, secretsprovider.FileSource{ID: source.ID, FilePath: source.FilePath})
+			continue
+		}
+		value, ok := os.LookupEnv(source.Env)
+		if !ok {
+			return nil, errors.Errorf("environment variable %s of secret %s is not set", source.Env, source.ID)
+		}
+		values[source.ID] = []byte(value)
+	}
+
+	for _, rs := range fromRegistries {
+		if err := addID(rs.ID); err != nil {
+			return nil, err
+		}
+		value, err := registrySecretValue(dockerCli, rs)
+		if err != nil {
+			return nil, err
+		}
+		values[rs.ID] = value
+	}
+
+	fileStore, err := secretsprovider.NewFileStore(files)
+	if err != nil {
+		return nil, err
+	}
+	return &secretStore{files: fileStore, values: values}, nil
+}
+
+// GetSecret returns the value of the secret id
+func (s *secretStore) GetSecret(ctx context.Context, id string) ([]byte, error) {
+	if value, ok := s.values[id]; ok {
+		return value, nil
+	}
+	return s.files.GetSecret(ctx, id)
+}
+
+// registrySecretValue returns the field of the credentials of the registry
+// of a secret, which are read from the credentials store or helper
+// configured for the registry
+func registrySecretValue(dockerCli command.Cli, rs registrySecret) ([]byte, error) {
+	// Resolve the registry as push and pull do, so that docker.io or an
+	// address with a scheme match the key of the stored credentials
+	ref, err := reference.ParseNormalizedNamed(registry.ConvertToHostname(rs.Registry) + "/secret")
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid registry %s for secret %s", rs.Registry, rs.ID)
+	}
+	repoInfo, err := registry.ParseRepositoryInfo(ref)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid registry %s for secret %s", rs.Registry, rs.ID)
+	}
+	authConfig := command.ResolveAuthConfig(context.Background(), dockerCli, repoInfo.Index)
+	if authConfig.Username == "" && authConfig.Password == "" && authConfig.IdentityToken == "" {
+		return nil, errors.Errorf("no credentials for %s for secret %s: run docker login %s", rs.Registry, rs.ID, rs.Registry)
+	}
+
+	switch rs.Field {
+	case "username":
+		return []byte(authConfig.Username), nil
+	case "auth":
+		// An identity token is not a password, and cannot be encoded with the
+		// username as basic authentication
+		if authConfig.Password == "" {
+			return nil, errors.Errorf("no password for %s for secret %s: the credentials of an identity token cannot be used with field=auth", rs.Registry, rs.ID)
+		}
+		return []byte(base64.StdEncoding.EncodeToString([]byte(authConfig.Username + ":" + authConfig.Password))), nil
+	default:
+		if authConfig.Password == "" {
+			return []byte(authConfig.IdentityToken), nil
+		}
+		return []byte(authConfig.Password), nil
+	}
+}
+
+// loadSSHKeys returns the private keys at paths if one of them is protected
+// by a passphrase, prompting for the passphrases, or nil if the keys can be
+// read by the SSH provider as they are
+func loadSSHKeys(in *command.InStream, out io.Writer, paths []string) ([]interface{}, error) {
+	var (
+		keys      []interface{}
+		encrypted bool
+		socket    string
+	)
+	for _, p := range paths {
+		fi, err := os.Stat(p)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to stat %s", p)
+		}
+		if fi.Mode()&os.ModeSocket != 0 {
+			// Agent sockets are forwarded by the SSH provider
+			socket = p
+			continue
+		}
+		dt, err := ioutil.ReadFile(p)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read %s", p)
+		}
+
+		key, err := ssh.ParseRawPrivateKey(dt)
+		if _, ok := err.(*ssh.PassphraseMissingError); ok {
+			encrypted = true
+			passphrase, err := readPassphrase(in, out, p)
+			if err != nil {
+				return nil, err
+			}
+			key, err = ssh.ParseRawPrivateKeyWithPassphrase(dt, passphrase)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to decrypt %s", p)
+			}
+		} else if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse %s", p)
+		}
+		keys = append(keys, key)
+	}
+	if !encrypted {
+		return nil, nil
+	}
+	if socket != "" {
+		return nil, errors.Errorf("cannot combine the SSH agent socket %s with keys protected by a passphrase", socket)
+	}
+	return keys, nil
+}
+
+func readPassphrase(in *command.InStream, out io.Writer, path string) ([]byte, error) {
+	if !in.IsTerminal() {
+		return nil, errors.Errorf("cannot read the passphrase of %s: stdin is not a terminal", path)
+	}
+	fmt.Fprintf(out, "Enter passphrase for %s: ", path)
+	passphrase, err := terminal.ReadPassword(int(in.FD()))
+	fmt.Fprintln(out)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read the passphrase of %s", path)
+	}
+	return passphrase, nil
+}
+
+// serveSSHKeys serves an SSH agent holding keys on a socket in a temporary
+// directory, and returns the path of the socket and a function stopping the
+// agent
+func serveSSHKeys(keys []interface{}) (string, func(), error) {
+	keyring := agent.NewKeyring()
+	for _, key := range keys {
+		if err := keyring.Add(agent.AddedKey{PrivateKey: key}); err != nil {
+			return "", nil, err
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "docker-build-ssh")
+	if err != nil {
+		return "", nil, err
+	}
+	socket := filepath.Join(dir, "agent.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", nil, err
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				agent.ServeAgent(keyring, conn)
+			}()
+		}
+	}()
+	return socket, func() {
+		l.Close()
+		os.RemoveAll(dir)
+	}, nil
+}
@@ -0,0 +1,240 @@
+package image
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/yuyangjack/dockercli/cli/command"
+	"github.com/yuyangjack/dockercli/cli/config/configfile"
+	"github.com/yuyangjack/dockercli/internal/test"
+	"github.com/yuyangjack/moby/api/types"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	"gotest.tools/env"
+	"gotest.tools/fs"
+	"gotest.tools/skip"
+)
+
+func TestParseSecretFrom(t *testing.T) {
+	testCases := []struct {
+		value         string
+		expected      registrySecret
+		expectedError string
+	}{
+		{
+			value:    "id=npm,registry=registry.npmjs.org",
+			expected: registrySecret{ID: "npm", Registry: "registry.npmjs.org", Field: "password"},
+		},
+		{
+			value:    "id=npm-user,registry=registry.npmjs.org,field=username",
+			expected: registrySecret{ID: "npm-user", Registry: "registry.npmjs.org", Field: "username"},
+		},
+		{
+			value:         "registry=registry.npmjs.org",
+			expectedError: `id is required for secret "registry=registry.npmjs.org"`,
+		},
+		{
+			value:         "id=npm",
+			expectedError: "registry is required for secret npm",
+		},
+		{
+			value:         "id=npm,registry=registry.npmjs.org,field=token",
+			expectedError: `unsupported field "token" for secret npm: must be username, password or auth`,
+		},
+		{
+			value:         "id=npm,src=file",
+			expectedError: "unexpected key 'src' in 'src=file'",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			secret, err := parseSecretFrom(tc.value)
+			if tc.expectedError != "" {
+				assert.Error(t, err, tc.expectedError)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Check(t, is.DeepEqual(tc.expected, *secret))
+		})
+	}
+}
+
+func TestSecretStore(t *testing.T) {
+	defer env.Patch(t, "TEST_NPM_TOKEN", "token-from-env")()
+	dir := fs.NewDir(t, "build-secrets", fs.WithFile("secret", "secret-from-file"))
+	defer dir.Remove()
+	cli := test.NewFakeCli(&fakeClient{})
+	cli.SetConfigFile(&configfile.ConfigFile{
+		AuthConfigs: map[string]types.AuthConfig{
+			"registry.example.com": {Username: "user", Password: "pass"},
+		},
+	})
+
+	store, err := newSecretStore(cli,
+		[]secretSource{{ID: "file", FilePath: dir.Join("secret")}, {ID: "npm", Env: "TEST_NPM_TOKEN"}},
+		[]registrySecret{
+			{ID: "password", Registry: "registry.example.com", Field: "password"},
+			{ID: "auth", Registry: "registry.example.com", Field: "auth"},
+		},
+	)
+	assert.NilError(t, err)
+	for id, expected := range map[string]string{
+		"file":     "secret-from-file",
+		"npm":      "token-from-env",
+		"password": "pass",
+		"auth":     "dXNlcjpwYXNz",
+	} {
+		value, err := store.GetSecret(context.Background(), id)
+		assert.Check(t, err)
+		assert.Check(t, is.Equal(expected, string(value)), id)
+	}
+}
+
+func TestSecretStoreResolvesRegistry(t *testing.T) {
+	cli := test.NewFakeCli(&fakeClient{
+		infoFunc: func() (types.Info, error) {
+			return types.Info{IndexServerAddress: "https://index.docker.io/v1/"}, nil
+		},
+	})
+	cli.SetConfigFile(&configfile.ConfigFile{
+		AuthConfigs: map[string]types.AuthConfig{
+			"https://index.docker.io/v1/": {Username: "hubuser", Password: "hubpass"},
+			"npm.example.com":             {Username: "user", Password: "pass"},
+			"token.example.com":           {Username: "<token>", IdentityToken: "token"},
+		},
+	})
+
+	store, err := newSecretStore(cli, nil, []registrySecret{
+		{ID: "hub", Registry: "docker.io", Field: "username"},
+		{ID: "npm", Registry: "https://npm.example.com/", Field: "password"},
+		{ID: "token", Registry: "token.example.com", Field: "password"},
+	})
+	assert.NilError(t, err)
+	for id, expected := range map[string]string{
+		"hub":   "hubuser",
+		"npm":   "pass",
+		"token": "token",
+	} {
+		value, err := store.GetSecret(context.Background(), id)
+		assert.Check(t, err)
+		assert.Check(t, is.Equal(expected, string(value)), id)
+	}
+
+	_, err = newSecretStore(cli, nil, []registrySecret{{ID: "token", Registry: "token.example.com", Field: "auth"}})
+	assert.Check(t, is.Error(err, "no password for token.example.com for secret token: the credentials of an identity token cannot be used with field=auth"))
+}
+
+func TestSecretStoreErrors(t *testing.T) {
+	cli := test.NewFakeCli(&fakeClient{})
+	cli.SetConfigFile(&configfile.ConfigFile{})
+
+	_, err := newSecretStore(cli, []secretSource{{ID: "npm", Env: "TEST_UNSET_NPM_TOKEN"}}, nil)
+	assert.Check(t, is.Error(err, "environment variable TEST_UNSET_NPM_TOKEN of secret npm is not set"))
+
+	_, err = newSecretStore(cli, nil, []registrySecret{{ID: "npm", Registry: "registry.npmjs.org", Field: "password"}})
+	assert.Check(t, is.Error(err, "no credentials for registry.npmjs.org for secret npm: run docker login registry.npmjs.org"))
+
+	defer env.Patch(t, "TEST_NPM_TOKEN", "token")()
+	_, err = newSecretStore(cli, []secretSource{{ID: "npm", Env: "TEST_NPM_TOKEN"}, {ID: "npm", Env: "TEST_NPM_TOKEN"}}, nil)
+	assert.Check(t, is.Error(err, "duplicate secret npm"))
+}
+
+func TestLoadSSHKeys(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	assert.NilError(t, err)
+	block := &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}
+	encrypted, err := x509.EncryptPEMBlock(rand.Reader, block.Type, block.Bytes, []byte("passphrase"), x509.PEMCipherAES256)
+	assert.NilError(t, err)
+	dir := fs.NewDir(t, "build-ssh",
+		fs.WithFile("id_rsa", string(pem.EncodeToMemory(block))),
+		fs.WithFile("id_rsa_encrypted", string(pem.EncodeToMemory(encrypted))),
+	)
+	defer dir.Remove()
+	in := command.NewInStream(ioutil.NopCloser(strings.NewReader("")))
+
+	// Keys without a passphrase are read by the SSH provider
+	keys, err := loadSSHKeys(in, ioutil.Discard, []string{dir.Join("id_rsa")})
+	assert.NilError(t, err)
+	assert.Check(t, is.Nil(keys))
+
+	_, err = loadSSHKeys(in, ioutil.Discard, []string{dir.Join("id_rsa"), dir.Join("id_rsa_encrypted")})
+	assert.Check(t, is.ErrorContains(err, "cannot read the passphrase of "+dir.Join("id_rsa_encrypted")+": stdin is not a terminal"))
+
+	// Keys in the OpenSSH format are detected as encrypted too
+	keys, err = loadSSHKeys(in, ioutil.Discard, []string{filepath.Join("testdata", "ssh", "id_ed25519")})
+	assert.NilError(t, err)
+	assert.Check(t, is.Nil(keys))
+	openSSHKey := filepath.Join("testdata", "ssh", "id_ed25519_encrypted")
+	_, err = loadSSHKeys(in, ioutil.Discard, []string{openSSHKey})
+	assert.Check(t, is.ErrorContains(err, "cannot read the passphrase of "+openSSHKey+": stdin is not a terminal"))
+}
+
+func TestLoadSSHKeysSkipsSockets(t *testing.T) {
+	skip.If(t, runtime.GOOS == "windows", "unix sockets are not supported")
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	assert.NilError(t, err)
+	dir := fs.NewDir(t, "build-ssh",
+		fs.WithFile("id_rsa", string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))),
+	)
+	defer dir.Remove()
+	l, err := net.Listen("unix", dir.Join("agent.sock"))
+	assert.NilError(t, err)
+	defer l.Close()
+	in := command.NewInStream(ioutil.NopCloser(strings.NewReader("")))
+
+	keys, err := loadSSHKeys(in, ioutil.Discard, []string{dir.Join("agent.sock"), dir.Join("id_rsa")})
+	assert.NilError(t, err)
+	assert.Check(t, is.Nil(keys))
+}
+
+func TestParseEncryptedOpenSSHKey(t *testing.T) {
+	dt, err := ioutil.ReadFile(filepath.Join("testdata", "ssh", "id_ed25519_encrypted"))
+	assert.NilError(t, err)
+	pub, err := ioutil.ReadFile(filepath.Join("testdata", "ssh", "id_ed25519_encrypted.pub"))
+	assert.NilError(t, err)
+	expected, _, _, _, err := ssh.ParseAuthorizedKey(pub)
+	assert.NilError(t, err)
+
+	_, err = ssh.ParseRawPrivateKey(dt)
+	assert.Check(t, is.ErrorType(err, &ssh.PassphraseMissingError{}))
+
+	key, err := ssh.ParseRawPrivateKeyWithPassphrase(dt, []byte("passphrase"))
+	assert.NilError(t, err)
+	signer, err := ssh.NewSignerFromKey(key)
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(expected.Marshal(), signer.PublicKey().Marshal()))
+
+	_, err = ssh.ParseRawPrivateKeyWithPassphrase(dt, []byte("wrong"))
+	assert.Check(t, is.Error(err, x509.IncorrectPasswordError.Error()))
+}
+
+func TestServeSSHKeys(t *testing.T) {
+	skip.If(t, runtime.GOOS == "windows", "unix sockets are not supported")
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	assert.NilError(t, err)
+	socket, stop, err := serveSSHKeys([]interface{}{key})
+	assert.NilError(t, err)
+
+	conn, err := net.Dial("unix", socket)
+	assert.NilError(t, err)
+	keys, err := agent.NewClient(conn).List()
+	conn.Close()
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(keys, 1))
+
+	stop()
+	_, err = os.Stat(socket)
+	assert.Check(t, os.IsNotExist(err))
+}
@@ -49,8 +49,8 @@ func trySession(dockerCli command.Cli, contextDir string, forStream bool) (*sess
 	return s, nil
 }
 
-func addDirToSession(session *session.Session, contextDir string, progressOutput progress.Output, done chan error) error {
-	excludes, err := build.ReadDockerignore(contextDir)
+func addDirToSession(session *session.Session, contextDir, dockerfile string, progressOutput progress.Output, done chan error) error {
+	excludes, _, err := build.ReadBuildDockerignore(contextDir, dockerfile)
 	if err != nil {
 		return err
 	}
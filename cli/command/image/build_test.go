@@ -18,7 +18,6 @@ import (
 	"github.com/yuyangjack/moby/api/types"
 	"github.com/yuyangjack/moby/pkg/archive"
 	"github.com/google/go-cmp/cmp"
-	"github.com/moby/buildkit/session/secrets/secretsprovider"
 	"gotest.tools/assert"
 	"gotest.tools/fs"
 	"gotest.tools/skip"
@@ -180,7 +179,7 @@ func TestParseSecret(t *testing.T) {
 		value       string
 		errExpected bool
 		errMatch    string
-		filesource  *secretsprovider.FileSource
+		filesource  *secretSource
 	}
 	var testcases = []testcase{
 		{
@@ -200,26 +199,36 @@ func TestParseSecret(t *testing.T) {
 			errMatch:    "unexpected key",
 		}, {
 			value:      "src=somefile",
-			filesource: &secretsprovider.FileSource{FilePath: "somefile"},
+			filesource: &secretSource{FilePath: "somefile"},
 		}, {
 			value:      "source=somefile",
-			filesource: &secretsprovider.FileSource{FilePath: "somefile"},
+			filesource: &secretSource{FilePath: "somefile"},
 		}, {
 			value:      "id=mysecret",
-			filesource: &secretsprovider.FileSource{ID: "mysecret"},
+			filesource: &secretSource{ID: "mysecret"},
 		}, {
 			value:      "id=mysecret,src=somefile",
-			filesource: &secretsprovider.FileSource{ID: "mysecret", FilePath: "somefile"},
+			filesource: &secretSource{ID: "mysecret", FilePath: "somefile"},
 		}, {
 			value:      "id=mysecret,source=somefile,type=file",
-			filesource: &secretsprovider.FileSource{ID: "mysecret", FilePath: "somefile"},
+			filesource: &secretSource{ID: "mysecret", FilePath: "somefile"},
 		}, {
 			value:      "id=mysecret,src=somefile,src=othersecretfile",
-			filesource: &secretsprovider.FileSource{ID: "mysecret", FilePath: "othersecretfile"},
+			filesource: &secretSource{ID: "mysecret", FilePath: "othersecretfile"},
 		}, {
 			value:       "type=invalid",
 			errExpected: true,
 			errMatch:    "unsupported secret type",
+		}, {
+			value:      "id=npm,env=NPM_TOKEN",
+			filesource: &secretSource{ID: "npm", Env: "NPM_TOKEN"},
+		}, {
+			value:      "id=NPM_TOKEN,type=env",
+			filesource: &secretSource{ID: "NPM_TOKEN", Env: "NPM_TOKEN"},
+		}, {
+			value:       "id=npm,src=somefile,env=NPM_TOKEN",
+			errExpected: true,
+			errMatch:    "cannot have both a source file and an environment variable",
 		},
 	}
 
@@ -235,6 +244,84 @@ func TestParseSecret(t *testing.T) {
 	}
 }
 
+func TestParseOutputs(t *testing.T) {
+	testCases := []struct {
+		values        []string
+		expected      []types.ImageBuildOutput
+		expectedError string
+	}{
+		{
+			values:   []string{"out"},
+			expected: []types.ImageBuildOutput{{Type: "local", Attrs: map[string]string{"dest": "out"}}},
+		},
+		{
+			values:   []string{"-"},
+			expected: []types.ImageBuildOutput{{Type: "tar", Attrs: map[string]string{"dest": "-"}}},
+		},
+		{
+			values:   []string{"type=tar,dest=out.tar"},
+			expected: []types.ImageBuildOutput{{Type: "tar", Attrs: map[string]string{"dest": "out.tar"}}},
+		},
+		{
+			values:   []string{"TYPE=local,Dest=out"},
+			expected: []types.ImageBuildOutput{{Type: "local", Attrs: map[string]string{"dest": "out"}}},
+		},
+		{
+			values:        []string{"out", "out.tar"},
+			expectedError: "only one output can be set",
+		},
+		{
+			values:        []string{"dest=out"},
+			expectedError: "type is required for output",
+		},
+		{
+			values:        []string{"type=image,name=app"},
+			expectedError: `unsupported output type "image": must be local or tar`,
+		},
+		{
+			values:        []string{"type=local"},
+			expectedError: "dest is required for local output",
+		},
+		{
+			values:        []string{"type=tar,out.tar"},
+			expectedError: "invalid field 'out.tar' must be a key=value pair",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprint(tc.values), func(t *testing.T) {
+			outputs, err := parseOutputs(tc.values)
+			if tc.expectedError != "" {
+				assert.Error(t, err, tc.expectedError)
+				return
+			}
+			assert.NilError(t, err)
+			assert.DeepEqual(t, tc.expected, outputs)
+		})
+	}
+}
+
+func TestRunBuildOutputWithoutBuildKit(t *testing.T) {
+	cli := test.NewFakeCli(&fakeClient{})
+	options := newBuildOptions()
+	options.context = "."
+	options.outputs = []string{"type=local,dest=out"}
+	assert.ErrorContains(t, runBuild(cli, options), "--output is only supported with BuildKit")
+}
+
+func TestBuildGitOptions(t *testing.T) {
+	options := newBuildOptions()
+	assert.Check(t, !options.gitOptionsSet())
+	assert.Check(t, options.gitOptions().CacheDir == "", "the git cache must be opt-in")
+
+	options.gitCache = true
+	assert.Check(t, options.gitOptionsSet())
+	assert.Check(t, options.gitOptions().CacheDir != "")
+
+	options = newBuildOptions()
+	options.git.Depth = 0
+	assert.Check(t, options.gitOptionsSet())
+}
+
 type fakeBuild struct {
 	context *tar.Reader
 	options types.ImageBuildOptions
@@ -17,12 +17,19 @@ func NewImageCommand(dockerCli command.Cli) *cobra.Command {
 	}
 	cmd.AddCommand(
 		NewBuildCommand(dockerCli),
+		NewCopyCommand(dockerCli),
+		NewDiffCommand(dockerCli),
+		NewExploreCommand(dockerCli),
 		NewHistoryCommand(dockerCli),
 		NewImportCommand(dockerCli),
 		NewLoadCommand(dockerCli),
+		NewMutateCommand(dockerCli),
 		NewPullCommand(dockerCli),
 		NewPushCommand(dockerCli),
+		NewRebaseCommand(dockerCli),
 		NewSaveCommand(dockerCli),
+		NewSbomCommand(dockerCli),
+		NewSquashCommand(dockerCli),
 		NewTagCommand(dockerCli),
 		newListCommand(dockerCli),
 		newRemoveCommand(dockerCli),
@@ -0,0 +1,171 @@
+package image
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/yuyangjack/dockercli/cli"
+	"github.com/yuyangjack/dockercli/cli/command"
+	registryclient "github.com/yuyangjack/dockercli/cli/registry/client"
+	"github.com/yuyangjack/distribution"
+	"github.com/yuyangjack/distribution/manifest/manifestlist"
+	"github.com/yuyangjack/distribution/manifest/ocischema"
+	"github.com/yuyangjack/distribution/manifest/schema2"
+	"github.com/yuyangjack/distribution/reference"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+type copyOptions struct {
+	source       string
+	target       string
+	allPlatforms bool
+	platform     string
+	insecure     bool
+}
+
+// NewCopyCommand creates a new `docker image copy` command
+func NewCopyCommand(dockerCli command.Cli) *cobra.Command {
+	var opts copyOptions
+
+	cmd := &cobra.Command{
+		Use:   "copy [OPTIONS] SOURCE_IMAGE[:TAG|@DIGEST] TARGET_IMAGE[:TAG]",
+		Short: "Copy an image from one registry to another without pulling it",
+		Args:  cli.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.source = args[0]
+			opts.target = args[1]
+			return runCopy(dockerCli, opts)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.BoolVar(&opts.allPlatforms, "all-platforms", false, "Copy the manifest list and the images for all platforms")
+	flags.StringVar(&opts.platform, "platform", "", "Platform to copy when the source is a manifest list (os[/arch[/variant]], default linux on the client architecture)")
+	flags.BoolVar(&opts.insecure, "insecure", false, "Allow communication with an insecure registry")
+	return cmd
+}
+
+func runCopy(dockerCli command.Cli, opts copyOptions) error {
+	if opts.allPlatforms && opts.platform != "" {
+		return errors.New("conflicting options: --all-platforms and --platform")
+	}
+	source, err := normalizeRegistryReference(opts.source)
+	if err != nil {
+		return err
+	}
+	target, err := normalizeRegistryReference(opts.target)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	platform, err := resolvePlatform(opts.platform)
+	if err != nil {
+		return err
+	}
+
+	client := dockerCli.RegistryClient(opts.insecure)
+	mf, err := client.GetDistributionManifest(ctx, source)
+	if err != nil {
+		return err
+	}
+
+	if list, ok := mf.(*manifestlist.DeserializedManifestList); ok && !opts.allPlatforms {
+		desc, err := selectManifest(list, platform)
+		if err != nil {
+			return errors.Wrapf(err, "%s", source)
+		}
+		childRef, err := reference.WithDigest(reference.TrimNamed(source), desc.Digest)
+		if err != nil {
+			return err
+		}
+		if mf, err = client.GetDistributionManifest(ctx, childRef); err != nil {
+			return err
+		}
+	}
+
+	dgst, err := copyManifest(ctx, dockerCli, client, source, target, mf)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(dockerCli.Out(), "%s@%s\n", reference.FamiliarName(target), dgst)
+	return nil
+}
+
+func normalizeRegistryReference(ref string) (reference.Named, error) {
+	namedRef, err := reference.ParseNormalizedNamed(ref)
+	if err != nil {
+		return nil, err
+	}
+	return reference.TagNameOnly(namedRef), nil
+}
+
+// copyManifest copies the blobs referenced by mf and then mf itself from
+// source to target. For a manifest list, every image in the list is copied
+// by digest before the list is pushed.
+func copyManifest(ctx context.Context, dockerCli command.Cli, client registryclient.RegistryClient, source, target reference.Named, mf distribution.Manifest) (digest.Digest, error) {
+	switch m := mf.(type) {
+	case *schema2.DeserializedManifest, *ocischema.DeserializedManifest:
+		for _, desc := range m.References() {
+			if isForeignLayer(desc.MediaType) {
+				continue
+			}
+			if err := client.CopyBlob(ctx, source, target, desc); err != nil {
+				return "", err
+			}
+		}
+	case *manifestlist.DeserializedManifestList:
+		for _, desc := range m.Manifests {
+			childSource, err := reference.WithDigest(reference.TrimNamed(source), desc.Digest)
+			if err != nil {
+				return "", err
+			}
+			childTarget, err := reference.WithDigest(reference.TrimNamed(target), desc.Digest)
+			if err != nil {
+				return "", err
+			}
+			child, err := client.GetDistributionManifest(ctx, childSource)
+			if err != nil {
+				return "", err
+			}
+			switch child.(type) {
+			case *schema2.DeserializedManifest, *ocischema.DeserializedManifest:
+			default:
+				return "", errors.Errorf("unsupported manifest format in %s: %s", childSource, desc.MediaType)
+			}
+			if _, err := copyManifest(ctx, dockerCli, client, childSource, childTarget, child); err != nil {
+				return "", err
+			}
+		}
+	default:
+		return "", errors.Errorf("unsupported manifest format for %s", source)
+	}
+
+	_, payload, err := mf.Payload()
+	if err != nil {
+		return "", err
+	}
+	expected := digest.FromBytes(payload)
+	dgst, err := client.PutManifest(ctx, target, mf)
+	if err != nil {
+		return "", err
+	}
+	if dgst != expected {
+		fmt.Fprintf(dockerCli.Err(), "Warning: registry stored %s as %s, expected %s\n", target, dgst, expected)
+	}
+	return expected, nil
+}
+
+// isForeignLayer reports whether a layer is not distributed through
+// registries, such as Windows base layers.
+func isForeignLayer(mediaType string) bool {
+	switch mediaType {
+	case schema2.MediaTypeForeignLayer,
+		ocispec.MediaTypeImageLayerNonDistributable,
+		ocispec.MediaTypeImageLayerNonDistributableGzip:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,179 @@
+package image
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/yuyangjack/dockercli/internal/test"
+	"github.com/yuyangjack/distribution"
+	"github.com/yuyangjack/distribution/manifest/manifestlist"
+	"github.com/yuyangjack/distribution/manifest/schema2"
+	"github.com/yuyangjack/distribution/reference"
+	"github.com/opencontainers/go-digest"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func newTestSchema2Manifest(t *testing.T, arch string) *schema2.DeserializedManifest {
+	mf, err := schema2.FromStruct(schema2.Manifest{
+		Versioned: schema2.SchemaVersion,
+		Config: distribution.Descriptor{
+			MediaType: schema2.MediaTypeImageConfig,
+			Digest:    digest.FromString("config-" + arch),
+			Size:      10,
+		},
+		Layers: []distribution.Descriptor{
+			{MediaType: schema2.MediaTypeLayer, Digest: digest.FromString("layer-" + arch), Size: 20},
+			{MediaType: schema2.MediaTypeForeignLayer, Digest: digest.FromString("foreign-" + arch), Size: 30},
+		},
+	})
+	assert.NilError(t, err)
+	return mf
+}
+
+func TestNewCopyCommandErrors(t *testing.T) {
+	testCases := []struct {
+		name          string
+		args          []string
+		expectedError string
+	}{
+		{
+			name:          "wrong-args",
+			args:          []string{"image1"},
+			expectedError: "requires exactly 2 arguments",
+		},
+		{
+			name:          "conflicting-platform",
+			args:          []string{"--all-platforms", "--platform", "linux/amd64", "image1", "image2"},
+			expectedError: "conflicting options: --all-platforms and --platform",
+		},
+		{
+			name:          "invalid-platform",
+			args:          []string{"--platform", "linux/amd64/v8/extra", "image1", "image2"},
+			expectedError: "invalid platform",
+		},
+	}
+	for _, tc := range testCases {
+		cli := test.NewFakeCli(&fakeClient{})
+		cli.SetRegistryClient(&fakeRegistryClient{})
+		cmd := NewCopyCommand(cli)
+		cmd.SetOutput(ioutil.Discard)
+		cmd.SetArgs(tc.args)
+		assert.ErrorContains(t, cmd.Execute(), tc.expectedError)
+	}
+}
+
+func TestCopyManifest(t *testing.T) {
+	mf := newTestSchema2Manifest(t, "amd64")
+	_, payload, err := mf.Payload()
+	assert.NilError(t, err)
+
+	var copied []digest.Digest
+	var pushed string
+	cli := test.NewFakeCli(&fakeClient{})
+	cli.SetRegistryClient(&fakeRegistryClient{
+		getDistributionManifestFunc: func(_ context.Context, ref reference.Named) (distribution.Manifest, error) {
+			assert.Check(t, is.Equal("docker.io/library/source:latest", ref.String()))
+			return mf, nil
+		},
+		copyBlobFunc: func(_ context.Context, source, target reference.Named, desc distribution.Descriptor) error {
+			assert.Check(t, is.Equal("docker.io/library/source:latest", source.String()))
+			assert.Check(t, is.Equal("example.com/target:v1", target.String()))
+			copied = append(copied, desc.Digest)
+			return nil
+		},
+		putManifestFunc: func(_ context.Context, ref reference.Named, _ distribution.Manifest) (digest.Digest, error) {
+			pushed = ref.String()
+			return digest.FromBytes(payload), nil
+		},
+	})
+	cmd := NewCopyCommand(cli)
+	cmd.SetArgs([]string{"source", "example.com/target:v1"})
+	assert.NilError(t, cmd.Execute())
+
+	assert.Check(t, is.DeepEqual([]digest.Digest{digest.FromString("config-amd64"), digest.FromString("layer-amd64")}, copied))
+	assert.Check(t, is.Equal("example.com/target:v1", pushed))
+	assert.Check(t, is.Equal("example.com/target@"+digest.FromBytes(payload).String()+"\n", cli.OutBuffer().String()))
+	assert.Check(t, is.Equal("", cli.ErrBuffer().String()))
+}
+
+func TestCopyManifestListAllPlatforms(t *testing.T) {
+	amd64 := newTestSchema2Manifest(t, "amd64")
+	arm64 := newTestSchema2Manifest(t, "arm64")
+	children := map[digest.Digest]distribution.Manifest{}
+	var descriptors []manifestlist.ManifestDescriptor
+	for arch, mf := range map[string]*schema2.DeserializedManifest{"amd64": amd64, "arm64": arm64} {
+		mediaType, payload, err := mf.Payload()
+		assert.NilError(t, err)
+		dgst := digest.FromBytes(payload)
+		children[dgst] = mf
+		descriptors = append(descriptors, manifestlist.ManifestDescriptor{
+			Descriptor: distribution.Descriptor{MediaType: mediaType, Digest: dgst, Size: int64(len(payload))},
+			Platform:   manifestlist.PlatformSpec{OS: "linux", Architecture: arch},
+		})
+	}
+	list, err := manifestlist.FromDescriptors(descriptors)
+	assert.NilError(t, err)
+
+	var pushed []string
+	cli := test.NewFakeCli(&fakeClient{})
+	cli.SetRegistryClient(&fakeRegistryClient{
+		getDistributionManifestFunc: func(_ context.Context, ref reference.Named) (distribution.Manifest, error) {
+			if canonical, ok := ref.(reference.Canonical); ok {
+				return children[canonical.Digest()], nil
+			}
+			return list, nil
+		},
+		putManifestFunc: func(_ context.Context, ref reference.Named, mf distribution.Manifest) (digest.Digest, error) {
+			pushed = append(pushed, ref.String())
+			_, payload, err := mf.Payload()
+			return digest.FromBytes(payload), err
+		},
+	})
+	cmd := NewCopyCommand(cli)
+	cmd.SetArgs([]string{"--all-platforms", "source", "target"})
+	assert.NilError(t, cmd.Execute())
+
+	assert.Assert(t, is.Len(pushed, 3))
+	for i, desc := range list.Manifests {
+		assert.Check(t, is.Equal("docker.io/library/target@"+desc.Digest.String(), pushed[i]))
+	}
+	assert.Check(t, is.Equal("docker.io/library/target:latest", pushed[2]))
+}
+
+func TestCopyManifestListPlatform(t *testing.T) {
+	arm64 := newTestSchema2Manifest(t, "arm64")
+	mediaType, payload, err := arm64.Payload()
+	assert.NilError(t, err)
+	list, err := manifestlist.FromDescriptors([]manifestlist.ManifestDescriptor{
+		{
+			Descriptor: distribution.Descriptor{MediaType: mediaType, Digest: digest.FromBytes(payload), Size: int64(len(payload))},
+			Platform:   manifestlist.PlatformSpec{OS: "linux", Architecture: "arm64", Variant: "v8"},
+		},
+	})
+	assert.NilError(t, err)
+
+	cli := test.NewFakeCli(&fakeClient{})
+	cli.SetRegistryClient(&fakeRegistryClient{
+		getDistributionManifestFunc: func(_ context.Context, ref reference.Named) (distribution.Manifest, error) {
+			if _, ok := ref.(reference.Canonical); ok {
+				return arm64, nil
+			}
+			return list, nil
+		},
+		putManifestFunc: func(_ context.Context, ref reference.Named, mf distribution.Manifest) (digest.Digest, error) {
+			assert.Check(t, is.Equal("docker.io/library/target:latest", ref.String()))
+			assert.Check(t, is.Equal(arm64, mf))
+			return digest.FromBytes(payload), nil
+		},
+	})
+	cmd := NewCopyCommand(cli)
+	cmd.SetArgs([]string{"--platform", "linux/arm64", "source", "target"})
+	assert.NilError(t, cmd.Execute())
+
+	cmd = NewCopyCommand(cli)
+	cmd.SetOutput(ioutil.Discard)
+	cmd.SetArgs([]string{"--platform", "linux/s390x", "source", "target"})
+	assert.ErrorContains(t, cmd.Execute(), "no manifest found for platform linux/s390x")
+}
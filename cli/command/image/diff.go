@@ -0,0 +1,325 @@
+package image
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/yuyangjack/dockercli/cli"
+	"github.com/yuyangjack/dockercli/cli/command"
+	units "github.com/docker/go-units"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+const (
+	fileAdded   = "added"
+	fileRemoved = "removed"
+	fileChanged = "changed"
+)
+
+type diffOptions struct {
+	source   string
+	target   string
+	format   string
+	remote   bool
+	platform string
+	insecure bool
+}
+
+// NewDiffCommand creates a new `docker image diff` command
+func NewDiffCommand(dockerCli command.Cli) *cobra.Command {
+	var opts diffOptions
+
+	cmd := &cobra.Command{
+		Use:   "diff [OPTIONS] IMAGE IMAGE",
+		Short: "Show the differences in layers, config and files between two images",
+		Args:  cli.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.source, opts.target = args[0], args[1]
+			return runDiff(dockerCli, opts)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&opts.format, "format", "", "Output the differences in the given format (\"json\")")
+	flags.BoolVar(&opts.remote, "remote", false, "Read the images from the registry instead of the daemon")
+	flags.StringVar(&opts.platform, "platform", "", "Platform of the images to compare with --remote (os[/arch[/variant]], default linux on the client architecture)")
+	flags.BoolVar(&opts.insecure, "insecure", false, "Allow communication with an insecure registry with --remote")
+
+	return cmd
+}
+
+// configChange is a difference in the config of two images
+type configChange struct {
+	Field string
+	Key   string `json:",omitempty"`
+	Old   string `json:",omitempty"`
+	New   string `json:",omitempty"`
+}
+
+// fileChange is a difference in the filesystem of two images
+type fileChange struct {
+	Path    string
+	Type    string
+	OldSize int64  `json:",omitempty"`
+	NewSize int64  `json:",omitempty"`
+	OldMode string `json:",omitempty"`
+	NewMode string `json:",omitempty"`
+}
+
+// layerDiff compares the layers of two images by diff ID
+type layerDiff struct {
+	Shared  int
+	Removed []digest.Digest
+	Added   []digest.Digest
+}
+
+// imageDiff is the output of `docker image diff`
+type imageDiff struct {
+	Source string
+	Target string
+	Layers layerDiff
+	Config []configChange
+	Files  []fileChange
+}
+
+func runDiff(dockerCli command.Cli, opts diffOptions) error {
+	if opts.format != "" && opts.format != "json" {
+		return errors.Errorf("invalid format %q: only json is supported", opts.format)
+	}
+	if !opts.remote && (opts.platform != "" || opts.insecure) {
+		return errors.New("--platform and --insecure can only be used with --remote")
+	}
+
+	ctx := context.Background()
+	indexOpts := indexOptions{hashContent: true}
+	load := func(name string) (*flattenedImage, error) {
+		return loadLocalImage(ctx, dockerCli, name, indexOpts)
+	}
+	if opts.remote {
+		platform, err := resolvePlatform(opts.platform)
+		if err != nil {
+			return err
+		}
+		client := dockerCli.RegistryClient(opts.insecure)
+		load = func(name string) (*flattenedImage, error) {
+			return loadRemoteImage(ctx, client, name, platform, indexOpts)
+		}
+	}
+
+	source, err := load(opts.source)
+	if err != nil {
+		return err
+	}
+	target, err := load(opts.target)
+	if err != nil {
+		return err
+	}
+	diff := imageDiff{
+		Source: opts.source,
+		Target: opts.target,
+		Layers: diffLayers(source.config.RootFS.DiffIDs, target.config.RootFS.DiffIDs),
+		Config: diffConfig(source.config, target.config),
+		Files:  diffFiles(source.files, target.files),
+	}
+
+	if opts.format == "json" {
+		enc := json.NewEncoder(dockerCli.Out())
+		enc.SetIndent("", "    ")
+		return enc.Encode(diff)
+	}
+	return printImageDiff(dockerCli.Out(), diff)
+}
+
+// diffLayers compares the diff IDs of two images
+func diffLayers(source, target []digest.Digest) layerDiff {
+	diff := layerDiff{Removed: []digest.Digest{}, Added: []digest.Digest{}}
+	inSource := make(map[digest.Digest]bool)
+	for _, d := range source {
+		inSource[d] = true
+	}
+	inTarget := make(map[digest.Digest]bool)
+	for _, d := range target {
+		inTarget[d] = true
+		if inSource[d] {
+			diff.Shared++
+		} else {
+			diff.Added = append(diff.Added, d)
+		}
+	}
+	for _, d := range source {
+		if !inTarget[d] {
+			diff.Removed = append(diff.Removed, d)
+		}
+	}
+	return diff
+}
+
+// diffConfig compares the environment, command, labels, exposed ports and
+// other runtime settings of two images
+func diffConfig(source, target ocispec.Image) []configChange {
+	changes := []configChange{}
+	diffValue := func(field, before, after string) {
+		if before != after {
+			changes = append(changes, configChange{Field: field, Old: before, New: after})
+		}
+	}
+	diffMap := func(field string, before, after map[string]string) {
+		var keys []string
+		for k := range before {
+			keys = append(keys, k)
+		}
+		for k := range after {
+			if _, ok := before[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if before[k] != after[k] {
+				changes = append(changes, configChange{Field: field, Key: k, Old: before[k], New: after[k]})
+			}
+		}
+	}
+	diffSet := func(field string, before, after map[string]struct{}) {
+		var removed, added []string
+		for k := range before {
+			if _, ok := after[k]; !ok {
+				removed = append(removed, k)
+			}
+		}
+		for k := range after {
+			if _, ok := before[k]; !ok {
+				added = append(added, k)
+			}
+		}
+		sort.Strings(removed)
+		sort.Strings(added)
+		for _, k := range removed {
+			changes = append(changes, configChange{Field: field, Old: k})
+		}
+		for _, k := range added {
+			changes = append(changes, configChange{Field: field, New: k})
+		}
+	}
+
+	diffValue("Architecture", source.Architecture, target.Architecture)
+	diffValue("Os", source.OS, target.OS)
+	diffValue("User", source.Config.User, target.Config.User)
+	diffValue("WorkingDir", source.Config.WorkingDir, target.Config.WorkingDir)
+	diffValue("Entrypoint", formatArgs(source.Config.Entrypoint), formatArgs(target.Config.Entrypoint))
+	diffValue("Cmd", formatArgs(source.Config.Cmd), formatArgs(target.Config.Cmd))
+	diffValue("StopSignal", source.Config.StopSignal, target.Config.StopSignal)
+	diffMap("Env", envMap(source.Config.Env), envMap(target.Config.Env))
+	diffMap("Labels", source.Config.Labels, target.Config.Labels)
+	diffSet("ExposedPorts", source.Config.ExposedPorts, target.Config.ExposedPorts)
+	diffSet("Volumes", source.Config.Volumes, target.Config.Volumes)
+	return changes
+}
+
+func formatArgs(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	out, _ := json.Marshal(args)
+	return string(out)
+}
+
+func envMap(env []string) map[string]string {
+	result := make(map[string]string, len(env))
+	for _, e := range env {
+		kv := strings.SplitN(e, "=", 2)
+		if len(kv) == 1 {
+			kv = append(kv, "")
+		}
+		result[kv[0]] = kv[1]
+	}
+	return result
+}
+
+// diffFiles compares two flattened filesystems. Files are changed if their
+// type, mode, size, content or link target differ.
+func diffFiles(source, target map[string]layerEntry) []fileChange {
+	changes := []fileChange{}
+	for p, before := range source {
+		after, ok := target[p]
+		switch {
+		case !ok:
+			changes = append(changes, fileChange{Path: p, Type: fileRemoved, OldSize: before.size, OldMode: before.mode.String()})
+		case before.mode != after.mode || before.size != after.size || before.digest != after.digest || before.linkname != after.linkname:
+			changes = append(changes, fileChange{
+				Path:    p,
+				Type:    fileChanged,
+				OldSize: before.size,
+				NewSize: after.size,
+				OldMode: before.mode.String(),
+				NewMode: after.mode.String(),
+			})
+		}
+	}
+	for p, after := range target {
+		if _, ok := source[p]; !ok {
+			changes = append(changes, fileChange{Path: p, Type: fileAdded, NewSize: after.size, NewMode: after.mode.String()})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+func printImageDiff(out io.Writer, diff imageDiff) error {
+	fmt.Fprintf(out, "Layers: %d shared, %d removed, %d added\n", diff.Layers.Shared, len(diff.Layers.Removed), len(diff.Layers.Added))
+	for _, d := range diff.Layers.Removed {
+		fmt.Fprintf(out, "  - %s\n", d)
+	}
+	for _, d := range diff.Layers.Added {
+		fmt.Fprintf(out, "  + %s\n", d)
+	}
+
+	fmt.Fprintf(out, "\nConfig: %d changes\n", len(diff.Config))
+	for _, c := range diff.Config {
+		name := c.Field
+		if c.Key != "" {
+			name += " " + c.Key
+		}
+		switch {
+		case c.Old == "":
+			fmt.Fprintf(out, "  + %s: %s\n", name, c.New)
+		case c.New == "":
+			fmt.Fprintf(out, "  - %s: %s\n", name, c.Old)
+		default:
+			fmt.Fprintf(out, "  ~ %s: %s -> %s\n", name, c.Old, c.New)
+		}
+	}
+
+	fmt.Fprintf(out, "\nFiles: %d changes\n", len(diff.Files))
+	if len(diff.Files) == 0 {
+		return nil
+	}
+	w := tabwriter.NewWriter(out, 10, 1, 3, ' ', 0)
+	fmt.Fprintln(w, "  CHANGE\tPATH\tSIZE\tMODE")
+	for _, f := range diff.Files {
+		switch f.Type {
+		case fileAdded:
+			fmt.Fprintf(w, "  +\t%s\t%s\t%s\n", f.Path, units.HumanSize(float64(f.NewSize)), f.NewMode)
+		case fileRemoved:
+			fmt.Fprintf(w, "  -\t%s\t%s\t%s\n", f.Path, units.HumanSize(float64(f.OldSize)), f.OldMode)
+		default:
+			fmt.Fprintf(w, "  ~\t%s\t%s\t%s\n", f.Path, formatChange(units.HumanSize(float64(f.OldSize)), units.HumanSize(float64(f.NewSize))), formatChange(f.OldMode, f.NewMode))
+		}
+	}
+	return w.Flush()
+}
+
+func formatChange(before, after string) string {
+	if before == after {
+		return after
+	}
+	return before + " -> " + after
+}
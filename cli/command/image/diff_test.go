@@ -0,0 +1,162 @@
+package image
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/yuyangjack/dockercli/internal/test"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+// newTestDiffArchive returns a `docker save` archive of an image with the
+// given config and layers
+func newTestDiffArchive(t *testing.T, config ocispec.ImageConfig, layers ...[]byte) []byte {
+	configJSON, err := json.Marshal(config)
+	assert.NilError(t, err)
+	return newTestRawConfigArchive(t, configJSON, layers...)
+}
+
+// newTestRawConfigArchive returns a `docker save` archive of an image with
+// the container config containerConfig
+func newTestRawConfigArchive(t *testing.T, containerConfig json.RawMessage, layers ...[]byte) []byte {
+	image := struct {
+		Architecture string          `json:"architecture"`
+		OS           string          `json:"os"`
+		Config       json.RawMessage `json:"config"`
+		RootFS       ocispec.RootFS  `json:"rootfs"`
+	}{Architecture: "amd64", OS: "linux", Config: containerConfig}
+	manifest := dockerArchiveManifest{Config: "config.json"}
+	var files []testTarFile
+	for i, layer := range layers {
+		image.RootFS.DiffIDs = append(image.RootFS.DiffIDs, digest.FromBytes(layer))
+		name := digest.FromBytes(layer).Hex() + "/layer.tar"
+		manifest.Layers = append(manifest.Layers, name)
+		files = append(files, testTarFile{name: name, content: layers[i]})
+	}
+	configJSON, err := json.Marshal(image)
+	assert.NilError(t, err)
+	manifestJSON, err := json.Marshal([]dockerArchiveManifest{manifest})
+	assert.NilError(t, err)
+	files = append(files,
+		testTarFile{name: "config.json", content: configJSON},
+		testTarFile{name: dockerArchiveManifestFile, content: manifestJSON},
+	)
+	return newTestTar(t, files...)
+}
+
+func TestDiffLocalImages(t *testing.T) {
+	base := newTestTar(t,
+		testTarFile{name: "etc/"},
+		testTarFile{name: "etc/os-release", content: []byte("v1")},
+		testTarFile{name: "lib/libc.so", content: []byte("libc-1")},
+		testTarFile{name: "usr/share/doc/README", content: []byte("docs")},
+	)
+	newBase := newTestTar(t,
+		testTarFile{name: "etc/"},
+		testTarFile{name: "etc/os-release", content: []byte("v2")},
+		testTarFile{name: "lib/libc.so", content: []byte("libc-2.0")},
+		testTarFile{name: "usr/share/doc/README", content: []byte("docs")},
+	)
+	app := newTestTar(t,
+		testTarFile{name: "app/bin", content: []byte("app")},
+		testTarFile{name: "usr/share/.wh.doc"},
+	)
+	images := map[string][]byte{
+		"app:1": newTestDiffArchive(t, ocispec.ImageConfig{
+			Env:          []string{"PATH=/bin", "VERSION=1"},
+			Cmd:          []string{"app"},
+			ExposedPorts: map[string]struct{}{"80/tcp": {}},
+			Labels:       map[string]string{"base": "1"},
+		}, base, app),
+		"app:2": newTestDiffArchive(t, ocispec.ImageConfig{
+			Env:          []string{"PATH=/bin", "VERSION=2"},
+			Cmd:          []string{"app", "--serve"},
+			ExposedPorts: map[string]struct{}{"8080/tcp": {}},
+			Labels:       map[string]string{"base": "1", "maintainer": "team"},
+		}, newBase, app),
+	}
+	cli := test.NewFakeCli(&fakeClient{
+		imageSaveFunc: func(names []string) (io.ReadCloser, error) {
+			assert.Assert(t, is.Len(names, 1))
+			return ioutil.NopCloser(bytes.NewReader(images[names[0]])), nil
+		},
+	})
+	cmd := NewDiffCommand(cli)
+	cmd.SetOutput(ioutil.Discard)
+	cmd.SetArgs([]string{"--format", "json", "app:1", "app:2"})
+	assert.NilError(t, cmd.Execute())
+
+	var diff imageDiff
+	assert.NilError(t, json.Unmarshal(cli.OutBuffer().Bytes(), &diff))
+	assert.Check(t, is.DeepEqual(layerDiff{
+		Shared:  1,
+		Removed: []digest.Digest{digest.FromBytes(base)},
+		Added:   []digest.Digest{digest.FromBytes(newBase)},
+	}, diff.Layers))
+	assert.Check(t, is.DeepEqual([]configChange{
+		{Field: "Cmd", Old: `["app"]`, New: `["app","--serve"]`},
+		{Field: "Env", Key: "VERSION", Old: "1", New: "2"},
+		{Field: "Labels", Key: "maintainer", New: "team"},
+		{Field: "ExposedPorts", Old: "80/tcp"},
+		{Field: "ExposedPorts", New: "8080/tcp"},
+	}, diff.Config))
+	// The documentation is removed by the app layer of both images
+	assert.Check(t, is.DeepEqual([]fileChange{
+		{Path: "/etc/os-release", Type: fileChanged, OldSize: 2, NewSize: 2, OldMode: "-rw-r--r--", NewMode: "-rw-r--r--"},
+		{Path: "/lib/libc.so", Type: fileChanged, OldSize: 6, NewSize: 8, OldMode: "-rw-r--r--", NewMode: "-rw-r--r--"},
+	}, diff.Files))
+}
+
+func TestDiffErrors(t *testing.T) {
+	testCases := []struct {
+		args          []string
+		expectedError string
+	}{
+		{
+			args:          []string{"app:1"},
+			expectedError: "requires exactly 2 arguments",
+		},
+		{
+			args:          []string{"--format", "table", "app:1", "app:2"},
+			expectedError: "invalid format \"table\": only json is supported",
+		},
+		{
+			args:          []string{"--platform", "linux/arm64", "app:1", "app:2"},
+			expectedError: "--platform and --insecure can only be used with --remote",
+		},
+	}
+	for _, tc := range testCases {
+		cmd := NewDiffCommand(test.NewFakeCli(&fakeClient{}))
+		cmd.SetOutput(ioutil.Discard)
+		cmd.SetArgs(tc.args)
+		assert.ErrorContains(t, cmd.Execute(), tc.expectedError)
+	}
+}
+
+func TestFlattenLayers(t *testing.T) {
+	files := flattenLayers([][]layerEntry{
+		{
+			{path: "/etc", dir: true},
+			{path: "/etc/a", size: 1},
+			{path: "/var/cache/x", size: 2},
+		},
+		{
+			{path: "/etc/.wh..wh..opq"},
+			{path: "/etc/b", size: 3},
+			{path: "/var/.wh.cache"},
+		},
+	})
+	var paths []string
+	for p := range files {
+		paths = append(paths, p)
+	}
+	assert.Check(t, is.Len(paths, 2))
+	assert.Check(t, is.Contains(files, "/etc"))
+	assert.Check(t, is.Contains(files, "/etc/b"))
+}
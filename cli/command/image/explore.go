@@ -0,0 +1,218 @@
+package image
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/yuyangjack/dockercli/cli"
+	"github.com/yuyangjack/dockercli/cli/command"
+	"github.com/yuyangjack/dockercli/cli/command/formatter"
+	units "github.com/docker/go-units"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// maxWastedFiles is the number of wasted files shown in the summary
+const maxWastedFiles = 10
+
+type exploreOptions struct {
+	image         string
+	format        string
+	minEfficiency float64
+}
+
+// NewExploreCommand creates a new `docker image explore` command
+func NewExploreCommand(dockerCli command.Cli) *cobra.Command {
+	var opts exploreOptions
+
+	cmd := &cobra.Command{
+		Use:   "explore [OPTIONS] IMAGE",
+		Short: "Explore the files added, modified and removed by each layer of an image",
+		Args:  cli.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.image = args[0]
+			return runExplore(dockerCli, opts)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&opts.format, "format", "", "Output the report in the given format (\"json\")")
+	flags.Float64Var(&opts.minEfficiency, "min-efficiency", 0, "Fail if the efficiency of the image is below this ratio (0-1)")
+
+	return cmd
+}
+
+func runExplore(dockerCli command.Cli, opts exploreOptions) error {
+	if opts.format != "" && opts.format != "json" {
+		return errors.Errorf("invalid format %q: only json is supported", opts.format)
+	}
+	if opts.minEfficiency < 0 || opts.minEfficiency > 1 {
+		return errors.Errorf("invalid minimum efficiency %v: must be between 0 and 1", opts.minEfficiency)
+	}
+
+	responseBody, err := dockerCli.Client().ImageSave(context.Background(), []string{opts.image})
+	if err != nil {
+		return err
+	}
+	defer responseBody.Close()
+
+	index, err := indexImageArchive(responseBody, indexOptions{})
+	if err != nil {
+		return err
+	}
+	report, err := analyzeImage(opts.image, index)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case opts.format == "json":
+		enc := json.NewEncoder(dockerCli.Out())
+		enc.SetIndent("", "    ")
+		err = enc.Encode(report)
+	case dockerCli.In().IsTerminal() && dockerCli.Out().IsTerminal():
+		err = exploreInteractive(dockerCli, report)
+	default:
+		err = printExploreSummary(dockerCli.Out(), report)
+	}
+	if err != nil {
+		return err
+	}
+
+	if report.Efficiency < opts.minEfficiency {
+		return errors.Errorf("image efficiency %.2f%% is below the minimum of %.2f%%", report.Efficiency*100, opts.minEfficiency*100)
+	}
+	return nil
+}
+
+// printExploreSummary prints the size, wasted space and efficiency of the
+// image, its layers, and the files wasting the most space
+func printExploreSummary(out io.Writer, report exploreReport) error {
+	w := tabwriter.NewWriter(out, 10, 1, 3, ' ', 0)
+	fmt.Fprintf(w, "Image:\t%s\n", report.Image)
+	fmt.Fprintf(w, "Size:\t%s\n", units.HumanSize(float64(report.Size)))
+	fmt.Fprintf(w, "Wasted space:\t%s\n", units.HumanSize(float64(report.WastedSize)))
+	fmt.Fprintf(w, "Efficiency:\t%.2f%%\n", report.Efficiency*100)
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(out)
+	w = tabwriter.NewWriter(out, 10, 1, 3, ' ', 0)
+	fmt.Fprintln(w, "LAYER\tSIZE\tWASTED\tCHANGES\tCREATED BY")
+	for _, layer := range report.Layers {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%s\n", layer.Index, units.HumanSize(float64(layer.Size)), units.HumanSize(float64(layer.WastedSize)), len(layer.Changes), truncateCreatedBy(layer.CreatedBy))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(report.WastedFiles) == 0 {
+		return nil
+	}
+	fmt.Fprintln(out)
+	w = tabwriter.NewWriter(out, 10, 1, 3, ' ', 0)
+	fmt.Fprintln(w, "WASTED\tCOUNT\tPATH")
+	for i, f := range report.WastedFiles {
+		if i == maxWastedFiles {
+			break
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\n", units.HumanSize(float64(f.WastedSize)), f.Occurrences, f.Path)
+	}
+	return w.Flush()
+}
+
+func truncateCreatedBy(createdBy string) string {
+	createdBy = strings.TrimPrefix(strings.Join(strings.Fields(createdBy), " "), "/bin/sh -c #(nop) ")
+	return formatter.Ellipsis(createdBy, 60)
+}
+
+// exploreInteractive prints the summary of the image, then prompts for the
+// layer to show the tree of changes of
+func exploreInteractive(dockerCli command.Cli, report exploreReport) error {
+	out := dockerCli.Out()
+	if err := printExploreSummary(out, report); err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(dockerCli.In())
+	for {
+		fmt.Fprintf(out, "\nLayer to explore [0-%d], 'w' for all wasted files, 'q' to quit: ", len(report.Layers)-1)
+		if !scanner.Scan() {
+			fmt.Fprintln(out)
+			return scanner.Err()
+		}
+		input := strings.TrimSpace(scanner.Text())
+		switch input {
+		case "":
+			continue
+		case "q", "quit":
+			return nil
+		case "w":
+			printWastedFiles(out, report.WastedFiles)
+			continue
+		}
+		n, err := strconv.Atoi(input)
+		if err != nil || n < 0 || n >= len(report.Layers) {
+			fmt.Fprintf(out, "Invalid layer %q\n", input)
+			continue
+		}
+		layer := report.Layers[n]
+		fmt.Fprintf(out, "\nLayer %d: %s\n", layer.Index, layer.CreatedBy)
+		printChangeTree(out, layer.Changes)
+	}
+}
+
+func printWastedFiles(out io.Writer, files []wastedFile) {
+	if len(files) == 0 {
+		fmt.Fprintln(out, "No wasted space")
+		return
+	}
+	w := tabwriter.NewWriter(out, 10, 1, 3, ' ', 0)
+	fmt.Fprintln(w, "WASTED\tCOUNT\tPATH")
+	for _, f := range files {
+		fmt.Fprintf(w, "%s\t%d\t%s\n", units.HumanSize(float64(f.WastedSize)), f.Occurrences, f.Path)
+	}
+	w.Flush()
+}
+
+// printChangeTree prints changes, sorted by path, as a tree of directories.
+// Added files are marked with "+", modified files with "~" and removed files
+// with "-".
+func printChangeTree(out io.Writer, changes []layerChange) {
+	if len(changes) == 0 {
+		fmt.Fprintln(out, "No changes")
+		return
+	}
+	var printed []string
+	for _, change := range changes {
+		dirs := strings.Split(strings.Trim(path.Dir(change.Path), "/"), "/")
+		if dirs[0] == "" {
+			dirs = nil
+		}
+		common := 0
+		for common < len(dirs) && common < len(printed) && dirs[common] == printed[common] {
+			common++
+		}
+		for depth := common; depth < len(dirs); depth++ {
+			fmt.Fprintf(out, "%s%s/\n", strings.Repeat("  ", depth), dirs[depth])
+		}
+		printed = dirs
+
+		indent := strings.Repeat("  ", len(dirs))
+		switch change.Type {
+		case changeAdded:
+			fmt.Fprintf(out, "%s+ %s (%s)\n", indent, path.Base(change.Path), units.HumanSize(float64(change.Size)))
+		case changeModified:
+			fmt.Fprintf(out, "%s~ %s (%s)\n", indent, path.Base(change.Path), units.HumanSize(float64(change.Size)))
+		case changeRemoved:
+			fmt.Fprintf(out, "%s- %s\n", indent, path.Base(change.Path))
+		}
+	}
+}
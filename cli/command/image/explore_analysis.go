@@ -0,0 +1,309 @@
+package image
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+const (
+	changeAdded    = "added"
+	changeModified = "modified"
+	changeRemoved  = "removed"
+
+	whiteoutPrefix = ".wh."
+	whiteoutOpaque = ".wh..wh..opq"
+)
+
+// layerEntry is a file in a layer tar
+type layerEntry struct {
+	path     string
+	size     int64
+	dir      bool
+	mode     os.FileMode
+	linkname string
+	// digest is the digest of the content of regular files, if requested
+	digest digest.Digest
+	// data is the data captured from the content of regular files
+	data []byte
+}
+
+// indexOptions configures what is kept of the content of the files of
+// indexed layers
+type indexOptions struct {
+	// hashContent digests the content of regular files
+	hashContent bool
+	// capture returns the data to keep from the content of a regular file,
+	// or nil
+	capture func(name string, hdr *tar.Header, r io.Reader) ([]byte, error)
+}
+
+// imageArchiveIndex is the content of a `docker save` archive, with the
+// entries of each layer instead of their content
+type imageArchiveIndex struct {
+	manifest []dockerArchiveManifest
+	configs  map[string][]byte
+	layers   map[string][]layerEntry
+	links    map[string]string
+}
+
+// indexImageArchive reads a `docker save` archive, indexing the tar entries
+// of every layer as they are streamed
+func indexImageArchive(r io.Reader, opts indexOptions) (*imageArchiveIndex, error) {
+	index := &imageArchiveIndex{
+		configs: make(map[string][]byte),
+		layers:  make(map[string][]layerEntry),
+		links:   make(map[string]string),
+	}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read image archive")
+		}
+		name := path.Clean(hdr.Name)
+		switch {
+		case path.Base(name) == "layer.tar" && hdr.Typeflag == tar.TypeSymlink:
+			index.links[name] = path.Join(path.Dir(name), hdr.Linkname)
+		case path.Base(name) == "layer.tar":
+			entries, err := indexLayer(tr, opts)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to read layer %s", name)
+			}
+			index.layers[name] = entries
+		case name == dockerArchiveManifestFile:
+			if err := json.NewDecoder(tr).Decode(&index.manifest); err != nil {
+				return nil, errors.Wrap(err, "invalid image archive manifest")
+			}
+		case strings.HasSuffix(name, ".json"):
+			content, err := ioutil.ReadAll(tr)
+			if err != nil {
+				return nil, err
+			}
+			index.configs[name] = content
+		}
+	}
+	if len(index.manifest) == 0 {
+		return nil, errors.New("no image found in image archive")
+	}
+	return index, nil
+}
+
+func indexLayer(r io.Reader, opts indexOptions) ([]layerEntry, error) {
+	var entries []layerEntry
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := path.Clean("/" + hdr.Name)
+		if name == "/" {
+			continue
+		}
+		entry := layerEntry{
+			path:     name,
+			size:     hdr.Size,
+			dir:      hdr.Typeflag == tar.TypeDir,
+			mode:     hdr.FileInfo().Mode(),
+			linkname: hdr.Linkname,
+		}
+		if hdr.Typeflag == tar.TypeReg || hdr.Typeflag == tar.TypeRegA {
+			var content io.Reader = tr
+			digester := digest.Canonical.Digester()
+			if opts.hashContent {
+				content = io.TeeReader(tr, digester.Hash())
+			}
+			if opts.capture != nil {
+				if entry.data, err = opts.capture(name, hdr, content); err != nil {
+					return nil, errors.Wrapf(err, "failed to read %s", name)
+				}
+			}
+			if opts.hashContent {
+				if _, err := io.Copy(ioutil.Discard, content); err != nil {
+					return nil, err
+				}
+				entry.digest = digester.Digest()
+			}
+		}
+		entries = append(entries, entry)
+	}
+}
+
+// layerEntries returns the entries of the layer at name, following links
+// to identical layers
+func (i *imageArchiveIndex) layerEntries(name string) ([]layerEntry, error) {
+	for n := 0; n < 10; n++ {
+		if entries, ok := i.layers[name]; ok {
+			return entries, nil
+		}
+		target, ok := i.links[name]
+		if !ok {
+			break
+		}
+		name = target
+	}
+	return nil, errors.Errorf("layer %s not found in image archive", name)
+}
+
+// layerChange is a file added, modified or removed by a layer
+type layerChange struct {
+	Path string
+	Type string
+	Size int64
+}
+
+// exploredLayer is a layer of an explored image
+type exploredLayer struct {
+	Index      int
+	DiffID     digest.Digest `json:",omitempty"`
+	CreatedBy  string        `json:",omitempty"`
+	Size       int64
+	WastedSize int64
+	Changes    []layerChange
+}
+
+// wastedFile is a path whose content is overwritten or removed by a later
+// layer
+type wastedFile struct {
+	Path        string
+	Occurrences int
+	WastedSize  int64
+}
+
+// exploreReport is the result of exploring an image
+type exploreReport struct {
+	Image       string
+	Size        int64
+	WastedSize  int64
+	Efficiency  float64
+	Layers      []exploredLayer
+	WastedFiles []wastedFile
+}
+
+// fileState is the layer and size of a file visible in the image
+type fileState struct {
+	layer int
+	size  int64
+}
+
+// analyzeImage computes the changes of each layer of the first image in the
+// archive, and the space wasted by files that are overwritten or removed by
+// later layers
+func analyzeImage(ref string, index *imageArchiveIndex) (exploreReport, error) {
+	image := index.manifest[0]
+	report := exploreReport{Image: ref, Layers: make([]exploredLayer, len(image.Layers))}
+
+	var config ocispec.Image
+	if content, ok := index.configs[path.Clean(image.Config)]; ok {
+		if err := json.Unmarshal(content, &config); err != nil {
+			return report, errors.Wrapf(err, "invalid image config %s", image.Config)
+		}
+	}
+	var createdBy []string
+	for _, h := range config.History {
+		if !h.EmptyLayer {
+			createdBy = append(createdBy, h.CreatedBy)
+		}
+	}
+
+	files := make(map[string]fileState)
+	wasted := make(map[string]*wastedFile)
+	waste := func(p string, state fileState) {
+		report.Layers[state.layer].WastedSize += state.size
+		report.WastedSize += state.size
+		if w, ok := wasted[p]; ok {
+			w.WastedSize += state.size
+		} else {
+			wasted[p] = &wastedFile{Path: p, WastedSize: state.size}
+		}
+	}
+	occurrences := make(map[string]int)
+
+	for i, name := range image.Layers {
+		layer := &report.Layers[i]
+		layer.Index = i
+		if i < len(config.RootFS.DiffIDs) {
+			layer.DiffID = config.RootFS.DiffIDs[i]
+		}
+		if len(createdBy) == len(image.Layers) {
+			layer.CreatedBy = createdBy[i]
+		}
+		entries, err := index.layerEntries(path.Clean(name))
+		if err != nil {
+			return report, err
+		}
+		for _, entry := range entries {
+			dir, base := path.Split(entry.path)
+			switch {
+			case base == whiteoutOpaque:
+				removeFiles(files, path.Clean(dir), false, i, waste)
+			case strings.HasPrefix(base, whiteoutPrefix):
+				removed := path.Join(dir, strings.TrimPrefix(base, whiteoutPrefix))
+				removeFiles(files, removed, true, i, waste)
+				layer.Changes = append(layer.Changes, layerChange{Path: removed, Type: changeRemoved})
+			case entry.dir:
+			default:
+				change := layerChange{Path: entry.path, Type: changeAdded, Size: entry.size}
+				if state, ok := files[entry.path]; ok {
+					change.Type = changeModified
+					waste(entry.path, state)
+				}
+				files[entry.path] = fileState{layer: i, size: entry.size}
+				occurrences[entry.path]++
+				layer.Size += entry.size
+				layer.Changes = append(layer.Changes, change)
+			}
+		}
+		sort.Slice(layer.Changes, func(a, b int) bool { return layer.Changes[a].Path < layer.Changes[b].Path })
+		report.Size += layer.Size
+	}
+
+	for _, w := range wasted {
+		w.Occurrences = occurrences[w.Path]
+		report.WastedFiles = append(report.WastedFiles, *w)
+	}
+	sort.Slice(report.WastedFiles, func(a, b int) bool {
+		if report.WastedFiles[a].WastedSize != report.WastedFiles[b].WastedSize {
+			return report.WastedFiles[a].WastedSize > report.WastedFiles[b].WastedSize
+		}
+		return report.WastedFiles[a].Path < report.WastedFiles[b].Path
+	})
+
+	report.Efficiency = 1
+	if report.Size > 0 {
+		report.Efficiency = float64(report.Size-report.WastedSize) / float64(report.Size)
+	}
+	return report, nil
+}
+
+// removeFiles removes the files of layers below layer at p, and below p,
+// from the files visible in the image. If self is false, only the files below
+// p are removed.
+func removeFiles(files map[string]fileState, p string, self bool, layer int, waste func(string, fileState)) {
+	prefix := strings.TrimSuffix(p, "/") + "/"
+	for f, state := range files {
+		if state.layer >= layer {
+			continue
+		}
+		if (self && f == p) || strings.HasPrefix(f, prefix) {
+			waste(f, state)
+			delete(files, f)
+		}
+	}
+}
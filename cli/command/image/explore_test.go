@@ -0,0 +1,134 @@
+package image
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/yuyangjack/dockercli/internal/test"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+type testTarFile struct {
+	name    string
+	content []byte
+}
+
+func newTestTar(t *testing.T, files ...testTarFile) []byte {
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	for _, file := range files {
+		hdr := &tar.Header{Name: file.name, Mode: 0644, Size: int64(len(file.content))}
+		if strings.HasSuffix(file.name, "/") {
+			hdr = &tar.Header{Name: file.name, Mode: 0755, Typeflag: tar.TypeDir}
+		}
+		assert.NilError(t, tw.WriteHeader(hdr))
+		_, err := tw.Write(file.content)
+		assert.NilError(t, err)
+	}
+	assert.NilError(t, tw.Close())
+	return buf.Bytes()
+}
+
+// newTestExploreArchive returns a `docker save` archive of an image whose
+// second layer modifies a file and removes a directory of the first layer
+func newTestExploreArchive(t *testing.T) []byte {
+	layer0 := newTestTar(t,
+		testTarFile{name: "etc/"},
+		testTarFile{name: "etc/config", content: make([]byte, 10)},
+		testTarFile{name: "tmp/"},
+		testTarFile{name: "tmp/cache/"},
+		testTarFile{name: "tmp/cache/big", content: make([]byte, 100)},
+		testTarFile{name: "bin/app", content: make([]byte, 50)},
+	)
+	layer1 := newTestTar(t,
+		testTarFile{name: "etc/config", content: make([]byte, 20)},
+		testTarFile{name: "tmp/.wh.cache"},
+	)
+	config, err := json.Marshal(ocispec.Image{
+		History: []ocispec.History{
+			{CreatedBy: "/bin/sh -c #(nop) ADD file:abc in /"},
+			{CreatedBy: "/bin/sh -c #(nop)  ENV A=b", EmptyLayer: true},
+			{CreatedBy: "/bin/sh -c rm -rf /tmp/cache && configure"},
+		},
+	})
+	assert.NilError(t, err)
+	manifest, err := json.Marshal([]dockerArchiveManifest{{
+		Config:   "config.json",
+		RepoTags: []string{"app:latest"},
+		Layers:   []string{"0/layer.tar", "1/layer.tar"},
+	}})
+	assert.NilError(t, err)
+	return newTestTar(t,
+		testTarFile{name: "0/layer.tar", content: layer0},
+		testTarFile{name: "1/layer.tar", content: layer1},
+		testTarFile{name: "config.json", content: config},
+		testTarFile{name: dockerArchiveManifestFile, content: manifest},
+	)
+}
+
+func TestExploreJSON(t *testing.T) {
+	cli := test.NewFakeCli(&fakeClient{
+		imageSaveFunc: func(images []string) (io.ReadCloser, error) {
+			assert.Check(t, is.DeepEqual([]string{"app:latest"}, images))
+			return ioutil.NopCloser(bytes.NewReader(newTestExploreArchive(t))), nil
+		},
+	})
+	cmd := NewExploreCommand(cli)
+	cmd.SetOutput(ioutil.Discard)
+	cmd.SetArgs([]string{"--format", "json", "app:latest"})
+	assert.NilError(t, cmd.Execute())
+
+	var report exploreReport
+	assert.NilError(t, json.Unmarshal(cli.OutBuffer().Bytes(), &report))
+	assert.Check(t, is.Equal(int64(180), report.Size))
+	assert.Check(t, is.Equal(int64(110), report.WastedSize))
+	assert.Check(t, is.Equal(float64(70)/180, report.Efficiency))
+	assert.Assert(t, is.Len(report.Layers, 2))
+	assert.Check(t, is.Equal(int64(110), report.Layers[0].WastedSize))
+	assert.Check(t, is.Equal("/bin/sh -c rm -rf /tmp/cache && configure", report.Layers[1].CreatedBy))
+	assert.Check(t, is.DeepEqual([]layerChange{
+		{Path: "/etc/config", Type: changeModified, Size: 20},
+		{Path: "/tmp/cache", Type: changeRemoved},
+	}, report.Layers[1].Changes))
+	assert.Check(t, is.DeepEqual([]wastedFile{
+		{Path: "/tmp/cache/big", Occurrences: 1, WastedSize: 100},
+		{Path: "/etc/config", Occurrences: 2, WastedSize: 10},
+	}, report.WastedFiles))
+}
+
+func TestExploreMinEfficiency(t *testing.T) {
+	cli := test.NewFakeCli(&fakeClient{
+		imageSaveFunc: func(images []string) (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(newTestExploreArchive(t))), nil
+		},
+	})
+	cmd := NewExploreCommand(cli)
+	cmd.SetOutput(ioutil.Discard)
+	cmd.SetArgs([]string{"--min-efficiency", "0.9", "app:latest"})
+	assert.ErrorContains(t, cmd.Execute(), "image efficiency 38.89% is below the minimum of 90.00%")
+	assert.Check(t, is.Contains(cli.OutBuffer().String(), "Efficiency:"))
+	assert.Check(t, is.Contains(cli.OutBuffer().String(), "/tmp/cache/big"))
+}
+
+func TestPrintChangeTree(t *testing.T) {
+	out := &bytes.Buffer{}
+	printChangeTree(out, []layerChange{
+		{Path: "/etc/config", Type: changeModified, Size: 20},
+		{Path: "/etc/ssl/cert.pem", Type: changeAdded, Size: 1000},
+		{Path: "/root", Type: changeRemoved},
+	})
+	expected := `etc/
+  ~ config (20B)
+  ssl/
+    + cert.pem (1kB)
+- root
+`
+	assert.Check(t, is.Equal(expected, out.String()))
+}
@@ -0,0 +1,141 @@
+package image
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/yuyangjack/dockercli/cli/command"
+	registryclient "github.com/yuyangjack/dockercli/cli/registry/client"
+	"github.com/yuyangjack/distribution/manifest/manifestlist"
+	"github.com/yuyangjack/distribution/reference"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+// flattenedImage is the config and flattened filesystem of an image
+type flattenedImage struct {
+	config ocispec.Image
+	files  map[string]layerEntry
+	// ref is the reference by digest of images read from the registry
+	ref reference.Canonical
+}
+
+// loadLocalImage reads an image from the daemon, indexing its layers as
+// they are streamed
+func loadLocalImage(ctx context.Context, dockerCli command.Cli, name string, opts indexOptions) (*flattenedImage, error) {
+	responseBody, err := dockerCli.Client().ImageSave(ctx, []string{name})
+	if err != nil {
+		return nil, err
+	}
+	defer responseBody.Close()
+
+	index, err := indexImageArchive(responseBody, opts)
+	if err != nil {
+		return nil, err
+	}
+	archived := index.manifest[0]
+	configJSON, ok := index.configs[path.Clean(archived.Config)]
+	if !ok {
+		return nil, errors.Errorf("image config for %s not found in image archive", name)
+	}
+	image := &flattenedImage{}
+	if err := json.Unmarshal(configJSON, &image.config); err != nil {
+		return nil, errors.Wrapf(err, "invalid image config for %s", name)
+	}
+	var layers [][]layerEntry
+	for _, layer := range archived.Layers {
+		entries, err := index.layerEntries(path.Clean(layer))
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, entries)
+	}
+	image.files = flattenLayers(layers)
+	return image, nil
+}
+
+// loadRemoteImage reads an image from the registry, indexing its layers as
+// they are streamed. If name refers to a manifest list, the image matching
+// platform is used.
+func loadRemoteImage(ctx context.Context, client registryclient.RegistryClient, name string, platform manifestlist.PlatformSpec, opts indexOptions) (*flattenedImage, error) {
+	ref, err := normalizeRegistryReference(name)
+	if err != nil {
+		return nil, err
+	}
+	imageRef, _, err := resolveRemoteImage(ctx, client, ref, platform)
+	if err != nil {
+		return nil, err
+	}
+	imageManifest, configJSON, err := client.GetImageConfig(ctx, imageRef)
+	if err != nil {
+		return nil, err
+	}
+	image := &flattenedImage{ref: imageRef}
+	if err := json.Unmarshal(configJSON, &image.config); err != nil {
+		return nil, errors.Wrapf(err, "invalid image config for %s", name)
+	}
+
+	var layers [][]layerEntry
+	for _, layer := range imageManifestLayers(imageManifest) {
+		desc := ocispec.Descriptor{MediaType: layer.MediaType, Digest: layer.Digest}
+		blob, err := client.GetBlob(ctx, imageRef, layer.Digest)
+		if err != nil {
+			return nil, err
+		}
+		var entries []layerEntry
+		err = decompressLayer(desc, blob, func(r io.Reader) error {
+			var indexErr error
+			entries, indexErr = indexLayer(r, opts)
+			return indexErr
+		})
+		blob.Close()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read layer %s of %s", layer.Digest, name)
+		}
+		layers = append(layers, entries)
+	}
+	image.files = flattenLayers(layers)
+	return image, nil
+}
+
+// flattenLayers applies layers in order, including their whiteouts, and
+// returns the files visible in the resulting filesystem
+func flattenLayers(layers [][]layerEntry) map[string]layerEntry {
+	files := make(map[string]layerEntry)
+	removeBelow := func(dir string) {
+		prefix := strings.TrimSuffix(dir, "/") + "/"
+		for p := range files {
+			if strings.HasPrefix(p, prefix) {
+				delete(files, p)
+			}
+		}
+	}
+	for _, entries := range layers {
+		// Whiteouts only apply to lower layers, so they are applied before
+		// the files of the layer are added
+		for _, entry := range entries {
+			dir, base := path.Split(entry.path)
+			switch {
+			case base == whiteoutOpaque:
+				removeBelow(dir)
+			case strings.HasPrefix(base, whiteoutPrefix):
+				removed := path.Join(dir, strings.TrimPrefix(base, whiteoutPrefix))
+				delete(files, removed)
+				removeBelow(removed)
+			}
+		}
+		for _, entry := range entries {
+			if strings.HasPrefix(path.Base(entry.path), whiteoutPrefix) {
+				continue
+			}
+			if existing, ok := files[entry.path]; ok && existing.dir && !entry.dir {
+				removeBelow(entry.path)
+			}
+			files[entry.path] = entry
+		}
+	}
+	return files
+}
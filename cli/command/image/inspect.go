@@ -6,12 +6,16 @@ import (
 	"github.com/yuyangjack/dockercli/cli"
 	"github.com/yuyangjack/dockercli/cli/command"
 	"github.com/yuyangjack/dockercli/cli/command/inspect"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 )
 
 type inspectOptions struct {
-	format string
-	refs   []string
+	format   string
+	refs     []string
+	remote   bool
+	platform string
+	insecure bool
 }
 
 // newInspectCommand creates a new cobra.Command for `docker image inspect`
@@ -30,10 +34,20 @@ func newInspectCommand(dockerCli command.Cli) *cobra.Command {
 
 	flags := cmd.Flags()
 	flags.StringVarP(&opts.format, "format", "f", "", "Format the output using the given Go template")
+	flags.BoolVar(&opts.remote, "remote", false, "Inspect the image in the registry without pulling it")
+	flags.StringVar(&opts.platform, "platform", "", "Platform to inspect if the image is a manifest list with --remote (os[/arch[/variant]], default linux on the client architecture)")
+	flags.BoolVar(&opts.insecure, "insecure", false, "Allow communication with an insecure registry, with --remote")
 	return cmd
 }
 
 func runInspect(dockerCli command.Cli, opts inspectOptions) error {
+	if opts.remote {
+		return runInspectRemote(dockerCli, opts)
+	}
+	if opts.platform != "" || opts.insecure {
+		return errors.New("--platform and --insecure can only be used with --remote")
+	}
+
 	client := dockerCli.Client()
 	ctx := context.Background()
 
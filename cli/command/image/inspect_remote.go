@@ -0,0 +1,136 @@
+package image
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/yuyangjack/dockercli/cli/command"
+	"github.com/yuyangjack/dockercli/cli/command/inspect"
+	manifesttypes "github.com/yuyangjack/dockercli/cli/manifest/types"
+	registryclient "github.com/yuyangjack/dockercli/cli/registry/client"
+	"github.com/yuyangjack/distribution"
+	"github.com/yuyangjack/distribution/manifest/manifestlist"
+	"github.com/yuyangjack/distribution/reference"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+// remoteImage is the output of `docker image inspect --remote`. It combines
+// the image config with the layers listed in the manifest.
+type remoteImage struct {
+	Name         string
+	Digest       digest.Digest
+	MediaType    string
+	Created      *time.Time `json:",omitempty"`
+	Author       string     `json:",omitempty"`
+	Architecture string
+	Os           string
+	Variant      string `json:",omitempty"`
+	Config       ocispec.ImageConfig
+	RootFS       ocispec.RootFS
+	History      []ocispec.History
+	Layers       []distribution.Descriptor
+	Size         int64
+}
+
+func runInspectRemote(dockerCli command.Cli, opts inspectOptions) error {
+	ctx := context.Background()
+	platform, err := resolvePlatform(opts.platform)
+	if err != nil {
+		return err
+	}
+	client := dockerCli.RegistryClient(opts.insecure)
+
+	getRefFunc := func(ref string) (interface{}, []byte, error) {
+		image, err := inspectRemoteImage(ctx, client, ref, platform)
+		return image, nil, err
+	}
+	return inspect.Inspect(dockerCli.Out(), opts.refs, opts.format, getRefFunc)
+}
+
+// inspectRemoteImage fetches the manifest and image config for name. If name
+// refers to a manifest list, the image matching platform is used.
+func inspectRemoteImage(ctx context.Context, client registryclient.RegistryClient, name string, platform manifestlist.PlatformSpec) (*remoteImage, error) {
+	ref, err := normalizeRegistryReference(name)
+	if err != nil {
+		return nil, err
+	}
+	imageRef, variant, err := resolveRemoteImage(ctx, client, ref, platform)
+	if err != nil {
+		return nil, err
+	}
+
+	imageManifest, configJSON, err := client.GetImageConfig(ctx, imageRef)
+	if err != nil {
+		return nil, err
+	}
+	var config ocispec.Image
+	if err := json.Unmarshal(configJSON, &config); err != nil {
+		return nil, errors.Wrapf(err, "invalid image config for %s", name)
+	}
+
+	image := &remoteImage{
+		Name:         reference.FamiliarString(ref),
+		Digest:       imageManifest.Descriptor.Digest,
+		MediaType:    imageManifest.Descriptor.MediaType,
+		Created:      config.Created,
+		Author:       config.Author,
+		Architecture: config.Architecture,
+		Os:           config.OS,
+		Variant:      variant,
+		Config:       config.Config,
+		RootFS:       config.RootFS,
+		History:      config.History,
+		Layers:       []distribution.Descriptor{},
+	}
+	for _, layer := range imageManifestLayers(imageManifest) {
+		image.Layers = append(image.Layers, layer)
+		image.Size += layer.Size
+	}
+	return image, nil
+}
+
+// resolveRemoteImage returns the reference by digest of the image manifest
+// for ref, and the platform variant if ref refers to a manifest list. The
+// manifest is pinned by digest so that the config and layers are read from
+// the same manifest even if the tag is moved in the meantime.
+func resolveRemoteImage(ctx context.Context, client registryclient.RegistryClient, ref reference.Named, platform manifestlist.PlatformSpec) (reference.Canonical, string, error) {
+	mf, err := client.GetDistributionManifest(ctx, ref)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var (
+		dgst    digest.Digest
+		variant string
+	)
+	if list, ok := mf.(*manifestlist.DeserializedManifestList); ok {
+		desc, err := selectManifest(list, platform)
+		if err != nil {
+			return nil, "", errors.Wrapf(err, "%s", reference.FamiliarString(ref))
+		}
+		dgst, variant = desc.Digest, desc.Platform.Variant
+	} else {
+		_, payload, err := mf.Payload()
+		if err != nil {
+			return nil, "", err
+		}
+		dgst = digest.FromBytes(payload)
+	}
+	imageRef, err := reference.WithDigest(reference.TrimNamed(ref), dgst)
+	return imageRef, variant, err
+}
+
+// imageManifestLayers returns the layers of a schema2 or OCI image manifest
+func imageManifestLayers(imageManifest manifesttypes.ImageManifest) []distribution.Descriptor {
+	switch {
+	case imageManifest.SchemaV2Manifest != nil:
+		return imageManifest.SchemaV2Manifest.Layers
+	case imageManifest.OCIManifest != nil:
+		return imageManifest.OCIManifest.Layers
+	default:
+		return nil
+	}
+}
@@ -1,12 +1,19 @@
 package image
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"testing"
 
+	manifesttypes "github.com/yuyangjack/dockercli/cli/manifest/types"
 	"github.com/yuyangjack/dockercli/internal/test"
+	"github.com/yuyangjack/distribution"
+	"github.com/yuyangjack/distribution/manifest/manifestlist"
+	"github.com/yuyangjack/distribution/reference"
 	"github.com/yuyangjack/moby/api/types"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"gotest.tools/assert"
 	is "gotest.tools/assert/cmp"
 	"gotest.tools/golden"
@@ -86,3 +93,48 @@ func TestNewInspectCommandSuccess(t *testing.T) {
 		assert.Check(t, is.Equal(imageInspectInvocationCount, tc.imageCount))
 	}
 }
+
+func TestNewInspectCommandRemote(t *testing.T) {
+	mf := newTestSchema2Manifest(t, "arm64")
+	mediaType, payload, err := mf.Payload()
+	assert.NilError(t, err)
+	dgst := digest.FromBytes(payload)
+	list, err := manifestlist.FromDescriptors([]manifestlist.ManifestDescriptor{
+		{
+			Descriptor: distribution.Descriptor{MediaType: mediaType, Digest: dgst, Size: int64(len(payload))},
+			Platform:   manifestlist.PlatformSpec{OS: "linux", Architecture: "arm64", Variant: "v8"},
+		},
+	})
+	assert.NilError(t, err)
+	config := `{"architecture":"arm64","os":"linux","config":{"Env":["PATH=/bin"],"Entrypoint":["/app"]},"rootfs":{"type":"layers","diff_ids":["sha256:abc"]}}`
+
+	cli := test.NewFakeCli(&fakeClient{})
+	cli.SetRegistryClient(&fakeRegistryClient{
+		getDistributionManifestFunc: func(_ context.Context, ref reference.Named) (distribution.Manifest, error) {
+			assert.Check(t, is.Equal("docker.io/library/image:latest", ref.String()))
+			return list, nil
+		},
+		getImageConfigFunc: func(_ context.Context, ref reference.Named) (manifesttypes.ImageManifest, []byte, error) {
+			assert.Check(t, is.Equal("docker.io/library/image@"+dgst.String(), ref.String()))
+			desc := ocispec.Descriptor{MediaType: mediaType, Digest: dgst, Size: int64(len(payload))}
+			return manifesttypes.NewImageManifest(ref, desc, mf), []byte(config), nil
+		},
+	})
+	cmd := newInspectCommand(cli)
+	cmd.SetOutput(ioutil.Discard)
+	cmd.SetArgs([]string{"--remote", "--platform", "linux/arm64", "--format", "{{.Os}}/{{.Architecture}}/{{.Variant}} {{.Config.Entrypoint}} {{.RootFS.DiffIDs}} {{len .Layers}} {{.Size}}", "image"})
+	assert.NilError(t, cmd.Execute())
+	assert.Check(t, is.Equal("linux/arm64/v8 [/app] [sha256:abc] 2 50\n", cli.OutBuffer().String()))
+
+	cmd = newInspectCommand(cli)
+	cmd.SetOutput(ioutil.Discard)
+	cmd.SetArgs([]string{"--remote", "--platform", "windows/amd64", "image"})
+	assert.ErrorContains(t, cmd.Execute(), "no manifest found for platform windows/amd64")
+}
+
+func TestNewInspectCommandPlatformRequiresRemote(t *testing.T) {
+	cmd := newInspectCommand(test.NewFakeCli(&fakeClient{}))
+	cmd.SetOutput(ioutil.Discard)
+	cmd.SetArgs([]string{"--platform", "linux/arm64", "image"})
+	assert.ErrorContains(t, cmd.Execute(), "can only be used with --remote")
+}
@@ -1,8 +1,10 @@
 package image
 
 import (
+	"bufio"
 	"context"
 	"io"
+	"os"
 
 	"github.com/yuyangjack/dockercli/cli"
 	"github.com/yuyangjack/dockercli/cli/command"
@@ -32,7 +34,7 @@ func NewLoadCommand(dockerCli command.Cli) *cobra.Command {
 
 	flags := cmd.Flags()
 
-	flags.StringVarP(&opts.input, "input", "i", "", "Read from tar archive file, instead of STDIN")
+	flags.StringVarP(&opts.input, "input", "i", "", "Read from tar archive file or OCI image layout directory, instead of STDIN")
 	flags.BoolVarP(&opts.quiet, "quiet", "q", false, "Suppress the load output")
 
 	return cmd
@@ -42,6 +44,9 @@ func runLoad(dockerCli command.Cli, opts loadOptions) error {
 
 	var input io.Reader = dockerCli.In()
 	if opts.input != "" {
+		if fi, err := os.Stat(opts.input); err == nil && fi.IsDir() {
+			return loadOCILayout(dockerCli, opts.input, opts.quiet)
+		}
 		// We use system.OpenSequential to use sequential file access on Windows, avoiding
 		// depleting the standby list un-necessarily. On Linux, this equates to a regular os.Open.
 		file, err := system.OpenSequential(opts.input)
@@ -58,10 +63,21 @@ func runLoad(dockerCli command.Cli, opts loadOptions) error {
 		return errors.Errorf("requested load from stdin, but stdin is empty")
 	}
 
+	// OCI image layouts are converted to a `docker save` archive on the
+	// client, other archives are passed to the daemon as is
+	bufferedInput := bufio.NewReader(input)
+	if isOCILayoutArchive(bufferedInput) {
+		return loadOCILayoutArchive(dockerCli, bufferedInput, opts.quiet)
+	}
+	return loadImage(dockerCli, bufferedInput, opts.quiet)
+}
+
+// loadImage loads the images of the `docker save` archive read from input
+func loadImage(dockerCli command.Cli, input io.Reader, quiet bool) error {
 	if !dockerCli.Out().IsTerminal() {
-		opts.quiet = true
+		quiet = true
 	}
-	response, err := dockerCli.Client().ImageLoad(context.Background(), input, opts.quiet)
+	response, err := dockerCli.Client().ImageLoad(context.Background(), input, quiet)
 	if err != nil {
 		return err
 	}
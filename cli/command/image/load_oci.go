@@ -0,0 +1,236 @@
+package image
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/yuyangjack/dockercli/cli/command"
+	"github.com/yuyangjack/distribution/manifest/manifestlist"
+	"github.com/yuyangjack/distribution/manifest/schema2"
+	"github.com/yuyangjack/moby/pkg/archive"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+// isOCILayoutArchive reports whether the tar archive read by r is an OCI
+// image layout, based on its first entry other than the root directory,
+// which archives created with `tar -C dir .` start with. Only the buffered
+// part of the input is inspected, and the input is left unread.
+func isOCILayoutArchive(r *bufio.Reader) bool {
+	buffered, _ := r.Peek(r.Size())
+	tr := tar.NewReader(bytes.NewReader(buffered))
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			return false
+		}
+		if path.Clean(hdr.Name) != "." {
+			return isOCILayoutEntry(hdr.Name)
+		}
+	}
+}
+
+// loadOCILayoutArchive loads the images of the OCI image layout tar archive
+// read from input
+func loadOCILayoutArchive(dockerCli command.Cli, input io.Reader, quiet bool) error {
+	tmpDir, err := ioutil.TempDir("", "docker-load-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if err := archive.Untar(input, tmpDir, &archive.TarOptions{NoLchown: true}); err != nil {
+		return errors.Wrap(err, "failed to read OCI image layout")
+	}
+	return loadOCILayout(dockerCli, tmpDir, quiet)
+}
+
+// loadOCILayout loads the images of the OCI image layout in dir by
+// converting it to a `docker save` archive on the client
+func loadOCILayout(dockerCli command.Cli, dir string, quiet bool) error {
+	tmpDir, err := ioutil.TempDir("", "docker-load-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if err := convertOCIToDockerArchive(context.Background(), dockerCli, dir, tmpDir); err != nil {
+		return err
+	}
+	archiveTar, err := archive.Tar(tmpDir, archive.Uncompressed)
+	if err != nil {
+		return err
+	}
+	defer archiveTar.Close()
+	return loadImage(dockerCli, archiveTar, quiet)
+}
+
+// convertOCIToDockerArchive converts the OCI image layout in src to an
+// extracted `docker save` archive in dst. Images are tagged using the ref
+// name annotations of the index. Image indexes are resolved to the manifest
+// for the platform of the daemon.
+func convertOCIToDockerArchive(ctx context.Context, dockerCli command.Cli, src, dst string) error {
+	var layoutFile ocispec.ImageLayout
+	if err := readJSONFile(filepath.Join(src, ocispec.ImageLayoutFile), &layoutFile); err != nil {
+		return errors.Wrap(err, "invalid OCI image layout")
+	}
+	if layoutFile.Version != ocispec.ImageLayoutVersion {
+		return errors.Errorf("unsupported OCI image layout version %q", layoutFile.Version)
+	}
+	var index ocispec.Index
+	if err := readJSONFile(filepath.Join(src, "index.json"), &index); err != nil {
+		return errors.Wrap(err, "invalid OCI image layout")
+	}
+
+	layout := ociLayout{root: src}
+	var (
+		images   []dockerArchiveManifest
+		byConfig = make(map[string]int)
+		layers   = make(map[digest.Digest]string)
+		platform *manifestlist.PlatformSpec
+	)
+	for _, desc := range index.Manifests {
+		manifestDesc := desc
+		if isIndexMediaType(desc.MediaType) {
+			if platform == nil {
+				p, err := daemonPlatform(ctx, dockerCli)
+				if err != nil {
+					return err
+				}
+				platform = &p
+			}
+			var err error
+			if manifestDesc, err = selectFromOCIIndex(layout, desc, *platform); err != nil {
+				return err
+			}
+		}
+		image, err := writeDockerArchiveImage(layout, manifestDesc, dst, layers)
+		if err != nil {
+			return err
+		}
+
+		i, ok := byConfig[image.Config]
+		if !ok {
+			i = len(images)
+			byConfig[image.Config] = i
+			images = append(images, image)
+		}
+		if tag := tagFromOCIAnnotations(desc.Annotations); tag != "" {
+			images[i].RepoTags = append(images[i].RepoTags, tag)
+		}
+	}
+	if len(images) == 0 {
+		return errors.New("no image found in OCI image layout")
+	}
+	return writeJSONFile(filepath.Join(dst, dockerArchiveManifestFile), images)
+}
+
+func isIndexMediaType(mediaType string) bool {
+	return mediaType == ocispec.MediaTypeImageIndex || mediaType == manifestlist.MediaTypeManifestList
+}
+
+// daemonPlatform returns the platform of the daemon
+func daemonPlatform(ctx context.Context, dockerCli command.Cli) (manifestlist.PlatformSpec, error) {
+	version, err := dockerCli.Client().ServerVersion(ctx)
+	if err != nil {
+		return manifestlist.PlatformSpec{}, err
+	}
+	return manifestlist.PlatformSpec{OS: version.Os, Architecture: version.Arch}, nil
+}
+
+// selectFromOCIIndex returns the descriptor of the manifest for platform in
+// the image index described by desc
+func selectFromOCIIndex(layout ociLayout, desc ocispec.Descriptor, platform manifestlist.PlatformSpec) (ocispec.Descriptor, error) {
+	var index ocispec.Index
+	if err := layout.readJSON(desc, &index); err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	for _, m := range index.Manifests {
+		if m.Platform == nil || !matchPlatform(platform, manifestlist.PlatformSpec{OS: m.Platform.OS, Architecture: m.Platform.Architecture, Variant: m.Platform.Variant}) {
+			continue
+		}
+		if isIndexMediaType(m.MediaType) {
+			return selectFromOCIIndex(layout, m, platform)
+		}
+		return m, nil
+	}
+	return ocispec.Descriptor{}, errors.Errorf("no manifest found for platform %s in image index %s", formatPlatform(platform), desc.Digest)
+}
+
+// writeDockerArchiveImage writes the config and layers of the image manifest
+// described by desc to dst. Layers already written, keyed by diff ID, are
+// reused.
+func writeDockerArchiveImage(layout ociLayout, desc ocispec.Descriptor, dst string, layers map[digest.Digest]string) (dockerArchiveManifest, error) {
+	if desc.MediaType != ocispec.MediaTypeImageManifest && desc.MediaType != schema2.MediaTypeManifest {
+		return dockerArchiveManifest{}, errors.Errorf("unsupported manifest media type %q", desc.MediaType)
+	}
+	var manifest ocispec.Manifest
+	if err := layout.readJSON(desc, &manifest); err != nil {
+		return dockerArchiveManifest{}, err
+	}
+	configJSON, err := layout.readBlob(manifest.Config)
+	if err != nil {
+		return dockerArchiveManifest{}, err
+	}
+	var config ocispec.Image
+	if err := json.Unmarshal(configJSON, &config); err != nil {
+		return dockerArchiveManifest{}, errors.Wrapf(err, "invalid image config %s", manifest.Config.Digest)
+	}
+	if len(config.RootFS.DiffIDs) != len(manifest.Layers) {
+		return dockerArchiveManifest{}, errors.Errorf("manifest %s has %d layers but its config has %d diff IDs", desc.Digest, len(manifest.Layers), len(config.RootFS.DiffIDs))
+	}
+
+	image := dockerArchiveManifest{Config: manifest.Config.Digest.Hex() + ".json"}
+	if err := ioutil.WriteFile(filepath.Join(dst, image.Config), configJSON, 0644); err != nil {
+		return dockerArchiveManifest{}, err
+	}
+	for i, layerDesc := range manifest.Layers {
+		diffID := config.RootFS.DiffIDs[i]
+		name, ok := layers[diffID]
+		if !ok {
+			name = path.Join(diffID.Hex(), "layer.tar")
+			if err := writeDockerArchiveLayer(layout, layerDesc, filepath.Join(dst, filepath.FromSlash(name)), diffID); err != nil {
+				return dockerArchiveManifest{}, err
+			}
+			layers[diffID] = name
+		}
+		image.Layers = append(image.Layers, name)
+	}
+	return image, nil
+}
+
+// writeDockerArchiveLayer decompresses the layer described by desc to the
+// file at dst, verifying that its content matches diffID
+func writeDockerArchiveLayer(layout ociLayout, desc ocispec.Descriptor, dst string, diffID digest.Digest) error {
+	if err := diffID.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid diff ID %q", diffID)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	digester := diffID.Algorithm().Digester()
+	err = layout.writeLayerTar(desc, io.MultiWriter(f, digester.Hash()))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to write layer %s", desc.Digest)
+	}
+	if digester.Digest() != diffID {
+		return errors.Errorf("layer %s does not match diff ID %s", desc.Digest, diffID)
+	}
+	return nil
+}
@@ -1,16 +1,24 @@
 package image
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"path"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/yuyangjack/dockercli/cli/command"
 	"github.com/yuyangjack/dockercli/internal/test"
 	"github.com/yuyangjack/moby/api/types"
+	"github.com/yuyangjack/moby/pkg/archive"
+	"github.com/opencontainers/go-digest"
 	"github.com/pkg/errors"
 	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	"gotest.tools/fs"
 	"gotest.tools/golden"
 )
 
@@ -99,3 +107,43 @@ func TestNewLoadCommandSuccess(t *testing.T) {
 		golden.Assert(t, cli.OutBuffer().String(), fmt.Sprintf("load-command-success.%s.golden", tc.name))
 	}
 }
+
+func TestLoadOCILayout(t *testing.T) {
+	dir := fs.NewDir(t, "test-load-oci")
+	defer dir.Remove()
+
+	layoutDir := dir.Join("layout")
+	saveCli := test.NewFakeCli(&fakeClient{
+		imageSaveFunc: func(images []string) (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(newTestDockerArchive(t, "busybox:latest", "busybox:1"))), nil
+		},
+	})
+	saveCmd := NewSaveCommand(saveCli)
+	saveCmd.SetArgs([]string{"--format", "oci", "--compression", "gzip", "-o", layoutDir, "busybox"})
+	assert.NilError(t, saveCmd.Execute())
+
+	layoutTar, err := archive.Tar(layoutDir, archive.Uncompressed)
+	assert.NilError(t, err)
+	defer layoutTar.Close()
+
+	var images []dockerArchiveManifest
+	cli := test.NewFakeCli(&fakeClient{
+		imageLoadFunc: func(input io.Reader, quiet bool) (types.ImageLoadResponse, error) {
+			extracted := dir.Join("extracted")
+			assert.NilError(t, archive.Untar(input, extracted, &archive.TarOptions{NoLchown: true}))
+			assert.NilError(t, readJSONFile(filepath.Join(extracted, dockerArchiveManifestFile), &images))
+			return types.ImageLoadResponse{Body: ioutil.NopCloser(strings.NewReader("Success"))}, nil
+		},
+	})
+	cli.SetIn(command.NewInStream(layoutTar))
+	cmd := NewLoadCommand(cli)
+	cmd.SetOutput(ioutil.Discard)
+	assert.NilError(t, cmd.Execute())
+
+	assert.Assert(t, is.Len(images, 1))
+	assert.Check(t, is.DeepEqual([]string{"busybox:latest", "busybox:1"}, images[0].RepoTags))
+	assert.Check(t, is.Len(images[0].Layers, 1))
+	layer, err := ioutil.ReadFile(filepath.Join(dir.Join("extracted"), filepath.FromSlash(images[0].Layers[0])))
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(digest.FromBytes(layer).Hex(), path.Dir(images[0].Layers[0])))
+}
@@ -0,0 +1,223 @@
+package image
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/yuyangjack/dockercli/cli"
+	"github.com/yuyangjack/dockercli/cli/command"
+	"github.com/yuyangjack/dockercli/opts"
+	"github.com/yuyangjack/distribution/reference"
+	shellwords "github.com/mattn/go-shellwords"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+type mutateOptions struct {
+	image      string
+	tag        string
+	appends    opts.ListOpts
+	labels     opts.ListOpts
+	env        opts.ListOpts
+	entrypoint string
+	cmd        string
+	workdir    string
+	user       string
+	platform   string
+	insecure   bool
+
+	entrypointSet bool
+	cmdSet        bool
+}
+
+// NewMutateCommand creates a new `docker image mutate` command
+func NewMutateCommand(dockerCli command.Cli) *cobra.Command {
+	options := mutateOptions{
+		appends: opts.NewListOpts(nil),
+		labels:  opts.NewListOpts(opts.ValidateLabel),
+		env:     opts.NewListOpts(opts.ValidateEnv),
+	}
+
+	cmd := &cobra.Command{
+		Use:   "mutate [OPTIONS] IMAGE[:TAG|@DIGEST]",
+		Short: "Change the layers and config of an image in a registry without pulling it",
+		Args:  cli.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			options.image = args[0]
+			options.entrypointSet = cmd.Flags().Changed("entrypoint")
+			options.cmdSet = cmd.Flags().Changed("cmd")
+			return runMutate(dockerCli, options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVarP(&options.tag, "tag", "t", "", "Name and tag of the mutated image (required)")
+	flags.Var(&options.appends, "append", "Append a layer from a tar file, which may be gzip compressed")
+	flags.Var(&options.labels, "label", "Set a label on the image")
+	flags.Var(&options.env, "env", "Set an environment variable in the image")
+	flags.StringVar(&options.entrypoint, "entrypoint", "", "Set the entrypoint of the image, as a JSON array or a command line")
+	flags.StringVar(&options.cmd, "cmd", "", "Set the default command of the image, as a JSON array or a command line")
+	flags.StringVar(&options.workdir, "workdir", "", "Set the working directory of the image")
+	flags.StringVar(&options.user, "user", "", "Set the user of the image (<name|uid>[:<group|gid>])")
+	flags.StringVar(&options.platform, "platform", "", "Platform to mutate when the image is a manifest list (os[/arch[/variant]], default linux on the client architecture)")
+	flags.BoolVar(&options.insecure, "insecure", false, "Allow communication with an insecure registry")
+	return cmd
+}
+
+func runMutate(dockerCli command.Cli, options mutateOptions) error {
+	if options.tag == "" {
+		return errors.New("a target image must be set with --tag")
+	}
+	target, err := normalizeRegistryReference(options.tag)
+	if err != nil {
+		return err
+	}
+	if _, isCanonical := target.(reference.Canonical); isCanonical {
+		return errors.Errorf("invalid target %s: cannot push to a digest", options.tag)
+	}
+	ctx := context.Background()
+	platform, err := resolvePlatform(options.platform)
+	if err != nil {
+		return err
+	}
+
+	client := dockerCli.RegistryClient(options.insecure)
+	image, err := loadRegistryImage(ctx, client, options.image, platform)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range options.appends.GetAll() {
+		if err := appendLayerFile(image, path); err != nil {
+			return err
+		}
+	}
+	if err := mutateConfig(&image.config.Config, options, image); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	image.config.Created = &now
+
+	dgst, err := image.push(ctx, client, target)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(dockerCli.Out(), "%s@%s\n", reference.FamiliarName(target), dgst)
+	return nil
+}
+
+func appendLayerFile(image *registryImage, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.Wrap(err, "failed to open layer")
+	}
+	defer f.Close()
+	if err := image.appendLayer(f, "ADD "+filepath.Base(path)+" /"); err != nil {
+		return errors.Wrapf(err, "failed to read layer %s", path)
+	}
+	return nil
+}
+
+// mutateConfig applies the config changes of options to config. The changes
+// are recorded in an empty layer history entry of image, written as the
+// equivalent Dockerfile instructions.
+func mutateConfig(config *ocispec.ImageConfig, options mutateOptions, image *registryImage) error {
+	var instructions []string
+
+	if labels := options.labels.GetAll(); len(labels) > 0 {
+		if config.Labels == nil {
+			config.Labels = make(map[string]string)
+		}
+		for _, label := range labels {
+			kv := strings.SplitN(label, "=", 2)
+			value := ""
+			if len(kv) == 2 {
+				value = kv[1]
+			}
+			config.Labels[kv[0]] = value
+			instructions = append(instructions, fmt.Sprintf("LABEL %s=%q", kv[0], value))
+		}
+	}
+	for _, env := range options.env.GetAll() {
+		config.Env = setEnv(config.Env, env)
+		instructions = append(instructions, "ENV "+env)
+	}
+	if options.entrypointSet {
+		entrypoint, err := parseCommandValue(options.entrypoint)
+		if err != nil {
+			return errors.Wrap(err, "invalid entrypoint")
+		}
+		config.Entrypoint = entrypoint
+		instructions = append(instructions, "ENTRYPOINT "+formatCommandValue(entrypoint))
+	}
+	if options.cmdSet {
+		cmd, err := parseCommandValue(options.cmd)
+		if err != nil {
+			return errors.Wrap(err, "invalid cmd")
+		}
+		config.Cmd = cmd
+		instructions = append(instructions, "CMD "+formatCommandValue(cmd))
+	}
+	if options.workdir != "" {
+		config.WorkingDir = options.workdir
+		instructions = append(instructions, "WORKDIR "+options.workdir)
+	}
+	if options.user != "" {
+		config.User = options.user
+		instructions = append(instructions, "USER "+options.user)
+	}
+
+	if len(instructions) > 0 {
+		now := time.Now().UTC()
+		image.config.History = append(image.config.History, ocispec.History{
+			Created:    &now,
+			CreatedBy:  strings.Join(instructions, " "),
+			EmptyLayer: true,
+		})
+	}
+	return nil
+}
+
+// setEnv sets the variable of env, in KEY=VALUE form, in the list of
+// variables vars, replacing its previous value
+func setEnv(vars []string, env string) []string {
+	key := strings.SplitN(env, "=", 2)[0]
+	for i, v := range vars {
+		if strings.SplitN(v, "=", 2)[0] == key {
+			vars[i] = env
+			return vars
+		}
+	}
+	return append(vars, env)
+}
+
+// parseCommandValue parses an entrypoint or command, given either as a JSON
+// array or as a command line. An empty value resets the command.
+func parseCommandValue(value string) ([]string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	if strings.HasPrefix(value, "[") {
+		var args []string
+		if err := json.Unmarshal([]byte(value), &args); err != nil {
+			return nil, err
+		}
+		return args, nil
+	}
+	return shellwords.Parse(value)
+}
+
+func formatCommandValue(args []string) string {
+	if args == nil {
+		return "[]"
+	}
+	out, _ := json.Marshal(args)
+	return string(out)
+}
@@ -0,0 +1,191 @@
+package image
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/yuyangjack/dockercli/internal/test"
+	manifesttypes "github.com/yuyangjack/dockercli/cli/manifest/types"
+	"github.com/yuyangjack/distribution"
+	"github.com/yuyangjack/distribution/manifest/schema2"
+	"github.com/yuyangjack/distribution/reference"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	"gotest.tools/fs"
+)
+
+// testDockerConfigFields are fields of the container config that are not
+// part of the OCI image spec
+const testDockerConfigFields = `"Healthcheck": {"Test": ["CMD", "true"]}, "OnBuild": ["RUN make"], "Shell": ["/bin/bash", "-c"]`
+
+const testMutateConfig = `{
+	"architecture": "amd64",
+	"os": "linux",
+	"container_config": {"Hostname": "builder"},
+	"config": {"Env": ["PATH=/usr/bin"], "Cmd": ["sh"], ` + testDockerConfigFields + `},
+	"rootfs": {"type": "layers", "diff_ids": ["sha256:1111111111111111111111111111111111111111111111111111111111111111", "sha256:2222222222222222222222222222222222222222222222222222222222222222"]},
+	"history": [{"created_by": "ADD rootfs /"}, {"created_by": "ADD foreign /"}]
+}`
+
+// checkDockerConfigFields checks that the fields of testDockerConfigFields
+// are kept in the container config of configJSON
+func checkDockerConfigFields(t *testing.T, configJSON []byte) {
+	t.Helper()
+	var image struct {
+		Config map[string]json.RawMessage `json:"config"`
+	}
+	assert.NilError(t, json.Unmarshal(configJSON, &image))
+	assert.Check(t, is.Equal(`{"Test":["CMD","true"]}`, string(image.Config["Healthcheck"])))
+	assert.Check(t, is.Equal(`["RUN make"]`, string(image.Config["OnBuild"])))
+	assert.Check(t, is.Equal(`["/bin/bash","-c"]`, string(image.Config["Shell"])))
+}
+
+// newTestMutateClient returns a registry client serving a schema2 image as
+// source:latest, which records the blobs and manifest that are pushed
+func newTestMutateClient(t *testing.T, pushed map[string][]byte, copied *[]digest.Digest, manifest *distribution.Manifest) *fakeRegistryClient {
+	mf := newTestSchema2Manifest(t, "amd64")
+	return &fakeRegistryClient{
+		getDistributionManifestFunc: func(_ context.Context, ref reference.Named) (distribution.Manifest, error) {
+			return mf, nil
+		},
+		getImageConfigFunc: func(_ context.Context, ref reference.Named) (manifesttypes.ImageManifest, []byte, error) {
+			assert.Check(t, is.Equal(ref.(reference.Canonical).Digest().Algorithm(), digest.SHA256))
+			return manifesttypes.ImageManifest{SchemaV2Manifest: mf}, []byte(testMutateConfig), nil
+		},
+		copyBlobFunc: func(_ context.Context, _, _ reference.Named, desc distribution.Descriptor) error {
+			*copied = append(*copied, desc.Digest)
+			return nil
+		},
+		putBlobFunc: func(_ context.Context, _ reference.Named, mediaType string, content []byte) (distribution.Descriptor, error) {
+			pushed[mediaType] = content
+			return distribution.Descriptor{MediaType: mediaType, Digest: digest.FromBytes(content), Size: int64(len(content))}, nil
+		},
+		putManifestFunc: func(_ context.Context, ref reference.Named, m distribution.Manifest) (digest.Digest, error) {
+			assert.Check(t, is.Equal("example.com/target:v2", ref.String()))
+			*manifest = m
+			return "", nil
+		},
+	}
+}
+
+func TestNewMutateCommandErrors(t *testing.T) {
+	testCases := []struct {
+		name          string
+		args          []string
+		expectedError string
+	}{
+		{
+			name:          "wrong-args",
+			args:          []string{},
+			expectedError: "requires exactly 1 argument.",
+		},
+		{
+			name:          "no-tag",
+			args:          []string{"source"},
+			expectedError: "a target image must be set with --tag",
+		},
+		{
+			name:          "digest-target",
+			args:          []string{"source", "-t", "target@" + digest.FromString("target").String()},
+			expectedError: "cannot push to a digest",
+		},
+		{
+			name:          "invalid-label",
+			args:          []string{"source", "-t", "target", "--label", "nolabel"},
+			expectedError: "bad attribute format: nolabel",
+		},
+		{
+			name:          "missing-layer",
+			args:          []string{"source", "-t", "target", "--append", "/nonexistent/layer.tar"},
+			expectedError: "failed to open layer",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cli := test.NewFakeCli(&fakeClient{})
+			cli.SetRegistryClient(newTestMutateClient(t, map[string][]byte{}, new([]digest.Digest), new(distribution.Manifest)))
+			cmd := NewMutateCommand(cli)
+			cmd.SetOutput(ioutil.Discard)
+			cmd.SetArgs(tc.args)
+			assert.ErrorContains(t, cmd.Execute(), tc.expectedError)
+		})
+	}
+}
+
+func TestMutate(t *testing.T) {
+	layer := newTestTar(t, testTarFile{name: "app/run.sh", content: []byte("#!/bin/sh\n")})
+	layerFile := fs.NewFile(t, "mutate-layer", fs.WithContent(string(layer)))
+	defer layerFile.Remove()
+
+	pushed := map[string][]byte{}
+	var copied []digest.Digest
+	var manifest distribution.Manifest
+	cli := test.NewFakeCli(&fakeClient{})
+	cli.SetRegistryClient(newTestMutateClient(t, pushed, &copied, &manifest))
+	cmd := NewMutateCommand(cli)
+	cmd.SetArgs([]string{"source",
+		"--append", layerFile.Path(),
+		"--label", "version=2",
+		"--env", "PATH=/app:/usr/bin",
+		"--entrypoint", `["/app/run.sh"]`,
+		"--cmd", "serve --port 80",
+		"--workdir", "/app",
+		"-t", "example.com/target:v2",
+	})
+	assert.NilError(t, cmd.Execute())
+
+	// Only the layers of the source are mounted, foreign layers are skipped
+	assert.Check(t, is.DeepEqual([]digest.Digest{digest.FromString("layer-amd64")}, copied))
+
+	assert.Assert(t, manifest != nil)
+	_, payload, err := manifest.Payload()
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal("example.com/target@"+digest.FromBytes(payload).String()+"\n", cli.OutBuffer().String()))
+
+	var mf schema2.Manifest
+	assert.NilError(t, json.Unmarshal(payload, &mf))
+	assert.Assert(t, is.Len(mf.Layers, 3))
+	blob := pushed[schema2.MediaTypeLayer]
+	assert.Check(t, is.Equal(digest.FromBytes(blob), mf.Layers[2].Digest))
+	assert.Check(t, is.Equal(digest.FromBytes(pushed[schema2.MediaTypeImageConfig]), mf.Config.Digest))
+
+	configJSON := pushed[schema2.MediaTypeImageConfig]
+	var config ocispec.Image
+	assert.NilError(t, json.Unmarshal(configJSON, &config))
+	assert.Check(t, is.DeepEqual(map[string]string{"version": "2"}, config.Config.Labels))
+	assert.Check(t, is.DeepEqual([]string{"PATH=/app:/usr/bin"}, config.Config.Env))
+	assert.Check(t, is.DeepEqual([]string{"/app/run.sh"}, config.Config.Entrypoint))
+	assert.Check(t, is.DeepEqual([]string{"serve", "--port", "80"}, config.Config.Cmd))
+	assert.Check(t, is.Equal("/app", config.Config.WorkingDir))
+	assert.Assert(t, is.Len(config.RootFS.DiffIDs, 3))
+	assert.Check(t, is.Equal(digest.FromBytes(layer), config.RootFS.DiffIDs[2]))
+	assert.Assert(t, is.Len(config.History, 4))
+	assert.Check(t, !config.History[2].EmptyLayer)
+	assert.Check(t, config.History[3].EmptyLayer)
+
+	// Fields that are not part of the OCI image spec are kept
+	var raw map[string]json.RawMessage
+	assert.NilError(t, json.Unmarshal(configJSON, &raw))
+	assert.Check(t, is.Equal(`{"Hostname":"builder"}`, string(raw["container_config"])))
+	checkDockerConfigFields(t, configJSON)
+}
+
+func TestParseCommandValue(t *testing.T) {
+	testCases := []struct {
+		value    string
+		expected []string
+	}{
+		{value: "", expected: nil},
+		{value: `["/bin/sh", "-c", "echo hello"]`, expected: []string{"/bin/sh", "-c", "echo hello"}},
+		{value: `/bin/sh -c "echo hello"`, expected: []string{"/bin/sh", "-c", "echo hello"}},
+	}
+	for _, tc := range testCases {
+		args, err := parseCommandValue(tc.value)
+		assert.NilError(t, err)
+		assert.Check(t, is.DeepEqual(tc.expected, args))
+	}
+}
@@ -0,0 +1,282 @@
+package image
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/yuyangjack/distribution/manifest/schema2"
+	"github.com/yuyangjack/distribution/reference"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+const (
+	// dockerArchiveManifestFile is the manifest of a `docker save` archive
+	dockerArchiveManifestFile = "manifest.json"
+	// annotationImageName is the annotation used by containerd and BuildKit
+	// to record the full reference of an image in an OCI layout
+	annotationImageName = "io.containerd.image.name"
+	// mediaTypeImageLayerZstd is the OCI media type of zstd compressed layers
+	mediaTypeImageLayerZstd = "application/vnd.oci.image.layer.v1.tar+zstd"
+
+	compressionNone = "none"
+	compressionGzip = "gzip"
+	compressionZstd = "zstd"
+)
+
+// dockerArchiveManifest is an entry of the manifest.json file of a
+// `docker save` archive
+type dockerArchiveManifest struct {
+	Config   string
+	RepoTags []string
+	Layers   []string
+}
+
+// ociLayout is an OCI image layout on disk
+type ociLayout struct {
+	root string
+}
+
+func (l ociLayout) blobPath(dgst digest.Digest) string {
+	return filepath.Join(l.root, "blobs", dgst.Algorithm().String(), dgst.Hex())
+}
+
+// writeBlob stores the content of r as a blob and returns its descriptor
+func (l ociLayout) writeBlob(mediaType string, r io.Reader) (ocispec.Descriptor, error) {
+	dir := filepath.Join(l.root, "blobs", digest.Canonical.String())
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	tmp, err := ioutil.TempFile(dir, ".tmp-")
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	defer os.Remove(tmp.Name())
+
+	digester := digest.Canonical.Digester()
+	size, err := io.Copy(io.MultiWriter(tmp, digester.Hash()), r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	desc := ocispec.Descriptor{MediaType: mediaType, Digest: digester.Digest(), Size: size}
+	return desc, os.Rename(tmp.Name(), l.blobPath(desc.Digest))
+}
+
+// writeJSON stores v as a JSON blob and returns its descriptor
+func (l ociLayout) writeJSON(mediaType string, v interface{}) (ocispec.Descriptor, error) {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(json.NewEncoder(pw).Encode(v))
+	}()
+	return l.writeBlob(mediaType, pr)
+}
+
+// writeLayer stores the uncompressed layer tar at src as a blob using the
+// given compression
+func (l ociLayout) writeLayer(src, compression string) (ocispec.Descriptor, error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	defer f.Close()
+
+	switch compression {
+	case compressionNone:
+		return l.writeBlob(ocispec.MediaTypeImageLayer, f)
+	case compressionGzip:
+		pr, pw := io.Pipe()
+		go func() {
+			gz := gzip.NewWriter(pw)
+			_, err := io.Copy(gz, f)
+			if closeErr := gz.Close(); err == nil {
+				err = closeErr
+			}
+			pw.CloseWithError(err)
+		}()
+		return l.writeBlob(ocispec.MediaTypeImageLayerGzip, pr)
+	case compressionZstd:
+		var desc ocispec.Descriptor
+		err := runZstd(f, func(r io.Reader) error {
+			var writeErr error
+			desc, writeErr = l.writeBlob(mediaTypeImageLayerZstd, r)
+			return writeErr
+		}, "-q", "-c")
+		return desc, err
+	default:
+		return ocispec.Descriptor{}, errors.Errorf("unsupported compression %q", compression)
+	}
+}
+
+// openBlob opens the blob described by desc
+func (l ociLayout) openBlob(desc ocispec.Descriptor) (*os.File, error) {
+	if err := desc.Digest.Validate(); err != nil {
+		return nil, errors.Wrapf(err, "invalid blob digest %q", desc.Digest)
+	}
+	f, err := os.Open(l.blobPath(desc.Digest))
+	if os.IsNotExist(err) {
+		return nil, errors.Errorf("blob %s is missing from the OCI layout", desc.Digest)
+	}
+	return f, err
+}
+
+// readBlob reads the blob described by desc, verifying its digest
+func (l ociLayout) readBlob(desc ocispec.Descriptor) ([]byte, error) {
+	f, err := l.openBlob(desc)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	content, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	if dgst := desc.Digest.Algorithm().FromBytes(content); dgst != desc.Digest {
+		return nil, errors.Errorf("blob %s has unexpected digest %s", desc.Digest, dgst)
+	}
+	return content, nil
+}
+
+// readJSON reads the blob described by desc and unmarshals it into v
+func (l ociLayout) readJSON(desc ocispec.Descriptor, v interface{}) error {
+	content, err := l.readBlob(desc)
+	if err != nil {
+		return err
+	}
+	return errors.Wrapf(json.Unmarshal(content, v), "invalid content in blob %s", desc.Digest)
+}
+
+// writeLayerTar decompresses the layer described by desc into dst
+func (l ociLayout) writeLayerTar(desc ocispec.Descriptor, dst io.Writer) error {
+	f, err := l.openBlob(desc)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return decompressLayer(desc, f, func(r io.Reader) error {
+		_, err := io.Copy(dst, r)
+		return err
+	})
+}
+
+// decompressLayer decompresses the content r of the layer described by desc
+// according to its media type, passing the uncompressed tar to consume
+func decompressLayer(desc ocispec.Descriptor, r io.Reader, consume func(io.Reader) error) error {
+	switch desc.MediaType {
+	case ocispec.MediaTypeImageLayer, ocispec.MediaTypeImageLayerNonDistributable, schema2.MediaTypeUncompressedLayer:
+		return consume(r)
+	case ocispec.MediaTypeImageLayerGzip, ocispec.MediaTypeImageLayerNonDistributableGzip, schema2.MediaTypeLayer, schema2.MediaTypeForeignLayer:
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return errors.Wrapf(err, "failed to decompress layer %s", desc.Digest)
+		}
+		defer gz.Close()
+		return consume(gz)
+	case mediaTypeImageLayerZstd:
+		return runZstd(r, consume, "-q", "-d", "-c")
+	default:
+		return errors.Errorf("unsupported layer media type %q", desc.MediaType)
+	}
+}
+
+// runZstd runs the zstd binary with input as stdin, passing its stdout to
+// consume. Like xz in pkg/archive, zstd is not implemented natively and
+// must be installed on the client.
+func runZstd(input io.Reader, consume func(io.Reader) error, args ...string) error {
+	bin, err := exec.LookPath("zstd")
+	if err != nil {
+		return errors.New("zstd compression requires the zstd binary to be installed")
+	}
+	cmd := exec.Command(bin, args...)
+	cmd.Stdin = input
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	consumeErr := consume(stdout)
+	if consumeErr != nil {
+		// Unblock zstd so that it can exit
+		io.Copy(ioutil.Discard, stdout)
+	}
+	if err := cmd.Wait(); err != nil {
+		return errors.Wrapf(err, "zstd failed: %s", strings.TrimSpace(stderr.String()))
+	}
+	return consumeErr
+}
+
+// ociRefAnnotations returns the index annotations recording the repository
+// tag ref in an OCI layout
+func ociRefAnnotations(ref string) (map[string]string, error) {
+	named, err := reference.ParseNormalizedNamed(ref)
+	if err != nil {
+		return nil, err
+	}
+	named = reference.TagNameOnly(named)
+	annotations := map[string]string{annotationImageName: named.String()}
+	if tagged, ok := named.(reference.Tagged); ok {
+		annotations[ocispec.AnnotationRefName] = tagged.Tag()
+	}
+	return annotations, nil
+}
+
+// tagFromOCIAnnotations returns the repository tag recorded for an image in
+// an OCI layout, or an empty string if the image is untagged. A ref name
+// that is only a tag, which is valid in an OCI layout, cannot be loaded
+// as the repository name is unknown.
+func tagFromOCIAnnotations(annotations map[string]string) string {
+	name := annotations[annotationImageName]
+	if name == "" {
+		name = annotations[ocispec.AnnotationRefName]
+		if !strings.ContainsAny(name, ":/") {
+			return ""
+		}
+	}
+	named, err := reference.ParseNormalizedNamed(name)
+	if err != nil {
+		return ""
+	}
+	if _, ok := named.(reference.NamedTagged); !ok {
+		return ""
+	}
+	return reference.FamiliarString(named)
+}
+
+// isOCILayoutEntry reports whether a tar entry name is part of an OCI image
+// layout rather than a `docker save` archive
+func isOCILayoutEntry(name string) bool {
+	name = path.Clean(strings.TrimPrefix(name, "./"))
+	return name == ocispec.ImageLayoutFile || name == "index.json" || name == "blobs" || strings.HasPrefix(name, "blobs/")
+}
+
+func writeJSONFile(path string, v interface{}) error {
+	content, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, content, 0644)
+}
+
+func readJSONFile(path string, v interface{}) error {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(content, v)
+}
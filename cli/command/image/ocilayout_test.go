@@ -0,0 +1,70 @@
+package image
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestTagFromOCIAnnotations(t *testing.T) {
+	testCases := []struct {
+		annotations map[string]string
+		expected    string
+	}{
+		{
+			annotations: map[string]string{annotationImageName: "docker.io/library/busybox:latest", ocispec.AnnotationRefName: "latest"},
+			expected:    "busybox:latest",
+		},
+		{
+			annotations: map[string]string{ocispec.AnnotationRefName: "example.com/app:1.0"},
+			expected:    "example.com/app:1.0",
+		},
+		{
+			// A tag without a repository cannot be loaded
+			annotations: map[string]string{ocispec.AnnotationRefName: "latest"},
+		},
+		{
+			annotations: map[string]string{annotationImageName: "busybox@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"},
+		},
+		{},
+	}
+	for _, tc := range testCases {
+		assert.Check(t, is.Equal(tc.expected, tagFromOCIAnnotations(tc.annotations)))
+	}
+}
+
+func TestIsOCILayoutEntry(t *testing.T) {
+	assert.Check(t, isOCILayoutEntry("oci-layout"))
+	assert.Check(t, isOCILayoutEntry("./index.json"))
+	assert.Check(t, isOCILayoutEntry("blobs/"))
+	assert.Check(t, isOCILayoutEntry("blobs/sha256/abc"))
+	assert.Check(t, !isOCILayoutEntry("manifest.json"))
+	assert.Check(t, !isOCILayoutEntry("0123abcd/layer.tar"))
+}
+
+func TestIsOCILayoutArchive(t *testing.T) {
+	testCases := []struct {
+		doc      string
+		names    []string
+		expected bool
+	}{
+		{doc: "oci layout", names: []string{"oci-layout", "index.json", "blobs/"}, expected: true},
+		{doc: "oci layout with root directory", names: []string{"./", "./blobs/", "./oci-layout", "./index.json"}, expected: true},
+		{doc: "oci layout with root directory without slash", names: []string{".", "./index.json"}, expected: true},
+		{doc: "docker save archive", names: []string{"0123abcd/", "0123abcd/layer.tar", "manifest.json"}},
+		{doc: "docker save archive with root directory", names: []string{"./", "./0123abcd/", "./manifest.json"}},
+		{doc: "root directory only", names: []string{"./"}},
+	}
+	for _, tc := range testCases {
+		var files []testTarFile
+		for _, name := range tc.names {
+			files = append(files, testTarFile{name: name})
+		}
+		r := bufio.NewReader(bytes.NewReader(newTestTar(t, files...)))
+		assert.Check(t, is.Equal(tc.expected, isOCILayoutArchive(r)), tc.doc)
+	}
+}
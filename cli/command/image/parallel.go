@@ -0,0 +1,106 @@
+package image
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	"github.com/yuyangjack/dockercli/cli/command"
+	"github.com/yuyangjack/moby/pkg/jsonmessage"
+)
+
+// imageStreamFunc starts an operation on the image ref, such as a push or a
+// pull, and returns its stream of progress messages
+type imageStreamFunc func(ctx context.Context, ref string) (io.ReadCloser, error)
+
+// parallelFunc runs an operation on the image ref, sending its progress
+// messages to send
+type parallelFunc func(ctx context.Context, ref string, send func(jsonmessage.JSONMessage)) error
+
+// runParallel runs op for every ref, with at most parallel operations at a
+// time. The progress of all operations is combined in a single display,
+// where the messages of each operation are prefixed with its ref, and
+// doneStatus is shown for every operation that succeeds. It returns the
+// error of each operation, in the order of refs.
+func runParallel(ctx context.Context, dockerCli command.Cli, refs []string, parallel int, doneStatus string, op parallelFunc) []error {
+	if parallel < 1 {
+		parallel = 1
+	}
+	errs := make([]error, len(refs))
+
+	pr, pw := io.Pipe()
+	var mu sync.Mutex
+	enc := json.NewEncoder(pw)
+	send := func(msg jsonmessage.JSONMessage) {
+		mu.Lock()
+		defer mu.Unlock()
+		enc.Encode(msg)
+	}
+
+	displayed := make(chan struct{})
+	go func() {
+		defer close(displayed)
+		out := dockerCli.Out()
+		if err := jsonmessage.DisplayJSONMessagesStream(pr, out, out.FD(), out.IsTerminal(), nil); err != nil {
+			// Keep draining the stream so that operations do not block
+			io.Copy(ioutil.Discard, pr)
+		}
+	}()
+
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i, ref := range refs {
+		wg.Add(1)
+		go func(i int, ref string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			errs[i] = op(ctx, ref, send)
+			if errs[i] == nil {
+				send(jsonmessage.JSONMessage{ID: ref, Status: doneStatus})
+			} else {
+				send(jsonmessage.JSONMessage{ID: ref, Status: "Failed: " + errs[i].Error()})
+			}
+		}(i, ref)
+	}
+	wg.Wait()
+	pw.Close()
+	<-displayed
+	return errs
+}
+
+// streamImageOperation runs op for ref and forwards its progress messages,
+// prefixed with ref, to send
+func streamImageOperation(ctx context.Context, ref string, op imageStreamFunc, send func(jsonmessage.JSONMessage)) error {
+	body, err := op(ctx, ref)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	dec := json.NewDecoder(body)
+	for {
+		var msg jsonmessage.JSONMessage
+		if err := dec.Decode(&msg); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if msg.Aux != nil {
+			continue
+		}
+		if msg.ID != "" {
+			msg.ID = ref + " " + msg.ID
+		} else {
+			msg.ID = ref
+		}
+		send(msg)
+	}
+}
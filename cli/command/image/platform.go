@@ -0,0 +1,95 @@
+package image
+
+import (
+	"context"
+	"runtime"
+	"strings"
+
+	"github.com/yuyangjack/dockercli/cli/command"
+	"github.com/yuyangjack/distribution/manifest/manifestlist"
+	"github.com/pkg/errors"
+)
+
+// resolvePlatform parses a platform in the form os[/arch[/variant]]. Missing
+// parts default to linux on the architecture of the client, so that commands
+// talking to a registry never need the daemon.
+func resolvePlatform(value string) (manifestlist.PlatformSpec, error) {
+	return parsePlatform(value, defaultPlatform())
+}
+
+// defaultPlatform returns linux on the architecture of the client, which is
+// the platform of most images
+func defaultPlatform() manifestlist.PlatformSpec {
+	return manifestlist.PlatformSpec{OS: "linux", Architecture: runtime.GOARCH}
+}
+
+// resolveDaemonPlatform parses a platform in the form os[/arch[/variant]].
+// Missing parts default to the platform of the daemon, which is only queried
+// if needed.
+func resolveDaemonPlatform(ctx context.Context, dockerCli command.Cli, value string) (manifestlist.PlatformSpec, error) {
+	var def manifestlist.PlatformSpec
+	if !strings.Contains(value, "/") {
+		def = defaultDaemonPlatform(ctx, dockerCli)
+	}
+	return parsePlatform(value, def)
+}
+
+// defaultDaemonPlatform returns the platform of the daemon, or linux on the
+// architecture of the client if the daemon does not report it.
+func defaultDaemonPlatform(ctx context.Context, dockerCli command.Cli) manifestlist.PlatformSpec {
+	platform, err := daemonPlatform(ctx, dockerCli)
+	if err != nil || platform.OS == "" || platform.Architecture == "" {
+		return defaultPlatform()
+	}
+	return platform
+}
+
+// parsePlatform parses a platform in the form os[/arch[/variant]]. Missing
+// parts default to def.
+func parsePlatform(value string, def manifestlist.PlatformSpec) (manifestlist.PlatformSpec, error) {
+	platform := def
+	if value == "" {
+		return platform, nil
+	}
+	parts := strings.Split(strings.ToLower(value), "/")
+	if len(parts) > 3 || parts[0] == "" {
+		return platform, errors.Errorf("invalid platform %q: expected os[/arch[/variant]]", value)
+	}
+	platform.OS = parts[0]
+	if len(parts) > 1 {
+		platform.Architecture = parts[1]
+	}
+	if len(parts) > 2 {
+		platform.Variant = parts[2]
+	}
+	return platform, nil
+}
+
+// matchPlatform reports whether candidate satisfies the requested platform.
+// An empty variant in the request matches any variant.
+func matchPlatform(requested, candidate manifestlist.PlatformSpec) bool {
+	if requested.OS != candidate.OS || requested.Architecture != candidate.Architecture {
+		return false
+	}
+	return requested.Variant == "" || requested.Variant == candidate.Variant
+}
+
+// selectManifest returns the descriptor of the first manifest in the list
+// that matches the requested platform.
+func selectManifest(list *manifestlist.DeserializedManifestList, platform manifestlist.PlatformSpec) (manifestlist.ManifestDescriptor, error) {
+	for _, m := range list.Manifests {
+		if matchPlatform(platform, m.Platform) {
+			return m, nil
+		}
+	}
+	return manifestlist.ManifestDescriptor{}, errors.Errorf("no manifest found for platform %s", formatPlatform(platform))
+}
+
+// formatPlatform formats a platform as os/arch[/variant]
+func formatPlatform(platform manifestlist.PlatformSpec) string {
+	p := platform.OS + "/" + platform.Architecture
+	if platform.Variant != "" {
+		p += "/" + platform.Variant
+	}
+	return p
+}
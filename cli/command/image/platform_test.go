@@ -0,0 +1,99 @@
+package image
+
+import (
+	"context"
+	"runtime"
+	"testing"
+
+	"github.com/yuyangjack/dockercli/internal/test"
+	"github.com/yuyangjack/distribution/manifest/manifestlist"
+	"github.com/yuyangjack/moby/api/types"
+	"github.com/pkg/errors"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestParsePlatform(t *testing.T) {
+	def := manifestlist.PlatformSpec{OS: "linux", Architecture: "arm64"}
+	testCases := []struct {
+		value       string
+		expected    manifestlist.PlatformSpec
+		expectedErr string
+	}{
+		{value: "", expected: def},
+		{value: "windows", expected: manifestlist.PlatformSpec{OS: "windows", Architecture: "arm64"}},
+		{value: "linux/amd64", expected: manifestlist.PlatformSpec{OS: "linux", Architecture: "amd64"}},
+		{value: "Linux/ARM/v7", expected: manifestlist.PlatformSpec{OS: "linux", Architecture: "arm", Variant: "v7"}},
+		{value: "/amd64", expectedErr: "invalid platform"},
+		{value: "linux/arm/v7/extra", expectedErr: "invalid platform"},
+	}
+	for _, tc := range testCases {
+		platform, err := parsePlatform(tc.value, def)
+		if tc.expectedErr != "" {
+			assert.Check(t, is.ErrorContains(err, tc.expectedErr), tc.value)
+			continue
+		}
+		assert.Check(t, err, tc.value)
+		assert.Check(t, is.DeepEqual(tc.expected, platform), tc.value)
+	}
+}
+
+func TestResolvePlatformDefaultsToLinux(t *testing.T) {
+	testCases := []struct {
+		value    string
+		expected manifestlist.PlatformSpec
+	}{
+		{value: "", expected: manifestlist.PlatformSpec{OS: "linux", Architecture: runtime.GOARCH}},
+		{value: "windows", expected: manifestlist.PlatformSpec{OS: "windows", Architecture: runtime.GOARCH}},
+		{value: "linux/arm/v7", expected: manifestlist.PlatformSpec{OS: "linux", Architecture: "arm", Variant: "v7"}},
+	}
+	for _, tc := range testCases {
+		platform, err := resolvePlatform(tc.value)
+		assert.Check(t, err, tc.value)
+		assert.Check(t, is.DeepEqual(tc.expected, platform), tc.value)
+	}
+}
+
+func TestResolveDaemonPlatformDefault(t *testing.T) {
+	testCases := []struct {
+		doc      string
+		version  types.Version
+		err      error
+		value    string
+		expected manifestlist.PlatformSpec
+	}{
+		{
+			doc:      "daemon platform",
+			version:  types.Version{Os: "linux", Arch: "s390x"},
+			expected: manifestlist.PlatformSpec{OS: "linux", Architecture: "s390x"},
+		},
+		{
+			doc:      "daemon architecture with explicit os",
+			version:  types.Version{Os: "windows", Arch: "arm64"},
+			value:    "linux",
+			expected: manifestlist.PlatformSpec{OS: "linux", Architecture: "arm64"},
+		},
+		{
+			doc:      "daemon unreachable",
+			err:      errors.New("cannot connect"),
+			expected: manifestlist.PlatformSpec{OS: "linux", Architecture: runtime.GOARCH},
+		},
+		{
+			doc:      "daemon platform unknown",
+			expected: manifestlist.PlatformSpec{OS: "linux", Architecture: runtime.GOARCH},
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.doc, func(t *testing.T) {
+			cli := test.NewFakeCli(&fakeClient{
+				serverVersionFunc: func() (types.Version, error) {
+					return tc.version, tc.err
+				},
+			})
+			platform, err := resolveDaemonPlatform(context.Background(), cli, tc.value)
+			assert.NilError(t, err)
+			assert.Check(t, is.DeepEqual(tc.expected, platform))
+		})
+	}
+}
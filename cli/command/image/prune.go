@@ -15,9 +15,13 @@ import (
 )
 
 type pruneOptions struct {
-	force  bool
-	all    bool
-	filter opts.FilterOpt
+	force          bool
+	all            bool
+	filter         opts.FilterOpt
+	keepLast       int
+	keepUsedWithin string
+	keep           []string
+	dryRun         bool
 }
 
 // NewPruneCommand returns a new cobra prune command for images
@@ -36,6 +40,10 @@ func NewPruneCommand(dockerCli command.Cli) *cobra.Command {
 			if output != "" {
 				fmt.Fprintln(dockerCli.Out(), output)
 			}
+			if options.dryRun {
+				fmt.Fprintln(dockerCli.Out(), "Total reclaimable space:", units.HumanSize(float64(spaceReclaimed)))
+				return nil
+			}
 			fmt.Fprintln(dockerCli.Out(), "Total reclaimed space:", units.HumanSize(float64(spaceReclaimed)))
 			return nil
 		},
@@ -46,6 +54,10 @@ func NewPruneCommand(dockerCli command.Cli) *cobra.Command {
 	flags.BoolVarP(&options.force, "force", "f", false, "Do not prompt for confirmation")
 	flags.BoolVarP(&options.all, "all", "a", false, "Remove all unused images, not just dangling ones")
 	flags.Var(&options.filter, "filter", "Provide filter values (e.g. 'until=<timestamp>')")
+	flags.IntVar(&options.keepLast, "keep-last", 0, "Keep the N most recent tags of each repository")
+	flags.StringVar(&options.keepUsedWithin, "keep-used-within", "", "Keep images used by a container or service within the duration (e.g. '72h' or '7d')")
+	flags.StringSliceVar(&options.keep, "keep", []string{}, "Keep tags matching the pattern (e.g. '*:release-*')")
+	flags.BoolVar(&options.dryRun, "dry-run", false, "Show what would be removed, without removing it")
 
 	return cmd
 }
@@ -54,6 +66,8 @@ const (
 	allImageWarning = `WARNING! This will remove all images without at least one container associated to them.
 Are you sure you want to continue?`
 	danglingWarning = `WARNING! This will remove all dangling images.
+Are you sure you want to continue?`
+	retentionWarning = `WARNING! This will remove all dangling images, and all image tags not kept by the retention policy.
 Are you sure you want to continue?`
 )
 
@@ -70,21 +84,48 @@ func cloneFilter(args filters.Args) (newArgs filters.Args, err error) {
 	return filters.FromJSON(string(b))
 }
 
-func runPrune(dockerCli command.Cli, options pruneOptions) (spaceReclaimed uint64, output string, err error) {
+// pruneFiltersFor returns the filters sent to the daemon, combining the
+// options with the pruneFilters of config.json
+func pruneFiltersFor(dockerCli command.Cli, options pruneOptions) (filters.Args, error) {
 	pruneFilters, err := cloneFilter(options.filter.Value())
 	if err != nil {
-		return 0, "", errors.Wrap(err, "could not copy filter in image prune")
+		return pruneFilters, errors.Wrap(err, "could not copy filter in image prune")
 	}
 	pruneFilters.Add("dangling", fmt.Sprintf("%v", !options.all))
-	pruneFilters = command.PruneFilters(dockerCli, pruneFilters)
+	return command.PruneFilters(dockerCli, pruneFilters), nil
+}
+
+// runPrune runs `docker image prune`, which applies the retention rules of
+// the command line and config.json after prompting for confirmation
+func runPrune(dockerCli command.Cli, options pruneOptions) (uint64, string, error) {
+	pruneFilters, err := pruneFiltersFor(dockerCli, options)
+	if err != nil {
+		return 0, "", err
+	}
+	policy, err := loadRetentionPolicy(dockerCli, options)
+	if err != nil {
+		return 0, "", err
+	}
 
 	warning := danglingWarning
-	if options.all {
+	switch {
+	case policy.isSet():
+		warning = retentionWarning
+	case options.all:
 		warning = allImageWarning
 	}
-	if !options.force && !command.PromptForConfirmation(dockerCli.In(), dockerCli.Out(), warning) {
+	if !options.force && !options.dryRun && !command.PromptForConfirmation(dockerCli.In(), dockerCli.Out(), warning) {
 		return 0, "", nil
 	}
+	return pruneImages(dockerCli, options, policy, pruneFilters)
+}
+
+// pruneImages removes the images selected by the policy on the client, or
+// calls the Image Prune API if no policy is set
+func pruneImages(dockerCli command.Cli, options pruneOptions, policy retentionPolicy, pruneFilters filters.Args) (spaceReclaimed uint64, output string, err error) {
+	if policy.isSet() || options.dryRun {
+		return runPolicyPrune(dockerCli, options, policy, pruneFilters)
+	}
 
 	report, err := dockerCli.Client().ImagesPrune(context.Background(), pruneFilters)
 	if err != nil {
@@ -113,7 +154,14 @@ func runPrune(dockerCli command.Cli, options pruneOptions) (spaceReclaimed uint6
 }
 
 // RunPrune calls the Image Prune API
-// This returns the amount of space reclaimed and a detailed output string
+// This returns the amount of space reclaimed and a detailed output string.
+// The retention rules in the pruneFilters of config.json are not applied, as
+// they are only confirmed by the prompt of `docker image prune`.
 func RunPrune(dockerCli command.Cli, all bool, filter opts.FilterOpt) (uint64, string, error) {
-	return runPrune(dockerCli, pruneOptions{force: true, all: all, filter: filter})
+	options := pruneOptions{force: true, all: all, filter: filter}
+	pruneFilters, err := pruneFiltersFor(dockerCli, options)
+	if err != nil {
+		return 0, "", err
+	}
+	return pruneImages(dockerCli, options, retentionPolicy{}, pruneFilters)
 }
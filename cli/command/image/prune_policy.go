@@ -0,0 +1,427 @@
+package image
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yuyangjack/dockercli/cli/command"
+	"github.com/yuyangjack/distribution/reference"
+	"github.com/yuyangjack/moby/api/types"
+	"github.com/yuyangjack/moby/api/types/filters"
+	"github.com/yuyangjack/moby/pkg/stringid"
+	units "github.com/docker/go-units"
+	"github.com/pkg/errors"
+)
+
+// retentionPolicy are the rules deciding which tags `docker image prune`
+// keeps. Tags that are not kept by any rule are removed.
+type retentionPolicy struct {
+	// keepLast keeps the most recent tags of each repository
+	keepLast int
+	// keepUsedWithin keeps the images used by a container or service within
+	// the duration
+	keepUsedWithin time.Duration
+	// keep protects the tags matching these patterns, where * matches any
+	// sequence of characters
+	keep []string
+}
+
+func (p retentionPolicy) isSet() bool {
+	return p.keepLast > 0 || p.keepUsedWithin > 0 || len(p.keep) > 0
+}
+
+// loadRetentionPolicy merges the retention rules of the pruneFilters of
+// config.json with the ones of the command line, which take precedence
+func loadRetentionPolicy(dockerCli command.Cli, options pruneOptions) (retentionPolicy, error) {
+	var policy retentionPolicy
+	if config := dockerCli.ConfigFile(); config != nil {
+		for _, f := range config.PruneFilters {
+			parts := strings.SplitN(f, "=", 2)
+			if len(parts) != 2 || !command.RetentionPruneFilters[parts[0]] {
+				continue
+			}
+			if err := policy.set(parts[0], parts[1]); err != nil {
+				return policy, errors.Wrap(err, "invalid pruneFilters in config file")
+			}
+		}
+	}
+	if options.keepLast != 0 {
+		if err := policy.set("keep-last", strconv.Itoa(options.keepLast)); err != nil {
+			return policy, err
+		}
+	}
+	if options.keepUsedWithin != "" {
+		if err := policy.set("keep-used-within", options.keepUsedWithin); err != nil {
+			return policy, err
+		}
+	}
+	for _, pattern := range options.keep {
+		if err := policy.set("keep", pattern); err != nil {
+			return policy, err
+		}
+	}
+	return policy, nil
+}
+
+func (p *retentionPolicy) set(key, value string) error {
+	switch key {
+	case "keep-last":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return errors.Errorf("invalid keep-last %q: must be a positive number", value)
+		}
+		p.keepLast = n
+	case "keep-used-within":
+		d, err := parseRetentionDuration(value)
+		if err != nil {
+			return err
+		}
+		p.keepUsedWithin = d
+	case "keep":
+		if value == "" {
+			return errors.New("invalid keep pattern: must not be empty")
+		}
+		p.keep = append(p.keep, value)
+	}
+	return nil
+}
+
+// parseRetentionDuration parses a duration such as "72h", also accepting a
+// number of days such as "7d"
+func parseRetentionDuration(value string) (time.Duration, error) {
+	if strings.HasSuffix(value, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(value, "d"))
+		if err == nil && days > 0 {
+			return time.Duration(days) * 24 * time.Hour, nil
+		}
+	} else if d, err := time.ParseDuration(value); err == nil && d > 0 {
+		return d, nil
+	}
+	return 0, errors.Errorf("invalid keep-used-within %q: must be a duration such as 72h or 7d", value)
+}
+
+// matchRetentionPattern matches ref against a pattern where * matches any
+// sequence of characters, including slashes
+func matchRetentionPattern(pattern, ref string) bool {
+	expr := regexp.QuoteMeta(pattern)
+	expr = strings.Replace(expr, `\*`, ".*", -1)
+	expr = strings.Replace(expr, `\?`, ".", -1)
+	matched, _ := regexp.MatchString("^"+expr+"$", ref)
+	return matched
+}
+
+// pruneAction is the removal of a tag, or of an image when Delete is set
+type pruneAction struct {
+	Ref     string
+	ImageID string
+	Size    int64
+	Delete  bool
+}
+
+// prunePlan is what a prune with a retention policy or --dry-run removes
+type prunePlan struct {
+	actions        []pruneAction
+	spaceReclaimed uint64
+}
+
+// imageUsage is the last time images were used by containers, by image ID,
+// and the references of the images used by services
+type imageUsage struct {
+	containers map[string]time.Time
+	services   map[string]bool
+}
+
+// lastUsed returns when image was last used. Images used by services are in
+// use now.
+func (u imageUsage) lastUsed(image types.ImageSummary, now time.Time) (time.Time, bool) {
+	for _, refs := range [][]string{image.RepoTags, image.RepoDigests} {
+		for _, ref := range refs {
+			if u.services[ref] {
+				return now, true
+			}
+		}
+	}
+	last, used := u.containers[image.ID]
+	return last, used
+}
+
+// loadImageUsage lists the containers and services using images. The last
+// use of a running container is now; for a stopped container it is the time
+// it exited, if stopped containers are inspected, or else its creation time.
+func loadImageUsage(ctx context.Context, dockerCli command.Cli, inspectStopped bool) (imageUsage, error) {
+	usage := imageUsage{containers: make(map[string]time.Time), services: make(map[string]bool)}
+	client := dockerCli.Client()
+	now := time.Now()
+
+	containers, err := client.ContainerList(ctx, types.ContainerListOptions{All: true})
+	if err != nil {
+		return usage, err
+	}
+	for _, c := range containers {
+		used := time.Unix(c.Created, 0)
+		switch {
+		case c.State == "running":
+			used = now
+		case inspectStopped:
+			inspect, err := client.ContainerInspect(ctx, c.ID)
+			if err != nil {
+				return usage, err
+			}
+			if inspect.ContainerJSONBase != nil && inspect.State != nil {
+				if finished, err := time.Parse(time.RFC3339Nano, inspect.State.FinishedAt); err == nil && finished.After(used) {
+					used = finished
+				}
+			}
+		}
+		if last, ok := usage.containers[c.ImageID]; !ok || used.After(last) {
+			usage.containers[c.ImageID] = used
+		}
+	}
+
+	info, err := client.Info(ctx)
+	if err != nil || !info.Swarm.ControlAvailable {
+		return usage, nil
+	}
+	services, err := client.ServiceList(ctx, types.ServiceListOptions{})
+	if err != nil {
+		return usage, err
+	}
+	for _, service := range services {
+		if service.Spec.TaskTemplate.ContainerSpec == nil {
+			continue
+		}
+		named, err := reference.ParseNormalizedNamed(service.Spec.TaskTemplate.ContainerSpec.Image)
+		if err != nil {
+			continue
+		}
+		if _, ok := named.(reference.Canonical); !ok {
+			named = reference.TagNameOnly(named)
+		}
+		if tagged, ok := named.(reference.Tagged); ok {
+			if ref, err := reference.WithTag(reference.TrimNamed(named), tagged.Tag()); err == nil {
+				usage.services[reference.FamiliarString(ref)] = true
+			}
+		}
+		if canonical, ok := named.(reference.Canonical); ok {
+			if ref, err := reference.WithDigest(reference.TrimNamed(named), canonical.Digest()); err == nil {
+				usage.services[reference.FamiliarString(ref)] = true
+			}
+		}
+	}
+	return usage, nil
+}
+
+// isDanglingImage returns whether image has no tag
+func isDanglingImage(image types.ImageSummary) bool {
+	return len(image.RepoTags) == 0 || (len(image.RepoTags) == 1 && image.RepoTags[0] == "<none>:<none>")
+}
+
+// matchPruneFilter returns whether image matches the until and label
+// filters of prune
+func matchPruneFilter(image types.ImageSummary, pruneFilters filters.Args, now time.Time) (bool, error) {
+	for _, until := range pruneFilters.Get("until") {
+		ts, err := parseUntilFilter(until, now)
+		if err != nil {
+			return false, err
+		}
+		if !time.Unix(image.Created, 0).Before(ts) {
+			return false, nil
+		}
+	}
+	if !pruneFilters.MatchKVList("label", image.Labels) {
+		return false, nil
+	}
+	if pruneFilters.Contains("label!") && pruneFilters.MatchKVList("label!", image.Labels) {
+		return false, nil
+	}
+	return true, nil
+}
+
+// parseUntilFilter parses the value of the until filter, either a duration
+// relative to now or a timestamp
+func parseUntilFilter(value string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02"} {
+		if ts, err := time.Parse(layout, value); err == nil {
+			return ts, nil
+		}
+	}
+	if secs, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Unix(int64(secs), 0), nil
+	}
+	return time.Time{}, errors.Errorf("invalid until filter %q", value)
+}
+
+// planPrune decides what to remove from images. Without a retention policy
+// it selects what the daemon would prune: dangling images, or all the
+// unused images if all is set. With a retention policy, every tag that is
+// not kept by a rule is removed, and images are deleted once all their tags
+// are removed. Images used by containers are only kept by the
+// keep-used-within rule when it is set.
+func planPrune(images []types.ImageSummary, usage imageUsage, policy retentionPolicy, all bool, pruneFilters filters.Args, now time.Time) (prunePlan, error) {
+	kept := make(map[string]bool)
+	if policy.keepLast > 0 {
+		type repoTag struct {
+			tag     string
+			created int64
+		}
+		repos := make(map[string][]repoTag)
+		for _, image := range images {
+			for _, tag := range image.RepoTags {
+				named, err := reference.ParseNormalizedNamed(tag)
+				if err != nil {
+					continue
+				}
+				name := reference.FamiliarName(named)
+				repos[name] = append(repos[name], repoTag{tag: tag, created: image.Created})
+			}
+		}
+		for _, tags := range repos {
+			sort.Slice(tags, func(i, j int) bool {
+				if tags[i].created != tags[j].created {
+					return tags[i].created > tags[j].created
+				}
+				return tags[i].tag > tags[j].tag
+			})
+			for i := 0; i < len(tags) && i < policy.keepLast; i++ {
+				kept[tags[i].tag] = true
+			}
+		}
+	}
+
+	var plan prunePlan
+	for _, image := range images {
+		match, err := matchPruneFilter(image, pruneFilters, now)
+		if err != nil {
+			return plan, err
+		}
+		lastUsed, used := usage.lastUsed(image, now)
+		if policy.keepUsedWithin > 0 {
+			used = used && now.Sub(lastUsed) < policy.keepUsedWithin
+		}
+		if !match || used {
+			continue
+		}
+
+		if isDanglingImage(image) {
+			plan.actions = append(plan.actions, pruneAction{Ref: image.ID, ImageID: image.ID, Size: image.Size, Delete: true})
+			plan.spaceReclaimed += uint64(image.Size)
+			continue
+		}
+		if !policy.isSet() {
+			if all {
+				plan.actions = append(plan.actions, pruneAction{Ref: image.ID, ImageID: image.ID, Size: image.Size, Delete: true})
+				plan.spaceReclaimed += uint64(image.Size)
+			}
+			continue
+		}
+
+		var removed []string
+		for _, tag := range image.RepoTags {
+			if kept[tag] || matchesAnyPattern(policy.keep, tag) {
+				continue
+			}
+			removed = append(removed, tag)
+		}
+		for i, tag := range removed {
+			action := pruneAction{Ref: tag, ImageID: image.ID}
+			if len(removed) == len(image.RepoTags) && i == len(removed)-1 {
+				// Removing the last tag deletes the image
+				action.Size, action.Delete = image.Size, true
+				plan.spaceReclaimed += uint64(image.Size)
+			}
+			plan.actions = append(plan.actions, action)
+		}
+	}
+	return plan, nil
+}
+
+func matchesAnyPattern(patterns []string, ref string) bool {
+	for _, pattern := range patterns {
+		if matchRetentionPattern(pattern, ref) {
+			return true
+		}
+	}
+	return false
+}
+
+// runPolicyPrune prunes images client-side, following the retention policy
+// or only reporting what would be removed with --dry-run
+func runPolicyPrune(dockerCli command.Cli, options pruneOptions, policy retentionPolicy, pruneFilters filters.Args) (uint64, string, error) {
+	ctx := context.Background()
+	images, err := dockerCli.Client().ImageList(ctx, types.ImageListOptions{})
+	if err != nil {
+		return 0, "", err
+	}
+	usage, err := loadImageUsage(ctx, dockerCli, policy.keepUsedWithin > 0)
+	if err != nil {
+		return 0, "", err
+	}
+	plan, err := planPrune(images, usage, policy, options.all, pruneFilters, time.Now())
+	if err != nil {
+		return 0, "", err
+	}
+
+	var sb strings.Builder
+	if options.dryRun {
+		if len(plan.actions) > 0 {
+			sb.WriteString("Would remove:\n")
+		}
+		for _, action := range plan.actions {
+			if action.Ref != action.ImageID {
+				fmt.Fprintf(&sb, "untag: %s\n", action.Ref)
+			}
+			if action.Delete {
+				fmt.Fprintf(&sb, "delete: %s (%s)\n", stringid.TruncateID(action.ImageID), units.HumanSize(float64(action.Size)))
+			}
+		}
+		return plan.spaceReclaimed, sb.String(), nil
+	}
+
+	var (
+		deleted        []types.ImageDeleteResponseItem
+		spaceReclaimed uint64
+		failed         []string
+	)
+	for _, action := range plan.actions {
+		items, err := dockerCli.Client().ImageRemove(ctx, action.Ref, types.ImageRemoveOptions{PruneChildren: true})
+		if err != nil {
+			// The image may be in use by a container that the retention
+			// policy did not protect; keep going with the other images
+			failed = append(failed, fmt.Sprintf("%s: %v", action.Ref, err))
+			continue
+		}
+		deleted = append(deleted, items...)
+		if action.Delete {
+			spaceReclaimed += uint64(action.Size)
+		}
+	}
+	if len(deleted) > 0 {
+		sb.WriteString("Deleted Images:\n")
+		for _, st := range deleted {
+			if st.Untagged != "" {
+				sb.WriteString("untagged: ")
+				sb.WriteString(st.Untagged)
+			} else {
+				sb.WriteString("deleted: ")
+				sb.WriteString(st.Deleted)
+			}
+			sb.WriteByte('\n')
+		}
+	}
+	if len(failed) > 0 {
+		sb.WriteString("Skipped Images:\n")
+		for _, f := range failed {
+			sb.WriteString(f)
+			sb.WriteByte('\n')
+		}
+	}
+	return spaceReclaimed, sb.String(), nil
+}
@@ -4,8 +4,11 @@ import (
 	"fmt"
 	"io/ioutil"
 	"testing"
+	"time"
 
+	"github.com/yuyangjack/dockercli/cli/config/configfile"
 	"github.com/yuyangjack/dockercli/internal/test"
+	"github.com/yuyangjack/dockercli/opts"
 	"github.com/yuyangjack/moby/api/types"
 	"github.com/yuyangjack/moby/api/types/filters"
 	"github.com/pkg/errors"
@@ -100,3 +103,151 @@ func TestNewPruneCommandSuccess(t *testing.T) {
 		golden.Assert(t, cli.OutBuffer().String(), fmt.Sprintf("prune-command-success.%s.golden", tc.name))
 	}
 }
+
+func newTestPruneImages() []types.ImageSummary {
+	return []types.ImageSummary{
+		{ID: "sha256:1111111111111111111111111111111111111111111111111111111111111111", RepoTags: []string{"app:1"}, Created: 100, Size: 2000000},
+		{ID: "sha256:2222222222222222222222222222222222222222222222222222222222222222", RepoTags: []string{"app:2"}, Created: 200, Size: 3000000},
+		{ID: "sha256:3333333333333333333333333333333333333333333333333333333333333333", RepoTags: []string{"<none>:<none>"}, Created: 50, Size: 1000},
+	}
+}
+
+func TestPlanPrune(t *testing.T) {
+	images := []types.ImageSummary{
+		{ID: "sha256:a1", RepoTags: []string{"app:1", "app:old"}, Created: 1, Size: 10},
+		{ID: "sha256:a2", RepoTags: []string{"app:2"}, Created: 2, Size: 20},
+		{ID: "sha256:a3", RepoTags: []string{"app:3", "registry.example.com/team/app:3"}, Created: 3, Size: 30},
+		{ID: "sha256:r0", RepoTags: []string{"registry.example.com/team/app:release-1"}, Created: 0, Size: 40},
+		{ID: "sha256:u0", RepoTags: []string{"tool:latest"}, Created: 0, Size: 50},
+		{ID: "sha256:d0", Created: 0, Size: 60},
+	}
+	now := time.Unix(1000, 0)
+	usage := imageUsage{
+		containers: map[string]time.Time{"sha256:u0": now.Add(-time.Hour)},
+		services:   map[string]bool{},
+	}
+	policy := retentionPolicy{keepLast: 2, keep: []string{"*:release-*"}}
+
+	plan, err := planPrune(images, usage, policy, false, filters.NewArgs(), now)
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual([]pruneAction{
+		{Ref: "app:1", ImageID: "sha256:a1"},
+		{Ref: "app:old", ImageID: "sha256:a1", Size: 10, Delete: true},
+		{Ref: "sha256:d0", ImageID: "sha256:d0", Size: 60, Delete: true},
+	}, plan.actions))
+	assert.Check(t, is.Equal(uint64(70), plan.spaceReclaimed))
+
+	// Containers only keep images used within keep-used-within
+	policy = retentionPolicy{keepUsedWithin: 30 * time.Minute, keep: []string{"*app*"}}
+	plan, err = planPrune(images, usage, policy, false, filters.NewArgs(), now)
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual([]pruneAction{
+		{Ref: "tool:latest", ImageID: "sha256:u0", Size: 50, Delete: true},
+		{Ref: "sha256:d0", ImageID: "sha256:d0", Size: 60, Delete: true},
+	}, plan.actions))
+
+	// Without a retention policy, all unused images are removed with --all
+	plan, err = planPrune(images, usage, retentionPolicy{}, true, filters.NewArgs("label=unknown"), now)
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(plan.actions, 0))
+	plan, err = planPrune(images, usage, retentionPolicy{}, true, filters.NewArgs(), now)
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(plan.actions, 5))
+}
+
+func TestPruneDryRun(t *testing.T) {
+	cli := test.NewFakeCli(&fakeClient{
+		imageListFunc: func(options types.ImageListOptions) ([]types.ImageSummary, error) {
+			return newTestPruneImages(), nil
+		},
+		imageRemoveFunc: func(image string, options types.ImageRemoveOptions) ([]types.ImageDeleteResponseItem, error) {
+			return nil, errors.New("images must not be removed with --dry-run")
+		},
+	})
+	cmd := NewPruneCommand(cli)
+	cmd.SetOutput(ioutil.Discard)
+	cmd.SetArgs([]string{"--dry-run", "--keep-last", "1"})
+	assert.NilError(t, cmd.Execute())
+	golden.Assert(t, cli.OutBuffer().String(), "prune-command-dry-run.golden")
+}
+
+func TestPruneRetentionPolicyFromConfig(t *testing.T) {
+	var removed []string
+	cli := test.NewFakeCli(&fakeClient{
+		imageListFunc: func(options types.ImageListOptions) ([]types.ImageSummary, error) {
+			return newTestPruneImages(), nil
+		},
+		imageRemoveFunc: func(image string, options types.ImageRemoveOptions) ([]types.ImageDeleteResponseItem, error) {
+			removed = append(removed, image)
+			if image == "app:1" {
+				return nil, errors.New("conflict: image is being used by stopped container")
+			}
+			return []types.ImageDeleteResponseItem{{Deleted: image}}, nil
+		},
+		imagesPruneFunc: func(pruneFilter filters.Args) (types.ImagesPruneReport, error) {
+			return types.ImagesPruneReport{}, errors.New("retention rules must be applied by the CLI")
+		},
+	})
+	cli.SetConfigFile(&configfile.ConfigFile{PruneFilters: []string{"keep-last=1", "label!=keep"}})
+	cmd := NewPruneCommand(cli)
+	cmd.SetOutput(ioutil.Discard)
+	cmd.SetArgs([]string{"--force"})
+	assert.NilError(t, cmd.Execute())
+	assert.Check(t, is.DeepEqual([]string{"app:1", "sha256:3333333333333333333333333333333333333333333333333333333333333333"}, removed))
+	assert.Check(t, is.Contains(cli.OutBuffer().String(), "Skipped Images:\napp:1: conflict"))
+	assert.Check(t, is.Contains(cli.OutBuffer().String(), "Total reclaimed space: 1kB"))
+}
+
+// RunPrune is used by `docker system prune`, which does not prompt for the
+// retention rules and must only remove dangling images
+func TestRunPruneIgnoresRetentionPolicy(t *testing.T) {
+	var pruneFilters filters.Args
+	cli := test.NewFakeCli(&fakeClient{
+		imageListFunc: func(options types.ImageListOptions) ([]types.ImageSummary, error) {
+			return nil, errors.New("retention rules must not be applied by system prune")
+		},
+		imageRemoveFunc: func(image string, options types.ImageRemoveOptions) ([]types.ImageDeleteResponseItem, error) {
+			return nil, errors.New("retention rules must not be applied by system prune")
+		},
+		imagesPruneFunc: func(pruneFilter filters.Args) (types.ImagesPruneReport, error) {
+			pruneFilters = pruneFilter
+			return types.ImagesPruneReport{SpaceReclaimed: 1024, ImagesDeleted: []types.ImageDeleteResponseItem{{Deleted: "sha256:1111"}}}, nil
+		},
+	})
+	cli.SetConfigFile(&configfile.ConfigFile{PruneFilters: []string{"keep-last=1", "keep-used-within=0d", "label!=keep"}})
+	spaceReclaimed, output, err := RunPrune(cli, false, opts.NewFilterOpt())
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(uint64(1024), spaceReclaimed))
+	assert.Check(t, is.Equal("Deleted Images:\ndeleted: sha256:1111\n", output))
+	assert.Check(t, is.DeepEqual([]string{"true"}, pruneFilters.Get("dangling")))
+	assert.Check(t, is.DeepEqual([]string{"keep"}, pruneFilters.Get("label!")))
+	assert.Check(t, !pruneFilters.Contains("keep-last"))
+	assert.Check(t, is.Equal("", cli.OutBuffer().String()))
+}
+
+func TestPruneRetentionPolicyErrors(t *testing.T) {
+	testCases := []struct {
+		args          []string
+		pruneFilters  []string
+		expectedError string
+	}{
+		{args: []string{"--keep-last", "-1"}, expectedError: `invalid keep-last "-1"`},
+		{args: []string{"--keep-used-within", "soon"}, expectedError: `invalid keep-used-within "soon"`},
+		{pruneFilters: []string{"keep-used-within=0d"}, expectedError: "invalid pruneFilters in config file"},
+	}
+	for _, tc := range testCases {
+		cli := test.NewFakeCli(&fakeClient{})
+		cli.SetConfigFile(&configfile.ConfigFile{PruneFilters: tc.pruneFilters})
+		cmd := NewPruneCommand(cli)
+		cmd.SetOutput(ioutil.Discard)
+		cmd.SetArgs(append([]string{"--force"}, tc.args...))
+		assert.ErrorContains(t, cmd.Execute(), tc.expectedError)
+	}
+}
+
+func TestMatchRetentionPattern(t *testing.T) {
+	assert.Check(t, matchRetentionPattern("*:release-*", "registry.example.com/team/app:release-1.2"))
+	assert.Check(t, matchRetentionPattern("app:v?", "app:v1"))
+	assert.Check(t, !matchRetentionPattern("app:v?", "app:v10"))
+	assert.Check(t, !matchRetentionPattern("*:release-*", "app:latest"))
+}
@@ -19,6 +19,8 @@ type PullOptions struct {
 	all       bool
 	platform  string
 	untrusted bool
+	fromFile  string
+	parallel  int
 }
 
 // NewPullCommand creates a new `docker pull` command
@@ -28,8 +30,16 @@ func NewPullCommand(dockerCli command.Cli) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "pull [OPTIONS] NAME[:TAG|@DIGEST]",
 		Short: "Pull an image or a repository from a registry",
-		Args:  cli.ExactArgs(1),
+		Args: func(cmd *cobra.Command, args []string) error {
+			if opts.fromFile != "" {
+				return cli.NoArgs(cmd, args)
+			}
+			return cli.ExactArgs(1)(cmd, args)
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.fromFile != "" {
+				return runPullMany(dockerCli, opts)
+			}
 			opts.remote = args[0]
 			return RunPull(dockerCli, opts)
 		},
@@ -38,6 +48,8 @@ func NewPullCommand(dockerCli command.Cli) *cobra.Command {
 	flags := cmd.Flags()
 
 	flags.BoolVarP(&opts.all, "all-tags", "a", false, "Download all tagged images in the repository")
+	flags.StringVar(&opts.fromFile, "from-file", "", "Pull the images listed in a file, one per line")
+	flags.IntVar(&opts.parallel, "parallel", 3, "Number of images pulled in parallel with --from-file")
 
 	command.AddPlatformFlag(flags, &opts.platform)
 	command.AddTrustVerificationFlags(flags, &opts.untrusted, dockerCli.ContentTrustEnabled())
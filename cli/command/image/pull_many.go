@@ -0,0 +1,175 @@
+package image
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/yuyangjack/dockercli/cli/command"
+	"github.com/yuyangjack/dockercli/cli/trust"
+	"github.com/yuyangjack/distribution/reference"
+	"github.com/yuyangjack/moby/api/types"
+	registrytypes "github.com/yuyangjack/moby/api/types/registry"
+	"github.com/yuyangjack/moby/pkg/jsonmessage"
+	units "github.com/docker/go-units"
+	"github.com/pkg/errors"
+)
+
+// maxPullAttempts is the number of times an image is pulled before giving
+// up, when the registry fails with a transient error
+const maxPullAttempts = 4
+
+// pullRetryDelay is the delay before the first retry of a pull, doubled for
+// every subsequent retry
+var pullRetryDelay = 2 * time.Second
+
+// pullResult is the outcome of the pull of one of several images
+type pullResult struct {
+	digest  string
+	size    int64
+	elapsed time.Duration
+}
+
+// runPullMany pulls the images listed in a file in parallel, retrying the
+// pulls that fail with transient registry errors
+func runPullMany(dockerCli command.Cli, opts PullOptions) error {
+	if opts.all {
+		return errors.New("--all-tags and --from-file cannot be used together")
+	}
+	if opts.parallel < 1 {
+		return errors.Errorf("invalid parallel %d: must be at least 1", opts.parallel)
+	}
+	refs, err := readImageList(dockerCli.In(), opts.fromFile)
+	if err != nil {
+		return err
+	}
+
+	// Resolve the credentials of every registry up front, as prompting for
+	// them is not possible while images are pulled in parallel
+	ctx := context.Background()
+	imgRefsAndAuth := make(map[string]trust.ImageRefAndAuth)
+	results := make(map[string]*pullResult)
+	for _, ref := range refs {
+		imgRefAndAuth, err := trust.GetImageReferencesAndAuth(ctx, nil, AuthResolver(dockerCli), ref)
+		if err != nil {
+			return err
+		}
+		imgRefsAndAuth[ref] = imgRefAndAuth
+		results[ref] = &pullResult{}
+	}
+
+	errs := runParallel(ctx, dockerCli, refs, opts.parallel, "Pulled", func(ctx context.Context, ref string, send func(jsonmessage.JSONMessage)) error {
+		start := time.Now()
+		err := pullOne(ctx, dockerCli, imgRefsAndAuth[ref], opts, results[ref], send)
+		results[ref].elapsed = time.Since(start)
+		return err
+	})
+
+	w := tabwriter.NewWriter(dockerCli.Out(), 10, 1, 3, ' ', 0)
+	fmt.Fprintln(w, "\nIMAGE\tDIGEST\tSIZE\tTIME\tERROR")
+	for i, ref := range refs {
+		result := results[ref]
+		digest, size, errMsg := "-", "-", ""
+		if errs[i] == nil {
+			digest = result.digest
+			size = units.HumanSizeWithPrecision(float64(result.size), 3)
+		} else {
+			errMsg = strings.SplitN(errs[i].Error(), "\n", 2)[0]
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", ref, digest, size, result.elapsed.Round(100*time.Millisecond), errMsg)
+	}
+	w.Flush()
+	return summarizeParallel(dockerCli, "pull", refs, errs)
+}
+
+// pullOne pulls a single image, verified with content trust unless it is
+// disabled, and records its digest and size in result
+func pullOne(ctx context.Context, dockerCli command.Cli, imgRefAndAuth trust.ImageRefAndAuth, opts PullOptions, result *pullResult, send func(jsonmessage.JSONMessage)) error {
+	ref := reference.FamiliarString(imgRefAndAuth.Reference())
+	pullRefAndAuth := imgRefAndAuth
+	tagged, isTagged := imgRefAndAuth.Reference().(reference.NamedTagged)
+	var trustedRef reference.Canonical
+	if !opts.untrusted && isTagged {
+		targets, err := getTrustedPullTargets(dockerCli, imgRefAndAuth)
+		if err != nil {
+			return err
+		}
+		trustedRef, err = reference.WithDigest(reference.TrimNamed(tagged), targets[0].digest)
+		if err != nil {
+			return err
+		}
+		authConfig := *imgRefAndAuth.AuthConfig()
+		resolver := func(context.Context, *registrytypes.IndexInfo) types.AuthConfig { return authConfig }
+		if pullRefAndAuth, err = trust.GetImageReferencesAndAuth(ctx, nil, resolver, trustedRef.String()); err != nil {
+			return err
+		}
+		result.digest = trustedRef.Digest().String()
+	}
+
+	record := func(msg jsonmessage.JSONMessage) {
+		if strings.HasPrefix(msg.Status, "Digest: ") && result.digest == "" {
+			result.digest = strings.TrimPrefix(msg.Status, "Digest: ")
+		}
+		send(msg)
+	}
+	pull := func(ctx context.Context, _ string) (io.ReadCloser, error) {
+		return imagePullStream(ctx, dockerCli, pullRefAndAuth, false, opts.platform, nil)
+	}
+	delay := pullRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := streamImageOperation(ctx, ref, pull, record)
+		if err == nil {
+			break
+		}
+		if attempt == maxPullAttempts || !isTransientPullError(err) {
+			return err
+		}
+		send(jsonmessage.JSONMessage{ID: ref, Status: fmt.Sprintf("Retrying in %s (attempt %d of %d): %v", delay, attempt+1, maxPullAttempts, err)})
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		delay *= 2
+	}
+
+	if trustedRef != nil {
+		send(jsonmessage.JSONMessage{ID: ref, Status: "Tagging " + reference.FamiliarString(trustedRef)})
+		if err := dockerCli.Client().ImageTag(ctx, reference.FamiliarString(trustedRef), ref); err != nil {
+			return err
+		}
+	}
+	inspect, _, err := dockerCli.Client().ImageInspectWithRaw(ctx, ref)
+	if err != nil {
+		return err
+	}
+	result.size = inspect.Size
+	return nil
+}
+
+// isTransientPullError reports whether a pull failed because of an error of
+// the network or the registry that may not happen again
+func isTransientPullError(err error) bool {
+	msg := strings.ToLower(err.Error())
+	for _, transient := range []string{
+		"timeout",
+		"connection reset",
+		"connection refused",
+		"unexpected eof",
+		"broken pipe",
+		"too many requests",
+		"toomanyrequests",
+		"service unavailable",
+		"bad gateway",
+		"gateway timeout",
+		"internal server error",
+	} {
+		if strings.Contains(msg, transient) {
+			return true
+		}
+	}
+	return false
+}
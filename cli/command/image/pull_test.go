@@ -5,13 +5,18 @@ import (
 	"io"
 	"io/ioutil"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/yuyangjack/dockercli/cli/command"
 	"github.com/yuyangjack/dockercli/internal/test"
 	"github.com/yuyangjack/dockercli/internal/test/notary"
 	"github.com/yuyangjack/moby/api/types"
+	"github.com/pkg/errors"
 	"gotest.tools/assert"
 	is "gotest.tools/assert/cmp"
+	"gotest.tools/fs"
 	"gotest.tools/golden"
 )
 
@@ -119,3 +124,70 @@ func TestNewPullCommandWithContentTrustErrors(t *testing.T) {
 		assert.ErrorContains(t, err, tc.expectedError)
 	}
 }
+
+func TestPullFromFile(t *testing.T) {
+	defer func(delay time.Duration) { pullRetryDelay = delay }(pullRetryDelay)
+	pullRetryDelay = 0
+
+	dir := fs.NewDir(t, "pull-from-file", fs.WithFile("images.txt", "# images to pre-warm\nbusybox\nflaky:1\nmissing:1\n"))
+	defer dir.Remove()
+
+	var (
+		mu       sync.Mutex
+		attempts = make(map[string]int)
+	)
+	cli := test.NewFakeCli(&fakeClient{
+		imagePullFunc: func(ref string, options types.ImagePullOptions) (io.ReadCloser, error) {
+			assert.Check(t, is.Equal("linux/arm64", options.Platform))
+			mu.Lock()
+			attempts[ref]++
+			attempt := attempts[ref]
+			mu.Unlock()
+			switch {
+			case ref == "missing:1":
+				return ioutil.NopCloser(strings.NewReader(`{"errorDetail":{"message":"manifest unknown"},"error":"manifest unknown"}`)), nil
+			case ref == "flaky:1" && attempt == 1:
+				return nil, errors.New("received unexpected HTTP status: 503 Service Unavailable")
+			}
+			return ioutil.NopCloser(strings.NewReader(`{"status":"Digest: sha256:abc"}`)), nil
+		},
+		imageInspectFunc: func(image string) (types.ImageInspect, []byte, error) {
+			return types.ImageInspect{Size: 2000000}, nil, nil
+		},
+	})
+	cmd := NewPullCommand(cli)
+	cmd.SetOutput(ioutil.Discard)
+	cmd.SetArgs([]string{"--from-file", dir.Join("images.txt"), "--parallel", "2", "--platform", "linux/arm64", "--disable-content-trust"})
+	assert.ErrorContains(t, cmd.Execute(), "failed to pull 1 images:\nmissing:1: manifest unknown")
+
+	assert.Check(t, is.DeepEqual(map[string]int{"busybox:latest": 1, "flaky:1": 2, "missing:1": 1}, attempts))
+	out := cli.OutBuffer().String()
+	assert.Check(t, is.Contains(out, "flaky:1: Retrying in 0s (attempt 2 of 4): received unexpected HTTP status: 503 Service Unavailable"))
+	assert.Check(t, is.Contains(out, "busybox:latest: Pulled"))
+	assert.Check(t, is.Contains(out, "IMAGE "))
+	assert.Check(t, is.Regexp(`flaky:1 +sha256:abc +2MB +[0-9.]+m?s`, out))
+	assert.Check(t, is.Regexp(`missing:1 +- +- +[0-9.]+m?s +manifest unknown`, out))
+	assert.Check(t, is.Contains(out, "2 of 3 images succeeded"))
+}
+
+func TestPullManyErrors(t *testing.T) {
+	testCases := []struct {
+		args          []string
+		expectedError string
+		notaryFunc    test.NotaryClientFuncType
+	}{
+		{args: []string{"--from-file", "images.txt", "app"}, expectedError: "accepts no arguments"},
+		{args: []string{"--from-file", "images.txt", "--all-tags"}, expectedError: "--all-tags and --from-file cannot be used together"},
+		{args: []string{"--from-file", "images.txt", "--parallel", "0"}, expectedError: "invalid parallel 0"},
+		{args: []string{"--from-file", "-"}, expectedError: "client is offline", notaryFunc: notary.GetOfflineNotaryRepository},
+	}
+	for _, tc := range testCases {
+		cli := test.NewFakeCli(&fakeClient{}, test.EnableContentTrust)
+		cli.SetIn(command.NewInStream(ioutil.NopCloser(strings.NewReader("app:1\n"))))
+		cli.SetNotaryClient(tc.notaryFunc)
+		cmd := NewPullCommand(cli)
+		cmd.SetOutput(ioutil.Discard)
+		cmd.SetArgs(tc.args)
+		assert.ErrorContains(t, cmd.Execute(), tc.expectedError)
+	}
+}
@@ -2,6 +2,7 @@ package image
 
 import (
 	"context"
+	"io/ioutil"
 
 	"github.com/yuyangjack/dockercli/cli"
 	"github.com/yuyangjack/dockercli/cli/command"
@@ -14,6 +15,12 @@ import (
 type pushOptions struct {
 	remote    string
 	untrusted bool
+	allTags   bool
+	fromFile  string
+	parallel  int
+	// quiet discards the progress of the push, for pushes run by other
+	// commands whose output must not change
+	quiet bool
 }
 
 // NewPushCommand creates a new `docker push` command
@@ -23,14 +30,28 @@ func NewPushCommand(dockerCli command.Cli) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "push [OPTIONS] NAME[:TAG]",
 		Short: "Push an image or a repository to a registry",
-		Args:  cli.ExactArgs(1),
+		Args: func(cmd *cobra.Command, args []string) error {
+			if opts.fromFile != "" {
+				return cli.NoArgs(cmd, args)
+			}
+			return cli.ExactArgs(1)(cmd, args)
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.fromFile != "" || opts.allTags {
+				if len(args) > 0 {
+					opts.remote = args[0]
+				}
+				return runPushMany(dockerCli, opts)
+			}
 			opts.remote = args[0]
 			return RunPush(dockerCli, opts)
 		},
 	}
 
 	flags := cmd.Flags()
+	flags.BoolVarP(&opts.allTags, "all-tags", "a", false, "Push all the tags of the repository")
+	flags.StringVar(&opts.fromFile, "from-file", "", "Push the images listed in a file, one per line")
+	flags.IntVar(&opts.parallel, "parallel", 3, "Number of images pushed in parallel with --all-tags or --from-file")
 
 	command.AddTrustSigningFlags(flags, &opts.untrusted, dockerCli.ContentTrustEnabled())
 
@@ -66,5 +87,9 @@ func RunPush(dockerCli command.Cli, opts pushOptions) error {
 	}
 
 	defer responseBody.Close()
-	return jsonmessage.DisplayJSONMessagesToStream(responseBody, dockerCli.Out(), nil)
+	out := dockerCli.Out()
+	if opts.quiet {
+		out = command.NewOutStream(ioutil.Discard)
+	}
+	return jsonmessage.DisplayJSONMessagesToStream(responseBody, out, nil)
 }
@@ -0,0 +1,181 @@
+package image
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/yuyangjack/dockercli/cli/command"
+	"github.com/yuyangjack/distribution/reference"
+	"github.com/yuyangjack/moby/api/types"
+	"github.com/yuyangjack/moby/api/types/filters"
+	"github.com/yuyangjack/moby/pkg/jsonmessage"
+	"github.com/yuyangjack/moby/registry"
+	"github.com/pkg/errors"
+)
+
+// runPushMany pushes all the tags of a repository, or the images listed in
+// a file, in parallel unless content trust is enabled
+func runPushMany(dockerCli command.Cli, opts pushOptions) error {
+	if opts.allTags && opts.fromFile != "" {
+		return errors.New("--all-tags and --from-file cannot be used together")
+	}
+	if opts.parallel < 1 {
+		return errors.Errorf("invalid parallel %d: must be at least 1", opts.parallel)
+	}
+
+	ctx := context.Background()
+	var (
+		refs []string
+		err  error
+	)
+	if opts.allTags {
+		refs, err = repositoryTags(ctx, dockerCli, opts.remote)
+	} else {
+		refs, err = readImageList(dockerCli.In(), opts.fromFile)
+	}
+	if err != nil {
+		return err
+	}
+	return PushImages(dockerCli, refs, opts.parallel, opts.untrusted)
+}
+
+// PushImages pushes the images refs, in parallel unless content trust is
+// enabled, as images are then signed one by one
+func PushImages(dockerCli command.Cli, refs []string, parallel int, untrusted bool) error {
+	if untrusted {
+		return pushImages(context.Background(), dockerCli, refs, parallel)
+	}
+	for _, ref := range refs {
+		if err := trustedPush(dockerCli, ref); err != nil {
+			return errors.Wrapf(err, "failed to push %s", ref)
+		}
+	}
+	return nil
+}
+
+// var for unit testing.
+var trustedPush = func(dockerCli command.Cli, ref string) error {
+	return RunPush(dockerCli, pushOptions{remote: ref})
+}
+
+// pushImages pushes the images refs in parallel
+func pushImages(ctx context.Context, dockerCli command.Cli, refs []string, parallel int) error {
+	// Resolve the credentials of every registry up front, as prompting for
+	// them is not possible while images are pushed in parallel
+	auths := make(map[string]types.AuthConfig)
+	for _, ref := range refs {
+		named, err := reference.ParseNormalizedNamed(ref)
+		if err != nil {
+			return err
+		}
+		repoInfo, err := registry.ParseRepositoryInfo(named)
+		if err != nil {
+			return err
+		}
+		auths[ref] = command.ResolveAuthConfig(ctx, dockerCli, repoInfo.Index)
+	}
+
+	push := func(ctx context.Context, ref string) (io.ReadCloser, error) {
+		named, err := reference.ParseNormalizedNamed(ref)
+		if err != nil {
+			return nil, err
+		}
+		return imagePushPrivileged(ctx, dockerCli, auths[ref], named, nil)
+	}
+	errs := runParallel(ctx, dockerCli, refs, parallel, "Pushed", func(ctx context.Context, ref string, send func(jsonmessage.JSONMessage)) error {
+		return streamImageOperation(ctx, ref, push, send)
+	})
+	return summarizeParallel(dockerCli, "push", refs, errs)
+}
+
+// repositoryTags returns the local tags of the repository name
+func repositoryTags(ctx context.Context, dockerCli command.Cli, name string) ([]string, error) {
+	named, err := reference.ParseNormalizedNamed(name)
+	if err != nil {
+		return nil, err
+	}
+	if !reference.IsNameOnly(named) {
+		return nil, errors.New("tag can't be used with --all-tags/-a")
+	}
+	images, err := dockerCli.Client().ImageList(ctx, types.ImageListOptions{
+		Filters: filters.NewArgs(filters.Arg("reference", reference.FamiliarName(named))),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var refs []string
+	for _, image := range images {
+		for _, tag := range image.RepoTags {
+			tagged, err := reference.ParseNormalizedNamed(tag)
+			if err == nil && tagged.Name() == named.Name() {
+				refs = append(refs, reference.FamiliarString(tagged))
+			}
+		}
+	}
+	if len(refs) == 0 {
+		return nil, errors.Errorf("An image does not exist locally with the repository: %s", reference.FamiliarName(named))
+	}
+	sort.Strings(refs)
+	return refs, nil
+}
+
+// readImageList reads the image references of a file, one per line, or of
+// in if name is "-". Empty lines and lines starting with # are ignored.
+func readImageList(in io.Reader, name string) ([]string, error) {
+	r := in
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var refs []string
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		named, err := reference.ParseNormalizedNamed(line)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid image %q in %s", line, name)
+		}
+		ref := reference.FamiliarString(reference.TagNameOnly(named))
+		if !seen[ref] {
+			seen[ref] = true
+			refs = append(refs, ref)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(refs) == 0 {
+		return nil, errors.Errorf("no image found in %s", name)
+	}
+	return refs, nil
+}
+
+// summarizeParallel prints the outcome of an operation run on several
+// images, and returns an error listing the images it failed for
+func summarizeParallel(dockerCli command.Cli, operation string, refs []string, errs []error) error {
+	var failed []string
+	for i, err := range errs {
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", refs[i], err))
+		}
+	}
+	fmt.Fprintf(dockerCli.Out(), "\n%d of %d images succeeded\n", len(refs)-len(failed), len(refs))
+	if len(failed) > 0 {
+		return errors.Errorf("failed to %s %d images:\n%s", operation, len(failed), strings.Join(failed, "\n"))
+	}
+	return nil
+}
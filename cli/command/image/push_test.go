@@ -3,13 +3,19 @@ package image
 import (
 	"io"
 	"io/ioutil"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/yuyangjack/dockercli/cli/command"
 	"github.com/yuyangjack/dockercli/internal/test"
 	"github.com/yuyangjack/moby/api/types"
 	"github.com/pkg/errors"
 	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	"gotest.tools/fs"
 )
 
 func TestNewPushCommandErrors(t *testing.T) {
@@ -69,3 +75,110 @@ func TestNewPushCommandSuccess(t *testing.T) {
 		assert.NilError(t, cmd.Execute())
 	}
 }
+
+func TestPushAllTags(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		pushed []string
+	)
+	cli := test.NewFakeCli(&fakeClient{
+		imageListFunc: func(options types.ImageListOptions) ([]types.ImageSummary, error) {
+			assert.Check(t, is.DeepEqual([]string{"example.com/app"}, options.Filters.Get("reference")))
+			return []types.ImageSummary{
+				{RepoTags: []string{"example.com/app:2", "example.com/other:1"}},
+				{RepoTags: []string{"example.com/app:1"}},
+			}, nil
+		},
+		imagePushFunc: func(ref string, options types.ImagePushOptions) (io.ReadCloser, error) {
+			mu.Lock()
+			pushed = append(pushed, ref)
+			mu.Unlock()
+			return ioutil.NopCloser(strings.NewReader(`{"status":"Pushing","id":"abc"}`)), nil
+		},
+	})
+	cmd := NewPushCommand(cli)
+	cmd.SetOutput(ioutil.Discard)
+	cmd.SetArgs([]string{"--all-tags", "--disable-content-trust", "example.com/app"})
+	assert.NilError(t, cmd.Execute())
+	sort.Strings(pushed)
+	assert.Check(t, is.DeepEqual([]string{"example.com/app:1", "example.com/app:2"}, pushed))
+	assert.Check(t, is.Contains(cli.OutBuffer().String(), "example.com/app:1 abc: Pushing"))
+	assert.Check(t, is.Contains(cli.OutBuffer().String(), "example.com/app:2: Pushed"))
+	assert.Check(t, is.Contains(cli.OutBuffer().String(), "2 of 2 images succeeded"))
+}
+
+func TestPushAllTagsTrusted(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		running  int
+		parallel bool
+		pushed   []string
+	)
+	defer func(orig func(command.Cli, string) error) { trustedPush = orig }(trustedPush)
+	trustedPush = func(dockerCli command.Cli, ref string) error {
+		mu.Lock()
+		running++
+		parallel = parallel || running > 1
+		pushed = append(pushed, ref)
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return nil
+	}
+	cli := test.NewFakeCli(&fakeClient{
+		imageListFunc: func(options types.ImageListOptions) ([]types.ImageSummary, error) {
+			return []types.ImageSummary{{RepoTags: []string{"example.com/app:2", "example.com/app:1"}}}, nil
+		},
+	}, test.EnableContentTrust)
+	cmd := NewPushCommand(cli)
+	cmd.SetOutput(ioutil.Discard)
+	cmd.SetArgs([]string{"--all-tags", "example.com/app"})
+	assert.NilError(t, cmd.Execute())
+	// The images are signed one by one, in order
+	assert.Check(t, is.DeepEqual([]string{"example.com/app:1", "example.com/app:2"}, pushed))
+	assert.Check(t, !parallel)
+}
+
+func TestPushFromFile(t *testing.T) {
+	dir := fs.NewDir(t, "push-from-file", fs.WithFile("images.txt", "# images to migrate\napp:1\n\nregistry.example.com/db\napp:1\n"))
+	defer dir.Remove()
+
+	cli := test.NewFakeCli(&fakeClient{
+		imagePushFunc: func(ref string, options types.ImagePushOptions) (io.ReadCloser, error) {
+			if ref == "registry.example.com/db:latest" {
+				return ioutil.NopCloser(strings.NewReader(`{"errorDetail":{"message":"denied"},"error":"denied"}`)), nil
+			}
+			return ioutil.NopCloser(strings.NewReader("")), nil
+		},
+	})
+	cmd := NewPushCommand(cli)
+	cmd.SetOutput(ioutil.Discard)
+	cmd.SetArgs([]string{"--from-file", dir.Join("images.txt"), "--parallel", "1", "--disable-content-trust"})
+	assert.ErrorContains(t, cmd.Execute(), "failed to push 1 images:\nregistry.example.com/db:latest: denied")
+	assert.Check(t, is.Contains(cli.OutBuffer().String(), "1 of 2 images succeeded"))
+}
+
+func TestPushManyErrors(t *testing.T) {
+	testCases := []struct {
+		args          []string
+		expectedError string
+	}{
+		{args: []string{"--all-tags", "--disable-content-trust", "app:1"}, expectedError: "tag can't be used with --all-tags/-a"},
+		{args: []string{"--from-file", "images.txt", "app"}, expectedError: "accepts no arguments"},
+		{args: []string{"--all-tags", "--disable-content-trust", "--parallel", "0", "app"}, expectedError: "invalid parallel 0"},
+		{args: []string{"--all-tags", "--disable-content-trust", "app"}, expectedError: "An image does not exist locally with the repository: app"},
+	}
+	for _, tc := range testCases {
+		cli := test.NewFakeCli(&fakeClient{
+			imageListFunc: func(options types.ImageListOptions) ([]types.ImageSummary, error) {
+				return []types.ImageSummary{}, nil
+			},
+		})
+		cmd := NewPushCommand(cli)
+		cmd.SetOutput(ioutil.Discard)
+		cmd.SetArgs(tc.args)
+		assert.ErrorContains(t, cmd.Execute(), tc.expectedError)
+	}
+}
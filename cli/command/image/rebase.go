@@ -0,0 +1,196 @@
+package image
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/yuyangjack/dockercli/cli"
+	"github.com/yuyangjack/dockercli/cli/command"
+	"github.com/yuyangjack/distribution"
+	"github.com/yuyangjack/distribution/manifest/schema2"
+	"github.com/yuyangjack/distribution/reference"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+type rebaseOptions struct {
+	image    string
+	oldBase  string
+	newBase  string
+	tag      string
+	platform string
+	insecure bool
+}
+
+// NewRebaseCommand creates a new `docker image rebase` command
+func NewRebaseCommand(dockerCli command.Cli) *cobra.Command {
+	var opts rebaseOptions
+
+	cmd := &cobra.Command{
+		Use:   "rebase [OPTIONS] IMAGE[:TAG|@DIGEST]",
+		Short: "Replace the base image of an image in a registry without rebuilding it",
+		Args:  cli.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.image = args[0]
+			return runRebase(dockerCli, opts)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&opts.oldBase, "old-base", "", "Base image the image was built from (required)")
+	flags.StringVar(&opts.newBase, "new-base", "", "Base image to rebase the image onto (required)")
+	flags.StringVarP(&opts.tag, "tag", "t", "", "Name and tag of the rebased image (required)")
+	flags.StringVar(&opts.platform, "platform", "", "Platform to rebase when the images are manifest lists (os[/arch[/variant]], default linux on the client architecture)")
+	flags.BoolVar(&opts.insecure, "insecure", false, "Allow communication with an insecure registry")
+	return cmd
+}
+
+func runRebase(dockerCli command.Cli, opts rebaseOptions) error {
+	switch {
+	case opts.oldBase == "":
+		return errors.New("the base image the image was built from must be set with --old-base")
+	case opts.newBase == "":
+		return errors.New("the base image to rebase onto must be set with --new-base")
+	case opts.tag == "":
+		return errors.New("a target image must be set with --tag")
+	}
+	target, err := normalizeRegistryReference(opts.tag)
+	if err != nil {
+		return err
+	}
+	if _, isCanonical := target.(reference.Canonical); isCanonical {
+		return errors.Errorf("invalid target %s: cannot push to a digest", opts.tag)
+	}
+	ctx := context.Background()
+	platform, err := resolvePlatform(opts.platform)
+	if err != nil {
+		return err
+	}
+
+	client := dockerCli.RegistryClient(opts.insecure)
+	image, err := loadRegistryImage(ctx, client, opts.image, platform)
+	if err != nil {
+		return err
+	}
+	oldBase, err := loadRegistryImage(ctx, client, opts.oldBase, platform)
+	if err != nil {
+		return err
+	}
+	newBase, err := loadRegistryImage(ctx, client, opts.newBase, platform)
+	if err != nil {
+		return err
+	}
+	if err := rebaseImage(image, oldBase, newBase); err != nil {
+		return errors.Wrapf(err, "cannot rebase %s", opts.image)
+	}
+
+	dgst, err := image.push(ctx, client, target)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(dockerCli.Out(), "%s@%s\n", reference.FamiliarName(target), dgst)
+	return nil
+}
+
+// rebaseImage replaces the layers of oldBase at the bottom of image with the
+// layers of newBase, in the manifest and in the config of image, together
+// with their history. It fails if image is not based on oldBase, or if the
+// history of oldBase is not the bottom of the history of image, as the
+// history would no longer match the layers.
+func rebaseImage(image, oldBase, newBase *registryImage) error {
+	if newBase.config.OS != image.config.OS || newBase.config.Architecture != image.config.Architecture {
+		return errors.Errorf("new base image is %s/%s, but the image is %s/%s",
+			newBase.config.OS, newBase.config.Architecture, image.config.OS, image.config.Architecture)
+	}
+	diffIDs := image.config.RootFS.DiffIDs
+	oldDiffIDs := oldBase.config.RootFS.DiffIDs
+	if len(image.layers) != len(diffIDs) || len(oldBase.layers) != len(oldDiffIDs) || len(newBase.layers) != len(newBase.config.RootFS.DiffIDs) {
+		return errors.New("the layers of the manifest do not match the config")
+	}
+	if !isLayerPrefix(oldDiffIDs, diffIDs) {
+		return errors.Errorf("the image is not based on %s: its bottom layers differ", reference.FamiliarName(oldBase.ref))
+	}
+	oldHistory := oldBase.config.History
+	if !isHistoryPrefix(oldHistory, image.config.History) {
+		return errors.Errorf("the history of the image does not start with the history of %s", reference.FamiliarName(oldBase.ref))
+	}
+
+	layers := make([]distribution.Descriptor, 0, len(newBase.layers)+len(image.layers)-len(oldBase.layers))
+	for _, layer := range newBase.layers {
+		layer.MediaType = convertLayerMediaType(layer.MediaType, image.oci)
+		layers = append(layers, layer)
+		image.mounts[layer.Digest] = newBase.ref
+	}
+	image.layers = append(layers, image.layers[len(oldBase.layers):]...)
+
+	newDiffIDs := append([]digest.Digest{}, newBase.config.RootFS.DiffIDs...)
+	image.config.RootFS.DiffIDs = append(newDiffIDs, diffIDs[len(oldDiffIDs):]...)
+
+	history := append([]ocispec.History{}, newBase.config.History...)
+	image.config.History = append(history, image.config.History[len(oldHistory):]...)
+	if len(image.config.History) > 0 && countLayerHistory(image.config.History) != len(image.layers) {
+		return errors.New("the history of the rebased image does not match its layers")
+	}
+	return nil
+}
+
+// countLayerHistory returns the number of history entries that created a
+// layer
+func countLayerHistory(history []ocispec.History) int {
+	n := 0
+	for _, h := range history {
+		if !h.EmptyLayer {
+			n++
+		}
+	}
+	return n
+}
+
+// isHistoryPrefix reports whether the history entries base are the bottom
+// entries of history, comparing the commands that created them
+func isHistoryPrefix(base, history []ocispec.History) bool {
+	if len(base) > len(history) {
+		return false
+	}
+	for i := range base {
+		if base[i].CreatedBy != history[i].CreatedBy || base[i].EmptyLayer != history[i].EmptyLayer {
+			return false
+		}
+	}
+	return true
+}
+
+// isLayerPrefix reports whether the layers with diff IDs base are the bottom
+// layers of the layers with diff IDs layers
+func isLayerPrefix(base, layers []digest.Digest) bool {
+	if len(base) > len(layers) {
+		return false
+	}
+	for i := range base {
+		if base[i] != layers[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// convertLayerMediaType returns the media type of a layer in an OCI manifest
+// if oci is set, or in a Docker schema2 manifest otherwise
+func convertLayerMediaType(mediaType string, oci bool) string {
+	ociTypes := map[string]string{
+		schema2.MediaTypeLayer:             ocispec.MediaTypeImageLayerGzip,
+		schema2.MediaTypeUncompressedLayer: ocispec.MediaTypeImageLayer,
+		schema2.MediaTypeForeignLayer:      ocispec.MediaTypeImageLayerNonDistributableGzip,
+	}
+	for schema2Type, ociType := range ociTypes {
+		switch {
+		case oci && mediaType == schema2Type:
+			return ociType
+		case !oci && mediaType == ociType:
+			return schema2Type
+		}
+	}
+	return mediaType
+}
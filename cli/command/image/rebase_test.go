@@ -0,0 +1,269 @@
+package image
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/yuyangjack/dockercli/internal/test"
+	manifesttypes "github.com/yuyangjack/dockercli/cli/manifest/types"
+	"github.com/yuyangjack/distribution"
+	"github.com/yuyangjack/distribution/manifest/schema2"
+	"github.com/yuyangjack/distribution/reference"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+type testRegistryImage struct {
+	manifest *schema2.DeserializedManifest
+	config   []byte
+}
+
+// newTestRegistryImage returns a schema2 image with one layer for each of
+// layers, whose content is the name of the layer, and with the fields of
+// testDockerConfigFields in its container config
+func newTestRegistryImage(t *testing.T, layers ...string) testRegistryImage {
+	config := ocispec.Image{
+		Architecture: "amd64",
+		OS:           "linux",
+		RootFS:       ocispec.RootFS{Type: "layers"},
+	}
+	m := schema2.Manifest{Versioned: schema2.SchemaVersion}
+	for _, layer := range layers {
+		m.Layers = append(m.Layers, distribution.Descriptor{
+			MediaType: schema2.MediaTypeLayer,
+			Digest:    digest.FromString("blob-" + layer),
+			Size:      int64(len(layer)),
+		})
+		config.RootFS.DiffIDs = append(config.RootFS.DiffIDs, digest.FromString(layer))
+		config.History = append(config.History, ocispec.History{CreatedBy: "ADD " + layer})
+	}
+	spec, err := json.Marshal(config)
+	assert.NilError(t, err)
+	var rawConfig map[string]json.RawMessage
+	assert.NilError(t, json.Unmarshal(spec, &rawConfig))
+	rawConfig["config"] = json.RawMessage(`{` + testDockerConfigFields + `}`)
+	configJSON, err := json.Marshal(rawConfig)
+	assert.NilError(t, err)
+	m.Config = distribution.Descriptor{MediaType: schema2.MediaTypeImageConfig, Digest: digest.FromBytes(configJSON), Size: int64(len(configJSON))}
+	mf, err := schema2.FromStruct(m)
+	assert.NilError(t, err)
+	return testRegistryImage{manifest: mf, config: configJSON}
+}
+
+// newTestRebaseClient returns a registry client serving images by repository
+// name, which records the blobs that are copied and the config and manifest
+// that are pushed
+func newTestRebaseClient(images map[string]testRegistryImage, copied map[string][]digest.Digest, pushed *ocispec.Image, manifest *distribution.Manifest) *fakeRegistryClient {
+	return &fakeRegistryClient{
+		getDistributionManifestFunc: func(_ context.Context, ref reference.Named) (distribution.Manifest, error) {
+			return images[reference.FamiliarName(ref)].manifest, nil
+		},
+		getImageConfigFunc: func(_ context.Context, ref reference.Named) (manifesttypes.ImageManifest, []byte, error) {
+			image := images[reference.FamiliarName(ref)]
+			return manifesttypes.ImageManifest{SchemaV2Manifest: image.manifest}, image.config, nil
+		},
+		copyBlobFunc: func(_ context.Context, source, _ reference.Named, desc distribution.Descriptor) error {
+			name := reference.FamiliarName(source)
+			copied[name] = append(copied[name], desc.Digest)
+			return nil
+		},
+		putBlobFunc: func(_ context.Context, _ reference.Named, mediaType string, content []byte) (distribution.Descriptor, error) {
+			if err := json.Unmarshal(content, pushed); err != nil {
+				return distribution.Descriptor{}, err
+			}
+			return distribution.Descriptor{MediaType: mediaType, Digest: digest.FromBytes(content), Size: int64(len(content))}, nil
+		},
+		putManifestFunc: func(_ context.Context, _ reference.Named, m distribution.Manifest) (digest.Digest, error) {
+			*manifest = m
+			return "", nil
+		},
+	}
+}
+
+func TestNewRebaseCommandErrors(t *testing.T) {
+	images := map[string]testRegistryImage{
+		"app":         newTestRegistryImage(t, "debian-9.5", "app"),
+		"debian":      newTestRegistryImage(t, "debian-9.5"),
+		"alpine":      newTestRegistryImage(t, "alpine-3.8"),
+		"debian-next": newTestRegistryImage(t, "debian-9.6"),
+	}
+	testCases := []struct {
+		name          string
+		args          []string
+		expectedError string
+	}{
+		{
+			name:          "wrong-args",
+			args:          []string{},
+			expectedError: "requires exactly 1 argument.",
+		},
+		{
+			name:          "no-old-base",
+			args:          []string{"app", "--new-base", "debian-next", "-t", "app:patched"},
+			expectedError: "must be set with --old-base",
+		},
+		{
+			name:          "no-new-base",
+			args:          []string{"app", "--old-base", "debian", "-t", "app:patched"},
+			expectedError: "must be set with --new-base",
+		},
+		{
+			name:          "no-tag",
+			args:          []string{"app", "--old-base", "debian", "--new-base", "debian-next"},
+			expectedError: "a target image must be set with --tag",
+		},
+		{
+			name:          "base-mismatch",
+			args:          []string{"app", "--old-base", "alpine", "--new-base", "debian-next", "-t", "app:patched"},
+			expectedError: "cannot rebase app: the image is not based on alpine: its bottom layers differ",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var manifest distribution.Manifest
+			cli := test.NewFakeCli(&fakeClient{})
+			cli.SetRegistryClient(newTestRebaseClient(images, map[string][]digest.Digest{}, &ocispec.Image{}, &manifest))
+			cmd := NewRebaseCommand(cli)
+			cmd.SetOutput(ioutil.Discard)
+			cmd.SetArgs(tc.args)
+			assert.ErrorContains(t, cmd.Execute(), tc.expectedError)
+			assert.Check(t, is.Nil(manifest))
+		})
+	}
+}
+
+func TestRebase(t *testing.T) {
+	images := map[string]testRegistryImage{
+		"app":         newTestRegistryImage(t, "debian-9.5-a", "debian-9.5-b", "app-deps", "app"),
+		"debian":      newTestRegistryImage(t, "debian-9.5-a", "debian-9.5-b"),
+		"debian-next": newTestRegistryImage(t, "debian-9.6"),
+	}
+	copied := map[string][]digest.Digest{}
+	var (
+		config     ocispec.Image
+		configJSON []byte
+		manifest   distribution.Manifest
+	)
+	registryClient := newTestRebaseClient(images, copied, &config, &manifest)
+	putBlob := registryClient.putBlobFunc
+	registryClient.putBlobFunc = func(ctx context.Context, ref reference.Named, mediaType string, content []byte) (distribution.Descriptor, error) {
+		configJSON = content
+		return putBlob(ctx, ref, mediaType, content)
+	}
+	cli := test.NewFakeCli(&fakeClient{})
+	cli.SetRegistryClient(registryClient)
+	cmd := NewRebaseCommand(cli)
+	cmd.SetArgs([]string{"app", "--old-base", "debian", "--new-base", "debian-next", "-t", "example.com/app:patched"})
+	assert.NilError(t, cmd.Execute())
+
+	// The layers are copied from the image they come from
+	assert.Check(t, is.DeepEqual(map[string][]digest.Digest{
+		"debian-next": {digest.FromString("blob-debian-9.6")},
+		"app":         {digest.FromString("blob-app-deps"), digest.FromString("blob-app")},
+	}, copied))
+
+	assert.Assert(t, manifest != nil)
+	var layers []digest.Digest
+	for _, layer := range manifest.(*schema2.DeserializedManifest).Layers {
+		layers = append(layers, layer.Digest)
+	}
+	assert.Check(t, is.DeepEqual([]digest.Digest{
+		digest.FromString("blob-debian-9.6"),
+		digest.FromString("blob-app-deps"),
+		digest.FromString("blob-app"),
+	}, layers))
+	assert.Check(t, is.DeepEqual([]digest.Digest{
+		digest.FromString("debian-9.6"),
+		digest.FromString("app-deps"),
+		digest.FromString("app"),
+	}, config.RootFS.DiffIDs))
+	var history []string
+	for _, h := range config.History {
+		history = append(history, h.CreatedBy)
+	}
+	assert.Check(t, is.DeepEqual([]string{"ADD debian-9.6", "ADD app-deps", "ADD app"}, history))
+	checkDockerConfigFields(t, configJSON)
+
+	_, payload, err := manifest.Payload()
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal("example.com/app@"+digest.FromBytes(payload).String()+"\n", cli.OutBuffer().String()))
+}
+
+func TestRebaseImageHistory(t *testing.T) {
+	newImage := func(layers []string, history ...string) *registryImage {
+		named, err := reference.ParseNormalizedNamed(layers[0])
+		assert.NilError(t, err)
+		ref, err := reference.WithDigest(named, digest.FromString(layers[0]))
+		assert.NilError(t, err)
+		image := &registryImage{
+			ref:    ref,
+			config: ocispec.Image{OS: "linux", Architecture: "amd64"},
+			mounts: map[digest.Digest]reference.Canonical{},
+		}
+		for _, layer := range layers {
+			image.layers = append(image.layers, distribution.Descriptor{MediaType: schema2.MediaTypeLayer, Digest: digest.FromString("blob-" + layer)})
+			image.config.RootFS.DiffIDs = append(image.config.RootFS.DiffIDs, digest.FromString(layer))
+		}
+		// An empty command is an entry that did not create a layer
+		for _, createdBy := range history {
+			image.config.History = append(image.config.History, ocispec.History{CreatedBy: createdBy, EmptyLayer: createdBy == ""})
+		}
+		return image
+	}
+	testCases := []struct {
+		doc             string
+		image           *registryImage
+		oldBase         *registryImage
+		newBase         *registryImage
+		expectedHistory []ocispec.History
+		expectedError   string
+	}{
+		{
+			doc:     "history replaced",
+			image:   newImage([]string{"debian-9.5", "app"}, "ADD debian-9.5", "ADD app"),
+			oldBase: newImage([]string{"debian-9.5"}, "ADD debian-9.5"),
+			// The new base has an empty layer entry, such as CMD
+			newBase:         newImage([]string{"debian-9.6"}, "ADD debian-9.6", ""),
+			expectedHistory: []ocispec.History{{CreatedBy: "ADD debian-9.6"}, {CreatedBy: "", EmptyLayer: true}, {CreatedBy: "ADD app"}},
+		},
+		{
+			// The image was built from a base image with the same layers,
+			// but whose history was squashed into a single entry
+			doc:           "history not a prefix",
+			image:         newImage([]string{"debian-9.5", "app"}, "ADD debian-9.5", "ADD app"),
+			oldBase:       newImage([]string{"debian-9.5"}, "ADD rootfs"),
+			newBase:       newImage([]string{"debian-9.6"}, "ADD debian-9.6"),
+			expectedError: "the history of the image does not start with the history of debian-9.5",
+		},
+		{
+			doc:           "history of the new base does not match its layers",
+			image:         newImage([]string{"debian-9.5", "app"}, "ADD debian-9.5", "ADD app"),
+			oldBase:       newImage([]string{"debian-9.5"}, "ADD debian-9.5"),
+			newBase:       newImage([]string{"debian-9.6"}, "ADD debian-9.6-a", "ADD debian-9.6-b"),
+			expectedError: "the history of the rebased image does not match its layers",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.doc, func(t *testing.T) {
+			err := rebaseImage(tc.image, tc.oldBase, tc.newBase)
+			if tc.expectedError != "" {
+				assert.Check(t, is.ErrorContains(err, tc.expectedError))
+				return
+			}
+			assert.NilError(t, err)
+			assert.Check(t, is.DeepEqual([]digest.Digest{digest.FromString("debian-9.6"), digest.FromString("app")}, tc.image.config.RootFS.DiffIDs))
+			assert.Check(t, is.DeepEqual(tc.expectedHistory, tc.image.config.History))
+		})
+	}
+}
+
+func TestConvertLayerMediaType(t *testing.T) {
+	assert.Check(t, is.Equal(ocispec.MediaTypeImageLayerGzip, convertLayerMediaType(schema2.MediaTypeLayer, true)))
+	assert.Check(t, is.Equal(schema2.MediaTypeLayer, convertLayerMediaType(ocispec.MediaTypeImageLayerGzip, false)))
+	assert.Check(t, is.Equal(schema2.MediaTypeForeignLayer, convertLayerMediaType(schema2.MediaTypeForeignLayer, false)))
+	assert.Check(t, is.Equal(ocispec.MediaTypeImageLayer, convertLayerMediaType(ocispec.MediaTypeImageLayer, true)))
+}
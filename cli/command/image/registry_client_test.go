@@ -0,0 +1,73 @@
+package image
+
+import (
+	"context"
+	"io"
+
+	manifesttypes "github.com/yuyangjack/dockercli/cli/manifest/types"
+	"github.com/yuyangjack/dockercli/cli/registry/client"
+	"github.com/yuyangjack/distribution"
+	"github.com/yuyangjack/distribution/reference"
+	"github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+)
+
+type fakeRegistryClient struct {
+	client.RegistryClient
+	getDistributionManifestFunc func(ctx context.Context, ref reference.Named) (distribution.Manifest, error)
+	copyBlobFunc                func(ctx context.Context, source reference.Named, target reference.Named, desc distribution.Descriptor) error
+	putManifestFunc             func(ctx context.Context, ref reference.Named, mf distribution.Manifest) (digest.Digest, error)
+	getManifestFunc             func(ctx context.Context, ref reference.Named) (manifesttypes.ImageManifest, error)
+	getImageConfigFunc          func(ctx context.Context, ref reference.Named) (manifesttypes.ImageManifest, []byte, error)
+	getBlobFunc                 func(ctx context.Context, ref reference.Named, dgst digest.Digest) (io.ReadCloser, error)
+	putBlobFunc                 func(ctx context.Context, ref reference.Named, mediaType string, content []byte) (distribution.Descriptor, error)
+}
+
+func (c *fakeRegistryClient) GetDistributionManifest(ctx context.Context, ref reference.Named) (distribution.Manifest, error) {
+	if c.getDistributionManifestFunc != nil {
+		return c.getDistributionManifestFunc(ctx, ref)
+	}
+	return nil, nil
+}
+
+func (c *fakeRegistryClient) CopyBlob(ctx context.Context, source reference.Named, target reference.Named, desc distribution.Descriptor) error {
+	if c.copyBlobFunc != nil {
+		return c.copyBlobFunc(ctx, source, target, desc)
+	}
+	return nil
+}
+
+func (c *fakeRegistryClient) PutManifest(ctx context.Context, ref reference.Named, mf distribution.Manifest) (digest.Digest, error) {
+	if c.putManifestFunc != nil {
+		return c.putManifestFunc(ctx, ref, mf)
+	}
+	return digest.Digest(""), nil
+}
+
+func (c *fakeRegistryClient) GetManifest(ctx context.Context, ref reference.Named) (manifesttypes.ImageManifest, error) {
+	if c.getManifestFunc != nil {
+		return c.getManifestFunc(ctx, ref)
+	}
+	return manifesttypes.ImageManifest{}, nil
+}
+
+func (c *fakeRegistryClient) GetImageConfig(ctx context.Context, ref reference.Named) (manifesttypes.ImageManifest, []byte, error) {
+	if c.getImageConfigFunc != nil {
+		return c.getImageConfigFunc(ctx, ref)
+	}
+	return manifesttypes.ImageManifest{}, nil, nil
+}
+
+func (c *fakeRegistryClient) GetBlob(ctx context.Context, ref reference.Named, dgst digest.Digest) (io.ReadCloser, error) {
+	if c.getBlobFunc != nil {
+		return c.getBlobFunc(ctx, ref, dgst)
+	}
+	return nil, errors.Errorf("blob %s not found", dgst)
+}
+
+func (c *fakeRegistryClient) PutBlob(ctx context.Context, ref reference.Named, mediaType string, content []byte) (distribution.Descriptor, error) {
+	if c.putBlobFunc != nil {
+		return c.putBlobFunc(ctx, ref, mediaType, content)
+	}
+	return distribution.Descriptor{MediaType: mediaType, Digest: digest.FromBytes(content), Size: int64(len(content))}, nil
+}
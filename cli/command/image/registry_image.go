@@ -0,0 +1,265 @@
+package image
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"time"
+
+	registryclient "github.com/yuyangjack/dockercli/cli/registry/client"
+	"github.com/yuyangjack/distribution"
+	"github.com/yuyangjack/distribution/manifest/manifestlist"
+	"github.com/yuyangjack/distribution/manifest/ocischema"
+	"github.com/yuyangjack/distribution/manifest/schema2"
+	"github.com/yuyangjack/distribution/reference"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+// registryImage is an image read from a registry to be modified and pushed
+// back, without a daemon. Only the manifest and config are downloaded; the
+// layers stay in the registry.
+type registryImage struct {
+	// ref is the reference by digest of the image
+	ref reference.Canonical
+	// oci is set if the image has an OCI manifest, rather than a Docker
+	// schema2 manifest
+	oci    bool
+	config ocispec.Image
+	// rawConfig holds the fields of the config that are not part of the OCI
+	// image spec, such as container_config, so that they are kept
+	rawConfig map[string]json.RawMessage
+	layers    []distribution.Descriptor
+	// newBlobs are the blobs to upload when the image is pushed
+	newBlobs map[digest.Digest][]byte
+	// mounts maps the layers taken from other images, such as a new base
+	// image, to the image they are copied from
+	mounts map[digest.Digest]reference.Canonical
+}
+
+// loadRegistryImage reads the manifest and config of name. If name refers to
+// a manifest list, the image matching platform is used.
+func loadRegistryImage(ctx context.Context, client registryclient.RegistryClient, name string, platform manifestlist.PlatformSpec) (*registryImage, error) {
+	ref, err := normalizeRegistryReference(name)
+	if err != nil {
+		return nil, err
+	}
+	imageRef, _, err := resolveRemoteImage(ctx, client, ref, platform)
+	if err != nil {
+		return nil, err
+	}
+	imageManifest, configJSON, err := client.GetImageConfig(ctx, imageRef)
+	if err != nil {
+		return nil, err
+	}
+	image := &registryImage{
+		ref:      imageRef,
+		oci:      imageManifest.OCIManifest != nil,
+		layers:   imageManifestLayers(imageManifest),
+		newBlobs: make(map[digest.Digest][]byte),
+		mounts:   make(map[digest.Digest]reference.Canonical),
+	}
+	if err := json.Unmarshal(configJSON, &image.config); err != nil {
+		return nil, errors.Wrapf(err, "invalid image config for %s", name)
+	}
+	if err := json.Unmarshal(configJSON, &image.rawConfig); err != nil {
+		return nil, errors.Wrapf(err, "invalid image config for %s", name)
+	}
+	return image, nil
+}
+
+func (i *registryImage) layerMediaType() string {
+	if i.oci {
+		return ocispec.MediaTypeImageLayerGzip
+	}
+	return schema2.MediaTypeLayer
+}
+
+func (i *registryImage) configMediaType() string {
+	if i.oci {
+		return ocispec.MediaTypeImageConfig
+	}
+	return schema2.MediaTypeImageConfig
+}
+
+// appendLayer adds the layer tar read from r, which may be gzip compressed,
+// on top of the image, with a history entry created by createdBy
+func (i *registryImage) appendLayer(r io.Reader, createdBy string) error {
+	blob, diffID, err := compressLayer(r)
+	if err != nil {
+		return err
+	}
+	desc := distribution.Descriptor{
+		MediaType: i.layerMediaType(),
+		Digest:    digest.FromBytes(blob),
+		Size:      int64(len(blob)),
+	}
+	i.newBlobs[desc.Digest] = blob
+	i.layers = append(i.layers, desc)
+	i.config.RootFS.DiffIDs = append(i.config.RootFS.DiffIDs, diffID)
+	now := time.Now().UTC()
+	i.config.History = append(i.config.History, ocispec.History{Created: &now, CreatedBy: createdBy})
+	return nil
+}
+
+// compressLayer returns the gzip compressed blob of a layer tar, and the
+// digest of the uncompressed tar. Layers that are already compressed are
+// kept as they are.
+func compressLayer(r io.Reader) ([]byte, digest.Digest, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(2)
+	if bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		blob, err := ioutil.ReadAll(br)
+		if err != nil {
+			return nil, "", err
+		}
+		gz, err := gzip.NewReader(bytes.NewReader(blob))
+		if err != nil {
+			return nil, "", err
+		}
+		defer gz.Close()
+		diffID, err := digest.Canonical.FromReader(gz)
+		if err != nil {
+			return nil, "", errors.Wrap(err, "invalid compressed layer")
+		}
+		return blob, diffID, nil
+	}
+
+	buf := &bytes.Buffer{}
+	digester := digest.Canonical.Digester()
+	gz := gzip.NewWriter(buf)
+	if _, err := io.Copy(io.MultiWriter(gz, digester.Hash()), br); err != nil {
+		return nil, "", err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), digester.Digest(), nil
+}
+
+// configJSON returns the config of the image, keeping the fields that are
+// not part of the OCI image spec
+func (i *registryImage) configJSON() ([]byte, error) {
+	return mergeImageConfig(i.rawConfig, i.config)
+}
+
+var (
+	// ociImageFields are the fields of ocispec.Image
+	ociImageFields = []string{"created", "author", "architecture", "os", "config", "rootfs", "history"}
+	// ociImageConfigFields are the fields of ocispec.ImageConfig
+	ociImageConfigFields = []string{"User", "ExposedPorts", "Env", "Entrypoint", "Cmd", "Volumes", "WorkingDir", "Labels", "StopSignal"}
+)
+
+// mergeImageConfig returns the image config rawConfig updated with the
+// fields of config, keeping the fields that are not part of the OCI image
+// spec, such as the Healthcheck, OnBuild and Shell of the container config
+func mergeImageConfig(rawConfig map[string]json.RawMessage, config ocispec.Image) ([]byte, error) {
+	fields, err := mergeJSONFields(rawConfig, config, ociImageFields)
+	if err != nil {
+		return nil, err
+	}
+	var rawContainerConfig map[string]json.RawMessage
+	if raw, ok := rawConfig["config"]; ok {
+		if err := json.Unmarshal(raw, &rawContainerConfig); err != nil {
+			return nil, errors.Wrap(err, "invalid image config")
+		}
+	}
+	containerConfig, err := mergeJSONFields(rawContainerConfig, config.Config, ociImageConfigFields)
+	if err != nil {
+		return nil, err
+	}
+	if fields["config"], err = json.Marshal(containerConfig); err != nil {
+		return nil, err
+	}
+	return json.Marshal(fields)
+}
+
+// mergeJSONFields returns the fields of the JSON object raw, with the spec
+// fields replaced by the ones of v. Spec fields that are omitted from v are
+// removed.
+func mergeJSONFields(raw map[string]json.RawMessage, v interface{}, spec []string) (map[string]json.RawMessage, error) {
+	content, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var specFields map[string]json.RawMessage
+	if err := json.Unmarshal(content, &specFields); err != nil {
+		return nil, err
+	}
+	fields := make(map[string]json.RawMessage)
+	for k, v := range raw {
+		fields[k] = v
+	}
+	for _, k := range spec {
+		delete(fields, k)
+	}
+	for k, v := range specFields {
+		fields[k] = v
+	}
+	return fields, nil
+}
+
+// push uploads the new blobs and config of the image to the repository of
+// target, mounting the layers of the original images if target is in another
+// repository, and pushes the manifest under target
+func (i *registryImage) push(ctx context.Context, client registryclient.RegistryClient, target reference.Named) (digest.Digest, error) {
+	repo := reference.TrimNamed(target)
+	for _, layer := range i.layers {
+		if blob, ok := i.newBlobs[layer.Digest]; ok {
+			if _, err := client.PutBlob(ctx, repo, layer.MediaType, blob); err != nil {
+				return "", errors.Wrapf(err, "failed to push layer %s", layer.Digest)
+			}
+			continue
+		}
+		source := i.ref
+		if mount, ok := i.mounts[layer.Digest]; ok {
+			source = mount
+		}
+		if isForeignLayer(layer.MediaType) || repo.Name() == source.Name() {
+			continue
+		}
+		if err := client.CopyBlob(ctx, source, repo, layer); err != nil {
+			return "", err
+		}
+	}
+
+	configJSON, err := i.configJSON()
+	if err != nil {
+		return "", err
+	}
+	config, err := client.PutBlob(ctx, repo, i.configMediaType(), configJSON)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to push image config")
+	}
+
+	var mf distribution.Manifest
+	if i.oci {
+		mf, err = ocischema.FromStruct(ocischema.Manifest{
+			Versioned: ocischema.SchemaVersion,
+			Config:    config,
+			Layers:    i.layers,
+		})
+	} else {
+		mf, err = schema2.FromStruct(schema2.Manifest{
+			Versioned: schema2.SchemaVersion,
+			Config:    config,
+			Layers:    i.layers,
+		})
+	}
+	if err != nil {
+		return "", err
+	}
+	_, payload, err := mf.Payload()
+	if err != nil {
+		return "", err
+	}
+	if _, err := client.PutManifest(ctx, target, mf); err != nil {
+		return "", err
+	}
+	return digest.FromBytes(payload), nil
+}
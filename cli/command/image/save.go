@@ -13,10 +13,18 @@ import (
 )
 
 type saveOptions struct {
-	images []string
-	output string
+	images      []string
+	output      string
+	format      string
+	compression string
+	platform    string
 }
 
+const (
+	saveFormatDocker = "docker"
+	saveFormatOCI    = "oci"
+)
+
 // NewSaveCommand creates a new `docker save` command
 func NewSaveCommand(dockerCli command.Cli) *cobra.Command {
 	var opts saveOptions
@@ -34,6 +42,9 @@ func NewSaveCommand(dockerCli command.Cli) *cobra.Command {
 	flags := cmd.Flags()
 
 	flags.StringVarP(&opts.output, "output", "o", "", "Write to a file, instead of STDOUT")
+	flags.StringVar(&opts.format, "format", saveFormatDocker, "Format of the saved images (\"docker\"|\"oci\")")
+	flags.StringVar(&opts.compression, "compression", "", "Compression of image layers with --format oci (\"none\"|\"gzip\"|\"zstd\")")
+	flags.StringVar(&opts.platform, "platform", "", "Only save images for this platform (os[/arch[/variant]]) with --format oci")
 
 	return cmd
 }
@@ -44,6 +55,24 @@ func RunSave(dockerCli command.Cli, opts saveOptions) error {
 		return errors.New("cowardly refusing to save to a terminal. Use the -o flag or redirect")
 	}
 
+	switch opts.format {
+	case saveFormatDocker:
+		if opts.compression != "" || opts.platform != "" {
+			return errors.New("--compression and --platform can only be used with --format oci")
+		}
+	case saveFormatOCI:
+		switch opts.compression {
+		case "":
+			opts.compression = compressionNone
+		case compressionNone, compressionGzip, compressionZstd:
+		default:
+			return errors.Errorf("invalid compression %q: must be one of none, gzip or zstd", opts.compression)
+		}
+		return runSaveOCI(dockerCli, opts)
+	default:
+		return errors.Errorf("invalid format %q: must be docker or oci", opts.format)
+	}
+
 	if err := validateOutputPath(opts.output); err != nil {
 		return errors.Wrap(err, "failed to save image")
 	}
@@ -0,0 +1,175 @@
+package image
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/yuyangjack/dockercli/cli/command"
+	"github.com/yuyangjack/distribution/manifest/manifestlist"
+	"github.com/yuyangjack/moby/pkg/archive"
+	specs "github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+// runSaveOCI saves images as an OCI image layout, converting the `docker
+// save` archive of the daemon on the client. The layout is written to a
+// directory, or as a tar archive if the output ends in .tar or is STDOUT.
+func runSaveOCI(dockerCli command.Cli, opts saveOptions) error {
+	var platform *manifestlist.PlatformSpec
+	if opts.platform != "" {
+		p, err := resolveDaemonPlatform(context.Background(), dockerCli, opts.platform)
+		if err != nil {
+			return err
+		}
+		platform = &p
+	}
+
+	toTar := opts.output == "" || strings.HasSuffix(opts.output, ".tar")
+	validate := validateLayoutDir
+	if toTar {
+		validate = validateOutputPath
+	}
+	if err := validate(opts.output); err != nil {
+		return errors.Wrap(err, "failed to save image")
+	}
+
+	tmpDir, err := ioutil.TempDir("", "docker-save-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	responseBody, err := dockerCli.Client().ImageSave(context.Background(), opts.images)
+	if err != nil {
+		return err
+	}
+	defer responseBody.Close()
+
+	archiveDir := filepath.Join(tmpDir, "archive")
+	if err := archive.Untar(responseBody, archiveDir, &archive.TarOptions{NoLchown: true}); err != nil {
+		return errors.Wrap(err, "failed to read image archive")
+	}
+
+	layoutDir := opts.output
+	if toTar {
+		layoutDir = filepath.Join(tmpDir, "layout")
+	}
+	if err := convertDockerArchiveToOCI(archiveDir, layoutDir, opts.compression, platform); err != nil {
+		return err
+	}
+	if !toTar {
+		return nil
+	}
+
+	layoutTar, err := archive.Tar(layoutDir, archive.Uncompressed)
+	if err != nil {
+		return err
+	}
+	defer layoutTar.Close()
+	if opts.output == "" {
+		_, err := io.Copy(dockerCli.Out(), layoutTar)
+		return err
+	}
+	return command.CopyToFile(opts.output, layoutTar)
+}
+
+// validateLayoutDir checks that an OCI layout can be written to dir, which
+// must not exist or be empty
+func validateLayoutDir(dir string) error {
+	entries, err := ioutil.ReadDir(dir)
+	switch {
+	case os.IsNotExist(err):
+		return validateOutputPath(dir)
+	case err != nil:
+		return err
+	case len(entries) > 0:
+		return errors.Errorf("output directory %q is not empty", dir)
+	}
+	return nil
+}
+
+// convertDockerArchiveToOCI converts the extracted `docker save` archive in
+// src to an OCI image layout in dst. If platform is set, only images for that
+// platform are included.
+func convertDockerArchiveToOCI(src, dst, compression string, platform *manifestlist.PlatformSpec) error {
+	content, err := ioutil.ReadFile(filepath.Join(src, dockerArchiveManifestFile))
+	if err != nil {
+		return errors.Wrap(err, "failed to read image archive manifest")
+	}
+	var images []dockerArchiveManifest
+	if err := json.Unmarshal(content, &images); err != nil {
+		return errors.Wrap(err, "invalid image archive manifest")
+	}
+
+	layout := ociLayout{root: dst}
+	index := ocispec.Index{Versioned: specs.Versioned{SchemaVersion: 2}}
+	layers := make(map[string]ocispec.Descriptor)
+	for _, image := range images {
+		configJSON, err := ioutil.ReadFile(filepath.Join(src, image.Config))
+		if err != nil {
+			return err
+		}
+		var config struct {
+			OS           string `json:"os"`
+			Architecture string `json:"architecture"`
+			Variant      string `json:"variant,omitempty"`
+		}
+		if err := json.Unmarshal(configJSON, &config); err != nil {
+			return errors.Wrapf(err, "invalid image config %s", image.Config)
+		}
+		imagePlatform := manifestlist.PlatformSpec{OS: config.OS, Architecture: config.Architecture, Variant: config.Variant}
+		if platform != nil && !matchPlatform(*platform, imagePlatform) {
+			continue
+		}
+
+		manifest := ocispec.Manifest{Versioned: specs.Versioned{SchemaVersion: 2}}
+		if manifest.Config, err = layout.writeBlob(ocispec.MediaTypeImageConfig, bytes.NewReader(configJSON)); err != nil {
+			return err
+		}
+		for _, layer := range image.Layers {
+			desc, ok := layers[layer]
+			if !ok {
+				if desc, err = layout.writeLayer(filepath.Join(src, layer), compression); err != nil {
+					return errors.Wrapf(err, "failed to write layer %s", layer)
+				}
+				layers[layer] = desc
+			}
+			manifest.Layers = append(manifest.Layers, desc)
+		}
+		manifestDesc, err := layout.writeJSON(ocispec.MediaTypeImageManifest, manifest)
+		if err != nil {
+			return err
+		}
+		manifestDesc.Platform = &ocispec.Platform{OS: config.OS, Architecture: config.Architecture, Variant: config.Variant}
+
+		if len(image.RepoTags) == 0 {
+			index.Manifests = append(index.Manifests, manifestDesc)
+			continue
+		}
+		for _, tag := range image.RepoTags {
+			desc := manifestDesc
+			if desc.Annotations, err = ociRefAnnotations(tag); err != nil {
+				return err
+			}
+			index.Manifests = append(index.Manifests, desc)
+		}
+	}
+	if len(index.Manifests) == 0 {
+		if platform != nil {
+			return errors.Errorf("no image found for platform %s", formatPlatform(*platform))
+		}
+		return errors.New("no image found in image archive")
+	}
+
+	if err := writeJSONFile(filepath.Join(dst, "index.json"), index); err != nil {
+		return err
+	}
+	return writeJSONFile(filepath.Join(dst, ocispec.ImageLayoutFile), ocispec.ImageLayout{Version: ocispec.ImageLayoutVersion})
+}
@@ -1,16 +1,23 @@
 package image
 
 import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
 	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/yuyangjack/dockercli/internal/test"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/pkg/errors"
 	"gotest.tools/assert"
 	is "gotest.tools/assert/cmp"
+	"gotest.tools/fs"
 )
 
 func TestNewSaveCommandErrors(t *testing.T) {
@@ -46,6 +53,21 @@ func TestNewSaveCommandErrors(t *testing.T) {
 			args:          []string{"-o", "fakedir/out.tar", "arg1"},
 			expectedError: "failed to save image: unable to validate output path: directory \"fakedir\" does not exist",
 		},
+		{
+			name:          "invalid format",
+			args:          []string{"--format", "tarball", "arg1"},
+			expectedError: "invalid format \"tarball\": must be docker or oci",
+		},
+		{
+			name:          "compression without oci format",
+			args:          []string{"--compression", "gzip", "arg1"},
+			expectedError: "--compression and --platform can only be used with --format oci",
+		},
+		{
+			name:          "invalid compression",
+			args:          []string{"--format", "oci", "--compression", "xz", "arg1"},
+			expectedError: "invalid compression \"xz\": must be one of none, gzip or zstd",
+		},
 	}
 	for _, tc := range testCases {
 		cli := test.NewFakeCli(&fakeClient{imageSaveFunc: tc.imageSaveFunc})
@@ -101,3 +123,87 @@ func TestNewSaveCommandSuccess(t *testing.T) {
 		}
 	}
 }
+
+// newTestDockerArchive returns a `docker save` archive with one image with a
+// single layer, tagged with tags
+func newTestDockerArchive(t *testing.T, tags ...string) []byte {
+	layer := &bytes.Buffer{}
+	layerWriter := tar.NewWriter(layer)
+	assert.NilError(t, layerWriter.WriteHeader(&tar.Header{Name: "hello", Mode: 0644, Size: 5}))
+	_, err := layerWriter.Write([]byte("world"))
+	assert.NilError(t, err)
+	assert.NilError(t, layerWriter.Close())
+
+	config, err := json.Marshal(ocispec.Image{
+		OS:           "linux",
+		Architecture: "amd64",
+		RootFS:       ocispec.RootFS{Type: "layers", DiffIDs: []digest.Digest{digest.FromBytes(layer.Bytes())}},
+	})
+	assert.NilError(t, err)
+	configName := digest.FromBytes(config).Hex() + ".json"
+	manifest, err := json.Marshal([]dockerArchiveManifest{{Config: configName, RepoTags: tags, Layers: []string{"layer/layer.tar"}}})
+	assert.NilError(t, err)
+
+	archive := &bytes.Buffer{}
+	archiveWriter := tar.NewWriter(archive)
+	for _, file := range []struct {
+		name    string
+		content []byte
+	}{
+		{name: "layer/layer.tar", content: layer.Bytes()},
+		{name: configName, content: config},
+		{name: dockerArchiveManifestFile, content: manifest},
+	} {
+		assert.NilError(t, archiveWriter.WriteHeader(&tar.Header{Name: file.name, Mode: 0644, Size: int64(len(file.content))}))
+		_, err := archiveWriter.Write(file.content)
+		assert.NilError(t, err)
+	}
+	assert.NilError(t, archiveWriter.Close())
+	return archive.Bytes()
+}
+
+func TestSaveOCILayout(t *testing.T) {
+	dir := fs.NewDir(t, "test-save-oci")
+	defer dir.Remove()
+
+	cli := test.NewFakeCli(&fakeClient{
+		imageSaveFunc: func(images []string) (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(newTestDockerArchive(t, "busybox:latest", "example.com/busybox:1"))), nil
+		},
+	})
+	cmd := NewSaveCommand(cli)
+	cmd.SetOutput(ioutil.Discard)
+	output := dir.Join("layout")
+	cmd.SetArgs([]string{"--format", "oci", "--compression", "gzip", "-o", output, "busybox"})
+	assert.NilError(t, cmd.Execute())
+
+	var index ocispec.Index
+	assert.NilError(t, readJSONFile(filepath.Join(output, "index.json"), &index))
+	assert.Assert(t, is.Len(index.Manifests, 2))
+	assert.Check(t, is.Equal("docker.io/library/busybox:latest", index.Manifests[0].Annotations[annotationImageName]))
+	assert.Check(t, is.Equal("latest", index.Manifests[0].Annotations[ocispec.AnnotationRefName]))
+	assert.Check(t, is.Equal("example.com/busybox:1", index.Manifests[1].Annotations[annotationImageName]))
+	assert.Check(t, is.Equal(index.Manifests[0].Digest, index.Manifests[1].Digest))
+	assert.Check(t, is.DeepEqual(&ocispec.Platform{OS: "linux", Architecture: "amd64"}, index.Manifests[0].Platform))
+
+	layout := ociLayout{root: output}
+	var manifest ocispec.Manifest
+	assert.NilError(t, layout.readJSON(index.Manifests[0], &manifest))
+	assert.Check(t, is.Equal(ocispec.MediaTypeImageConfig, manifest.Config.MediaType))
+	assert.Assert(t, is.Len(manifest.Layers, 1))
+	assert.Check(t, is.Equal(ocispec.MediaTypeImageLayerGzip, manifest.Layers[0].MediaType))
+	_, err := layout.readBlob(manifest.Layers[0])
+	assert.NilError(t, err)
+}
+
+func TestSaveOCILayoutPlatformMismatch(t *testing.T) {
+	cli := test.NewFakeCli(&fakeClient{
+		imageSaveFunc: func(images []string) (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(newTestDockerArchive(t, "busybox:latest"))), nil
+		},
+	})
+	cmd := NewSaveCommand(cli)
+	cmd.SetOutput(ioutil.Discard)
+	cmd.SetArgs([]string{"--format", "oci", "--platform", "linux/arm64", "busybox"})
+	assert.ErrorContains(t, cmd.Execute(), "no image found for platform linux/arm64")
+}
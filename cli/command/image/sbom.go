@@ -0,0 +1,262 @@
+package image
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/yuyangjack/dockercli/cli"
+	"github.com/yuyangjack/dockercli/cli/command"
+	"github.com/yuyangjack/distribution"
+	"github.com/yuyangjack/distribution/manifest/ocischema"
+	"github.com/yuyangjack/distribution/reference"
+	"github.com/yuyangjack/moby/api/types"
+	"github.com/yuyangjack/moby/pkg/jsonmessage"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+const (
+	sbomAttachLabel    = "label"
+	sbomAttachManifest = "manifest"
+
+	// sbomLabel is the label holding the SBOM of images when it is attached
+	// with --attach label
+	sbomLabel = "com.docker.image.sbom"
+	// sbomTagSuffix is appended to the digest of an image to form the tag of
+	// the SBOM manifest attached with --attach manifest
+	sbomTagSuffix = ".sbom"
+)
+
+type sbomOptions struct {
+	image    string
+	format   string
+	output   string
+	remote   bool
+	platform string
+	insecure bool
+	attach   string
+}
+
+// NewSbomCommand creates a new `docker image sbom` command
+func NewSbomCommand(dockerCli command.Cli) *cobra.Command {
+	var opts sbomOptions
+
+	cmd := &cobra.Command{
+		Use:   "sbom [OPTIONS] IMAGE",
+		Short: "Generate a software bill of materials for an image",
+		Args:  cli.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.image = args[0]
+			return runSbom(dockerCli, opts)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&opts.format, "format", sbomFormatSPDX, "Format of the SBOM (\"spdx-json\"|\"cyclonedx-json\")")
+	flags.StringVarP(&opts.output, "output", "o", "", "Write the SBOM to a file, instead of STDOUT")
+	flags.BoolVar(&opts.remote, "remote", false, "Read the image from the registry instead of the daemon")
+	flags.StringVar(&opts.platform, "platform", "", "Platform of the image with --remote (os[/arch[/variant]], default linux on the client architecture)")
+	flags.BoolVar(&opts.insecure, "insecure", false, "Allow communication with an insecure registry")
+	flags.StringVar(&opts.attach, "attach", "", "Attach the SBOM to the image as a \"label\", or as a sibling \"manifest\" in the registry")
+
+	return cmd
+}
+
+func runSbom(dockerCli command.Cli, opts sbomOptions) error {
+	if opts.format != sbomFormatSPDX && opts.format != sbomFormatCycloneDX {
+		return errors.Errorf("invalid format %q: must be spdx-json or cyclonedx-json", opts.format)
+	}
+	switch opts.attach {
+	case "", sbomAttachManifest:
+	case sbomAttachLabel:
+		if opts.remote {
+			return errors.New("--attach label cannot be used with --remote")
+		}
+	default:
+		return errors.Errorf("invalid attach mode %q: must be label or manifest", opts.attach)
+	}
+	if !opts.remote && opts.platform != "" {
+		return errors.New("--platform can only be used with --remote")
+	}
+
+	ctx := context.Background()
+	indexOpts := indexOptions{capture: captureSBOMFile}
+	var (
+		image   *flattenedImage
+		subject = sbomSubject{Name: opts.image}
+		err     error
+	)
+	if opts.remote {
+		platform, err := resolvePlatform(opts.platform)
+		if err != nil {
+			return err
+		}
+		image, err = loadRemoteImage(ctx, dockerCli.RegistryClient(opts.insecure), opts.image, platform, indexOpts)
+		if err != nil {
+			return err
+		}
+		subject.Digest = image.ref.Digest().String()
+	} else {
+		image, err = loadLocalImage(ctx, dockerCli, opts.image, indexOpts)
+		if err != nil {
+			return err
+		}
+		inspect, _, err := dockerCli.Client().ImageInspectWithRaw(ctx, opts.image)
+		if err != nil {
+			return err
+		}
+		subject.Digest = inspect.ID
+	}
+
+	catalog, err := catalogImage(image.files)
+	if err != nil {
+		return err
+	}
+	doc := &bytes.Buffer{}
+	if err := writeSBOM(doc, opts.format, subject, catalog, time.Now()); err != nil {
+		return err
+	}
+
+	switch {
+	case opts.output != "":
+		if err := validateOutputPath(opts.output); err != nil {
+			return errors.Wrap(err, "failed to save SBOM")
+		}
+		if err := command.CopyToFile(opts.output, bytes.NewReader(doc.Bytes())); err != nil {
+			return err
+		}
+	case opts.attach == "":
+		// With --attach, STDOUT shows the progress of attaching the SBOM
+		if _, err := dockerCli.Out().Write(doc.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	switch opts.attach {
+	case sbomAttachLabel:
+		return attachSBOMLabel(ctx, dockerCli, opts.image, doc.Bytes())
+	case sbomAttachManifest:
+		imageRef := image.ref
+		if imageRef == nil {
+			if imageRef, err = localImageDigestRef(ctx, dockerCli, opts.image); err != nil {
+				return err
+			}
+		}
+		return attachSBOMManifest(ctx, dockerCli, opts, imageRef, doc.Bytes())
+	}
+	return nil
+}
+
+// attachSBOMLabel rebuilds image with the SBOM as a label, and tags the
+// result with the same name. The build only adds a metadata layer to the
+// history of the image.
+func attachSBOMLabel(ctx context.Context, dockerCli command.Cli, image string, doc []byte) error {
+	compact := &bytes.Buffer{}
+	if err := json.Compact(compact, doc); err != nil {
+		return err
+	}
+	dockerfile := fmt.Sprintf("FROM %s\nLABEL %s=\"%s\"\n", image, sbomLabel, escapeDockerfileValue(compact.String()))
+
+	buildCtx := &bytes.Buffer{}
+	tw := tar.NewWriter(buildCtx)
+	if err := tw.WriteHeader(&tar.Header{Name: "Dockerfile", Mode: 0644, Size: int64(len(dockerfile))}); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(tw, dockerfile); err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	options := types.ImageBuildOptions{Dockerfile: "Dockerfile", Remove: true}
+	if named, err := reference.ParseNormalizedNamed(image); err == nil {
+		if _, isCanonical := named.(reference.Canonical); !isCanonical {
+			options.Tags = []string{reference.FamiliarString(reference.TagNameOnly(named))}
+		}
+	}
+	response, err := dockerCli.Client().ImageBuild(ctx, buildCtx, options)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	return jsonmessage.DisplayJSONMessagesStream(response.Body, dockerCli.Out(), dockerCli.Out().FD(), dockerCli.Out().IsTerminal(), nil)
+}
+
+// escapeDockerfileValue escapes s to be used in a double quoted value of a
+// Dockerfile instruction
+func escapeDockerfileValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`).Replace(s)
+}
+
+// localImageDigestRef returns the reference by digest of a local image in
+// its repository, which is only known once the image was pushed or pulled
+func localImageDigestRef(ctx context.Context, dockerCli command.Cli, image string) (reference.Canonical, error) {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return nil, err
+	}
+	inspect, _, err := dockerCli.Client().ImageInspectWithRaw(ctx, image)
+	if err != nil {
+		return nil, err
+	}
+	for _, repoDigest := range inspect.RepoDigests {
+		ref, err := reference.ParseNormalizedNamed(repoDigest)
+		if err != nil {
+			continue
+		}
+		if canonical, ok := ref.(reference.Canonical); ok && ref.Name() == named.Name() {
+			return canonical, nil
+		}
+	}
+	return nil, errors.Errorf("image %s has no digest in %s: push the image before attaching an SBOM manifest", image, reference.FamiliarName(named))
+}
+
+// attachSBOMManifest pushes the SBOM as the single layer of an OCI manifest
+// in the repository of imageRef, tagged with the digest of the image
+// followed by ".sbom" so that it can be found from the image
+func attachSBOMManifest(ctx context.Context, dockerCli command.Cli, opts sbomOptions, imageRef reference.Canonical, doc []byte) error {
+	client := dockerCli.RegistryClient(opts.insecure)
+	repo := reference.TrimNamed(imageRef)
+
+	config, err := client.PutBlob(ctx, repo, ocispec.MediaTypeImageConfig, []byte("{}"))
+	if err != nil {
+		return errors.Wrap(err, "failed to push SBOM config")
+	}
+	layer, err := client.PutBlob(ctx, repo, sbomMediaType(opts.format), doc)
+	if err != nil {
+		return errors.Wrap(err, "failed to push SBOM")
+	}
+	mf, err := ocischema.FromStruct(ocischema.Manifest{
+		Versioned: ocischema.SchemaVersion,
+		Config:    config,
+		Layers:    []distribution.Descriptor{layer},
+	})
+	if err != nil {
+		return err
+	}
+
+	dgst := imageRef.Digest()
+	tagged, err := reference.WithTag(repo, sbomTag(dgst))
+	if err != nil {
+		return err
+	}
+	if _, err := client.PutManifest(ctx, tagged, mf); err != nil {
+		return errors.Wrapf(err, "failed to push SBOM manifest to %s", reference.FamiliarString(tagged))
+	}
+	fmt.Fprintf(dockerCli.Out(), "Attached SBOM to %s as %s\n", reference.FamiliarString(imageRef), reference.FamiliarString(tagged))
+	return nil
+}
+
+// sbomTag returns the tag of the SBOM manifest of the image with digest dgst
+func sbomTag(dgst digest.Digest) string {
+	return dgst.Algorithm().String() + "-" + dgst.Hex() + sbomTagSuffix
+}
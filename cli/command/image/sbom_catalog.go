@@ -0,0 +1,490 @@
+package image
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	packageTypeDeb    = "deb"
+	packageTypeApk    = "apk"
+	packageTypeRPM    = "rpm"
+	packageTypeNpm    = "npm"
+	packageTypePyPI   = "pypi"
+	packageTypeGolang = "golang"
+
+	// maxSBOMFileSize is the size above which package databases and
+	// lockfiles are ignored
+	maxSBOMFileSize = 256 * 1024 * 1024
+	// maxGoBuildInfoSize is the maximum size of the version and module
+	// information embedded in a Go binary
+	maxGoBuildInfoSize = 1024 * 1024
+)
+
+var (
+	goBuildInfoMagic = []byte("\xff Go buildinf:")
+	// goBuildInfoPrefix is prepended to the data captured from Go binaries,
+	// so that they can be told apart from other files
+	goBuildInfoPrefix = "go\t"
+)
+
+// sbomPackage is a package found in an image
+type sbomPackage struct {
+	Name     string
+	Version  string
+	Type     string
+	Arch     string
+	License  string
+	Location string
+	PURL     string
+}
+
+// osRelease is the distribution information of /etc/os-release
+type osRelease struct {
+	ID         string
+	VersionID  string
+	PrettyName string
+}
+
+// sbomCatalog is the inventory of an image
+type sbomCatalog struct {
+	Distro   osRelease
+	Packages []sbomPackage
+}
+
+// isSBOMSourceFile returns whether the file at name is a package database or
+// a lockfile read to generate an SBOM
+func isSBOMSourceFile(name string) bool {
+	base := path.Base(name)
+	switch {
+	case name == "/etc/os-release", name == "/usr/lib/os-release":
+		return true
+	case name == "/var/lib/dpkg/status", name == "/lib/apk/db/installed", name == "/var/lib/rpm/Packages":
+		return true
+	case path.Dir(name) == "/var/lib/dpkg/status.d" && !strings.HasSuffix(base, ".md5sums"):
+		return true
+	case base == "package-lock.json":
+		return !strings.Contains(name, "/node_modules/")
+	case strings.HasPrefix(base, "requirements") && strings.HasSuffix(base, ".txt"):
+		return true
+	}
+	return false
+}
+
+// captureSBOMFile is the capture function used when indexing layers for an
+// SBOM. It keeps the content of package databases and lockfiles, and the
+// build information of Go executables.
+func captureSBOMFile(name string, hdr *tar.Header, r io.Reader) ([]byte, error) {
+	if isSBOMSourceFile(name) {
+		if hdr.Size > maxSBOMFileSize {
+			return nil, nil
+		}
+		return ioutil.ReadAll(r)
+	}
+	if hdr.Mode&0111 != 0 && hdr.Size > int64(len(goBuildInfoMagic)) {
+		return scanGoBuildInfo(r)
+	}
+	return nil, nil
+}
+
+// scanGoBuildInfo streams an executable looking for the build information
+// embedded by the Go linker. Only the format used since Go 1.18, where the
+// version and module information are stored inline, is supported.
+func scanGoBuildInfo(r io.Reader) ([]byte, error) {
+	buf := make([]byte, 0, 64*1024)
+	chunk := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(chunk)
+		buf = append(buf, chunk[:n]...)
+		if i := bytes.Index(buf, goBuildInfoMagic); i >= 0 {
+			return readGoBuildInfo(io.MultiReader(bytes.NewReader(buf[i:]), r))
+		}
+		// Keep enough of the end of the buffer to match the magic across
+		// reads
+		if keep := len(goBuildInfoMagic) - 1; len(buf) > keep {
+			buf = buf[:copy(buf, buf[len(buf)-keep:])]
+		}
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func readGoBuildInfo(r io.Reader) ([]byte, error) {
+	header := make([]byte, 32)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, nil
+	}
+	ptrSize, flags := header[14], header[15]
+	if (ptrSize != 4 && ptrSize != 8) || flags&2 == 0 {
+		return nil, nil
+	}
+	br := bufio.NewReader(r)
+	readString := func() (string, bool) {
+		n, err := binary.ReadUvarint(br)
+		if err != nil || n > maxGoBuildInfoSize {
+			return "", false
+		}
+		s := make([]byte, n)
+		if _, err := io.ReadFull(br, s); err != nil {
+			return "", false
+		}
+		return string(s), true
+	}
+	version, ok := readString()
+	if !ok {
+		return nil, nil
+	}
+	modinfo, ok := readString()
+	if !ok {
+		return nil, nil
+	}
+	// The module information is wrapped in 16 byte sentinels
+	if len(modinfo) >= 33 && modinfo[len(modinfo)-17] == '\n' {
+		modinfo = modinfo[16 : len(modinfo)-16]
+	} else {
+		modinfo = ""
+	}
+	return []byte(goBuildInfoPrefix + version + "\n" + modinfo), nil
+}
+
+// catalogImage lists the packages of a flattened filesystem indexed with
+// captureSBOMFile. Corrupt OS package databases are an error, but
+// lockfiles that cannot be parsed are skipped.
+func catalogImage(files map[string]layerEntry) (sbomCatalog, error) {
+	var catalog sbomCatalog
+	for _, name := range []string{"/etc/os-release", "/usr/lib/os-release"} {
+		if entry, ok := files[name]; ok && entry.data != nil {
+			catalog.Distro = parseOSRelease(entry.data)
+			break
+		}
+	}
+
+	var paths []string
+	for p, entry := range files {
+		if entry.data != nil {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+
+	catalog.Packages = []sbomPackage{}
+	for _, p := range paths {
+		data := files[p].data
+		var (
+			packages []sbomPackage
+			err      error
+		)
+		base := path.Base(p)
+		switch {
+		case p == "/etc/os-release", p == "/usr/lib/os-release":
+			continue
+		case p == "/var/lib/dpkg/status", path.Dir(p) == "/var/lib/dpkg/status.d":
+			packages = parseDpkgStatus(data)
+		case p == "/lib/apk/db/installed":
+			packages = parseApkInstalled(data)
+		case p == "/var/lib/rpm/Packages":
+			var headers []rpmHeader
+			if headers, err = readRPMDatabase(data); err != nil {
+				return catalog, errors.Wrapf(err, "failed to read %s", p)
+			}
+			packages = rpmPackages(headers)
+		case base == "package-lock.json":
+			packages = parseNpmLockfile(data)
+		case strings.HasPrefix(base, "requirements") && strings.HasSuffix(base, ".txt"):
+			packages = parseRequirements(data)
+		case bytes.HasPrefix(data, []byte(goBuildInfoPrefix)):
+			packages = parseGoBuildInfo(data)
+		}
+		for _, pkg := range packages {
+			pkg.Location = p
+			pkg.PURL = packageURL(pkg, catalog.Distro)
+			catalog.Packages = append(catalog.Packages, pkg)
+		}
+	}
+	sort.SliceStable(catalog.Packages, func(i, j int) bool {
+		a, b := catalog.Packages[i], catalog.Packages[j]
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Version < b.Version
+	})
+	return catalog, nil
+}
+
+func parseOSRelease(data []byte) osRelease {
+	var release osRelease
+	for _, line := range strings.Split(string(data), "\n") {
+		kv := strings.SplitN(strings.TrimSpace(line), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		value := strings.Trim(kv[1], `"'`)
+		switch kv[0] {
+		case "ID":
+			release.ID = value
+		case "VERSION_ID":
+			release.VersionID = value
+		case "PRETTY_NAME":
+			release.PrettyName = value
+		}
+	}
+	return release
+}
+
+// controlParagraphs splits a dpkg or apk database in paragraphs of fields.
+// Continuation lines are ignored.
+func controlParagraphs(data []byte, sep string) []map[string]string {
+	var paragraphs []map[string]string
+	for _, block := range strings.Split(strings.Replace(string(data), "\r\n", "\n", -1), "\n\n") {
+		fields := make(map[string]string)
+		for _, line := range strings.Split(block, "\n") {
+			if line == "" || line[0] == ' ' || line[0] == '\t' {
+				continue
+			}
+			kv := strings.SplitN(line, sep, 2)
+			if len(kv) == 2 {
+				fields[kv[0]] = strings.TrimSpace(kv[1])
+			}
+		}
+		if len(fields) > 0 {
+			paragraphs = append(paragraphs, fields)
+		}
+	}
+	return paragraphs
+}
+
+func parseDpkgStatus(data []byte) []sbomPackage {
+	var packages []sbomPackage
+	for _, fields := range controlParagraphs(data, ":") {
+		if status, ok := fields["Status"]; ok && status != "install ok installed" {
+			continue
+		}
+		if fields["Package"] == "" {
+			continue
+		}
+		packages = append(packages, sbomPackage{
+			Name:    fields["Package"],
+			Version: fields["Version"],
+			Type:    packageTypeDeb,
+			Arch:    fields["Architecture"],
+		})
+	}
+	return packages
+}
+
+func parseApkInstalled(data []byte) []sbomPackage {
+	var packages []sbomPackage
+	for _, fields := range controlParagraphs(data, ":") {
+		if fields["P"] == "" {
+			continue
+		}
+		packages = append(packages, sbomPackage{
+			Name:    fields["P"],
+			Version: fields["V"],
+			Type:    packageTypeApk,
+			Arch:    fields["A"],
+			License: fields["L"],
+		})
+	}
+	return packages
+}
+
+func rpmPackages(headers []rpmHeader) []sbomPackage {
+	var packages []sbomPackage
+	for _, header := range headers {
+		version := header.version
+		if header.release != "" {
+			version += "-" + header.release
+		}
+		if header.epoch > 0 {
+			version = strconv.Itoa(header.epoch) + ":" + version
+		}
+		packages = append(packages, sbomPackage{
+			Name:    header.name,
+			Version: version,
+			Type:    packageTypeRPM,
+			Arch:    header.arch,
+			License: header.license,
+		})
+	}
+	return packages
+}
+
+type npmLockfile struct {
+	Packages     map[string]npmLockPackage    `json:"packages"`
+	Dependencies map[string]npmLockDependency `json:"dependencies"`
+}
+
+type npmLockPackage struct {
+	Version string      `json:"version"`
+	License interface{} `json:"license"`
+	Link    bool        `json:"link"`
+}
+
+type npmLockDependency struct {
+	Version      string                       `json:"version"`
+	Dependencies map[string]npmLockDependency `json:"dependencies"`
+}
+
+// parseNpmLockfile lists the packages of a package-lock.json. The packages
+// of lockfile version 2 and 3 are used if present, otherwise the
+// dependencies of version 1.
+func parseNpmLockfile(data []byte) []sbomPackage {
+	var lockfile npmLockfile
+	if err := json.Unmarshal(data, &lockfile); err != nil {
+		return nil
+	}
+	seen := make(map[string]bool)
+	var packages []sbomPackage
+	add := func(name, version, license string) {
+		if name == "" || version == "" || seen[name+"@"+version] {
+			return
+		}
+		seen[name+"@"+version] = true
+		packages = append(packages, sbomPackage{Name: name, Version: version, Type: packageTypeNpm, License: license})
+	}
+
+	if len(lockfile.Packages) > 0 {
+		for key, pkg := range lockfile.Packages {
+			i := strings.LastIndex(key, "node_modules/")
+			if i < 0 || pkg.Link {
+				continue
+			}
+			license, _ := pkg.License.(string)
+			add(key[i+len("node_modules/"):], pkg.Version, license)
+		}
+		return packages
+	}
+
+	var walk func(map[string]npmLockDependency)
+	walk = func(dependencies map[string]npmLockDependency) {
+		for name, dep := range dependencies {
+			add(name, dep.Version, "")
+			walk(dep.Dependencies)
+		}
+	}
+	walk(lockfile.Dependencies)
+	return packages
+}
+
+// parseRequirements lists the packages pinned with == in a pip requirements
+// file
+func parseRequirements(data []byte) []sbomPackage {
+	var packages []sbomPackage
+	for _, line := range strings.Split(string(data), "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		if i := strings.Index(line, ";"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "-") {
+			continue
+		}
+		nv := strings.SplitN(line, "==", 2)
+		if len(nv) != 2 {
+			continue
+		}
+		name := strings.TrimSpace(nv[0])
+		if i := strings.Index(name, "["); i >= 0 {
+			name = name[:i]
+		}
+		version := strings.TrimSpace(strings.Fields(nv[1] + " ")[0])
+		if name == "" || version == "" {
+			continue
+		}
+		packages = append(packages, sbomPackage{Name: name, Version: version, Type: packageTypePyPI})
+	}
+	return packages
+}
+
+// parseGoBuildInfo lists the main module and dependencies recorded in the
+// build information of a Go binary
+func parseGoBuildInfo(data []byte) []sbomPackage {
+	var packages []sbomPackage
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 3 {
+			continue
+		}
+		switch fields[0] {
+		case "mod", "dep":
+			if fields[2] == "(devel)" {
+				continue
+			}
+			packages = append(packages, sbomPackage{Name: fields[1], Version: fields[2], Type: packageTypeGolang})
+		case "=>":
+			// A replacement applies to the module on the previous line
+			if len(packages) > 0 {
+				packages[len(packages)-1].Name = fields[1]
+				packages[len(packages)-1].Version = fields[2]
+			}
+		}
+	}
+	return packages
+}
+
+// packageURL returns the package URL (purl) of pkg. OS packages are
+// namespaced with the distribution of the image.
+func packageURL(pkg sbomPackage, distro osRelease) string {
+	escape := func(s string) string {
+		return strings.Replace(url.QueryEscape(s), "+", "%20", -1)
+	}
+	var namespace, name string
+	switch pkg.Type {
+	case packageTypeDeb, packageTypeApk, packageTypeRPM:
+		namespace, name = distro.ID, escape(pkg.Name)
+	case packageTypeNpm:
+		if i := strings.Index(pkg.Name, "/"); strings.HasPrefix(pkg.Name, "@") && i > 0 {
+			namespace, name = pkg.Name[:i], escape(pkg.Name[i+1:])
+		} else {
+			name = escape(pkg.Name)
+		}
+	case packageTypePyPI:
+		name = escape(strings.Replace(strings.ToLower(pkg.Name), "_", "-", -1))
+	case packageTypeGolang:
+		segments := strings.Split(pkg.Name, "/")
+		for i := range segments {
+			segments[i] = escape(segments[i])
+		}
+		name = strings.Join(segments, "/")
+	}
+
+	purl := "pkg:" + pkg.Type + "/"
+	if namespace != "" {
+		purl += escape(namespace) + "/"
+	}
+	purl += name + "@" + escape(pkg.Version)
+
+	var qualifiers []string
+	if pkg.Arch != "" {
+		qualifiers = append(qualifiers, "arch="+escape(pkg.Arch))
+	}
+	if namespace != "" && distro.VersionID != "" && pkg.Type != packageTypeNpm {
+		qualifiers = append(qualifiers, "distro="+escape(distro.ID+"-"+distro.VersionID))
+	}
+	if len(qualifiers) > 0 {
+		purl += "?" + strings.Join(qualifiers, "&")
+	}
+	return purl
+}
@@ -0,0 +1,272 @@
+package image
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"time"
+)
+
+const (
+	sbomFormatSPDX      = "spdx-json"
+	sbomFormatCycloneDX = "cyclonedx-json"
+
+	mediaTypeSPDX      = "application/spdx+json"
+	mediaTypeCycloneDX = "application/vnd.cyclonedx+json"
+
+	sbomToolName = "docker-image-sbom"
+	noAssertion  = "NOASSERTION"
+)
+
+var spdxIDInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9.-]+`)
+
+// sbomSubject is the image an SBOM is generated for
+type sbomSubject struct {
+	Name string
+	// Digest is the image ID of local images, or the manifest digest of
+	// images read from the registry
+	Digest string
+}
+
+// sbomMediaType returns the media type of documents in format
+func sbomMediaType(format string) string {
+	if format == sbomFormatCycloneDX {
+		return mediaTypeCycloneDX
+	}
+	return mediaTypeSPDX
+}
+
+// writeSBOM encodes catalog in format. created is the creation time
+// recorded in the document.
+func writeSBOM(w io.Writer, format string, subject sbomSubject, catalog sbomCatalog, created time.Time) error {
+	var doc interface{}
+	if format == sbomFormatCycloneDX {
+		doc = newCycloneDXDocument(subject, catalog, created)
+	} else {
+		doc = newSPDXDocument(subject, catalog, created)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// sbomContentHash is a hash of the subject and packages of an SBOM, used to
+// derive stable document identifiers
+func sbomContentHash(subject sbomSubject, catalog sbomCatalog) []byte {
+	h := sha256.New()
+	json.NewEncoder(h).Encode(struct {
+		Subject  sbomSubject
+		Packages []sbomPackage
+	}{subject, catalog.Packages})
+	return h.Sum(nil)
+}
+
+type spdxDocument struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+	Packages          []spdxPackage      `json:"packages"`
+	Relationships     []spdxRelationship `json:"relationships"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	Name             string            `json:"name"`
+	SPDXID           string            `json:"SPDXID"`
+	VersionInfo      string            `json:"versionInfo,omitempty"`
+	DownloadLocation string            `json:"downloadLocation"`
+	FilesAnalyzed    bool              `json:"filesAnalyzed"`
+	LicenseConcluded string            `json:"licenseConcluded"`
+	LicenseDeclared  string            `json:"licenseDeclared"`
+	LicenseComments  string            `json:"licenseComments,omitempty"`
+	CopyrightText    string            `json:"copyrightText"`
+	SourceInfo       string            `json:"sourceInfo,omitempty"`
+	ExternalRefs     []spdxExternalRef `json:"externalRefs,omitempty"`
+}
+
+type spdxExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type spdxRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+// newSPDXDocument returns an SPDX 2.2 document describing the image as a
+// package containing the packages of catalog. Licenses are recorded as
+// comments, as package databases do not use SPDX license expressions.
+func newSPDXDocument(subject sbomSubject, catalog sbomCatalog, created time.Time) spdxDocument {
+	const imageID = "SPDXRef-Image"
+	doc := spdxDocument{
+		SPDXVersion:       "SPDX-2.2",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              subject.Name,
+		DocumentNamespace: fmt.Sprintf("https://docs.docker.com/sbom/%s-%x", spdxIDInvalidChars.ReplaceAllString(subject.Name, "-"), sbomContentHash(subject, catalog)),
+		CreationInfo: spdxCreationInfo{
+			Created:  created.UTC().Format(time.RFC3339),
+			Creators: []string{"Tool: " + sbomToolName},
+		},
+		Packages: []spdxPackage{{
+			Name:             subject.Name,
+			SPDXID:           imageID,
+			VersionInfo:      subject.Digest,
+			DownloadLocation: noAssertion,
+			LicenseConcluded: noAssertion,
+			LicenseDeclared:  noAssertion,
+			CopyrightText:    noAssertion,
+		}},
+		Relationships: []spdxRelationship{{
+			SPDXElementID:      "SPDXRef-DOCUMENT",
+			RelationshipType:   "DESCRIBES",
+			RelatedSPDXElement: imageID,
+		}},
+	}
+	for i, pkg := range catalog.Packages {
+		id := fmt.Sprintf("SPDXRef-Package-%s-%s-%d", pkg.Type, spdxIDInvalidChars.ReplaceAllString(pkg.Name, "-"), i)
+		doc.Packages = append(doc.Packages, spdxPackage{
+			Name:             pkg.Name,
+			SPDXID:           id,
+			VersionInfo:      pkg.Version,
+			DownloadLocation: noAssertion,
+			LicenseConcluded: noAssertion,
+			LicenseDeclared:  noAssertion,
+			LicenseComments:  pkg.License,
+			CopyrightText:    noAssertion,
+			SourceInfo:       "acquired package info from " + pkg.Location,
+			ExternalRefs: []spdxExternalRef{{
+				ReferenceCategory: "PACKAGE_MANAGER",
+				ReferenceType:     "purl",
+				ReferenceLocator:  pkg.PURL,
+			}},
+		})
+		doc.Relationships = append(doc.Relationships, spdxRelationship{
+			SPDXElementID:      imageID,
+			RelationshipType:   "CONTAINS",
+			RelatedSPDXElement: id,
+		})
+	}
+	return doc
+}
+
+type cycloneDXDocument struct {
+	BOMFormat    string               `json:"bomFormat"`
+	SpecVersion  string               `json:"specVersion"`
+	SerialNumber string               `json:"serialNumber"`
+	Version      int                  `json:"version"`
+	Metadata     cycloneDXMetadata    `json:"metadata"`
+	Components   []cycloneDXComponent `json:"components"`
+}
+
+type cycloneDXMetadata struct {
+	Timestamp string             `json:"timestamp"`
+	Tools     []cycloneDXTool    `json:"tools"`
+	Component cycloneDXComponent `json:"component"`
+}
+
+type cycloneDXTool struct {
+	Vendor string `json:"vendor"`
+	Name   string `json:"name"`
+}
+
+type cycloneDXComponent struct {
+	BOMRef     string              `json:"bom-ref,omitempty"`
+	Type       string              `json:"type"`
+	Name       string              `json:"name"`
+	Version    string              `json:"version,omitempty"`
+	PURL       string              `json:"purl,omitempty"`
+	Licenses   []cycloneDXLicense  `json:"licenses,omitempty"`
+	Properties []cycloneDXProperty `json:"properties,omitempty"`
+}
+
+type cycloneDXLicense struct {
+	License cycloneDXLicenseName `json:"license"`
+}
+
+type cycloneDXLicenseName struct {
+	Name string `json:"name"`
+}
+
+type cycloneDXProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// newCycloneDXDocument returns a CycloneDX 1.4 document with the image as
+// the metadata component and the packages of catalog as components
+func newCycloneDXDocument(subject sbomSubject, catalog sbomCatalog, created time.Time) cycloneDXDocument {
+	doc := cycloneDXDocument{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  "1.4",
+		SerialNumber: "urn:uuid:" + hashUUID(sbomContentHash(subject, catalog)),
+		Version:      1,
+		Metadata: cycloneDXMetadata{
+			Timestamp: created.UTC().Format(time.RFC3339),
+			Tools:     []cycloneDXTool{{Vendor: "Docker", Name: sbomToolName}},
+			Component: cycloneDXComponent{
+				BOMRef:  subject.Digest,
+				Type:    "container",
+				Name:    subject.Name,
+				Version: subject.Digest,
+			},
+		},
+		Components: []cycloneDXComponent{},
+	}
+	if catalog.Distro.ID != "" {
+		doc.Components = append(doc.Components, cycloneDXComponent{
+			BOMRef:  "os:" + catalog.Distro.ID,
+			Type:    "operating-system",
+			Name:    catalog.Distro.ID,
+			Version: catalog.Distro.VersionID,
+		})
+	}
+	// bom-ref must be unique, but the same package can be found in several
+	// places
+	refs := make(map[string]int)
+	for _, pkg := range catalog.Packages {
+		ref := pkg.PURL
+		if n := refs[pkg.PURL]; n > 0 {
+			ref = fmt.Sprintf("%s#%d", pkg.PURL, n)
+		}
+		refs[pkg.PURL]++
+		component := cycloneDXComponent{
+			BOMRef:  ref,
+			Type:    "library",
+			Name:    pkg.Name,
+			Version: pkg.Version,
+			PURL:    pkg.PURL,
+			Properties: []cycloneDXProperty{
+				{Name: "docker:sbom:package:type", Value: pkg.Type},
+				{Name: "docker:sbom:location", Value: pkg.Location},
+			},
+		}
+		if pkg.License != "" {
+			component.Licenses = []cycloneDXLicense{{License: cycloneDXLicenseName{Name: pkg.License}}}
+		}
+		doc.Components = append(doc.Components, component)
+	}
+	return doc
+}
+
+// hashUUID formats the first 16 bytes of hash as a name-based (version 5)
+// UUID
+func hashUUID(hash []byte) string {
+	u := make([]byte, 16)
+	copy(u, hash)
+	u[6] = u[6]&0x0f | 0x50
+	u[8] = u[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
@@ -0,0 +1,204 @@
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/pkg/errors"
+)
+
+// Berkeley DB hash databases, used by rpm for /var/lib/rpm/Packages, are
+// read directly: the database is a sequence of fixed size pages, and every
+// value of the hash pages is an rpm header. Large values are stored in
+// chains of overflow pages.
+const (
+	bdbHashMagic = 0x061561
+
+	bdbPageHeaderSize = 26
+
+	bdbPageHashUnsorted = 2
+	bdbPageOverflow     = 7
+	bdbPageHash         = 13
+
+	bdbItemKeyData = 1
+	bdbItemOffPage = 3
+
+	rpmTagName      = 1000
+	rpmTagVersion   = 1001
+	rpmTagRelease   = 1002
+	rpmTagEpoch     = 1003
+	rpmTagLicense   = 1014
+	rpmTagArch      = 1022
+	rpmTypeInt32    = 4
+	rpmTypeString   = 6
+	rpmTypeStrArray = 8
+	rpmTypeI18N     = 9
+)
+
+// rpmHeader is the information read from the header of an installed rpm
+type rpmHeader struct {
+	name    string
+	version string
+	release string
+	epoch   int
+	arch    string
+	license string
+}
+
+// readRPMDatabase returns the headers of the packages in a Berkeley DB rpm
+// database
+func readRPMDatabase(db []byte) ([]rpmHeader, error) {
+	if len(db) < 512 {
+		return nil, errors.New("rpm database is too small")
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if order.Uint32(db[12:]) != bdbHashMagic {
+		order = binary.BigEndian
+		if order.Uint32(db[12:]) != bdbHashMagic {
+			return nil, errors.New("rpm database is not a Berkeley DB hash database")
+		}
+	}
+	pageSize := int(order.Uint32(db[20:]))
+	lastPage := int(order.Uint32(db[32:]))
+	if pageSize < 512 || pageSize > 64*1024 {
+		return nil, errors.Errorf("invalid rpm database page size %d", pageSize)
+	}
+	page := func(n int) ([]byte, error) {
+		start := n * pageSize
+		if n < 0 || start+pageSize > len(db) {
+			return nil, errors.Errorf("rpm database page %d is out of range", n)
+		}
+		return db[start : start+pageSize], nil
+	}
+
+	var headers []rpmHeader
+	for n := 1; n <= lastPage; n++ {
+		p, err := page(n)
+		if err != nil {
+			return nil, err
+		}
+		if p[25] != bdbPageHash && p[25] != bdbPageHashUnsorted {
+			continue
+		}
+		entries := int(order.Uint16(p[20:]))
+		// Entries alternate between keys and values
+		for i := 1; i < entries; i += 2 {
+			offset := int(order.Uint16(p[bdbPageHeaderSize+2*i:]))
+			if offset >= pageSize {
+				return nil, errors.Errorf("invalid item offset in rpm database page %d", n)
+			}
+			var value []byte
+			switch p[offset] {
+			case bdbItemKeyData:
+				end := pageSize
+				if i > 0 {
+					end = int(order.Uint16(p[bdbPageHeaderSize+2*(i-1):]))
+				}
+				if end <= offset || end > pageSize {
+					return nil, errors.Errorf("invalid item in rpm database page %d", n)
+				}
+				value = p[offset+1 : end]
+			case bdbItemOffPage:
+				if offset+12 > pageSize {
+					return nil, errors.Errorf("invalid item in rpm database page %d", n)
+				}
+				pgno := int(order.Uint32(p[offset+4:]))
+				length := int(order.Uint32(p[offset+8:]))
+				if value, err = readBDBOverflow(page, order, pgno, length); err != nil {
+					return nil, err
+				}
+			default:
+				continue
+			}
+			header, err := parseRPMHeader(value)
+			if err != nil {
+				return nil, err
+			}
+			if header.name != "" && header.name != "gpg-pubkey" {
+				headers = append(headers, header)
+			}
+		}
+	}
+	return headers, nil
+}
+
+// readBDBOverflow reads a value of length bytes stored in the chain of
+// overflow pages starting at pgno
+func readBDBOverflow(page func(int) ([]byte, error), order binary.ByteOrder, pgno, length int) ([]byte, error) {
+	value := make([]byte, 0, length)
+	for pgno != 0 && len(value) < length {
+		p, err := page(pgno)
+		if err != nil {
+			return nil, err
+		}
+		if p[25] != bdbPageOverflow {
+			return nil, errors.Errorf("rpm database page %d is not an overflow page", pgno)
+		}
+		n := int(order.Uint16(p[22:]))
+		if bdbPageHeaderSize+n > len(p) {
+			return nil, errors.Errorf("invalid overflow page %d in rpm database", pgno)
+		}
+		value = append(value, p[bdbPageHeaderSize:bdbPageHeaderSize+n]...)
+		pgno = int(order.Uint32(p[16:]))
+	}
+	if len(value) < length {
+		return nil, errors.New("truncated value in rpm database")
+	}
+	return value[:length], nil
+}
+
+// parseRPMHeader parses the name, version, release, epoch, architecture and
+// license of an rpm header as stored in the rpm database
+func parseRPMHeader(blob []byte) (rpmHeader, error) {
+	var header rpmHeader
+	if len(blob) < 8 {
+		// Not a header, such as the record holding the next instance number
+		return header, nil
+	}
+	indexCount := int(binary.BigEndian.Uint32(blob[0:]))
+	dataLength := int(binary.BigEndian.Uint32(blob[4:]))
+	storeStart := 8 + 16*indexCount
+	if indexCount < 0 || indexCount > 100000 || dataLength < 0 || storeStart+dataLength > len(blob) {
+		return header, errors.New("invalid rpm header in rpm database")
+	}
+	store := blob[storeStart : storeStart+dataLength]
+
+	for i := 0; i < indexCount; i++ {
+		entry := blob[8+16*i:]
+		tag := binary.BigEndian.Uint32(entry[0:])
+		typ := binary.BigEndian.Uint32(entry[4:])
+		offset := int(binary.BigEndian.Uint32(entry[8:]))
+		if offset < 0 || offset >= len(store) {
+			continue
+		}
+		var value string
+		switch typ {
+		case rpmTypeString, rpmTypeStrArray, rpmTypeI18N:
+			end := bytes.IndexByte(store[offset:], 0)
+			if end < 0 {
+				continue
+			}
+			value = string(store[offset : offset+end])
+		case rpmTypeInt32:
+			if tag == rpmTagEpoch && offset+4 <= len(store) {
+				header.epoch = int(binary.BigEndian.Uint32(store[offset:]))
+			}
+			continue
+		default:
+			continue
+		}
+		switch tag {
+		case rpmTagName:
+			header.name = value
+		case rpmTagVersion:
+			header.version = value
+		case rpmTagRelease:
+			header.release = value
+		case rpmTagLicense:
+			header.license = value
+		case rpmTagArch:
+			header.arch = value
+		}
+	}
+	return header, nil
+}
@@ -0,0 +1,305 @@
+package image
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yuyangjack/dockercli/internal/test"
+	"github.com/yuyangjack/distribution"
+	"github.com/yuyangjack/distribution/manifest/ocischema"
+	"github.com/yuyangjack/distribution/reference"
+	"github.com/yuyangjack/moby/api/types"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+const (
+	testOSRelease  = "PRETTY_NAME=\"Debian GNU/Linux 10 (buster)\"\nID=debian\nVERSION_ID=\"10\"\n"
+	testDpkgStatus = `Package: libc6
+Status: install ok installed
+Architecture: amd64
+Version: 2.28-10
+Description: GNU C Library
+ Contains the standard libraries.
+
+Package: removed
+Status: deinstall ok config-files
+Version: 1.0
+
+Package: tzdata
+Status: install ok installed
+Architecture: all
+Version: 2019c-0+deb10u1
+`
+	testPackageLock = `{
+  "lockfileVersion": 2,
+  "packages": {
+    "": {"name": "app", "version": "1.0.0"},
+    "node_modules/express": {"version": "4.17.1", "license": "MIT"},
+    "node_modules/@types/node": {"version": "12.0.0", "license": "MIT"},
+    "node_modules/local": {"resolved": "../local", "link": true}
+  }
+}`
+)
+
+func newTestSBOMArchive(t *testing.T) []byte {
+	layer := newTestTar(t,
+		testTarFile{name: "etc/os-release", content: []byte(testOSRelease)},
+		testTarFile{name: "var/lib/dpkg/status", content: []byte(testDpkgStatus)},
+		testTarFile{name: "app/package-lock.json", content: []byte(testPackageLock)},
+		testTarFile{name: "app/node_modules/express/package-lock.json", content: []byte(testPackageLock)},
+		testTarFile{name: "app/requirements.txt", content: []byte("flask==1.1.1\nrequests>=2.0\n")},
+	)
+	return newTestDiffArchive(t, ocispec.ImageConfig{}, layer)
+}
+
+func newTestSBOMClient(t *testing.T) *fakeClient {
+	return &fakeClient{
+		imageSaveFunc: func(names []string) (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(newTestSBOMArchive(t))), nil
+		},
+		imageInspectFunc: func(image string) (types.ImageInspect, []byte, error) {
+			return types.ImageInspect{
+				ID:          "sha256:1111111111111111111111111111111111111111111111111111111111111111",
+				RepoDigests: []string{"example.com/app@sha256:2222222222222222222222222222222222222222222222222222222222222222"},
+			}, nil, nil
+		},
+	}
+}
+
+func TestSbomSPDX(t *testing.T) {
+	cli := test.NewFakeCli(newTestSBOMClient(t))
+	cmd := NewSbomCommand(cli)
+	cmd.SetOutput(ioutil.Discard)
+	cmd.SetArgs([]string{"app:latest"})
+	assert.NilError(t, cmd.Execute())
+
+	var doc spdxDocument
+	assert.NilError(t, json.Unmarshal(cli.OutBuffer().Bytes(), &doc))
+	assert.Check(t, is.Equal("SPDX-2.2", doc.SPDXVersion))
+	var purls []string
+	for _, pkg := range doc.Packages[1:] {
+		purls = append(purls, pkg.ExternalRefs[0].ReferenceLocator)
+	}
+	assert.Check(t, is.DeepEqual([]string{
+		"pkg:deb/debian/libc6@2.28-10?arch=amd64&distro=debian-10",
+		"pkg:deb/debian/tzdata@2019c-0%2Bdeb10u1?arch=all&distro=debian-10",
+		"pkg:npm/%40types/node@12.0.0",
+		"pkg:npm/express@4.17.1",
+		"pkg:pypi/flask@1.1.1",
+	}, purls))
+	assert.Check(t, is.Len(doc.Relationships, 6))
+}
+
+func TestSbomCycloneDX(t *testing.T) {
+	cli := test.NewFakeCli(newTestSBOMClient(t))
+	cmd := NewSbomCommand(cli)
+	cmd.SetOutput(ioutil.Discard)
+	cmd.SetArgs([]string{"--format", "cyclonedx-json", "app:latest"})
+	assert.NilError(t, cmd.Execute())
+
+	var doc cycloneDXDocument
+	assert.NilError(t, json.Unmarshal(cli.OutBuffer().Bytes(), &doc))
+	assert.Check(t, is.Equal("1.4", doc.SpecVersion))
+	assert.Check(t, is.Equal("container", doc.Metadata.Component.Type))
+	assert.Check(t, is.Equal("sha256:1111111111111111111111111111111111111111111111111111111111111111", doc.Metadata.Component.Version))
+	assert.Assert(t, is.Len(doc.Components, 6))
+	assert.Check(t, is.Equal("operating-system", doc.Components[0].Type))
+	assert.Check(t, is.Equal("express", doc.Components[4].Name))
+	assert.Check(t, is.DeepEqual([]cycloneDXLicense{{License: cycloneDXLicenseName{Name: "MIT"}}}, doc.Components[4].Licenses))
+}
+
+func TestSbomAttachManifest(t *testing.T) {
+	cli := test.NewFakeCli(newTestSBOMClient(t))
+	var pushed []string
+	cli.SetRegistryClient(&fakeRegistryClient{
+		putBlobFunc: func(_ context.Context, ref reference.Named, mediaType string, content []byte) (distribution.Descriptor, error) {
+			assert.Check(t, is.Equal("example.com/app", ref.String()))
+			pushed = append(pushed, mediaType)
+			return distribution.Descriptor{MediaType: mediaType, Digest: digest.FromBytes(content), Size: int64(len(content))}, nil
+		},
+		putManifestFunc: func(_ context.Context, ref reference.Named, mf distribution.Manifest) (digest.Digest, error) {
+			assert.Check(t, is.Equal("example.com/app:sha256-2222222222222222222222222222222222222222222222222222222222222222.sbom", ref.String()))
+			m, ok := mf.(*ocischema.DeserializedManifest)
+			assert.Assert(t, ok)
+			assert.Check(t, is.Equal(mediaTypeCycloneDX, m.Layers[0].MediaType))
+			return "", nil
+		},
+	})
+	cmd := NewSbomCommand(cli)
+	cmd.SetOutput(ioutil.Discard)
+	cmd.SetArgs([]string{"--format", "cyclonedx-json", "--attach", "manifest", "example.com/app"})
+	assert.NilError(t, cmd.Execute())
+	assert.Check(t, is.DeepEqual([]string{ocispec.MediaTypeImageConfig, mediaTypeCycloneDX}, pushed))
+	assert.Check(t, is.Contains(cli.OutBuffer().String(), "Attached SBOM to example.com/app@sha256:2222"))
+}
+
+func TestSbomAttachLabel(t *testing.T) {
+	client := newTestSBOMClient(t)
+	client.imageBuildFunc = func(_ context.Context, buildCtx io.Reader, options types.ImageBuildOptions) (types.ImageBuildResponse, error) {
+		assert.Check(t, is.DeepEqual([]string{"app:latest"}, options.Tags))
+		tr := tar.NewReader(buildCtx)
+		hdr, err := tr.Next()
+		assert.NilError(t, err)
+		assert.Check(t, is.Equal("Dockerfile", hdr.Name))
+		dockerfile, err := ioutil.ReadAll(tr)
+		assert.NilError(t, err)
+		assert.Check(t, is.Contains(string(dockerfile), "FROM app\nLABEL com.docker.image.sbom=\"{\\\"spdxVersion\\\":\\\"SPDX-2.2\\\""))
+		return types.ImageBuildResponse{Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+	}
+	cli := test.NewFakeCli(client)
+	cmd := NewSbomCommand(cli)
+	cmd.SetOutput(ioutil.Discard)
+	cmd.SetArgs([]string{"--attach", "label", "app"})
+	assert.NilError(t, cmd.Execute())
+}
+
+func TestSbomErrors(t *testing.T) {
+	testCases := []struct {
+		args          []string
+		expectedError string
+	}{
+		{args: []string{"--format", "xml", "app"}, expectedError: `invalid format "xml"`},
+		{args: []string{"--attach", "annotation", "app"}, expectedError: `invalid attach mode "annotation"`},
+		{args: []string{"--attach", "label", "--remote", "app"}, expectedError: "--attach label cannot be used with --remote"},
+		{args: []string{"--platform", "linux", "app"}, expectedError: "--platform can only be used with --remote"},
+	}
+	for _, tc := range testCases {
+		cmd := NewSbomCommand(test.NewFakeCli(&fakeClient{}))
+		cmd.SetOutput(ioutil.Discard)
+		cmd.SetArgs(tc.args)
+		assert.ErrorContains(t, cmd.Execute(), tc.expectedError)
+	}
+}
+
+func TestParseApkInstalled(t *testing.T) {
+	packages := parseApkInstalled([]byte("C:Q1abc=\nP:musl\nV:1.1.24-r2\nA:x86_64\nL:MIT\n\nP:busybox\nV:1.31.1-r9\nA:x86_64\nL:GPL-2.0-only\n"))
+	assert.Check(t, is.DeepEqual([]sbomPackage{
+		{Name: "musl", Version: "1.1.24-r2", Type: packageTypeApk, Arch: "x86_64", License: "MIT"},
+		{Name: "busybox", Version: "1.31.1-r9", Type: packageTypeApk, Arch: "x86_64", License: "GPL-2.0-only"},
+	}, packages))
+}
+
+func TestParseRequirements(t *testing.T) {
+	packages := parseRequirements([]byte("# pinned\nDjango[bcrypt]==2.2.10 ; python_version > '3'\n-r base.txt\nsix\nPyYAML==5.3 # yaml\n"))
+	assert.Check(t, is.DeepEqual([]sbomPackage{
+		{Name: "Django", Version: "2.2.10", Type: packageTypePyPI},
+		{Name: "PyYAML", Version: "5.3", Type: packageTypePyPI},
+	}, packages))
+	assert.Check(t, is.Equal("pkg:pypi/pyyaml@5.3", packageURL(packages[1], osRelease{})))
+}
+
+func TestParseNpmLockfileV1(t *testing.T) {
+	packages := parseNpmLockfile([]byte(`{"lockfileVersion": 1, "dependencies": {"a": {"version": "1.0.0", "dependencies": {"b": {"version": "2.0.0"}}}}}`))
+	assert.Check(t, is.Len(packages, 2))
+}
+
+func TestScanGoBuildInfo(t *testing.T) {
+	sentinel := strings.Repeat("x", 16)
+	modinfo := sentinel + "path\texample.com/app\nmod\texample.com/app\t(devel)\t\ndep\tgithub.com/pkg/errors\tv0.8.0\th1:a\n=>\tgithub.com/pkg/errors\tv0.9.1\th1:b\ndep\tgolang.org/x/sys\tv0.0.1\th1:c\n" + sentinel
+	binaryData := bytes.NewBuffer(make([]byte, 40000))
+	binaryData.Write(goBuildInfoMagic)
+	binaryData.Write([]byte{8, 2})
+	binaryData.Write(make([]byte, 16))
+	writeString := func(s string) {
+		buf := make([]byte, binary.MaxVarintLen64)
+		binaryData.Write(buf[:binary.PutUvarint(buf, uint64(len(s)))])
+		binaryData.WriteString(s)
+	}
+	writeString("go1.13.8")
+	writeString(modinfo)
+	binaryData.Write(make([]byte, 1000))
+
+	data, err := scanGoBuildInfo(binaryData)
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual([]sbomPackage{
+		{Name: "github.com/pkg/errors", Version: "v0.9.1", Type: packageTypeGolang},
+		{Name: "golang.org/x/sys", Version: "v0.0.1", Type: packageTypeGolang},
+	}, parseGoBuildInfo(data)))
+
+	data, err = scanGoBuildInfo(bytes.NewReader(make([]byte, 100000)))
+	assert.NilError(t, err)
+	assert.Check(t, is.Nil(data))
+}
+
+// newTestRPMHeader returns an rpm header blob with the given string tags
+func newTestRPMHeader(tags map[uint32]string) []byte {
+	var index, store bytes.Buffer
+	for _, tag := range []uint32{rpmTagName, rpmTagVersion, rpmTagRelease, rpmTagLicense, rpmTagArch} {
+		value, ok := tags[tag]
+		if !ok {
+			continue
+		}
+		binary.Write(&index, binary.BigEndian, []uint32{tag, rpmTypeString, uint32(store.Len()), 1})
+		store.WriteString(value + "\x00")
+	}
+	header := &bytes.Buffer{}
+	binary.Write(header, binary.BigEndian, []uint32{uint32(index.Len() / 16), uint32(store.Len())})
+	header.Write(index.Bytes())
+	header.Write(store.Bytes())
+	return header.Bytes()
+}
+
+func TestReadRPMDatabase(t *testing.T) {
+	const pageSize = 512
+	value := newTestRPMHeader(map[uint32]string{
+		rpmTagName:    "bash",
+		rpmTagVersion: "4.4.19",
+		rpmTagRelease: "10.el8",
+		rpmTagLicense: "GPLv3+",
+		rpmTagArch:    "x86_64",
+	})
+	db := make([]byte, 3*pageSize)
+	le := binary.LittleEndian
+
+	le.PutUint32(db[12:], bdbHashMagic)
+	le.PutUint32(db[20:], pageSize)
+	le.PutUint32(db[32:], 2)
+
+	// Hash page with a key and a value stored in an overflow page
+	hash := db[pageSize : 2*pageSize]
+	hash[25] = bdbPageHash
+	le.PutUint16(hash[20:], 2)
+	le.PutUint16(hash[26:], pageSize-5)
+	le.PutUint16(hash[28:], pageSize-17)
+	hash[pageSize-5] = bdbItemKeyData
+	hash[pageSize-17] = bdbItemOffPage
+	le.PutUint32(hash[pageSize-13:], 2)
+	le.PutUint32(hash[pageSize-9:], uint32(len(value)))
+
+	overflow := db[2*pageSize:]
+	overflow[25] = bdbPageOverflow
+	le.PutUint16(overflow[22:], uint16(len(value)))
+	copy(overflow[bdbPageHeaderSize:], value)
+
+	headers, err := readRPMDatabase(db)
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual([]sbomPackage{
+		{Name: "bash", Version: "4.4.19-10.el8", Type: packageTypeRPM, Arch: "x86_64", License: "GPLv3+"},
+	}, rpmPackages(headers)))
+
+	_, err = readRPMDatabase(make([]byte, pageSize))
+	assert.ErrorContains(t, err, "not a Berkeley DB hash database")
+}
+
+func TestWriteSBOMIsDeterministic(t *testing.T) {
+	catalog := sbomCatalog{Packages: []sbomPackage{{Name: "a", Version: "1", Type: packageTypeNpm, PURL: "pkg:npm/a@1"}}}
+	created := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, format := range []string{sbomFormatSPDX, sbomFormatCycloneDX} {
+		first, second := &bytes.Buffer{}, &bytes.Buffer{}
+		assert.NilError(t, writeSBOM(first, format, sbomSubject{Name: "app"}, catalog, created))
+		assert.NilError(t, writeSBOM(second, format, sbomSubject{Name: "app"}, catalog, created))
+		assert.Check(t, is.Equal(first.String(), second.String()))
+		assert.Check(t, is.Contains(first.String(), "2020-01-01T00:00:00Z"))
+	}
+}
@@ -0,0 +1,257 @@
+package image
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/yuyangjack/dockercli/cli"
+	"github.com/yuyangjack/dockercli/cli/command"
+	"github.com/yuyangjack/distribution/reference"
+	"github.com/yuyangjack/moby/pkg/archive"
+	units "github.com/docker/go-units"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+type squashOptions struct {
+	image     string
+	tag       string
+	fromLayer int
+	quiet     bool
+}
+
+// NewSquashCommand creates a new `docker image squash` command
+func NewSquashCommand(dockerCli command.Cli) *cobra.Command {
+	var opts squashOptions
+
+	cmd := &cobra.Command{
+		Use:   "squash [OPTIONS] IMAGE",
+		Short: "Merge the layers of an image into a single layer",
+		Args:  cli.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.image = args[0]
+			return runSquash(dockerCli, opts)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVarP(&opts.tag, "tag", "t", "", "Name and tag of the squashed image (required)")
+	flags.IntVar(&opts.fromLayer, "from-layer", 0, "Index of the first layer to squash, starting from 0 for the bottom layer")
+	flags.BoolVarP(&opts.quiet, "quiet", "q", false, "Suppress the load output")
+	return cmd
+}
+
+func runSquash(dockerCli command.Cli, opts squashOptions) error {
+	if opts.tag == "" {
+		return errors.New("a target image must be set with --tag")
+	}
+	target, err := reference.ParseNormalizedNamed(opts.tag)
+	if err != nil {
+		return err
+	}
+	if _, isCanonical := target.(reference.Canonical); isCanonical {
+		return errors.Errorf("invalid target %s: cannot tag with a digest", opts.tag)
+	}
+	if opts.fromLayer < 0 {
+		return errors.Errorf("invalid layer index %d: must be positive", opts.fromLayer)
+	}
+
+	tmpDir, err := ioutil.TempDir("", "docker-squash-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	responseBody, err := dockerCli.Client().ImageSave(context.Background(), []string{opts.image})
+	if err != nil {
+		return err
+	}
+	err = archive.Untar(responseBody, tmpDir, &archive.TarOptions{NoLchown: true})
+	responseBody.Close()
+	if err != nil {
+		return errors.Wrap(err, "failed to read image archive")
+	}
+
+	result, err := squashDockerArchive(tmpDir, opts.fromLayer, reference.FamiliarString(reference.TagNameOnly(target)))
+	if err != nil {
+		return errors.Wrapf(err, "failed to squash %s", opts.image)
+	}
+
+	archiveTar, err := archive.Tar(tmpDir, archive.Uncompressed)
+	if err != nil {
+		return err
+	}
+	defer archiveTar.Close()
+	if err := loadImage(dockerCli, archiveTar, opts.quiet); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(dockerCli.Out(), "Squashed %d layers into 1: %s -> %s, saved %s\n",
+		result.layers, units.HumanSize(float64(result.sizeBefore)), units.HumanSize(float64(result.sizeAfter)),
+		units.HumanSize(float64(result.sizeBefore-result.sizeAfter)))
+	return nil
+}
+
+// squashResult is the number and size of the layers that were squashed,
+// and the size of the squashed layer
+type squashResult struct {
+	layers     int
+	sizeBefore int64
+	sizeAfter  int64
+}
+
+// squashDockerArchive squashes the layers of the image in the extracted
+// `docker save` archive in dir, starting from the layer at index from, and
+// tags the squashed image with tag. The layers that are no longer used are
+// removed from the archive.
+func squashDockerArchive(dir string, from int, tag string) (squashResult, error) {
+	var manifest []dockerArchiveManifest
+	if err := readJSONFile(filepath.Join(dir, dockerArchiveManifestFile), &manifest); err != nil {
+		return squashResult{}, errors.Wrap(err, "invalid image archive manifest")
+	}
+	if len(manifest) != 1 {
+		return squashResult{}, errors.Errorf("expected one image in image archive, found %d", len(manifest))
+	}
+	archived := manifest[0]
+	if n := len(archived.Layers); from >= n-1 {
+		return squashResult{}, errors.Errorf("nothing to squash: the image has %d layers, and --from-layer must be lower than %d", n, n-1)
+	}
+
+	configPath := filepath.Join(dir, filepath.FromSlash(archived.Config))
+	configJSON, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		return squashResult{}, err
+	}
+	var (
+		config    ocispec.Image
+		rawConfig map[string]json.RawMessage
+	)
+	if err := json.Unmarshal(configJSON, &config); err != nil {
+		return squashResult{}, errors.Wrap(err, "invalid image config")
+	}
+	if err := json.Unmarshal(configJSON, &rawConfig); err != nil {
+		return squashResult{}, errors.Wrap(err, "invalid image config")
+	}
+	if len(config.RootFS.DiffIDs) != len(archived.Layers) {
+		return squashResult{}, errors.New("the layers of the image archive do not match the image config")
+	}
+
+	result := squashResult{layers: len(archived.Layers) - from}
+	var layers []string
+	for _, layer := range archived.Layers[from:] {
+		layerPath := filepath.Join(dir, filepath.FromSlash(layer))
+		fi, err := os.Stat(layerPath)
+		if err != nil {
+			return squashResult{}, err
+		}
+		result.sizeBefore += fi.Size()
+		layers = append(layers, layerPath)
+	}
+
+	squashedPath := filepath.Join(dir, "squashed.tar")
+	f, err := os.Create(squashedPath)
+	if err != nil {
+		return squashResult{}, err
+	}
+	squashed, err := squashLayers(layers, from > 0, f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return squashResult{}, err
+	}
+	result.sizeAfter = squashed.size
+
+	if err := removeUnusedLayers(dir, archived.Layers[:from], layers); err != nil {
+		return squashResult{}, err
+	}
+	squashedLayer := squashed.diffID.Hex() + "/layer.tar"
+	if err := os.MkdirAll(filepath.Join(dir, squashed.diffID.Hex()), 0755); err != nil {
+		return squashResult{}, err
+	}
+	if err := os.Rename(squashedPath, filepath.Join(dir, filepath.FromSlash(squashedLayer))); err != nil {
+		return squashResult{}, err
+	}
+
+	config.RootFS.DiffIDs = append(config.RootFS.DiffIDs[:from], squashed.diffID)
+	config.History = squashHistory(config.History, from, result.layers)
+	newConfigJSON, err := mergeImageConfig(rawConfig, config)
+	if err != nil {
+		return squashResult{}, err
+	}
+	newConfig := digest.FromBytes(newConfigJSON).Hex() + ".json"
+	if err := os.Remove(configPath); err != nil {
+		return squashResult{}, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, newConfig), newConfigJSON, 0644); err != nil {
+		return squashResult{}, err
+	}
+
+	// The legacy repositories file would tag the original image again
+	if err := os.RemoveAll(filepath.Join(dir, "repositories")); err != nil {
+		return squashResult{}, err
+	}
+	archived = dockerArchiveManifest{
+		Config:   newConfig,
+		RepoTags: []string{tag},
+		Layers:   append(archived.Layers[:from:from], squashedLayer),
+	}
+	return result, writeJSONFile(filepath.Join(dir, dockerArchiveManifestFile), []dockerArchiveManifest{archived})
+}
+
+// removeUnusedLayers removes the squashed layer tars from the archive in
+// dir, unless they are also used by the layers that are kept
+func removeUnusedLayers(dir string, kept []string, squashed []string) error {
+	used := make(map[string]bool)
+	for _, layer := range kept {
+		p, err := filepath.EvalSymlinks(filepath.Join(dir, filepath.FromSlash(layer)))
+		if err != nil {
+			return err
+		}
+		used[p] = true
+	}
+	var unused []string
+	for _, layer := range squashed {
+		p, err := filepath.EvalSymlinks(layer)
+		if err != nil {
+			return err
+		}
+		if !used[p] {
+			unused = append(unused, filepath.Dir(layer))
+		}
+	}
+	for _, layerDir := range unused {
+		if err := os.RemoveAll(layerDir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// squashHistory marks the history entries of the squashed layers, starting
+// from the layer at index from, as empty layers, and adds the entry of the
+// squashed layer
+func squashHistory(history []ocispec.History, from, squashed int) []ocispec.History {
+	layer := 0
+	for i, h := range history {
+		if h.EmptyLayer {
+			continue
+		}
+		if layer >= from {
+			history[i].EmptyLayer = true
+		}
+		layer++
+	}
+	now := time.Now().UTC()
+	return append(history, ocispec.History{
+		Created: &now,
+		Comment: fmt.Sprintf("squashed %d layers", squashed),
+	})
+}
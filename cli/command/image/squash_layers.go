@@ -0,0 +1,226 @@
+package image
+
+import (
+	"archive/tar"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+)
+
+// squashSelection is the entries of each layer that are kept when the
+// layers are merged
+type squashSelection struct {
+	// keep holds the paths of the entries kept from each layer
+	keep []map[string]bool
+	// opaqueDirs are directories that are removed and created again by the
+	// merged layers, which need an opaque whiteout to hide the content of the
+	// layers below
+	opaqueDirs []string
+}
+
+// squashedLayer is the result of merging layers
+type squashedLayer struct {
+	diffID digest.Digest
+	size   int64
+}
+
+// squashLayers merges the layer tars at layers, ordered from the bottom
+// layer, into a single layer tar written to w. Files removed or replaced by
+// an upper layer are dropped. If keepWhiteouts is set, the layers are merged
+// on top of other layers, so the whiteouts that remove files from the layers
+// below are kept.
+func squashLayers(layers []string, keepWhiteouts bool, w io.Writer) (squashedLayer, error) {
+	selection, err := selectSquashedEntries(layers, keepWhiteouts)
+	if err != nil {
+		return squashedLayer{}, err
+	}
+
+	digester := digest.Canonical.Digester()
+	counter := &countingWriter{}
+	tw := tar.NewWriter(io.MultiWriter(w, digester.Hash(), counter))
+	written := make(map[string]bool)
+	for i, layer := range layers {
+		if err := copySelectedEntries(tw, layer, selection.keep[i], written); err != nil {
+			return squashedLayer{}, errors.Wrapf(err, "failed to read layer %s", layer)
+		}
+	}
+	for _, dir := range selection.opaqueDirs {
+		name := path.Join(dir, whiteoutOpaque)
+		if written[name] {
+			continue
+		}
+		hdr := &tar.Header{
+			Name:     strings.TrimPrefix(name, "/"),
+			Typeflag: tar.TypeReg,
+			Mode:     0644,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return squashedLayer{}, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return squashedLayer{}, err
+	}
+	return squashedLayer{diffID: digester.Digest(), size: counter.n}, nil
+}
+
+// selectSquashedEntries reads the entries of layers from the top layer down,
+// and selects the entries that are visible in the merged layer: the first
+// version of each path that is not removed by a whiteout, or hidden by an
+// opaque directory or a file replacing a parent directory in an upper layer
+func selectSquashedEntries(layers []string, keepWhiteouts bool) (squashSelection, error) {
+	selection := squashSelection{keep: make([]map[string]bool, len(layers))}
+	var (
+		// selected maps the selected paths to whether they are directories
+		selected   = make(map[string]bool)
+		removed    = make(map[string]bool)
+		opaque     = make(map[string]bool)
+		opaqueDirs = make(map[string]bool)
+	)
+	hidden := func(name string) bool {
+		for p := name; p != "/"; p = path.Dir(p) {
+			if removed[p] {
+				return true
+			}
+			if p == name {
+				continue
+			}
+			if opaque[p] {
+				return true
+			}
+			if isDir, ok := selected[p]; ok && !isDir {
+				return true
+			}
+		}
+		return false
+	}
+
+	for i := len(layers) - 1; i >= 0; i-- {
+		keep := make(map[string]bool)
+		layerRemoved := make(map[string]bool)
+		layerOpaque := make(map[string]bool)
+		err := walkLayerTar(layers[i], func(name string, hdr *tar.Header) error {
+			if hidden(name) {
+				return nil
+			}
+			dir, base := path.Dir(name), path.Base(name)
+			switch {
+			case base == whiteoutOpaque:
+				layerOpaque[dir] = true
+				if keepWhiteouts {
+					keep[name] = true
+				}
+			case strings.HasPrefix(base, whiteoutPrefix):
+				target := path.Join(dir, strings.TrimPrefix(base, whiteoutPrefix))
+				layerRemoved[target] = true
+				isDir, ok := selected[target]
+				switch {
+				case !keepWhiteouts:
+				case !ok:
+					keep[name] = true
+				case isDir:
+					// The directory was created again above the whiteout: the
+					// content of the layers below must stay hidden
+					opaqueDirs[target] = true
+				}
+			default:
+				if _, ok := selected[name]; ok {
+					return nil
+				}
+				selected[name] = hdr.Typeflag == tar.TypeDir
+				keep[name] = true
+			}
+			return nil
+		})
+		if err != nil {
+			return squashSelection{}, errors.Wrapf(err, "failed to read layer %s", layers[i])
+		}
+		for p := range layerRemoved {
+			removed[p] = true
+		}
+		for p := range layerOpaque {
+			opaque[p] = true
+		}
+		selection.keep[i] = keep
+	}
+
+	for dir := range opaqueDirs {
+		selection.opaqueDirs = append(selection.opaqueDirs, dir)
+	}
+	sort.Strings(selection.opaqueDirs)
+	return selection, nil
+}
+
+// walkLayerTar calls fn for every entry of the layer tar at layer, with its
+// absolute path in the filesystem of the image
+func walkLayerTar(layer string, fn func(name string, hdr *tar.Header) error) error {
+	f, err := os.Open(layer)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := path.Clean("/" + hdr.Name)
+		if name == "/" {
+			continue
+		}
+		if err := fn(name, hdr); err != nil {
+			return err
+		}
+	}
+}
+
+// copySelectedEntries copies the entries of the layer tar at layer whose
+// path is in keep, and not in written yet, to tw
+func copySelectedEntries(tw *tar.Writer, layer string, keep, written map[string]bool) error {
+	f, err := os.Open(layer)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := path.Clean("/" + hdr.Name)
+		if !keep[name] || written[name] {
+			continue
+		}
+		written[name] = true
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return err
+		}
+	}
+}
+
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
@@ -0,0 +1,207 @@
+package image
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yuyangjack/dockercli/internal/test"
+	"github.com/yuyangjack/moby/api/types"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	"gotest.tools/fs"
+)
+
+// newTestSquashLayers returns three layers: the second one modifies and
+// removes files of the first one, and the third one creates a directory
+// removed by the second one again
+func newTestSquashLayers(t *testing.T) [][]byte {
+	return [][]byte{
+		newTestTar(t,
+			testTarFile{name: "etc/"},
+			testTarFile{name: "etc/a", content: []byte("a1")},
+			testTarFile{name: "etc/b", content: []byte("b1")},
+			testTarFile{name: "var/"},
+			testTarFile{name: "var/cache/"},
+			testTarFile{name: "var/cache/old", content: []byte("old")},
+		),
+		newTestTar(t,
+			testTarFile{name: "etc/a", content: []byte("a2")},
+			testTarFile{name: "etc/.wh.b"},
+			testTarFile{name: "var/.wh.cache"},
+		),
+		newTestTar(t,
+			testTarFile{name: "etc/c", content: []byte("c3")},
+			testTarFile{name: "var/cache/"},
+			testTarFile{name: "var/cache/new", content: []byte("new")},
+		),
+	}
+}
+
+// readTestTar returns the content of the entries of a tar, by name
+func readTestTar(t *testing.T, r io.Reader) map[string]string {
+	files := make(map[string]string)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		assert.NilError(t, err)
+		content, err := ioutil.ReadAll(tr)
+		assert.NilError(t, err)
+		files[hdr.Name] = string(content)
+	}
+}
+
+func TestSquashLayers(t *testing.T) {
+	layers := newTestSquashLayers(t)
+	dir := fs.NewDir(t, "squash-layers",
+		fs.WithFile("0.tar", string(layers[0])),
+		fs.WithFile("1.tar", string(layers[1])),
+		fs.WithFile("2.tar", string(layers[2])),
+	)
+	defer dir.Remove()
+	paths := []string{dir.Join("0.tar"), dir.Join("1.tar"), dir.Join("2.tar")}
+
+	testCases := []struct {
+		name          string
+		layers        []string
+		keepWhiteouts bool
+		expected      map[string]string
+	}{
+		{
+			name:   "all-layers",
+			layers: paths,
+			expected: map[string]string{
+				"etc/":          "",
+				"etc/a":         "a2",
+				"etc/c":         "c3",
+				"var/":          "",
+				"var/cache/":    "",
+				"var/cache/new": "new",
+			},
+		},
+		{
+			name:          "upper-layers",
+			layers:        paths[1:],
+			keepWhiteouts: true,
+			expected: map[string]string{
+				"etc/a":                  "a2",
+				"etc/.wh.b":              "",
+				"etc/c":                  "c3",
+				"var/cache/":             "",
+				"var/cache/new":          "new",
+				"var/cache/.wh..wh..opq": "",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			squashed, err := squashLayers(tc.layers, tc.keepWhiteouts, buf)
+			assert.NilError(t, err)
+			assert.Check(t, is.Equal(int64(buf.Len()), squashed.size))
+			assert.Check(t, is.DeepEqual(tc.expected, readTestTar(t, bytes.NewReader(buf.Bytes()))))
+		})
+	}
+}
+
+func TestNewSquashCommandErrors(t *testing.T) {
+	testCases := []struct {
+		name          string
+		args          []string
+		expectedError string
+	}{
+		{
+			name:          "wrong-args",
+			args:          []string{},
+			expectedError: "requires exactly 1 argument.",
+		},
+		{
+			name:          "no-tag",
+			args:          []string{"app"},
+			expectedError: "a target image must be set with --tag",
+		},
+		{
+			name:          "negative-layer",
+			args:          []string{"app", "-t", "app:squashed", "--from-layer", "-1"},
+			expectedError: "invalid layer index -1",
+		},
+		{
+			name:          "nothing-to-squash",
+			args:          []string{"app", "-t", "app:squashed", "--from-layer", "2"},
+			expectedError: "nothing to squash: the image has 3 layers",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cli := test.NewFakeCli(&fakeClient{
+				imageSaveFunc: func(images []string) (io.ReadCloser, error) {
+					return ioutil.NopCloser(bytes.NewReader(newTestDiffArchive(t, ocispec.ImageConfig{}, newTestSquashLayers(t)...))), nil
+				},
+			})
+			cmd := NewSquashCommand(cli)
+			cmd.SetOutput(ioutil.Discard)
+			cmd.SetArgs(tc.args)
+			assert.ErrorContains(t, cmd.Execute(), tc.expectedError)
+		})
+	}
+}
+
+func TestSquash(t *testing.T) {
+	layers := newTestSquashLayers(t)
+	var loaded []byte
+	cli := test.NewFakeCli(&fakeClient{
+		imageSaveFunc: func(images []string) (io.ReadCloser, error) {
+			assert.Check(t, is.DeepEqual([]string{"app:1"}, images))
+			return ioutil.NopCloser(bytes.NewReader(newTestRawConfigArchive(t, json.RawMessage(`{"Cmd": ["app"], `+testDockerConfigFields+`}`), layers...))), nil
+		},
+		imageLoadFunc: func(input io.Reader, quiet bool) (types.ImageLoadResponse, error) {
+			var err error
+			loaded, err = ioutil.ReadAll(input)
+			assert.NilError(t, err)
+			return types.ImageLoadResponse{Body: ioutil.NopCloser(strings.NewReader("Loaded image: app:squashed\n"))}, nil
+		},
+	})
+	cmd := NewSquashCommand(cli)
+	cmd.SetArgs([]string{"app:1", "--from-layer", "1", "-t", "app:squashed"})
+	assert.NilError(t, cmd.Execute())
+	assert.Check(t, is.Contains(cli.OutBuffer().String(), "Loaded image: app:squashed\nSquashed 2 layers into 1: "))
+
+	files := readTestTar(t, bytes.NewReader(loaded))
+	var manifest []dockerArchiveManifest
+	assert.NilError(t, json.Unmarshal([]byte(files[dockerArchiveManifestFile]), &manifest))
+	assert.Assert(t, is.Len(manifest, 1))
+	assert.Check(t, is.DeepEqual([]string{"app:squashed"}, manifest[0].RepoTags))
+	assert.Assert(t, is.Len(manifest[0].Layers, 2))
+
+	// Only the bottom layer and the squashed layer are loaded
+	var layerFiles []string
+	for name := range files {
+		if filepath.Base(name) == "layer.tar" {
+			layerFiles = append(layerFiles, name)
+		}
+	}
+	assert.Check(t, is.Len(layerFiles, 2))
+	assert.Check(t, is.Equal(string(layers[0]), files[manifest[0].Layers[0]]))
+	squashed := readTestTar(t, strings.NewReader(files[manifest[0].Layers[1]]))
+	assert.Check(t, is.Equal("a2", squashed["etc/a"]))
+	_, ok := squashed["etc/.wh.b"]
+	assert.Check(t, ok)
+
+	var config ocispec.Image
+	assert.NilError(t, json.Unmarshal([]byte(files[manifest[0].Config]), &config))
+	assert.Check(t, is.DeepEqual([]string{"app"}, config.Config.Cmd))
+	assert.Check(t, is.Len(config.RootFS.DiffIDs, 2))
+	assert.Check(t, is.Equal(config.RootFS.DiffIDs[0].String(), "sha256:"+filepath.Dir(manifest[0].Layers[0])))
+	assert.Assert(t, is.Len(config.History, 1))
+	assert.Check(t, is.Equal("squashed 2 layers", config.History[0].Comment))
+	checkDockerConfigFields(t, []byte(files[manifest[0].Config]))
+}
@@ -2,15 +2,24 @@ package image
 
 import (
 	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
 
 	"github.com/yuyangjack/dockercli/cli"
 	"github.com/yuyangjack/dockercli/cli/command"
+	"github.com/yuyangjack/distribution/reference"
+	"github.com/yuyangjack/moby/api/types"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 )
 
 type tagOptions struct {
 	image string
 	name  string
+	from  string
+	to    string
 }
 
 // NewTagCommand creates a new `docker tag` command
@@ -20,8 +29,16 @@ func NewTagCommand(dockerCli command.Cli) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "tag SOURCE_IMAGE[:TAG] TARGET_IMAGE[:TAG]",
 		Short: "Create a tag TARGET_IMAGE that refers to SOURCE_IMAGE",
-		Args:  cli.ExactArgs(2),
+		Args: func(cmd *cobra.Command, args []string) error {
+			if opts.from != "" || opts.to != "" {
+				return cli.NoArgs(cmd, args)
+			}
+			return cli.ExactArgs(2)(cmd, args)
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.from != "" || opts.to != "" {
+				return runTagMany(dockerCli, opts)
+			}
 			opts.image = args[0]
 			opts.name = args[1]
 			return runTag(dockerCli, opts)
@@ -30,6 +47,8 @@ func NewTagCommand(dockerCli command.Cli) *cobra.Command {
 
 	flags := cmd.Flags()
 	flags.SetInterspersed(false)
+	flags.StringVar(&opts.from, "from", "", "Tag every local image whose name matches the regular expression")
+	flags.StringVar(&opts.to, "to", "", "Name of the new tags, where $1, $2... are the groups matched by --from")
 
 	return cmd
 }
@@ -39,3 +58,54 @@ func runTag(dockerCli command.Cli, opts tagOptions) error {
 
 	return dockerCli.Client().ImageTag(ctx, opts.image, opts.name)
 }
+
+// runTagMany tags every local image whose name matches the --from regular
+// expression with the name produced by expanding --to
+func runTagMany(dockerCli command.Cli, opts tagOptions) error {
+	if opts.from == "" || opts.to == "" {
+		return errors.New("--from and --to must be used together")
+	}
+	// The expression must match the whole name
+	from, err := regexp.Compile("^(?:" + opts.from + ")$")
+	if err != nil {
+		return errors.Wrap(err, "invalid --from expression")
+	}
+
+	ctx := context.Background()
+	images, err := dockerCli.Client().ImageList(ctx, types.ImageListOptions{})
+	if err != nil {
+		return err
+	}
+	var sources []string
+	for _, image := range images {
+		for _, tag := range image.RepoTags {
+			if tag != "<none>:<none>" && from.MatchString(tag) {
+				sources = append(sources, tag)
+			}
+		}
+	}
+	if len(sources) == 0 {
+		return errors.Errorf("no local image matches %s", opts.from)
+	}
+	sort.Strings(sources)
+
+	var errs []string
+	for _, source := range sources {
+		target := from.ReplaceAllString(source, opts.to)
+		named, err := reference.ParseNormalizedNamed(target)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: invalid name %s: %v", source, target, err))
+			continue
+		}
+		target = reference.FamiliarString(reference.TagNameOnly(named))
+		if err := dockerCli.Client().ImageTag(ctx, source, target); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", source, err))
+			continue
+		}
+		fmt.Fprintf(dockerCli.Out(), "%s -> %s\n", source, target)
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "\n"))
+	}
+	return nil
+}
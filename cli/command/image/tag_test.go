@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/yuyangjack/dockercli/internal/test"
+	"github.com/yuyangjack/moby/api/types"
 	"gotest.tools/assert"
 	is "gotest.tools/assert/cmp"
 )
@@ -39,3 +40,52 @@ func TestCliNewTagCommand(t *testing.T) {
 	value, _ := cmd.Flags().GetBool("interspersed")
 	assert.Check(t, !value)
 }
+
+func TestTagMany(t *testing.T) {
+	var tagged []string
+	cli := test.NewFakeCli(&fakeClient{
+		imageListFunc: func(options types.ImageListOptions) ([]types.ImageSummary, error) {
+			return []types.ImageSummary{
+				{RepoTags: []string{"registry-old.corp/team/app:1", "app:1"}},
+				{RepoTags: []string{"registry-old.corp/team/db:2"}},
+				{RepoTags: []string{"<none>:<none>"}},
+			}, nil
+		},
+		imageTagFunc: func(image string, ref string) error {
+			tagged = append(tagged, image+" "+ref)
+			return nil
+		},
+	})
+	cmd := NewTagCommand(cli)
+	cmd.SetArgs([]string{"--from", "registry-old.corp/(.*)", "--to", "registry-new.corp/$1"})
+	cmd.SetOutput(ioutil.Discard)
+	assert.NilError(t, cmd.Execute())
+	assert.Check(t, is.DeepEqual([]string{
+		"registry-old.corp/team/app:1 registry-new.corp/team/app:1",
+		"registry-old.corp/team/db:2 registry-new.corp/team/db:2",
+	}, tagged))
+	assert.Check(t, is.Contains(cli.OutBuffer().String(), "registry-old.corp/team/db:2 -> registry-new.corp/team/db:2\n"))
+}
+
+func TestTagManyErrors(t *testing.T) {
+	testCases := []struct {
+		args          []string
+		expectedError string
+	}{
+		{args: []string{"--from", "app:(.*)"}, expectedError: "--from and --to must be used together"},
+		{args: []string{"--from", "app:(", "--to", "x"}, expectedError: "invalid --from expression"},
+		{args: []string{"--from", "app:(.*)", "--to", "x", "image"}, expectedError: "accepts no arguments"},
+		{args: []string{"--from", "other:(.*)", "--to", "x:$1"}, expectedError: "no local image matches other:(.*)"},
+		{args: []string{"--from", "app:(.*)", "--to", "UPPER:$1"}, expectedError: "app:1: invalid name UPPER:1"},
+	}
+	for _, tc := range testCases {
+		cmd := NewTagCommand(test.NewFakeCli(&fakeClient{
+			imageListFunc: func(options types.ImageListOptions) ([]types.ImageSummary, error) {
+				return []types.ImageSummary{{RepoTags: []string{"app:1"}}}, nil
+			},
+		}))
+		cmd.SetArgs(tc.args)
+		cmd.SetOutput(ioutil.Discard)
+		assert.ErrorContains(t, cmd.Execute(), tc.expectedError)
+	}
+}
@@ -2,6 +2,7 @@ package manifest
 
 import (
 	"context"
+	"io"
 
 	manifesttypes "github.com/yuyangjack/dockercli/cli/manifest/types"
 	"github.com/yuyangjack/dockercli/cli/registry/client"
@@ -11,11 +12,43 @@ import (
 )
 
 type fakeRegistryClient struct {
-	getManifestFunc     func(ctx context.Context, ref reference.Named) (manifesttypes.ImageManifest, error)
-	getManifestListFunc func(ctx context.Context, ref reference.Named) ([]manifesttypes.ImageManifest, error)
-	mountBlobFunc       func(ctx context.Context, source reference.Canonical, target reference.Named) error
-	putManifestFunc     func(ctx context.Context, source reference.Named, mf distribution.Manifest) (digest.Digest, error)
-	getTagsFunc         func(ctx context.Context, ref reference.Named) ([]string, error)
+	getManifestFunc             func(ctx context.Context, ref reference.Named) (manifesttypes.ImageManifest, error)
+	getManifestListFunc         func(ctx context.Context, ref reference.Named) ([]manifesttypes.ImageManifest, error)
+	getDistributionManifestFunc func(ctx context.Context, ref reference.Named) (distribution.Manifest, error)
+	mountBlobFunc               func(ctx context.Context, source reference.Canonical, target reference.Named) error
+	putManifestFunc             func(ctx context.Context, source reference.Named, mf distribution.Manifest) (digest.Digest, error)
+	getTagsFunc                 func(ctx context.Context, ref reference.Named) ([]string, error)
+}
+
+func (c *fakeRegistryClient) GetDistributionManifest(ctx context.Context, ref reference.Named) (distribution.Manifest, error) {
+	if c.getDistributionManifestFunc != nil {
+		return c.getDistributionManifestFunc(ctx, ref)
+	}
+	return nil, nil
+}
+
+func (c *fakeRegistryClient) CopyBlob(ctx context.Context, source reference.Named, target reference.Named, desc distribution.Descriptor) error {
+	return nil
+}
+
+func (c *fakeRegistryClient) GetImageConfig(ctx context.Context, ref reference.Named) (manifesttypes.ImageManifest, []byte, error) {
+	return manifesttypes.ImageManifest{}, nil, nil
+}
+
+func (c *fakeRegistryClient) GetCatalog(ctx context.Context, domain string) ([]string, error) {
+	return nil, nil
+}
+
+func (c *fakeRegistryClient) DeleteManifest(ctx context.Context, ref reference.Canonical) error {
+	return nil
+}
+
+func (c *fakeRegistryClient) GetBlob(ctx context.Context, ref reference.Named, dgst digest.Digest) (io.ReadCloser, error) {
+	return nil, nil
+}
+
+func (c *fakeRegistryClient) PutBlob(ctx context.Context, ref reference.Named, mediaType string, content []byte) (distribution.Descriptor, error) {
+	return distribution.Descriptor{}, nil
 }
 
 func (c *fakeRegistryClient) GetManifest(ctx context.Context, ref reference.Named) (manifesttypes.ImageManifest, error) {
@@ -3,18 +3,22 @@ package manifest
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/yuyangjack/dockercli/cli"
 	"github.com/yuyangjack/dockercli/cli/command"
 	"github.com/yuyangjack/dockercli/cli/manifest/store"
+	"github.com/yuyangjack/dockercli/cli/manifest/types"
 	"github.com/yuyangjack/moby/registry"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 )
 
 type createOpts struct {
-	amend    bool
-	insecure bool
+	amend       bool
+	insecure    bool
+	oci         bool
+	annotations []string
 }
 
 func newCreateListCommand(dockerCli command.Cli) *cobra.Command {
@@ -32,6 +36,8 @@ func newCreateListCommand(dockerCli command.Cli) *cobra.Command {
 	flags := cmd.Flags()
 	flags.BoolVar(&opts.insecure, "insecure", false, "Allow communication with an insecure registry")
 	flags.BoolVarP(&opts.amend, "amend", "a", false, "Amend an existing manifest list")
+	flags.BoolVar(&opts.oci, "oci", false, "Push the manifest list as an OCI image index, converting Docker image manifests to OCI")
+	flags.StringArrayVar(&opts.annotations, "annotation", nil, "Set an index-level annotation (key=value), requires --oci")
 	return cmd
 }
 
@@ -58,6 +64,22 @@ func createManifestList(dockerCli command.Cli, args []string, opts createOpts) e
 		return errors.Errorf("refusing to amend an existing manifest list with no --amend flag")
 	}
 
+	listOptions, err := manifestStore.GetListOptions(targetRef)
+	if err != nil {
+		return err
+	}
+	if opts.oci {
+		listOptions.OCI = true
+	}
+	if len(opts.annotations) > 0 {
+		if !listOptions.OCI {
+			return errors.New("--annotation requires --oci")
+		}
+		if listOptions.Annotations, err = parseAnnotations(listOptions.Annotations, opts.annotations); err != nil {
+			return err
+		}
+	}
+
 	ctx := context.Background()
 	// Now create the local manifest list transaction by looking up the manifest schemas
 	// for the constituent images:
@@ -77,6 +99,27 @@ func createManifestList(dockerCli command.Cli, args []string, opts createOpts) e
 			return err
 		}
 	}
+	if listOptions.OCI {
+		if err := manifestStore.SaveListOptions(targetRef, listOptions); err != nil {
+			return err
+		}
+	}
 	fmt.Fprintf(dockerCli.Out(), "Created manifest list %s\n", targetRef.String())
 	return nil
 }
+
+// parseAnnotations adds annotations in the form key=value to existing.
+func parseAnnotations(existing map[string]string, annotations []string) (map[string]string, error) {
+	result := make(map[string]string, len(existing)+len(annotations))
+	for k, v := range existing {
+		result[k] = v
+	}
+	for _, annotation := range annotations {
+		kv := strings.SplitN(annotation, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, errors.Errorf("invalid annotation %q: expected key=value", annotation)
+		}
+		result[kv[0]] = kv[1]
+	}
+	return result, nil
+}
@@ -2,12 +2,14 @@ package manifest
 
 import (
 	"context"
+	"encoding/json"
 	"io/ioutil"
 	"testing"
 
 	manifesttypes "github.com/yuyangjack/dockercli/cli/manifest/types"
 	"github.com/yuyangjack/dockercli/internal/test"
 	"github.com/yuyangjack/distribution/reference"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/pkg/errors"
 	"gotest.tools/assert"
 	is "gotest.tools/assert/cmp"
@@ -114,3 +116,45 @@ func TestManifestCreateNoManifest(t *testing.T) {
 	err := cmd.Execute()
 	assert.Error(t, err, "No such image: example.com/alpine:3.0")
 }
+
+func TestManifestCreateOCI(t *testing.T) {
+	store, cleanup := newTempManifestStore(t)
+	defer cleanup()
+
+	cli := test.NewFakeCli(nil)
+	cli.SetManifestStore(store)
+	cli.SetRegistryClient(&fakeRegistryClient{
+		getManifestFunc: func(_ context.Context, ref reference.Named) (manifesttypes.ImageManifest, error) {
+			return fullImageManifest(t, ref), nil
+		},
+	})
+
+	cmd := newCreateListCommand(cli)
+	cmd.SetArgs([]string{"--annotation", "org.opencontainers.image.version=1.0", "example.com/list:v1", "example.com/alpine:3.0"})
+	cmd.SetOutput(ioutil.Discard)
+	assert.ErrorContains(t, cmd.Execute(), "--annotation requires --oci")
+
+	cmd = newCreateListCommand(cli)
+	cmd.SetArgs([]string{"--oci", "--annotation", "org.opencontainers.image.version=1.0", "example.com/list:v1", "example.com/alpine:3.0"})
+	cmd.SetOutput(ioutil.Discard)
+	assert.NilError(t, cmd.Execute())
+
+	cli = test.NewFakeCli(nil)
+	cli.SetManifestStore(store)
+	inspectCmd := newInspectCommand(cli)
+	inspectCmd.SetArgs([]string{"example.com/list:v1"})
+	assert.NilError(t, inspectCmd.Execute())
+
+	var index ocispec.Index
+	assert.NilError(t, json.Unmarshal(cli.OutBuffer().Bytes(), &index))
+	assert.Check(t, is.DeepEqual(map[string]string{"org.opencontainers.image.version": "1.0"}, index.Annotations))
+	assert.Assert(t, is.Len(index.Manifests, 1))
+	assert.Check(t, is.Equal(ocispec.MediaTypeImageManifest, index.Manifests[0].MediaType))
+	assert.Check(t, is.Equal("amd64", index.Manifests[0].Platform.Architecture))
+
+	converted, ok, err := convertToOCI(fullImageManifest(t, ref(t, "alpine:3.0")))
+	assert.NilError(t, err)
+	assert.Check(t, ok)
+	assert.Check(t, is.Equal(converted.Descriptor.Digest, index.Manifests[0].Digest))
+	assert.Check(t, is.Equal(ocispec.MediaTypeImageLayerGzip, converted.OCIManifest.Layers[0].MediaType))
+}
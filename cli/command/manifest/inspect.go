@@ -72,7 +72,11 @@ func runInspect(dockerCli command.Cli, opts inspectOptions) error {
 	// Try a local manifest list first
 	localManifestList, err := dockerCli.ManifestStore().GetList(namedRef)
 	if err == nil {
-		return printManifestList(dockerCli, namedRef, localManifestList, opts)
+		listOptions, err := dockerCli.ManifestStore().GetListOptions(namedRef)
+		if err != nil {
+			return err
+		}
+		return printManifestList(dockerCli, namedRef, localManifestList, listOptions, opts)
 	}
 
 	// Next try a remote manifest
@@ -84,11 +88,32 @@ func runInspect(dockerCli command.Cli, opts inspectOptions) error {
 	}
 
 	// Finally try a remote manifest list
+	if !opts.verbose {
+		// Print the list as stored in the registry, which keeps the media
+		// type and annotations of an OCI image index.
+		manifestList, err := registryClient.GetDistributionManifest(ctx, namedRef)
+		if err != nil {
+			return err
+		}
+		if manifestList == nil {
+			return errors.Errorf("No such manifest: %s", namedRef)
+		}
+		_, raw, err := manifestList.Payload()
+		if err != nil {
+			return err
+		}
+		buffer := new(bytes.Buffer)
+		if err := json.Indent(buffer, raw, "", "\t"); err != nil {
+			return err
+		}
+		fmt.Fprintln(dockerCli.Out(), buffer.String())
+		return nil
+	}
 	manifestList, err := registryClient.GetManifestList(ctx, namedRef)
 	if err != nil {
 		return err
 	}
-	return printManifestList(dockerCli, namedRef, manifestList, opts)
+	return printManifestList(dockerCli, namedRef, manifestList, types.ListOptions{}, opts)
 }
 
 func printManifest(dockerCli command.Cli, manifest types.ImageManifest, opts inspectOptions) error {
@@ -112,7 +137,18 @@ func printManifest(dockerCli command.Cli, manifest types.ImageManifest, opts ins
 	return nil
 }
 
-func printManifestList(dockerCli command.Cli, namedRef reference.Named, list []types.ImageManifest, opts inspectOptions) error {
+func printManifestList(dockerCli command.Cli, namedRef reference.Named, list []types.ImageManifest, listOptions types.ListOptions, opts inspectOptions) error {
+	if listOptions.OCI {
+		ociList := make([]types.ImageManifest, 0, len(list))
+		for _, img := range list {
+			ociManifest, _, err := convertToOCI(img)
+			if err != nil {
+				return err
+			}
+			ociList = append(ociList, ociManifest)
+		}
+		list = ociList
+	}
 	if !opts.verbose {
 		targetRepo, err := registry.ParseRepositoryInfo(namedRef)
 		if err != nil {
@@ -128,11 +164,11 @@ func printManifestList(dockerCli command.Cli, namedRef reference.Named, list []t
 			}
 			manifests = append(manifests, mfd)
 		}
-		deserializedML, err := manifestlist.FromDescriptors(manifests)
+		deserializedML, err := newManifestList(manifests, listOptions)
 		if err != nil {
 			return err
 		}
-		jsonBytes, err := deserializedML.MarshalJSON()
+		_, jsonBytes, err := deserializedML.Payload()
 		if err != nil {
 			return err
 		}
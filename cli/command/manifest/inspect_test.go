@@ -96,6 +96,34 @@ func TestInspectCommandNotFound(t *testing.T) {
 		getManifestListFunc: func(ctx context.Context, ref reference.Named) ([]manifesttypes.ImageManifest, error) {
 			return nil, errors.Errorf("No such manifest: %s", ref)
 		},
+		getDistributionManifestFunc: func(ctx context.Context, ref reference.Named) (distribution.Manifest, error) {
+			return nil, errors.Errorf("No such manifest: %s", ref)
+		},
+	})
+
+	cmd := newInspectCommand(cli)
+	cmd.SetOutput(ioutil.Discard)
+	cmd.SetArgs([]string{"example.com/alpine:3.0"})
+	err := cmd.Execute()
+	assert.Error(t, err, "No such manifest: example.com/alpine:3.0")
+
+	cmd = newInspectCommand(cli)
+	cmd.SetOutput(ioutil.Discard)
+	cmd.SetArgs([]string{"--verbose", "example.com/alpine:3.0"})
+	err = cmd.Execute()
+	assert.Error(t, err, "No such manifest: example.com/alpine:3.0")
+}
+
+func TestInspectCommandNilRemoteManifestList(t *testing.T) {
+	store, cleanup := newTempManifestStore(t)
+	defer cleanup()
+
+	cli := test.NewFakeCli(nil)
+	cli.SetManifestStore(store)
+	cli.SetRegistryClient(&fakeRegistryClient{
+		getManifestFunc: func(_ context.Context, _ reference.Named) (manifesttypes.ImageManifest, error) {
+			return manifesttypes.ImageManifest{}, errors.New("missing")
+		},
 	})
 
 	cmd := newInspectCommand(cli)
@@ -0,0 +1,79 @@
+package manifest
+
+import (
+	"github.com/yuyangjack/dockercli/cli/manifest/types"
+	"github.com/yuyangjack/distribution"
+	"github.com/yuyangjack/distribution/manifest/manifestlist"
+	"github.com/yuyangjack/distribution/manifest/ocischema"
+	"github.com/yuyangjack/distribution/manifest/schema2"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+// ociMediaTypes maps Docker image media types to their OCI equivalents
+var ociMediaTypes = map[string]string{
+	schema2.MediaTypeImageConfig:       ocispec.MediaTypeImageConfig,
+	schema2.MediaTypeLayer:             ocispec.MediaTypeImageLayerGzip,
+	schema2.MediaTypeUncompressedLayer: ocispec.MediaTypeImageLayer,
+	schema2.MediaTypeForeignLayer:      ocispec.MediaTypeImageLayerNonDistributableGzip,
+}
+
+// convertToOCI returns imageManifest as an OCI image manifest. A Docker image
+// manifest is converted to an OCI manifest referencing the same blobs, which
+// gives it a new digest. The boolean reports whether it was converted.
+func convertToOCI(imageManifest types.ImageManifest) (types.ImageManifest, bool, error) {
+	mf := imageManifest.SchemaV2Manifest
+	if mf == nil {
+		return imageManifest, false, nil
+	}
+
+	config, err := ociDescriptor(mf.Config)
+	if err != nil {
+		return imageManifest, false, err
+	}
+	layers := make([]distribution.Descriptor, 0, len(mf.Layers))
+	for _, layer := range mf.Layers {
+		l, err := ociDescriptor(layer)
+		if err != nil {
+			return imageManifest, false, err
+		}
+		layers = append(layers, l)
+	}
+	ociManifest, err := ocischema.FromStruct(ocischema.Manifest{
+		Versioned: ocischema.SchemaVersion,
+		Config:    config,
+		Layers:    layers,
+	})
+	if err != nil {
+		return imageManifest, false, err
+	}
+	mediaType, payload, err := ociManifest.Payload()
+	if err != nil {
+		return imageManifest, false, err
+	}
+
+	desc := imageManifest.Descriptor
+	desc.MediaType = mediaType
+	desc.Digest = digest.FromBytes(payload)
+	desc.Size = int64(len(payload))
+	return types.NewOCIImageManifest(imageManifest.Ref.Named, desc, ociManifest), true, nil
+}
+
+func ociDescriptor(desc distribution.Descriptor) (distribution.Descriptor, error) {
+	mediaType, ok := ociMediaTypes[desc.MediaType]
+	if !ok {
+		return desc, errors.Errorf("cannot convert %s to OCI: unsupported media type %s", desc.Digest, desc.MediaType)
+	}
+	desc.MediaType = mediaType
+	return desc, nil
+}
+
+// newManifestList returns a Docker manifest list, or an OCI image index if
+// the local list was created with --oci.
+func newManifestList(descriptors []manifestlist.ManifestDescriptor, options types.ListOptions) (distribution.Manifest, error) {
+	if options.OCI {
+		return types.NewOCIIndex(descriptors, options.Annotations)
+	}
+	return manifestlist.FromDescriptors(descriptors)
+}
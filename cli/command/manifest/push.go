@@ -12,6 +12,7 @@ import (
 	registryclient "github.com/yuyangjack/dockercli/cli/registry/client"
 	"github.com/yuyangjack/distribution"
 	"github.com/yuyangjack/distribution/manifest/manifestlist"
+	"github.com/yuyangjack/distribution/manifest/ocischema"
 	"github.com/yuyangjack/distribution/manifest/schema2"
 	"github.com/yuyangjack/distribution/reference"
 	"github.com/yuyangjack/moby/registry"
@@ -37,7 +38,7 @@ type manifestBlob struct {
 
 type pushRequest struct {
 	targetRef     reference.Named
-	list          *manifestlist.DeserializedManifestList
+	list          distribution.Manifest
 	mountRequests []mountRequest
 	manifestBlobs []manifestBlob
 	insecure      bool
@@ -76,8 +77,12 @@ func runPush(dockerCli command.Cli, opts pushOpts) error {
 	if len(manifests) == 0 {
 		return errors.Errorf("%s not found", targetRef)
 	}
+	listOptions, err := dockerCli.ManifestStore().GetListOptions(targetRef)
+	if err != nil {
+		return err
+	}
 
-	pushRequest, err := buildPushRequest(manifests, targetRef, opts.insecure)
+	pushRequest, err := buildPushRequest(manifests, listOptions, targetRef, opts.insecure)
 	if err != nil {
 		return err
 	}
@@ -92,11 +97,27 @@ func runPush(dockerCli command.Cli, opts pushOpts) error {
 	return nil
 }
 
-func buildPushRequest(manifests []types.ImageManifest, targetRef reference.Named, insecure bool) (pushRequest, error) {
+func buildPushRequest(manifests []types.ImageManifest, listOptions types.ListOptions, targetRef reference.Named, insecure bool) (pushRequest, error) {
 	req := pushRequest{targetRef: targetRef, insecure: insecure}
 
+	// Manifests converted to OCI do not exist in the registry yet, so they
+	// are always pushed.
+	converted := make([]bool, len(manifests))
+	if listOptions.OCI {
+		ociManifests := make([]types.ImageManifest, 0, len(manifests))
+		for i, imageManifest := range manifests {
+			ociManifest, ok, err := convertToOCI(imageManifest)
+			if err != nil {
+				return req, err
+			}
+			ociManifests = append(ociManifests, ociManifest)
+			converted[i] = ok
+		}
+		manifests = ociManifests
+	}
+
 	var err error
-	req.list, err = buildManifestList(manifests, targetRef)
+	req.list, err = buildManifestList(manifests, listOptions, targetRef)
 	if err != nil {
 		return req, err
 	}
@@ -110,7 +131,7 @@ func buildPushRequest(manifests []types.ImageManifest, targetRef reference.Named
 		return req, err
 	}
 
-	for _, imageManifest := range manifests {
+	for i, imageManifest := range manifests {
 		manifestRepoName, err := registryclient.RepoNameForReference(imageManifest.Ref)
 		if err != nil {
 			return req, err
@@ -123,7 +144,8 @@ func buildPushRequest(manifests []types.ImageManifest, targetRef reference.Named
 				return req, err
 			}
 			req.manifestBlobs = append(req.manifestBlobs, blobs...)
-
+		}
+		if repoName.Name() != targetRepoName || converted[i] {
 			manifestPush, err := buildPutManifestRequest(imageManifest, targetRef)
 			if err != nil {
 				return req, err
@@ -134,7 +156,7 @@ func buildPushRequest(manifests []types.ImageManifest, targetRef reference.Named
 	return req, nil
 }
 
-func buildManifestList(manifests []types.ImageManifest, targetRef reference.Named) (*manifestlist.DeserializedManifestList, error) {
+func buildManifestList(manifests []types.ImageManifest, listOptions types.ListOptions, targetRef reference.Named) (distribution.Manifest, error) {
 	targetRepoInfo, err := registry.ParseRepositoryInfo(targetRef)
 	if err != nil {
 		return nil, err
@@ -155,7 +177,7 @@ func buildManifestList(manifests []types.ImageManifest, targetRef reference.Name
 		descriptors = append(descriptors, descriptor)
 	}
 
-	return manifestlist.FromDescriptors(descriptors)
+	return newManifestList(descriptors, listOptions)
 }
 
 func buildManifestDescriptor(targetRepo *registry.RepositoryInfo, imageManifest types.ImageManifest) (manifestlist.ManifestDescriptor, error) {
@@ -219,18 +241,31 @@ func buildPutManifestRequest(imageManifest types.ImageManifest, targetRef refere
 		return mountRequest{}, err
 	}
 
-	// This indentation has to be added to ensure sha parity with the registry
-	v2ManifestBytes, err := json.MarshalIndent(imageManifest.SchemaV2Manifest, "", "   ")
-	if err != nil {
-		return mountRequest{}, err
-	}
-	// indent only the DeserializedManifest portion of this, in order to maintain parity with the registry
-	// and not alter the sha
-	var v2Manifest schema2.DeserializedManifest
-	if err = v2Manifest.UnmarshalJSON(v2ManifestBytes); err != nil {
-		return mountRequest{}, err
+	switch {
+	case imageManifest.SchemaV2Manifest != nil:
+		// This indentation has to be added to ensure sha parity with the registry
+		v2ManifestBytes, err := json.MarshalIndent(imageManifest.SchemaV2Manifest, "", "   ")
+		if err != nil {
+			return mountRequest{}, err
+		}
+		// indent only the DeserializedManifest portion of this, in order to maintain parity with the registry
+		// and not alter the sha
+		var v2Manifest schema2.DeserializedManifest
+		if err = v2Manifest.UnmarshalJSON(v2ManifestBytes); err != nil {
+			return mountRequest{}, err
+		}
+		imageManifest.SchemaV2Manifest = &v2Manifest
+	case imageManifest.OCIManifest != nil:
+		ociManifestBytes, err := json.MarshalIndent(imageManifest.OCIManifest, "", "   ")
+		if err != nil {
+			return mountRequest{}, err
+		}
+		var ociManifest ocischema.DeserializedManifest
+		if err = ociManifest.UnmarshalJSON(ociManifestBytes); err != nil {
+			return mountRequest{}, err
+		}
+		imageManifest.OCIManifest = &ociManifest
 	}
-	imageManifest.SchemaV2Manifest = &v2Manifest
 
 	return mountRequest{ref: mountRef, manifest: imageManifest}, err
 }
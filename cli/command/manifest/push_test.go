@@ -7,9 +7,13 @@ import (
 
 	manifesttypes "github.com/yuyangjack/dockercli/cli/manifest/types"
 	"github.com/yuyangjack/dockercli/internal/test"
+	"github.com/yuyangjack/distribution"
 	"github.com/yuyangjack/distribution/reference"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/pkg/errors"
 	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
 )
 
 func newFakeRegistryClient() *fakeRegistryClient {
@@ -67,3 +71,39 @@ func TestManifestPush(t *testing.T) {
 	err = cmd.Execute()
 	assert.NilError(t, err)
 }
+
+func TestManifestPushOCI(t *testing.T) {
+	store, sCleanup := newTempManifestStore(t)
+	defer sCleanup()
+
+	var pushed []string
+	var listMediaType string
+	registry := newFakeRegistryClient()
+	registry.putManifestFunc = func(_ context.Context, ref reference.Named, mf distribution.Manifest) (digest.Digest, error) {
+		pushed = append(pushed, ref.String())
+		mediaType, payload, err := mf.Payload()
+		if _, ok := ref.(reference.Canonical); !ok {
+			listMediaType = mediaType
+		}
+		return digest.FromBytes(payload), err
+	}
+
+	cli := test.NewFakeCli(nil)
+	cli.SetManifestStore(store)
+	cli.SetRegistryClient(registry)
+
+	listRef := ref(t, "list:v1")
+	namedRef := ref(t, "list:amd64")
+	assert.NilError(t, store.Save(listRef, namedRef, fullImageManifest(t, namedRef)))
+	assert.NilError(t, store.SaveListOptions(listRef, manifesttypes.ListOptions{OCI: true}))
+
+	cmd := newPushListCommand(cli)
+	cmd.SetArgs([]string{"example.com/list:v1"})
+	assert.NilError(t, cmd.Execute())
+
+	// The converted manifest is pushed even though it is in the same repository
+	converted, _, err := convertToOCI(fullImageManifest(t, namedRef))
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual([]string{"example.com/list@" + converted.Descriptor.Digest.String(), "example.com/list:v1"}, pushed))
+	assert.Check(t, is.Equal(ocispec.MediaTypeImageIndex, listMediaType))
+}
@@ -0,0 +1,44 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/yuyangjack/dockercli/cli"
+	"github.com/yuyangjack/dockercli/cli/command"
+	"github.com/spf13/cobra"
+)
+
+type catalogOptions struct {
+	registry string
+	insecure bool
+}
+
+func newCatalogCommand(dockerCli command.Cli) *cobra.Command {
+	var opts catalogOptions
+
+	cmd := &cobra.Command{
+		Use:   "catalog [OPTIONS] REGISTRY",
+		Short: "List the repositories in a registry",
+		Args:  cli.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.registry = args[0]
+			return runCatalog(dockerCli, opts)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.BoolVar(&opts.insecure, "insecure", false, "Allow communication with an insecure registry")
+	return cmd
+}
+
+func runCatalog(dockerCli command.Cli, opts catalogOptions) error {
+	repositories, err := dockerCli.RegistryClient(opts.insecure).GetCatalog(context.Background(), opts.registry)
+	if err != nil {
+		return err
+	}
+	for _, repo := range repositories {
+		fmt.Fprintln(dockerCli.Out(), repo)
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package registry
+
+import (
+	"github.com/yuyangjack/dockercli/cli"
+	"github.com/yuyangjack/dockercli/cli/command"
+	"github.com/spf13/cobra"
+)
+
+// NewRegistryCommand returns a cobra command for `registry` subcommands
+func NewRegistryCommand(dockerCli command.Cli) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "registry",
+		Short: "Manage repositories in a registry",
+		Args:  cli.NoArgs,
+		RunE:  command.ShowHelp(dockerCli.Err()),
+	}
+	cmd.AddCommand(
+		newTagsCommand(dockerCli),
+		newCatalogCommand(dockerCli),
+		newRemoveCommand(dockerCli),
+		newGCPlanCommand(dockerCli),
+	)
+	return cmd
+}
@@ -0,0 +1,211 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/yuyangjack/dockercli/cli"
+	"github.com/yuyangjack/dockercli/cli/command"
+	registryclient "github.com/yuyangjack/dockercli/cli/registry/client"
+	"github.com/yuyangjack/distribution/manifest/manifestlist"
+	"github.com/yuyangjack/distribution/reference"
+	units "github.com/docker/go-units"
+	"github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+type gcPlanOptions struct {
+	repository string
+	olderThan  time.Duration
+	keep       []string
+	keepLast   int
+	quiet      bool
+	insecure   bool
+}
+
+// gcPolicy decides which tags of a repository are kept.
+type gcPolicy struct {
+	olderThan time.Duration
+	keep      []*regexp.Regexp
+	keepLast  int
+}
+
+// tagInfo is a tag with the manifest it refers to and the creation time of
+// the image.
+type tagInfo struct {
+	tag     string
+	digest  digest.Digest
+	created time.Time
+}
+
+func newGCPlanCommand(dockerCli command.Cli) *cobra.Command {
+	var opts gcPlanOptions
+
+	cmd := &cobra.Command{
+		Use:   "gc-plan [OPTIONS] REPOSITORY",
+		Short: "List the tags of a repository that a retention policy would delete",
+		Long: "List the tags of a repository that a retention policy would delete. " +
+			"Tags that share a manifest with a kept tag are never listed, because deleting the manifest would delete the kept tag too. " +
+			"The output of --quiet can be passed to 'docker registry rm'.",
+		Args: cli.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.repository = args[0]
+			return runGCPlan(dockerCli, opts)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.DurationVar(&opts.olderThan, "older-than", 0, "Only delete tags of images created more than this long ago (e.g. 720h)")
+	flags.StringArrayVar(&opts.keep, "keep", nil, "Keep tags matching this regular expression")
+	flags.IntVar(&opts.keepLast, "keep-last", 0, "Keep this many of the most recently created tags")
+	flags.BoolVarP(&opts.quiet, "quiet", "q", false, "Only display the digest references to delete")
+	flags.BoolVar(&opts.insecure, "insecure", false, "Allow communication with an insecure registry")
+	return cmd
+}
+
+func runGCPlan(dockerCli command.Cli, opts gcPlanOptions) error {
+	if opts.olderThan <= 0 && len(opts.keep) == 0 && opts.keepLast <= 0 {
+		return errors.New("at least one of --older-than, --keep or --keep-last is required")
+	}
+	policy := gcPolicy{olderThan: opts.olderThan, keepLast: opts.keepLast}
+	for _, expr := range opts.keep {
+		re, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			return errors.Wrapf(err, "invalid --keep expression %q", expr)
+		}
+		policy.keep = append(policy.keep, re)
+	}
+	repo, err := parseRepository(opts.repository)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	client := dockerCli.RegistryClient(opts.insecure)
+	tags, err := client.GetTags(ctx, repo)
+	if err != nil {
+		return err
+	}
+	infos := make([]tagInfo, 0, len(tags))
+	for _, tag := range tags {
+		info, err := resolveTag(ctx, client, repo, tag)
+		if err != nil {
+			return err
+		}
+		infos = append(infos, info)
+	}
+
+	plan := planGC(infos, policy, time.Now())
+	if opts.quiet {
+		seen := map[digest.Digest]bool{}
+		for _, t := range plan {
+			if !seen[t.digest] {
+				seen[t.digest] = true
+				fmt.Fprintf(dockerCli.Out(), "%s@%s\n", reference.FamiliarName(repo), t.digest)
+			}
+		}
+		return nil
+	}
+
+	w := tabwriter.NewWriter(dockerCli.Out(), 20, 1, 3, ' ', 0)
+	fmt.Fprintln(w, "TAG\tDIGEST\tCREATED")
+	for _, t := range plan {
+		created := "unknown"
+		if !t.created.IsZero() {
+			created = units.HumanDuration(time.Now().UTC().Sub(t.created)) + " ago"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", t.tag, t.digest, created)
+	}
+	return w.Flush()
+}
+
+// resolveTag returns the manifest digest of a tag and the creation time of
+// its image. For a manifest list, the first image in the list is used.
+func resolveTag(ctx context.Context, client registryclient.RegistryClient, repo reference.Named, tag string) (tagInfo, error) {
+	ref, err := reference.WithTag(repo, tag)
+	if err != nil {
+		return tagInfo{}, err
+	}
+	mf, err := client.GetDistributionManifest(ctx, ref)
+	if err != nil {
+		return tagInfo{}, err
+	}
+	_, payload, err := mf.Payload()
+	if err != nil {
+		return tagInfo{}, err
+	}
+	info := tagInfo{tag: tag, digest: digest.FromBytes(payload)}
+
+	imageDigest := info.digest
+	if list, ok := mf.(*manifestlist.DeserializedManifestList); ok {
+		if len(list.Manifests) == 0 {
+			return info, nil
+		}
+		imageDigest = list.Manifests[0].Digest
+	}
+	imageRef, err := reference.WithDigest(repo, imageDigest)
+	if err != nil {
+		return tagInfo{}, err
+	}
+	_, configJSON, err := client.GetImageConfig(ctx, imageRef)
+	if err != nil {
+		return tagInfo{}, err
+	}
+	var config struct {
+		Created time.Time `json:"created"`
+	}
+	if err := json.Unmarshal(configJSON, &config); err != nil {
+		return tagInfo{}, errors.Wrapf(err, "invalid image config for %s", reference.FamiliarString(ref))
+	}
+	info.created = config.Created
+	return info, nil
+}
+
+// planGC returns the tags that are not kept by policy, most recent first.
+// Tags of images with an unknown creation time are kept if the policy has an
+// age limit.
+func planGC(tags []tagInfo, policy gcPolicy, now time.Time) []tagInfo {
+	sorted := make([]tagInfo, len(tags))
+	copy(sorted, tags)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].created.After(sorted[j].created)
+	})
+
+	cutoff := now.Add(-policy.olderThan)
+	kept := map[digest.Digest]bool{}
+	var candidates []tagInfo
+	for i, t := range sorted {
+		switch {
+		case i < policy.keepLast,
+			matchAny(policy.keep, t.tag),
+			policy.olderThan > 0 && (t.created.IsZero() || t.created.After(cutoff)):
+			kept[t.digest] = true
+		default:
+			candidates = append(candidates, t)
+		}
+	}
+
+	// Deleting a manifest deletes every tag referring to it.
+	var plan []tagInfo
+	for _, t := range candidates {
+		if !kept[t.digest] {
+			plan = append(plan, t)
+		}
+	}
+	return plan
+}
+
+func matchAny(expressions []*regexp.Regexp, s string) bool {
+	for _, re := range expressions {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"testing"
+	"time"
+
+	manifesttypes "github.com/yuyangjack/dockercli/cli/manifest/types"
+	"github.com/yuyangjack/dockercli/internal/test"
+	"github.com/yuyangjack/distribution"
+	"github.com/yuyangjack/distribution/manifest/schema2"
+	"github.com/yuyangjack/distribution/reference"
+	"github.com/opencontainers/go-digest"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func planTags(plan []tagInfo) []string {
+	tags := []string{}
+	for _, t := range plan {
+		tags = append(tags, t.tag)
+	}
+	return tags
+}
+
+func TestPlanGC(t *testing.T) {
+	now := time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	tags := []tagInfo{
+		{tag: "v1", digest: "sha256:1", created: now.Add(-30 * day)},
+		{tag: "v2", digest: "sha256:2", created: now.Add(-20 * day)},
+		{tag: "v3", digest: "sha256:3", created: now.Add(-10 * day)},
+		{tag: "stable", digest: "sha256:2", created: now.Add(-20 * day)},
+		{tag: "latest", digest: "sha256:4", created: now.Add(-1 * day)},
+		{tag: "unknown", digest: "sha256:5"},
+	}
+
+	testCases := []struct {
+		name     string
+		policy   gcPolicy
+		expected []string
+	}{
+		{
+			name:     "older-than",
+			policy:   gcPolicy{olderThan: 15 * day},
+			expected: []string{"v2", "stable", "v1"},
+		},
+		{
+			name:     "keep-last",
+			policy:   gcPolicy{keepLast: 2},
+			expected: []string{"v2", "stable", "v1", "unknown"},
+		},
+		{
+			name:     "keep-shares-digest",
+			policy:   gcPolicy{keep: []*regexp.Regexp{regexp.MustCompile("^(?:stable|latest)$")}},
+			expected: []string{"v3", "v1", "unknown"},
+		},
+		{
+			name:     "combined",
+			policy:   gcPolicy{olderThan: 5 * day, keepLast: 1, keep: []*regexp.Regexp{regexp.MustCompile("^(?:v3)$")}},
+			expected: []string{"v2", "stable", "v1"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Check(t, is.DeepEqual(tc.expected, planTags(planGC(tags, tc.policy, now))))
+		})
+	}
+}
+
+func TestGCPlanCommandQuiet(t *testing.T) {
+	created := map[string]time.Time{
+		"old":   time.Now().Add(-48 * time.Hour),
+		"older": time.Now().Add(-72 * time.Hour),
+		"new":   time.Now(),
+	}
+	manifests := map[digest.Digest]string{}
+	cli := test.NewFakeCli(nil)
+	cli.SetRegistryClient(&fakeRegistryClient{
+		getTagsFunc: func(_ context.Context, ref reference.Named) ([]string, error) {
+			assert.Check(t, is.Equal("docker.io/library/repo", ref.String()))
+			return []string{"new", "old", "older"}, nil
+		},
+		getDistributionManifestFunc: func(_ context.Context, ref reference.Named) (distribution.Manifest, error) {
+			tag := ref.(reference.Tagged).Tag()
+			mf, err := schema2.FromStruct(schema2.Manifest{
+				Versioned: schema2.SchemaVersion,
+				Config:    distribution.Descriptor{MediaType: schema2.MediaTypeImageConfig, Digest: digest.FromString(tag)},
+			})
+			assert.NilError(t, err)
+			_, payload, _ := mf.Payload()
+			manifests[digest.FromBytes(payload)] = tag
+			return mf, nil
+		},
+		getImageConfigFunc: func(_ context.Context, ref reference.Named) (manifesttypes.ImageManifest, []byte, error) {
+			tag := manifests[ref.(reference.Canonical).Digest()]
+			config := fmt.Sprintf(`{"created":%q}`, created[tag].Format(time.RFC3339Nano))
+			return manifesttypes.ImageManifest{}, []byte(config), nil
+		},
+	})
+	cmd := newGCPlanCommand(cli)
+	cmd.SetArgs([]string{"--quiet", "--older-than", "24h", "--keep", "old.*r", "repo"})
+	assert.NilError(t, cmd.Execute())
+
+	var expected string
+	for dgst, tag := range manifests {
+		if tag == "old" {
+			expected = "repo@" + dgst.String() + "\n"
+		}
+	}
+	assert.Check(t, is.Equal(expected, cli.OutBuffer().String()))
+}
+
+func TestGCPlanCommandErrors(t *testing.T) {
+	testCases := []struct {
+		args          []string
+		expectedError string
+	}{
+		{
+			args:          []string{"repo"},
+			expectedError: "at least one of --older-than, --keep or --keep-last is required",
+		},
+		{
+			args:          []string{"--keep", "(", "repo"},
+			expectedError: "invalid --keep expression",
+		},
+		{
+			args:          []string{"--keep-last", "1", "repo:tag"},
+			expectedError: "repo:tag is not a repository name",
+		},
+	}
+	for _, tc := range testCases {
+		cmd := newGCPlanCommand(test.NewFakeCli(nil))
+		cmd.SetOutput(ioutil.Discard)
+		cmd.SetArgs(tc.args)
+		assert.ErrorContains(t, cmd.Execute(), tc.expectedError)
+	}
+}
@@ -0,0 +1,46 @@
+package registry
+
+import (
+	"context"
+
+	manifesttypes "github.com/yuyangjack/dockercli/cli/manifest/types"
+	"github.com/yuyangjack/dockercli/cli/registry/client"
+	"github.com/yuyangjack/distribution"
+	"github.com/yuyangjack/distribution/reference"
+)
+
+type fakeRegistryClient struct {
+	client.RegistryClient
+	getTagsFunc                 func(ctx context.Context, ref reference.Named) ([]string, error)
+	getDistributionManifestFunc func(ctx context.Context, ref reference.Named) (distribution.Manifest, error)
+	getImageConfigFunc          func(ctx context.Context, ref reference.Named) (manifesttypes.ImageManifest, []byte, error)
+	deleteManifestFunc          func(ctx context.Context, ref reference.Canonical) error
+}
+
+func (c *fakeRegistryClient) GetTags(ctx context.Context, ref reference.Named) ([]string, error) {
+	if c.getTagsFunc != nil {
+		return c.getTagsFunc(ctx, ref)
+	}
+	return nil, nil
+}
+
+func (c *fakeRegistryClient) GetDistributionManifest(ctx context.Context, ref reference.Named) (distribution.Manifest, error) {
+	if c.getDistributionManifestFunc != nil {
+		return c.getDistributionManifestFunc(ctx, ref)
+	}
+	return nil, nil
+}
+
+func (c *fakeRegistryClient) GetImageConfig(ctx context.Context, ref reference.Named) (manifesttypes.ImageManifest, []byte, error) {
+	if c.getImageConfigFunc != nil {
+		return c.getImageConfigFunc(ctx, ref)
+	}
+	return manifesttypes.ImageManifest{}, nil, nil
+}
+
+func (c *fakeRegistryClient) DeleteManifest(ctx context.Context, ref reference.Canonical) error {
+	if c.deleteManifestFunc != nil {
+		return c.deleteManifestFunc(ctx, ref)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/yuyangjack/dockercli/cli"
+	"github.com/yuyangjack/dockercli/cli/command"
+	"github.com/yuyangjack/distribution/reference"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+type removeOptions struct {
+	refs     []string
+	insecure bool
+}
+
+func newRemoveCommand(dockerCli command.Cli) *cobra.Command {
+	var opts removeOptions
+
+	cmd := &cobra.Command{
+		Use:     "rm [OPTIONS] REPOSITORY@DIGEST [REPOSITORY@DIGEST...]",
+		Aliases: []string{"remove"},
+		Short:   "Delete one or more manifests from a registry",
+		Long:    "Delete one or more manifests from a registry. All tags referring to a deleted manifest are removed as well.",
+		Args:    cli.RequiresMinArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.refs = args
+			return runRemove(dockerCli, opts)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.BoolVar(&opts.insecure, "insecure", false, "Allow communication with an insecure registry")
+	return cmd
+}
+
+func runRemove(dockerCli command.Cli, opts removeOptions) error {
+	client := dockerCli.RegistryClient(opts.insecure)
+	ctx := context.Background()
+
+	var errs []string
+	for _, name := range opts.refs {
+		ref, err := reference.ParseNormalizedNamed(name)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		canonical, ok := ref.(reference.Canonical)
+		if !ok {
+			errs = append(errs, fmt.Sprintf("%s: a digest reference is required", name))
+			continue
+		}
+		if err := client.DeleteManifest(ctx, canonical); err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		fmt.Fprintf(dockerCli.Out(), "Deleted: %s\n", reference.FamiliarString(canonical))
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "\n"))
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package registry
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/yuyangjack/dockercli/internal/test"
+	"github.com/yuyangjack/distribution/reference"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestRemoveRequiresDigest(t *testing.T) {
+	const dgst = "sha256:4d5f3bc6d0d0b2c3e2e4f06b6c0b1a7cd7db0b0e34ad7b4dd0ef6d4cfdbd4b0e"
+	var deleted []string
+	cli := test.NewFakeCli(nil)
+	cli.SetRegistryClient(&fakeRegistryClient{
+		deleteManifestFunc: func(_ context.Context, ref reference.Canonical) error {
+			deleted = append(deleted, ref.String())
+			return nil
+		},
+	})
+	cmd := newRemoveCommand(cli)
+	cmd.SetOutput(ioutil.Discard)
+	cmd.SetArgs([]string{"repo:latest", "example.com/repo@" + dgst})
+	assert.ErrorContains(t, cmd.Execute(), "repo:latest: a digest reference is required")
+	assert.Check(t, is.DeepEqual([]string{"example.com/repo@" + dgst}, deleted))
+	assert.Check(t, is.Equal("Deleted: example.com/repo@"+dgst+"\n", cli.OutBuffer().String()))
+}
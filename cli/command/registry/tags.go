@@ -0,0 +1,63 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/yuyangjack/dockercli/cli"
+	"github.com/yuyangjack/dockercli/cli/command"
+	"github.com/yuyangjack/distribution/reference"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+type tagsOptions struct {
+	repository string
+	insecure   bool
+}
+
+func newTagsCommand(dockerCli command.Cli) *cobra.Command {
+	var opts tagsOptions
+
+	cmd := &cobra.Command{
+		Use:   "tags [OPTIONS] REPOSITORY",
+		Short: "List the tags of a repository",
+		Args:  cli.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.repository = args[0]
+			return runTags(dockerCli, opts)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.BoolVar(&opts.insecure, "insecure", false, "Allow communication with an insecure registry")
+	return cmd
+}
+
+func runTags(dockerCli command.Cli, opts tagsOptions) error {
+	repo, err := parseRepository(opts.repository)
+	if err != nil {
+		return err
+	}
+	tags, err := dockerCli.RegistryClient(opts.insecure).GetTags(context.Background(), repo)
+	if err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		fmt.Fprintln(dockerCli.Out(), tag)
+	}
+	return nil
+}
+
+// parseRepository parses a repository name, which must not have a tag or
+// digest.
+func parseRepository(name string) (reference.Named, error) {
+	ref, err := reference.ParseNormalizedNamed(name)
+	if err != nil {
+		return nil, err
+	}
+	if !reference.IsNameOnly(ref) {
+		return nil, errors.Errorf("%s is not a repository name: remove the tag or digest", name)
+	}
+	return ref, nil
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/yuyangjack/dockercli/opts"
 	"github.com/yuyangjack/moby/api/types"
 	"github.com/yuyangjack/moby/api/types/container"
+	mounttypes "github.com/yuyangjack/moby/api/types/mount"
 	"github.com/yuyangjack/moby/api/types/swarm"
 	"github.com/yuyangjack/moby/client"
 	"github.com/docker/swarmkit/api"
@@ -585,6 +586,10 @@ func (options *serviceOptions) ToService(ctx context.Context, apiClient client.N
 		return service, err
 	}
 
+	if err := validateMounts(options.mounts.Value()); err != nil {
+		return service, err
+	}
+
 	serviceMode, err := options.ToServiceMode()
 	if err != nil {
 		return service, err
@@ -909,3 +914,14 @@ const (
 	flagConfigRemove            = "config-rm"
 	flagIsolation               = "isolation"
 )
+
+// validateMounts rejects the mount types that the CLI only supports for
+// containers, as the daemon does not know them
+func validateMounts(mounts []mounttypes.Mount) error {
+	for _, m := range mounts {
+		if m.Type == opts.MountTypeSync {
+			return errors.Errorf("mount type '%s' is only supported by docker run and docker create, not by services", m.Type)
+		}
+	}
+	return nil
+}
@@ -764,6 +764,9 @@ func updateMounts(flags *pflag.FlagSet, mounts *[]mounttypes.Mount) error {
 
 	if flags.Changed(flagMountAdd) {
 		values := flags.Lookup(flagMountAdd).Value.(*opts.MountOpt).Value()
+		if err := validateMounts(values); err != nil {
+			return err
+		}
 		for _, mount := range values {
 			if _, ok := mountsByTarget[mount.Target]; ok {
 				return errors.Errorf("duplicate mount target")
@@ -808,3 +808,13 @@ func TestUpdateNetworks(t *testing.T) {
 	assert.NilError(t, err)
 	assert.Check(t, is.DeepEqual([]swarm.NetworkAttachmentConfig{{Target: "id999"}}, svc.TaskTemplate.Networks))
 }
+
+func TestUpdateMountsRejectsSyncMounts(t *testing.T) {
+	flags := newUpdateCommand(nil).Flags()
+	flags.Set("mount-add", "type=sync,source=.,target=/app")
+
+	mounts := []mounttypes.Mount{}
+	err := updateMounts(flags, &mounts)
+	assert.Check(t, is.Error(err, "mount type 'sync' is only supported by docker run and docker create, not by services"))
+	assert.Check(t, is.Len(mounts, 0))
+}
@@ -0,0 +1,321 @@
+package stack
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/yuyangjack/dockercli/cli/command"
+	"github.com/yuyangjack/dockercli/cli/command/image"
+	"github.com/yuyangjack/dockercli/cli/command/stack/loader"
+	"github.com/yuyangjack/dockercli/cli/command/stack/options"
+	composetypes "github.com/yuyangjack/dockercli/cli/compose/types"
+	"github.com/yuyangjack/distribution/reference"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+func newBuildCommand(dockerCli command.Cli) *cobra.Command {
+	var opts options.Build
+
+	cmd := &cobra.Command{
+		Use:   "build [OPTIONS] [SERVICE...]",
+		Short: "Build the images of the services of a stack",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.Services = args
+			return runBuild(dockerCli, opts)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringSliceVarP(&opts.Composefiles, "compose-file", "c", []string{}, `Path to a Compose file, or "-" to read from stdin`)
+	flags.BoolVar(&opts.Push, "push", false, "Push the images once they are all built")
+	flags.BoolVar(&opts.Pull, "pull", false, "Always attempt to pull a newer version of the base images")
+	flags.BoolVar(&opts.NoCache, "no-cache", false, "Do not use cache when building the images")
+	flags.IntVar(&opts.Parallel, "parallel", 3, "Number of images built and pushed in parallel")
+	command.AddTrustVerificationFlags(flags, &opts.Untrusted, dockerCli.ContentTrustEnabled())
+	return cmd
+}
+
+// serviceBuild is the build of the image of a service
+type serviceBuild struct {
+	service string
+	image   string
+	build   composetypes.BuildConfig
+}
+
+func runBuild(dockerCli command.Cli, opts options.Build) error {
+	if len(opts.Composefiles) == 0 {
+		return errors.Errorf("Please specify a Compose file (with --compose-file).")
+	}
+	if opts.Parallel < 1 {
+		return errors.Errorf("invalid parallel %d: must be at least 1", opts.Parallel)
+	}
+	config, err := loader.LoadBuildComposefile(dockerCli, opts.Composefiles)
+	if err != nil {
+		return err
+	}
+	builds, err := selectBuilds(config, opts.Services)
+	if err != nil {
+		return err
+	}
+	levels, err := buildLevels(builds)
+	if err != nil {
+		return err
+	}
+	for _, level := range levels {
+		if err := buildServices(dockerCli, level, opts); err != nil {
+			return err
+		}
+	}
+
+	if !opts.Push {
+		return nil
+	}
+	var images []string
+	for _, b := range builds {
+		images = append(images, b.image)
+	}
+	return image.PushImages(dockerCli, images, opts.Parallel, opts.Untrusted)
+}
+
+// selectBuilds returns the builds of the services named services, or of all
+// the services with a build section if services is empty, sorted by name
+func selectBuilds(config *composetypes.Config, services []string) ([]serviceBuild, error) {
+	byName := make(map[string]composetypes.ServiceConfig)
+	for _, service := range config.Services {
+		byName[service.Name] = service
+	}
+	if len(services) == 0 {
+		for _, service := range config.Services {
+			if service.Build.Context != "" {
+				services = append(services, service.Name)
+			}
+		}
+		if len(services) == 0 {
+			return nil, errors.New("no service to build: the Compose file has no service with a build section")
+		}
+	}
+	sort.Strings(services)
+
+	var builds []serviceBuild
+	built := make(map[string]string)
+	for _, name := range services {
+		service, ok := byName[name]
+		switch {
+		case !ok:
+			return nil, errors.Errorf("no such service: %s", name)
+		case service.Build.Context == "":
+			return nil, errors.Errorf("service %s has no build section", name)
+		case service.Image == "":
+			return nil, errors.Errorf("service %s has no image to tag its build with", name)
+		}
+		named, err := reference.ParseNormalizedNamed(service.Image)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid image for service %s", name)
+		}
+		if _, isCanonical := named.(reference.Canonical); isCanonical {
+			return nil, errors.Errorf("invalid image for service %s: cannot tag a build with a digest", name)
+		}
+		img := reference.FamiliarString(reference.TagNameOnly(named))
+		if other, ok := built[img]; ok {
+			return nil, errors.Errorf("services %s and %s are both built as %s", other, name, img)
+		}
+		built[img] = name
+		builds = append(builds, serviceBuild{service: name, image: img, build: service.Build})
+	}
+	return builds, nil
+}
+
+// buildLevels groups builds in levels, where the builds of each level only
+// depend on the images built by the levels before it, and can run in
+// parallel. A build depends on another one if its Dockerfile is based on
+// the image of the other build.
+func buildLevels(builds []serviceBuild) ([][]serviceBuild, error) {
+	byImage := make(map[string]int)
+	for i, b := range builds {
+		byImage[b.image] = i
+	}
+	deps := make([][]int, len(builds))
+	for i, b := range builds {
+		for _, base := range baseImages(b.build) {
+			if j, ok := byImage[base]; ok && j != i {
+				deps[i] = append(deps[i], j)
+			}
+		}
+	}
+
+	var levels [][]serviceBuild
+	done := make(map[int]bool)
+	for len(done) < len(builds) {
+		var ready []int
+		for i := range builds {
+			if !done[i] && dependenciesDone(deps[i], done) {
+				ready = append(ready, i)
+			}
+		}
+		if len(ready) == 0 {
+			var services []string
+			for i, b := range builds {
+				if !done[i] {
+					services = append(services, b.service)
+				}
+			}
+			return nil, errors.Errorf("cannot build services %s: their images are based on each other", strings.Join(services, ", "))
+		}
+		var level []serviceBuild
+		for _, i := range ready {
+			done[i] = true
+			level = append(level, builds[i])
+		}
+		levels = append(levels, level)
+	}
+	return levels, nil
+}
+
+func dependenciesDone(deps []int, done map[int]bool) bool {
+	for _, dep := range deps {
+		if !done[dep] {
+			return false
+		}
+	}
+	return true
+}
+
+// baseImages returns the images that the Dockerfile of a local build is
+// based on. The Dockerfiles of remote builds, and the Dockerfiles that
+// cannot be read, are considered not to be based on any image.
+func baseImages(build composetypes.BuildConfig) []string {
+	if !filepath.IsAbs(build.Context) {
+		return nil
+	}
+	dockerfile := build.Dockerfile
+	if dockerfile == "" {
+		dockerfile = filepath.Join(build.Context, "Dockerfile")
+	}
+	f, err := os.Open(dockerfile)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	refs, err := image.DockerfileBaseImages(f)
+	if err != nil {
+		return nil
+	}
+	var images []string
+	for _, ref := range refs {
+		images = append(images, reference.FamiliarString(reference.TagNameOnly(ref)))
+	}
+	return images
+}
+
+// buildServices builds images that do not depend on each other. When
+// several images are built in parallel, their build output is only shown if
+// they fail, as it would be mixed otherwise.
+func buildServices(dockerCli command.Cli, builds []serviceBuild, opts options.Build) error {
+	if opts.Parallel == 1 || len(builds) == 1 {
+		for _, b := range builds {
+			fmt.Fprintf(dockerCli.Out(), "Building %s\n", b.service)
+			if err := image.BuildImage(dockerCli, buildImageOptions(b, opts, false)); err != nil {
+				return errors.Wrapf(err, "failed to build service %s", b.service)
+			}
+		}
+		return nil
+	}
+
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		errs = make([]error, len(builds))
+		sem  = make(chan struct{}, opts.Parallel)
+	)
+	for i, b := range builds {
+		wg.Add(1)
+		go func(i int, b serviceBuild) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			mu.Lock()
+			fmt.Fprintf(dockerCli.Out(), "Building %s\n", b.service)
+			mu.Unlock()
+			buildCli := &bufferedCli{Cli: dockerCli, out: command.NewOutStream(&bytes.Buffer{})}
+			errs[i] = image.BuildImage(buildCli, buildImageOptions(b, opts, true))
+
+			mu.Lock()
+			defer mu.Unlock()
+			if errs[i] != nil {
+				dockerCli.Err().Write(buildCli.err.Bytes())
+				return
+			}
+			fmt.Fprintf(dockerCli.Out(), "Built %s as %s\n", b.service, b.image)
+		}(i, b)
+	}
+	wg.Wait()
+
+	var failed []string
+	for i, err := range errs {
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", builds[i].service, err))
+		}
+	}
+	if len(failed) > 0 {
+		return errors.Errorf("failed to build %d services:\n%s", len(failed), strings.Join(failed, "\n"))
+	}
+	return nil
+}
+
+// buildImageOptions returns the options of the build of the image of a
+// service
+func buildImageOptions(b serviceBuild, opts options.Build, quiet bool) image.BuildImageOptions {
+	var buildArgs, labels []string
+	for key, value := range b.build.Args {
+		if value == nil {
+			buildArgs = append(buildArgs, key)
+		} else {
+			buildArgs = append(buildArgs, key+"="+*value)
+		}
+	}
+	for key, value := range b.build.Labels {
+		labels = append(labels, key+"="+value)
+	}
+	sort.Strings(buildArgs)
+	sort.Strings(labels)
+	return image.BuildImageOptions{
+		Context:    b.build.Context,
+		Dockerfile: b.build.Dockerfile,
+		Tags:       []string{b.image},
+		BuildArgs:  buildArgs,
+		Labels:     labels,
+		CacheFrom:  b.build.CacheFrom,
+		Network:    b.build.Network,
+		Target:     b.build.Target,
+		Pull:       opts.Pull,
+		NoCache:    opts.NoCache,
+		Quiet:      quiet,
+		Untrusted:  opts.Untrusted,
+	}
+}
+
+// bufferedCli is the command.Cli of a build run in parallel with other
+// builds, which keeps the output of the build instead of writing it
+type bufferedCli struct {
+	command.Cli
+	out *command.OutStream
+	err bytes.Buffer
+}
+
+// Out returns the writer used for the output of the build
+func (c *bufferedCli) Out() *command.OutStream {
+	return c.out
+}
+
+// Err returns the writer used for the errors of the build
+func (c *bufferedCli) Err() io.Writer {
+	return &c.err
+}
@@ -0,0 +1,237 @@
+package stack
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	composetypes "github.com/yuyangjack/dockercli/cli/compose/types"
+	"github.com/yuyangjack/dockercli/internal/test"
+	"github.com/yuyangjack/moby/api/types"
+	"github.com/yuyangjack/moby/pkg/jsonmessage"
+	controlapi "github.com/moby/buildkit/api/services/control"
+	"github.com/opencontainers/go-digest"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	"gotest.tools/env"
+	"gotest.tools/fs"
+)
+
+const testBuildComposefile = `version: "3.4"
+services:
+  app:
+    image: example/app:1.0
+    build:
+      context: ./app
+      args:
+        VERSION: "1.0"
+      labels:
+        team: web
+      target: prod
+  worker:
+    image: example/worker
+    build: ./worker
+  db:
+    image: postgres
+  docs:
+    build: ./docs
+`
+
+func TestBuildErrors(t *testing.T) {
+	dir := fs.NewDir(t, "stack-build",
+		fs.WithFile("stack.yml", testBuildComposefile),
+	)
+	defer dir.Remove()
+
+	testCases := []struct {
+		name          string
+		args          []string
+		expectedError string
+	}{
+		{
+			name:          "no-compose-file",
+			args:          []string{},
+			expectedError: "Please specify a Compose file",
+		},
+		{
+			name:          "invalid-parallel",
+			args:          []string{"-c", dir.Join("stack.yml"), "--parallel", "0"},
+			expectedError: "invalid parallel 0: must be at least 1",
+		},
+		{
+			name:          "no-image",
+			args:          []string{"-c", dir.Join("stack.yml")},
+			expectedError: "service docs has no image to tag its build with",
+		},
+		{
+			name:          "unknown-service",
+			args:          []string{"-c", dir.Join("stack.yml"), "api"},
+			expectedError: "no such service: api",
+		},
+		{
+			name:          "no-build-section",
+			args:          []string{"-c", dir.Join("stack.yml"), "db"},
+			expectedError: "service db has no build section",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cmd := newBuildCommand(test.NewFakeCli(&fakeClient{}))
+			cmd.SetArgs(tc.args)
+			cmd.SetOutput(ioutil.Discard)
+			assert.ErrorContains(t, cmd.Execute(), tc.expectedError)
+		})
+	}
+}
+
+func TestBuild(t *testing.T) {
+	dir := fs.NewDir(t, "stack-build",
+		fs.WithFile("stack.yml", testBuildComposefile),
+		fs.WithDir("app", fs.WithFile("Dockerfile", "FROM alpine AS prod\n")),
+		fs.WithDir("worker", fs.WithFile("Dockerfile", "FROM alpine\n")),
+	)
+	defer dir.Remove()
+
+	var (
+		mu     sync.Mutex
+		builds = make(map[string]types.ImageBuildOptions)
+	)
+	cli := test.NewFakeCli(&fakeClient{
+		imageBuildFunc: func(context io.Reader, options types.ImageBuildOptions) (types.ImageBuildResponse, error) {
+			_, err := ioutil.ReadAll(context)
+			assert.Check(t, err)
+			mu.Lock()
+			defer mu.Unlock()
+			builds[options.Tags[0]] = options
+			return types.ImageBuildResponse{Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+		},
+	})
+	cmd := newBuildCommand(cli)
+	cmd.SetArgs([]string{"-c", dir.Join("stack.yml"), "app", "worker"})
+	assert.NilError(t, cmd.Execute())
+
+	var tags []string
+	for tag := range builds {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	assert.Check(t, is.DeepEqual([]string{"example/app:1.0", "example/worker:latest"}, tags))
+	app := builds["example/app:1.0"]
+	assert.Check(t, is.Equal("1.0", *app.BuildArgs["VERSION"]))
+	assert.Check(t, is.DeepEqual(map[string]string{"team": "web"}, app.Labels))
+	assert.Check(t, is.Equal("prod", app.Target))
+	assert.Check(t, is.Contains(cli.OutBuffer().String(), "Built app as example/app:1.0\n"))
+	assert.Check(t, is.Contains(cli.OutBuffer().String(), "Built worker as example/worker:latest\n"))
+}
+
+func TestBuildParallelFailuresBuildKit(t *testing.T) {
+	defer env.Patch(t, "DOCKER_BUILDKIT", "1")()
+	dir := fs.NewDir(t, "stack-build",
+		fs.WithFile("stack.yml", testBuildComposefile),
+		fs.WithDir("app", fs.WithFile("Dockerfile", "FROM alpine AS prod\nRUN make app\n")),
+		fs.WithDir("worker", fs.WithFile("Dockerfile", "FROM alpine\nRUN make worker\n")),
+	)
+	defer dir.Remove()
+
+	// Both builds report their progress before failing, once they are both
+	// running
+	var started sync.WaitGroup
+	started.Add(2)
+	cli := test.NewFakeCli(&fakeClient{
+		version: "1.39",
+		imageBuildFunc: func(_ io.Reader, options types.ImageBuildOptions) (types.ImageBuildResponse, error) {
+			started.Done()
+			started.Wait()
+			name := map[string]string{"example/app:1.0": "app", "example/worker:latest": "worker"}[options.Tags[0]]
+			return types.ImageBuildResponse{Body: ioutil.NopCloser(strings.NewReader(failedBuildKitBuild(t, name)))}, nil
+		},
+	})
+	cmd := newBuildCommand(cli)
+	cmd.SetArgs([]string{"-c", dir.Join("stack.yml"), "app", "worker"})
+	assert.Check(t, is.ErrorContains(cmd.Execute(), "failed to build 2 services"))
+
+	out := cli.ErrBuffer().String()
+	for name, other := range map[string]string{"app": "worker", "worker": "app"} {
+		first := strings.Index(out, "RUN make "+name)
+		last := strings.LastIndex(out, "make "+name+" failed")
+		assert.Assert(t, first >= 0 && last > first, out)
+		assert.Check(t, !strings.Contains(out[first:last], other), out)
+	}
+}
+
+// failedBuildKitBuild returns the response of a BuildKit build of name that
+// fails in a RUN step
+func failedBuildKitBuild(t *testing.T, name string) string {
+	now := time.Now()
+	status := controlapi.StatusResponse{
+		Vertexes: []*controlapi.Vertex{{
+			Digest:    digest.FromString(name),
+			Name:      "RUN make " + name,
+			Started:   &now,
+			Completed: &now,
+			Error:     "make " + name + " failed",
+		}},
+	}
+	dt, err := status.Marshal()
+	assert.NilError(t, err)
+	aux, err := json.Marshal(dt)
+	assert.NilError(t, err)
+	rawAux := json.RawMessage(aux)
+
+	var out strings.Builder
+	enc := json.NewEncoder(&out)
+	assert.NilError(t, enc.Encode(jsonmessage.JSONMessage{ID: "moby.buildkit.trace", Aux: &rawAux}))
+	assert.NilError(t, enc.Encode(jsonmessage.JSONMessage{
+		Error:        &jsonmessage.JSONError{Message: "failed to build " + name},
+		ErrorMessage: "failed to build " + name,
+	}))
+	return out.String()
+}
+
+func TestBuildLevels(t *testing.T) {
+	dir := fs.NewDir(t, "stack-build-levels",
+		fs.WithFile("Dockerfile.base", "FROM alpine:3.8\n"),
+		fs.WithFile("Dockerfile.app", "FROM example/base AS build\nFROM scratch\n"),
+		fs.WithFile("Dockerfile.worker", "ARG BASE=example/base\nFROM $BASE\nFROM example/base:latest\n"),
+		fs.WithFile("Dockerfile.tools", "FROM example/app\n"),
+	)
+	defer dir.Remove()
+	newBuild := func(service string) serviceBuild {
+		return serviceBuild{
+			service: service,
+			image:   "example/" + service + ":latest",
+			build:   composetypes.BuildConfig{Context: dir.Path(), Dockerfile: dir.Join("Dockerfile." + service)},
+		}
+	}
+
+	levels, err := buildLevels([]serviceBuild{newBuild("app"), newBuild("base"), newBuild("tools"), newBuild("worker")})
+	assert.NilError(t, err)
+	var services [][]string
+	for _, level := range levels {
+		var names []string
+		for _, b := range level {
+			names = append(names, b.service)
+		}
+		services = append(services, names)
+	}
+	assert.Check(t, is.DeepEqual([][]string{{"base"}, {"app", "worker"}, {"tools"}}, services))
+}
+
+func TestBuildLevelsCycle(t *testing.T) {
+	dir := fs.NewDir(t, "stack-build-levels",
+		fs.WithFile("Dockerfile.a", "FROM example/b\n"),
+		fs.WithFile("Dockerfile.b", "FROM example/a\n"),
+	)
+	defer dir.Remove()
+	builds := []serviceBuild{
+		{service: "a", image: "example/a:latest", build: composetypes.BuildConfig{Context: dir.Path(), Dockerfile: dir.Join("Dockerfile.a")}},
+		{service: "b", image: "example/b:latest", build: composetypes.BuildConfig{Context: dir.Path(), Dockerfile: dir.Join("Dockerfile.b")}},
+	}
+	_, err := buildLevels(builds)
+	assert.Check(t, is.Error(err, "cannot build services a, b: their images are based on each other"))
+}
@@ -2,6 +2,9 @@ package stack
 
 import (
 	"context"
+	"io"
+	"io/ioutil"
+	"net"
 	"strings"
 
 	"github.com/yuyangjack/dockercli/cli/compose/convert"
@@ -41,6 +44,8 @@ type fakeClient struct {
 	networkRemoveFunc func(networkID string) error
 	secretRemoveFunc  func(secretID string) error
 	configRemoveFunc  func(configID string) error
+
+	imageBuildFunc func(context io.Reader, options types.ImageBuildOptions) (types.ImageBuildResponse, error)
 }
 
 func (cli *fakeClient) ServerVersion(ctx context.Context) (types.Version, error) {
@@ -179,6 +184,20 @@ func (cli *fakeClient) ConfigRemove(ctx context.Context, configID string) error
 	return nil
 }
 
+func (cli *fakeClient) ImageBuild(ctx context.Context, context io.Reader, options types.ImageBuildOptions) (types.ImageBuildResponse, error) {
+	if cli.imageBuildFunc != nil {
+		return cli.imageBuildFunc(context, options)
+	}
+	return types.ImageBuildResponse{Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+}
+
+// DialSession returns a connection on which the session of a BuildKit build
+// is served without any builder on the other side
+func (cli *fakeClient) DialSession(ctx context.Context, proto string, meta map[string][]string) (net.Conn, error) {
+	conn, _ := net.Pipe()
+	return conn, nil
+}
+
 func serviceFromName(name string) swarm.Service {
 	return swarm.Service{
 		ID: "ID-" + name,
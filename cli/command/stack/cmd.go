@@ -56,6 +56,7 @@ func NewStackCommand(dockerCli command.Cli) *cobra.Command {
 		defaultHelpFunc(c, args)
 	})
 	cmd.AddCommand(
+		newBuildCommand(dockerCli),
 		newDeployCommand(dockerCli, &opts),
 		newListCommand(dockerCli, &opts),
 		newPsCommand(dockerCli, &opts),
@@ -14,6 +14,7 @@ import (
 	"github.com/yuyangjack/dockercli/cli/compose/loader"
 	"github.com/yuyangjack/dockercli/cli/compose/schema"
 	composetypes "github.com/yuyangjack/dockercli/cli/compose/types"
+	"github.com/yuyangjack/moby/pkg/urlutil"
 	"github.com/pkg/errors"
 )
 
@@ -25,13 +26,8 @@ func LoadComposefile(dockerCli command.Cli, opts options.Deploy) (*composetypes.
 	}
 
 	dicts := getDictsFrom(configDetails.ConfigFiles)
-	config, err := loader.Load(configDetails)
+	config, err := load(configDetails)
 	if err != nil {
-		if fpe, ok := err.(*loader.ForbiddenPropertiesError); ok {
-			return nil, errors.Errorf("Compose file contains unsupported options:\n\n%s\n",
-				propertyWarnings(fpe.Properties))
-		}
-
 		return nil, err
 	}
 
@@ -49,6 +45,52 @@ func LoadComposefile(dockerCli command.Cli, opts options.Deploy) (*composetypes.
 	return config, nil
 }
 
+// LoadBuildComposefile parses the composefiles specified in the cli to build
+// the images of their services. The local build contexts and Dockerfiles
+// are resolved relative to the directory of the first composefile.
+func LoadBuildComposefile(dockerCli command.Cli, composefiles []string) (*composetypes.Config, error) {
+	configDetails, err := getConfigDetails(composefiles, dockerCli.In())
+	if err != nil {
+		return nil, err
+	}
+	config, err := load(configDetails)
+	if err != nil {
+		return nil, err
+	}
+	for i, service := range config.Services {
+		config.Services[i].Build = resolveBuildPaths(service.Build, configDetails.WorkingDir)
+	}
+	return config, nil
+}
+
+func load(configDetails composetypes.ConfigDetails) (*composetypes.Config, error) {
+	config, err := loader.Load(configDetails)
+	if err != nil {
+		if fpe, ok := err.(*loader.ForbiddenPropertiesError); ok {
+			return nil, errors.Errorf("Compose file contains unsupported options:\n\n%s\n",
+				propertyWarnings(fpe.Properties))
+		}
+
+		return nil, err
+	}
+	return config, nil
+}
+
+// resolveBuildPaths makes the local build context of build absolute, and its
+// Dockerfile too, which is relative to the context in a Compose file
+func resolveBuildPaths(build composetypes.BuildConfig, workingDir string) composetypes.BuildConfig {
+	if build.Context == "" || urlutil.IsGitURL(build.Context) || urlutil.IsURL(build.Context) {
+		return build
+	}
+	if !filepath.IsAbs(build.Context) {
+		build.Context = filepath.Join(workingDir, build.Context)
+	}
+	if build.Dockerfile != "" && !filepath.IsAbs(build.Dockerfile) {
+		build.Dockerfile = filepath.Join(build.Context, build.Dockerfile)
+	}
+	return build
+}
+
 func getDictsFrom(configFiles []composetypes.ConfigFile) []map[string]interface{} {
 	dicts := []map[string]interface{}{}
 
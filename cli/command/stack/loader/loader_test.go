@@ -6,6 +6,7 @@ import (
 	"strings"
 	"testing"
 
+	composetypes "github.com/yuyangjack/dockercli/cli/compose/types"
 	"gotest.tools/assert"
 	is "gotest.tools/assert/cmp"
 	"gotest.tools/fs"
@@ -45,3 +46,34 @@ services:
 	assert.Check(t, is.Equal("3.0", details.ConfigFiles[0].Config["version"]))
 	assert.Check(t, is.Len(details.Environment, len(os.Environ())))
 }
+
+func TestResolveBuildPaths(t *testing.T) {
+	testCases := []struct {
+		build    composetypes.BuildConfig
+		expected composetypes.BuildConfig
+	}{
+		{
+			build:    composetypes.BuildConfig{Context: "./app"},
+			expected: composetypes.BuildConfig{Context: "/stack/app"},
+		},
+		{
+			build:    composetypes.BuildConfig{Context: ".", Dockerfile: "Dockerfile.prod"},
+			expected: composetypes.BuildConfig{Context: "/stack", Dockerfile: "/stack/Dockerfile.prod"},
+		},
+		{
+			build:    composetypes.BuildConfig{Context: "/src/app", Dockerfile: "docker/Dockerfile"},
+			expected: composetypes.BuildConfig{Context: "/src/app", Dockerfile: "/src/app/docker/Dockerfile"},
+		},
+		{
+			build:    composetypes.BuildConfig{Context: "https://github.com/docker/app.git#master", Dockerfile: "Dockerfile.prod"},
+			expected: composetypes.BuildConfig{Context: "https://github.com/docker/app.git#master", Dockerfile: "Dockerfile.prod"},
+		},
+		{
+			build:    composetypes.BuildConfig{},
+			expected: composetypes.BuildConfig{},
+		},
+	}
+	for _, tc := range testCases {
+		assert.Check(t, is.DeepEqual(tc.expected, resolveBuildPaths(tc.build, "/stack")))
+	}
+}
@@ -2,6 +2,17 @@ package options
 
 import "github.com/yuyangjack/dockercli/opts"
 
+// Build holds docker stack build options
+type Build struct {
+	Composefiles []string
+	Services     []string
+	Push         bool
+	Pull         bool
+	NoCache      bool
+	Parallel     int
+	Untrusted    bool
+}
+
 // Deploy holds docker stack deploy options
 type Deploy struct {
 	Bundlefile       string
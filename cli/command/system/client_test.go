@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/yuyangjack/moby/api/types"
+	eventtypes "github.com/yuyangjack/moby/api/types/events"
 	"github.com/yuyangjack/moby/client"
 )
 
@@ -12,6 +13,11 @@ type fakeClient struct {
 
 	version       string
 	serverVersion func(ctx context.Context) (types.Version, error)
+	eventsFunc    func(ctx context.Context, options types.EventsOptions) (<-chan eventtypes.Message, <-chan error)
+}
+
+func (cli *fakeClient) Events(ctx context.Context, options types.EventsOptions) (<-chan eventtypes.Message, <-chan error) {
+	return cli.eventsFunc(ctx, options)
 }
 
 func (cli *fakeClient) ServerVersion(ctx context.Context) (types.Version, error) {
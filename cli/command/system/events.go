@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"sort"
 	"strings"
 	"text/template"
@@ -16,19 +17,37 @@ import (
 	"github.com/yuyangjack/dockercli/templates"
 	"github.com/yuyangjack/moby/api/types"
 	eventtypes "github.com/yuyangjack/moby/api/types/events"
+	"github.com/yuyangjack/moby/client"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 )
 
+const (
+	// minReconnectDelay and maxReconnectDelay bound the backoff between
+	// attempts to re-establish a broken events stream.
+	minReconnectDelay = 500 * time.Millisecond
+	maxReconnectDelay = 30 * time.Second
+)
+
 type eventsOptions struct {
 	since  string
 	until  string
 	filter opts.FilterOpt
 	format string
+	exec   string
+
+	output         string
+	outputMaxSize  opts.MemBytes
+	outputMaxFiles int
 }
 
 // NewEventsCommand creates a new cobra.Command for `docker events`
 func NewEventsCommand(dockerCli command.Cli) *cobra.Command {
-	options := eventsOptions{filter: opts.NewFilterOpt()}
+	options := eventsOptions{
+		filter:         opts.NewFilterOpt(),
+		outputMaxSize:  defaultEventsMaxSize,
+		outputMaxFiles: defaultEventsMaxFiles,
+	}
 
 	cmd := &cobra.Command{
 		Use:   "events [OPTIONS]",
@@ -44,7 +63,12 @@ func NewEventsCommand(dockerCli command.Cli) *cobra.Command {
 	flags.StringVar(&options.until, "until", "", "Stream events until this timestamp")
 	flags.VarP(&options.filter, "filter", "f", "Filter output based on conditions provided")
 	flags.StringVar(&options.format, "format", "", "Format the output using the given Go template")
+	flags.StringVar(&options.exec, "exec", "", "Run a command for every event matching the filters")
+	flags.StringVarP(&options.output, "output", "o", "", "Append events as JSON lines to a file")
+	flags.Var(&options.outputMaxSize, "output-max-size", "Rotate the output file when it exceeds this size (0 to disable)")
+	flags.IntVar(&options.outputMaxFiles, "output-max-files", defaultEventsMaxFiles, "Number of output files to keep when rotating")
 
+	cmd.AddCommand(newEventsReplayCommand(dockerCli))
 	return cmd
 }
 
@@ -55,31 +79,131 @@ func runEvents(dockerCli command.Cli, options *eventsOptions) error {
 			StatusCode: 64,
 			Status:     "Error parsing format: " + err.Error()}
 	}
-	eventOptions := types.EventsOptions{
-		Since:   options.since,
-		Until:   options.until,
-		Filters: options.filter.Value(),
+	hooks, err := newEventHooks(dockerCli.ConfigFile().Hooks, options.exec, options.filter.Value())
+	if err != nil {
+		return err
+	}
+	serverFilters, err := splitEventFilters(options.filter.Value())
+	if err != nil {
+		return err
+	}
+	var output *rotatingFile
+	if options.output != "" {
+		if options.outputMaxFiles < 1 {
+			return errors.New("--output-max-files must be at least 1")
+		}
+		output, err = openRotatingFile(options.output, options.outputMaxSize.Value(), options.outputMaxFiles)
+		if err != nil {
+			return errors.Wrap(err, "failed to open output file")
+		}
+		defer output.Close()
+	}
+	// If the stream breaks it is resumed from the last event received, or
+	// from the time it was first opened. A relative --since is resolved once,
+	// so that reconnecting does not move it forward.
+	now := time.Now()
+	sinceNano, err := parseEventTime(options.since, now, now.UnixNano())
+	if err != nil {
+		return errors.Wrap(err, "invalid --since")
 	}
+	since, resumeFrom := options.since, formatEventTime(sinceNano)
+
+	runner := newEventHookRunner(hooks, eventHookTimeout, eventHookQueueSize, dockerCli.Out(), dockerCli.Err())
+	defer runner.close()
 
 	ctx, cancel := context.WithCancel(context.Background())
-	events, errs := dockerCli.Client().Events(ctx, eventOptions)
 	defer cancel()
 
 	out := dockerCli.Out()
-
+	var (
+		last     eventtypes.Message
+		received bool
+		delay    = minReconnectDelay
+	)
 	for {
-		select {
-		case event := <-events:
-			if err := handleEvent(out, event, tmpl); err != nil {
-				return err
-			}
-		case err := <-errs:
-			if err == io.EOF {
-				return nil
+		events, errs := dockerCli.Client().Events(ctx, types.EventsOptions{
+			Since:   since,
+			Until:   options.until,
+			Filters: serverFilters,
+		})
+
+		err := func() error {
+			for {
+				select {
+				case event := <-events:
+					if received && isDuplicateEvent(last, event) {
+						continue
+					}
+					last, received = event, true
+					delay = minReconnectDelay
+					if !matchEventAttributes(options.filter.Value(), event.Actor.Attributes) {
+						continue
+					}
+					if err := handleEvent(out, event, tmpl); err != nil {
+						return err
+					}
+					if output != nil {
+						if err := writeEventLine(output, event); err != nil {
+							return errors.Wrap(err, "failed to write event to output file")
+						}
+					}
+					runner.enqueue(event)
+				case err := <-errs:
+					return err
+				}
 			}
+		}()
+		if err == io.EOF {
+			return nil
+		}
+		if !isTransientEventsError(err) {
 			return err
 		}
+		if received {
+			resumeFrom = formatEventTime(eventTimeNano(last))
+		}
+		since = resumeFrom
+		fmt.Fprintf(dockerCli.Err(), "Lost connection to the events stream (%s), reconnecting in %s\n", err, delay)
+		time.Sleep(delay)
+		if delay *= 2; delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+	}
+}
+
+// isTransientEventsError returns true for errors caused by a broken
+// connection, after which the events stream is re-established.
+func isTransientEventsError(err error) bool {
+	if client.IsErrConnectionFailed(err) || err == io.ErrUnexpectedEOF {
+		return true
+	}
+	_, ok := errors.Cause(err).(net.Error)
+	return ok
+}
+
+func eventTimeNano(event eventtypes.Message) int64 {
+	if event.TimeNano != 0 {
+		return event.TimeNano
+	}
+	return event.Time * int64(time.Second)
+}
+
+// formatEventTime formats a time in nanoseconds in the "seconds.nanoseconds"
+// form accepted by the daemon's --since and --until.
+func formatEventTime(nano int64) string {
+	return fmt.Sprintf("%d.%09d", nano/int64(time.Second), nano%int64(time.Second))
+}
+
+// isDuplicateEvent returns true if event was already seen before the
+// stream was re-established with --since set to the last event's time.
+func isDuplicateEvent(last, event eventtypes.Message) bool {
+	switch t := eventTimeNano(event); {
+	case t < eventTimeNano(last):
+		return true
+	case t == eventTimeNano(last):
+		return event.Type == last.Type && event.Action == last.Action && event.Actor.ID == last.Actor.ID
 	}
+	return false
 }
 
 func handleEvent(out io.Writer, event eventtypes.Message, tmpl *template.Template) error {
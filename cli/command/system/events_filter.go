@@ -0,0 +1,114 @@
+package system
+
+import (
+	"strings"
+
+	"github.com/yuyangjack/moby/api/types/filters"
+	eventtypes "github.com/yuyangjack/moby/api/types/events"
+)
+
+// attrFilterKey is a client-side only filter on actor attributes, in the
+// form "attr=name=value" or "attr=name!=value".
+const attrFilterKey = "attr"
+
+// splitEventFilters returns a copy of f without the client-side only keys,
+// suitable for sending to the daemon.
+func splitEventFilters(f filters.Args) (filters.Args, error) {
+	repr, err := filters.ToJSON(f)
+	if err != nil {
+		return f, err
+	}
+	server, err := filters.FromJSON(repr)
+	if err != nil {
+		return f, err
+	}
+	for _, v := range server.Get(attrFilterKey) {
+		server.Del(attrFilterKey, v)
+	}
+	return server, nil
+}
+
+// matchEvent reports whether event matches f, following the daemon's events
+// filter semantics and the client-side "attr" key.
+func matchEvent(f filters.Args, event eventtypes.Message) bool {
+	return matchEventAction(f, event.Action) &&
+		f.ExactMatch("type", string(event.Type)) &&
+		matchEventScope(f, event.Scope) &&
+		matchEventName(f, event, eventtypes.DaemonEventType) &&
+		matchEventName(f, event, eventtypes.ContainerEventType) &&
+		matchEventName(f, event, eventtypes.PluginEventType) &&
+		matchEventName(f, event, eventtypes.VolumeEventType) &&
+		matchEventName(f, event, eventtypes.NetworkEventType) &&
+		matchEventImage(f, event) &&
+		matchEventName(f, event, eventtypes.NodeEventType) &&
+		matchEventName(f, event, eventtypes.ServiceEventType) &&
+		matchEventName(f, event, eventtypes.SecretEventType) &&
+		matchEventName(f, event, eventtypes.ConfigEventType) &&
+		matchEventLabels(f, event.Actor.Attributes) &&
+		matchEventAttributes(f, event.Actor.Attributes)
+}
+
+// matchEventAction matches actions like "health_status: healthy" against
+// their prefix, as the daemon does.
+func matchEventAction(f filters.Args, action string) bool {
+	for _, v := range f.Get("event") {
+		switch v {
+		case "health_status", "exec_create", "exec_start":
+			return f.FuzzyMatch("event", action)
+		}
+	}
+	return f.ExactMatch("event", action)
+}
+
+func matchEventScope(f filters.Args, scope string) bool {
+	if !f.Contains("scope") {
+		return true
+	}
+	return f.ExactMatch("scope", scope)
+}
+
+func matchEventName(f filters.Args, event eventtypes.Message, key string) bool {
+	return f.FuzzyMatch(key, event.Actor.ID) || f.FuzzyMatch(key, event.Actor.Attributes["name"])
+}
+
+func matchEventImage(f filters.Args, event eventtypes.Message) bool {
+	nameAttr := "image"
+	if event.Type == eventtypes.ImageEventType {
+		nameAttr = "name"
+	}
+	name := event.Actor.Attributes[nameAttr]
+	return f.ExactMatch("image", event.Actor.ID) ||
+		f.ExactMatch("image", name) ||
+		f.ExactMatch("image", stripTag(name))
+}
+
+func stripTag(image string) string {
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		return image[:i]
+	}
+	return image
+}
+
+func matchEventLabels(f filters.Args, attributes map[string]string) bool {
+	if !f.Contains("label") {
+		return true
+	}
+	return f.MatchKVList("label", attributes)
+}
+
+// matchEventAttributes requires every "attr" filter to hold.
+func matchEventAttributes(f filters.Args, attributes map[string]string) bool {
+	for _, v := range f.Get(attrFilterKey) {
+		if i := strings.Index(v, "!="); i >= 0 {
+			if attributes[v[:i]] == v[i+2:] {
+				return false
+			}
+			continue
+		}
+		kv := strings.SplitN(v, "=", 2)
+		if len(kv) != 2 || attributes[kv[0]] != kv[1] {
+			return false
+		}
+	}
+	return true
+}
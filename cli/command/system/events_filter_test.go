@@ -0,0 +1,69 @@
+package system
+
+import (
+	"testing"
+
+	eventtypes "github.com/yuyangjack/moby/api/types/events"
+	"github.com/yuyangjack/moby/api/types/filters"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestMatchEvent(t *testing.T) {
+	die := eventtypes.Message{
+		Type:   eventtypes.ContainerEventType,
+		Action: "die",
+		Actor: eventtypes.Actor{
+			ID:         "abcdef",
+			Attributes: map[string]string{"name": "web", "image": "nginx:1.15", "exitCode": "137"},
+		},
+	}
+	health := eventtypes.Message{
+		Type:   eventtypes.ContainerEventType,
+		Action: "health_status: unhealthy",
+		Actor:  eventtypes.Actor{ID: "abcdef", Attributes: map[string]string{"name": "web"}},
+	}
+
+	testCases := []struct {
+		filters  []filters.KeyValuePair
+		event    eventtypes.Message
+		expected bool
+	}{
+		{event: die, expected: true},
+		{filters: []filters.KeyValuePair{filters.Arg("type", "container"), filters.Arg("event", "die")}, event: die, expected: true},
+		{filters: []filters.KeyValuePair{filters.Arg("event", "start")}, event: die, expected: false},
+		{filters: []filters.KeyValuePair{filters.Arg("container", "web")}, event: die, expected: true},
+		{filters: []filters.KeyValuePair{filters.Arg("container", "abc")}, event: die, expected: true},
+		{filters: []filters.KeyValuePair{filters.Arg("container", "db")}, event: die, expected: false},
+		{filters: []filters.KeyValuePair{filters.Arg("image", "nginx")}, event: die, expected: true},
+		{filters: []filters.KeyValuePair{filters.Arg("attr", "exitCode!=0")}, event: die, expected: true},
+		{filters: []filters.KeyValuePair{filters.Arg("attr", "exitCode=0")}, event: die, expected: false},
+		{filters: []filters.KeyValuePair{filters.Arg("event", "health_status")}, event: health, expected: true},
+		{filters: []filters.KeyValuePair{filters.Arg("event", "health_status: healthy")}, event: health, expected: false},
+	}
+	for _, tc := range testCases {
+		f := filters.NewArgs(tc.filters...)
+		assert.Check(t, is.Equal(tc.expected, matchEvent(f, tc.event)), "%v", tc.filters)
+	}
+}
+
+func TestSplitEventFilters(t *testing.T) {
+	f := filters.NewArgs(filters.Arg("type", "container"), filters.Arg("attr", "exitCode!=0"))
+	server, err := splitEventFilters(f)
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual([]string{"container"}, server.Get("type")))
+	assert.Check(t, !server.Contains("attr"))
+	assert.Check(t, f.Contains("attr"))
+}
+
+func TestIsDuplicateEvent(t *testing.T) {
+	last := eventtypes.Message{Type: "container", Action: "start", Actor: eventtypes.Actor{ID: "a"}, TimeNano: 100}
+	assert.Check(t, isDuplicateEvent(last, eventtypes.Message{TimeNano: 99}))
+	assert.Check(t, isDuplicateEvent(last, last))
+	assert.Check(t, !isDuplicateEvent(last, eventtypes.Message{Type: "container", Action: "die", Actor: eventtypes.Actor{ID: "a"}, TimeNano: 100}))
+	assert.Check(t, !isDuplicateEvent(last, eventtypes.Message{TimeNano: 101}))
+}
+
+func TestFormatEventTime(t *testing.T) {
+	assert.Check(t, is.Equal("1556704800.000000042", formatEventTime(1556704800000000042)))
+}
@@ -0,0 +1,180 @@
+package system
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yuyangjack/dockercli/cli/config/configfile"
+	"github.com/yuyangjack/dockercli/opts"
+	eventtypes "github.com/yuyangjack/moby/api/types/events"
+	"github.com/yuyangjack/moby/api/types/filters"
+	"github.com/pkg/errors"
+)
+
+const (
+	// eventHookTimeout is how long a hook may run before it is killed.
+	eventHookTimeout = 30 * time.Second
+	// eventHookQueueSize is the number of events waiting for their hooks
+	// before new events are dropped.
+	eventHookQueueSize = 100
+)
+
+// eventHook is a local command run for every event matching its filter.
+type eventHook struct {
+	command string
+	filter  filters.Args
+}
+
+// newEventHooks returns the hooks configured in the config file, followed by
+// the --exec command, which uses the command line filters.
+func newEventHooks(configured []configfile.EventHook, command string, cliFilter filters.Args) ([]eventHook, error) {
+	var hooks []eventHook
+	for i, h := range configured {
+		if h.Exec == "" {
+			return nil, errors.Errorf("invalid hook %d in config file: exec is required", i)
+		}
+		f := opts.NewFilterOpt()
+		for _, v := range h.Filters {
+			if err := f.Set(v); err != nil {
+				return nil, errors.Wrapf(err, "invalid hook %d in config file", i)
+			}
+		}
+		hooks = append(hooks, eventHook{command: h.Exec, filter: f.Value()})
+	}
+	if command != "" {
+		hooks = append(hooks, eventHook{command: command, filter: cliFilter})
+	}
+	return hooks, nil
+}
+
+// eventHookRunner runs the hooks of events in the background, so that slow
+// hooks do not hold up the event stream. Events are handled one at a time, in
+// order; if too many are waiting, new events are dropped with a warning.
+type eventHookRunner struct {
+	hooks   []eventHook
+	timeout time.Duration
+	stdout  io.Writer
+	stderr  io.Writer
+	queue   chan eventtypes.Message
+	done    chan struct{}
+}
+
+func newEventHookRunner(hooks []eventHook, timeout time.Duration, queueSize int, stdout, stderr io.Writer) *eventHookRunner {
+	r := &eventHookRunner{
+		hooks:   hooks,
+		timeout: timeout,
+		stdout:  stdout,
+		stderr:  stderr,
+		queue:   make(chan eventtypes.Message, queueSize),
+		done:    make(chan struct{}),
+	}
+	go r.loop()
+	return r
+}
+
+// enqueue schedules the hooks matching event, without blocking.
+func (r *eventHookRunner) enqueue(event eventtypes.Message) {
+	if len(r.hooks) == 0 {
+		return
+	}
+	select {
+	case r.queue <- event:
+	default:
+		fmt.Fprintf(r.stderr, "Too many events waiting for hooks, skipping hooks for %s %s %s\n", event.Type, event.Action, event.Actor.ID)
+	}
+}
+
+// close waits for the hooks of the queued events to finish.
+func (r *eventHookRunner) close() {
+	close(r.queue)
+	<-r.done
+}
+
+func (r *eventHookRunner) loop() {
+	defer close(r.done)
+	for event := range r.queue {
+		r.run(event)
+	}
+}
+
+// run runs every hook matching event in order. A failing hook is reported on
+// stderr but does not stop the event stream.
+func (r *eventHookRunner) run(event eventtypes.Message) {
+	for _, h := range r.hooks {
+		if !matchEvent(h.filter, event) {
+			continue
+		}
+		if err := h.run(event, r.timeout, r.stdout, r.stderr); err != nil {
+			fmt.Fprintf(r.stderr, "Error running hook %q: %s\n", h.command, err)
+		}
+	}
+}
+
+// run executes the hook through the shell, killing it after timeout. The
+// event is passed as environment variables and as JSON on stdin.
+func (h eventHook) run(event eventtypes.Message, timeout time.Duration, stdout, stderr io.Writer) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/S", "/C", h.command)
+	} else {
+		cmd = exec.CommandContext(ctx, "/bin/sh", "-c", h.command)
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	cmd.Env = append(os.Environ(), eventEnv(event)...)
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	err = cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return errors.Errorf("timed out after %s", timeout)
+	}
+	return err
+}
+
+// eventEnv returns the environment variables describing an event. Actor
+// attributes are exported as DOCKER_EVENT_ATTR_<NAME>, with the name upper
+// cased and characters other than letters and digits replaced by '_'.
+func eventEnv(event eventtypes.Message) []string {
+	env := []string{
+		"DOCKER_EVENT_TYPE=" + event.Type,
+		"DOCKER_EVENT_ACTION=" + event.Action,
+		"DOCKER_EVENT_ACTOR_ID=" + event.Actor.ID,
+		"DOCKER_EVENT_SCOPE=" + event.Scope,
+		"DOCKER_EVENT_TIME=" + strconv.FormatInt(event.Time, 10),
+		"DOCKER_EVENT_TIME_NANO=" + strconv.FormatInt(event.TimeNano, 10),
+	}
+	var attrs []string
+	for k, v := range event.Actor.Attributes {
+		attrs = append(attrs, "DOCKER_EVENT_ATTR_"+envName(k)+"="+v)
+	}
+	sort.Strings(attrs)
+	return append(env, attrs...)
+}
+
+func envName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, name)
+}
@@ -0,0 +1,56 @@
+package system
+
+import (
+	"testing"
+
+	"github.com/yuyangjack/dockercli/cli/config/configfile"
+	eventtypes "github.com/yuyangjack/moby/api/types/events"
+	"github.com/yuyangjack/moby/api/types/filters"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestEventEnv(t *testing.T) {
+	event := eventtypes.Message{
+		Type:     eventtypes.ServiceEventType,
+		Action:   "update",
+		Scope:    "swarm",
+		Time:     1556704800,
+		TimeNano: 1556704800000000042,
+		Actor: eventtypes.Actor{
+			ID:         "svc1",
+			Attributes: map[string]string{"name": "web", "updatestate.new": "paused"},
+		},
+	}
+	expected := []string{
+		"DOCKER_EVENT_TYPE=service",
+		"DOCKER_EVENT_ACTION=update",
+		"DOCKER_EVENT_ACTOR_ID=svc1",
+		"DOCKER_EVENT_SCOPE=swarm",
+		"DOCKER_EVENT_TIME=1556704800",
+		"DOCKER_EVENT_TIME_NANO=1556704800000000042",
+		"DOCKER_EVENT_ATTR_NAME=web",
+		"DOCKER_EVENT_ATTR_UPDATESTATE_NEW=paused",
+	}
+	assert.Check(t, is.DeepEqual(expected, eventEnv(event)))
+}
+
+func TestNewEventHooks(t *testing.T) {
+	configured := []configfile.EventHook{
+		{Exec: "notify.sh", Filters: []string{"type=container", "event=die", "attr=exitCode!=0"}},
+	}
+	cliFilter := filters.NewArgs(filters.Arg("type", "service"))
+
+	hooks, err := newEventHooks(configured, "script.sh", cliFilter)
+	assert.NilError(t, err)
+	assert.Assert(t, is.Len(hooks, 2))
+	assert.Check(t, is.Equal("notify.sh", hooks[0].command))
+	assert.Check(t, is.DeepEqual([]string{"exitCode!=0"}, hooks[0].filter.Get("attr")))
+	assert.Check(t, is.Equal("script.sh", hooks[1].command))
+	assert.Check(t, is.DeepEqual([]string{"service"}, hooks[1].filter.Get("type")))
+
+	_, err = newEventHooks([]configfile.EventHook{{Filters: []string{"type=container"}}}, "", cliFilter)
+	assert.Check(t, is.ErrorContains(err, "exec is required"))
+	_, err = newEventHooks([]configfile.EventHook{{Exec: "x", Filters: []string{"bad"}}}, "", cliFilter)
+	assert.Check(t, is.ErrorContains(err, "bad format of filter"))
+}
@@ -0,0 +1,98 @@
+package system
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	eventtypes "github.com/yuyangjack/moby/api/types/events"
+)
+
+const (
+	defaultEventsMaxSize  = 100 * 1024 * 1024
+	defaultEventsMaxFiles = 5
+)
+
+// rotatingFile appends to a file, rotating it to path.1, path.2, ... once it
+// grows beyond maxSize. At most maxFiles files are kept, including the
+// current one. A maxSize of 0 disables rotation.
+type rotatingFile struct {
+	path     string
+	maxSize  int64
+	maxFiles int
+	file     *os.File
+	size     int64
+}
+
+func openRotatingFile(path string, maxSize int64, maxFiles int) (*rotatingFile, error) {
+	r := &rotatingFile{path: path, maxSize: maxSize, maxFiles: maxFiles}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.file, r.size = f, fi.Size()
+	return nil
+}
+
+func (r *rotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	for i := r.maxFiles - 1; i > 0; i-- {
+		from := r.path
+		if i > 1 {
+			from = fmt.Sprintf("%s.%d", r.path, i-1)
+		}
+		if err := os.Rename(from, fmt.Sprintf("%s.%d", r.path, i)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if r.maxFiles <= 1 {
+		if err := os.Remove(r.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return r.open()
+}
+
+// Write appends p and syncs it to disk so that no event is lost if the CLI
+// is killed.
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	if err != nil {
+		return n, err
+	}
+	return n, r.file.Sync()
+}
+
+func (r *rotatingFile) Close() error {
+	return r.file.Close()
+}
+
+// writeEventLine writes event as a single JSON line.
+func writeEventLine(r *rotatingFile, event eventtypes.Message) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = r.Write(append(line, '\n'))
+	return err
+}
@@ -0,0 +1,52 @@
+package system
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	"gotest.tools/fs"
+)
+
+func TestRotatingFile(t *testing.T) {
+	dir := fs.NewDir(t, "events-output")
+	defer dir.Remove()
+	path := dir.Join("events.jsonl")
+
+	r, err := openRotatingFile(path, 10, 3)
+	assert.NilError(t, err)
+	for _, line := range []string{"aaaaaa\n", "bbbbbb\n", "cccccc\n", "dddddd\n"} {
+		_, err := r.Write([]byte(line))
+		assert.NilError(t, err)
+	}
+	assert.NilError(t, r.Close())
+
+	for file, expected := range map[string]string{
+		path:        "dddddd\n",
+		path + ".1": "cccccc\n",
+		path + ".2": "bbbbbb\n",
+	} {
+		content, err := ioutil.ReadFile(file)
+		assert.NilError(t, err)
+		assert.Check(t, is.Equal(expected, string(content)))
+	}
+	_, err = os.Stat(path + ".3")
+	assert.Check(t, os.IsNotExist(err))
+}
+
+func TestRotatingFileAppends(t *testing.T) {
+	file := fs.NewFile(t, "events-output", fs.WithContent("existing\n"))
+	defer file.Remove()
+
+	r, err := openRotatingFile(file.Path(), 0, 1)
+	assert.NilError(t, err)
+	_, err = r.Write([]byte("new\n"))
+	assert.NilError(t, err)
+	assert.NilError(t, r.Close())
+
+	content, err := ioutil.ReadFile(file.Path())
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal("existing\nnew\n", string(content)))
+}
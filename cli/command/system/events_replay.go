@@ -0,0 +1,130 @@
+package system
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+
+	"github.com/yuyangjack/dockercli/cli"
+	"github.com/yuyangjack/dockercli/cli/command"
+	"github.com/yuyangjack/dockercli/opts"
+	eventtypes "github.com/yuyangjack/moby/api/types/events"
+	timetypes "github.com/yuyangjack/moby/api/types/time"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+type replayOptions struct {
+	files  []string
+	since  string
+	until  string
+	filter opts.FilterOpt
+	format string
+}
+
+func newEventsReplayCommand(dockerCli command.Cli) *cobra.Command {
+	options := replayOptions{filter: opts.NewFilterOpt()}
+
+	cmd := &cobra.Command{
+		Use:   "replay [OPTIONS] FILE [FILE...]",
+		Short: "Print events saved with --output",
+		Long:  "Print events saved with --output, applying the same filters and format as live events. Use '-' to read from STDIN.",
+		Args:  cli.RequiresMinArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			options.files = args
+			return runEventsReplay(dockerCli, &options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.since, "since", "", "Show all events created since timestamp")
+	flags.StringVar(&options.until, "until", "", "Show events created until this timestamp")
+	flags.VarP(&options.filter, "filter", "f", "Filter output based on conditions provided")
+	flags.StringVar(&options.format, "format", "", "Format the output using the given Go template")
+
+	return cmd
+}
+
+func runEventsReplay(dockerCli command.Cli, options *replayOptions) error {
+	tmpl, err := makeTemplate(options.format)
+	if err != nil {
+		return cli.StatusError{
+			StatusCode: 64,
+			Status:     "Error parsing format: " + err.Error()}
+	}
+	now := time.Now()
+	since, err := parseEventTime(options.since, now, 0)
+	if err != nil {
+		return errors.Wrap(err, "invalid --since")
+	}
+	until, err := parseEventTime(options.until, now, 1<<63-1)
+	if err != nil {
+		return errors.Wrap(err, "invalid --until")
+	}
+
+	for _, name := range options.files {
+		err := replayEvents(dockerCli, name, func(event eventtypes.Message) error {
+			if t := eventTimeNano(event); t < since || t > until {
+				return nil
+			}
+			if !matchEvent(options.filter.Value(), event) {
+				return nil
+			}
+			return handleEvent(dockerCli.Out(), event, tmpl)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseEventTime parses a --since or --until value into nanoseconds since
+// the epoch, returning def if value is empty.
+func parseEventTime(value string, reference time.Time, def int64) (int64, error) {
+	if value == "" {
+		return def, nil
+	}
+	ts, err := timetypes.GetTimestamp(value, reference)
+	if err != nil {
+		return 0, err
+	}
+	sec, nsec, err := timetypes.ParseTimestamps(ts, 0)
+	if err != nil {
+		return 0, err
+	}
+	return time.Unix(sec, nsec).UnixNano(), nil
+}
+
+// replayEvents calls fn for every event in a file of JSON lines.
+func replayEvents(dockerCli command.Cli, name string, fn func(eventtypes.Message) error) error {
+	var in io.Reader
+	if name == "-" {
+		in = dockerCli.In()
+	} else {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var event eventtypes.Message
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return errors.Wrapf(err, "%s:%d: invalid event", name, line)
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
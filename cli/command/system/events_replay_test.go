@@ -0,0 +1,55 @@
+package system
+
+import (
+	"testing"
+
+	"github.com/yuyangjack/dockercli/internal/test"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	"gotest.tools/fs"
+)
+
+const savedEvents = `{"Type":"container","Action":"start","Actor":{"ID":"a1","Attributes":{"name":"web"}},"time":1556704800,"timeNano":1556704800000000000}
+{"Type":"container","Action":"die","Actor":{"ID":"a1","Attributes":{"exitCode":"1","name":"web"}},"time":1556704860,"timeNano":1556704860000000000}
+{"Type":"image","Action":"pull","Actor":{"ID":"nginx:latest","Attributes":{"name":"nginx"}},"time":1556704920,"timeNano":1556704920000000000}
+`
+
+func TestEventsReplay(t *testing.T) {
+	file := fs.NewFile(t, "events-replay", fs.WithContent(savedEvents))
+	defer file.Remove()
+
+	testCases := []struct {
+		args     []string
+		expected string
+	}{
+		{
+			args:     []string{"--format", "{{.Type}} {{.Action}}", file.Path()},
+			expected: "container start\ncontainer die\nimage pull\n",
+		},
+		{
+			args:     []string{"--filter", "type=container", "--filter", "attr=exitCode!=0", "--format", "{{.Action}} {{.Actor.ID}}", file.Path()},
+			expected: "die a1\n",
+		},
+		{
+			args:     []string{"--since", "1556704850", "--until", "1556704900", "--format", "{{.Action}}", file.Path()},
+			expected: "die\n",
+		},
+	}
+	for _, tc := range testCases {
+		cli := test.NewFakeCli(&fakeClient{})
+		cmd := newEventsReplayCommand(cli)
+		cmd.SetArgs(tc.args)
+		assert.NilError(t, cmd.Execute())
+		assert.Check(t, is.Equal(tc.expected, cli.OutBuffer().String()))
+	}
+}
+
+func TestEventsReplayInvalidFile(t *testing.T) {
+	file := fs.NewFile(t, "events-replay", fs.WithContent("not json\n"))
+	defer file.Remove()
+
+	cli := test.NewFakeCli(&fakeClient{})
+	cmd := newEventsReplayCommand(cli)
+	cmd.SetArgs([]string{file.Path()})
+	assert.ErrorContains(t, cmd.Execute(), ":1: invalid event")
+}
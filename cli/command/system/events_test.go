@@ -0,0 +1,110 @@
+package system
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/yuyangjack/dockercli/internal/test"
+	"github.com/yuyangjack/dockercli/opts"
+	"github.com/yuyangjack/moby/api/types"
+	eventtypes "github.com/yuyangjack/moby/api/types/events"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	"gotest.tools/fs"
+	"gotest.tools/skip"
+)
+
+// eventStream returns channels sending events, followed by err.
+func eventStream(events []eventtypes.Message, err error) (<-chan eventtypes.Message, <-chan error) {
+	msgs := make(chan eventtypes.Message)
+	errs := make(chan error, 1)
+	go func() {
+		for _, event := range events {
+			msgs <- event
+		}
+		errs <- err
+	}()
+	return msgs, errs
+}
+
+func TestEventsReconnectKeepsRelativeSince(t *testing.T) {
+	var since []string
+	cli := test.NewFakeCli(&fakeClient{
+		eventsFunc: func(ctx context.Context, options types.EventsOptions) (<-chan eventtypes.Message, <-chan error) {
+			since = append(since, options.Since)
+			if len(since) == 1 {
+				return eventStream(nil, io.ErrUnexpectedEOF)
+			}
+			return eventStream(nil, io.EOF)
+		},
+	})
+	start := time.Now()
+	cmd := NewEventsCommand(cli)
+	cmd.SetArgs([]string{"--since", "10m"})
+	assert.NilError(t, cmd.Execute())
+
+	assert.Assert(t, is.Len(since, 2))
+	assert.Check(t, is.Equal("10m", since[0]))
+	resumed, err := strconv.ParseFloat(since[1], 64)
+	assert.NilError(t, err)
+	expected := float64(start.Add(-10 * time.Minute).Unix())
+	assert.Check(t, resumed >= expected-1 && resumed <= expected+1, "resumed from %s", since[1])
+}
+
+func TestEventsHooksUseAttrFilter(t *testing.T) {
+	skip.If(t, runtime.GOOS == "windows", "hooks run through /bin/sh")
+	dir := fs.NewDir(t, "events-hooks")
+	defer dir.Remove()
+	log := filepath.Join(dir.Path(), "hooks.log")
+
+	cli := test.NewFakeCli(&fakeClient{
+		eventsFunc: func(ctx context.Context, options types.EventsOptions) (<-chan eventtypes.Message, <-chan error) {
+			return eventStream([]eventtypes.Message{
+				{Type: "container", Action: "die", Actor: eventtypes.Actor{ID: "a1", Attributes: map[string]string{"exitCode": "0"}}, TimeNano: 1},
+				{Type: "container", Action: "die", Actor: eventtypes.Actor{ID: "a2", Attributes: map[string]string{"exitCode": "1"}}, TimeNano: 2},
+			}, io.EOF)
+		},
+	})
+	cmd := NewEventsCommand(cli)
+	cmd.SetArgs([]string{"--filter", "attr=exitCode!=0", "--exec", `echo "$DOCKER_EVENT_ACTOR_ID" >> ` + log})
+	assert.NilError(t, cmd.Execute())
+
+	content, err := ioutil.ReadFile(log)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal("a2\n", string(content)))
+}
+
+func TestEventHookRunnerTimeout(t *testing.T) {
+	skip.If(t, runtime.GOOS == "windows", "hooks run through /bin/sh")
+	cli := test.NewFakeCli(&fakeClient{})
+	hooks, err := newEventHooks(nil, "sleep 10", opts.NewFilterOpt().Value())
+	assert.NilError(t, err)
+
+	start := time.Now()
+	runner := newEventHookRunner(hooks, 100*time.Millisecond, 1, cli.Out(), cli.Err())
+	runner.enqueue(eventtypes.Message{Type: "container", Action: "start"})
+	runner.close()
+
+	assert.Check(t, time.Since(start) < 5*time.Second)
+	assert.Check(t, is.Contains(cli.ErrBuffer().String(), `Error running hook "sleep 10": timed out after 100ms`))
+}
+
+func TestEventHookRunnerQueueFull(t *testing.T) {
+	cli := test.NewFakeCli(&fakeClient{})
+	runner := &eventHookRunner{
+		hooks:  []eventHook{{command: "true"}},
+		stderr: cli.Err(),
+		queue:  make(chan eventtypes.Message, 1),
+	}
+	runner.enqueue(eventtypes.Message{Type: "container", Action: "start", Actor: eventtypes.Actor{ID: "a1"}})
+	runner.enqueue(eventtypes.Message{Type: "container", Action: "die", Actor: eventtypes.Actor{ID: "a1"}})
+
+	assert.Check(t, is.Len(runner.queue, 1))
+	assert.Check(t, is.Contains(cli.ErrBuffer().String(), "skipping hooks for container die a1"))
+}
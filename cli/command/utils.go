@@ -88,6 +88,15 @@ func PromptForConfirmation(ins io.Reader, outs io.Writer, message string) bool {
 	return strings.ToLower(string(answer)) == "y"
 }
 
+// RetentionPruneFilters are the pruneFilters of config.json that hold the
+// retention rules of `docker image prune`. They are applied by the CLI and
+// never sent to the daemon.
+var RetentionPruneFilters = map[string]bool{
+	"keep":             true,
+	"keep-last":        true,
+	"keep-used-within": true,
+}
+
 // PruneFilters returns consolidated prune filters obtained from config.json and cli
 func PruneFilters(dockerCli Cli, pruneFilters filters.Args) filters.Args {
 	if dockerCli.ConfigFile() == nil {
@@ -95,7 +104,7 @@ func PruneFilters(dockerCli Cli, pruneFilters filters.Args) filters.Args {
 	}
 	for _, f := range dockerCli.ConfigFile().PruneFilters {
 		parts := strings.SplitN(f, "=", 2)
-		if len(parts) != 2 {
+		if len(parts) != 2 || RetentionPruneFilters[parts[0]] {
 			continue
 		}
 		if parts[0] == "label" {
@@ -135,6 +135,9 @@ func convertVolumeToMount(
 		return handleBindToMount(volume)
 	case "tmpfs":
 		return handleTmpfsToMount(volume)
+	case "sync":
+		// Sync mounts are populated by the CLI for containers only
+		return mount.Mount{}, errors.New("volume type sync is only supported by docker run and docker create, not by services")
 	}
 	return mount.Mount{}, errors.New("volume type must be volume, bind, or tmpfs")
 }
@@ -44,6 +44,16 @@ func TestConvertVolumeToMountUnapprovedType(t *testing.T) {
 	assert.Error(t, err, "volume type must be volume, bind, or tmpfs")
 }
 
+func TestConvertVolumeToMountSyncType(t *testing.T) {
+	config := composetypes.ServiceVolumeConfig{
+		Type:   "sync",
+		Source: "./src",
+		Target: "/app",
+	}
+	_, err := convertVolumeToMount(config, volumes{}, NewNamespace("foo"))
+	assert.Error(t, err, "volume type sync is only supported by docker run and docker create, not by services")
+}
+
 func TestConvertVolumeToMountConflictingOptionsBindInVolume(t *testing.T) {
 	namespace := NewNamespace("foo")
 
@@ -48,6 +48,7 @@ type ConfigFile struct {
 	Experimental         string                      `json:"experimental,omitempty"`
 	StackOrchestrator    string                      `json:"stackOrchestrator,omitempty"`
 	Kubernetes           *KubernetesConfig           `json:"kubernetes,omitempty"`
+	Hooks                []EventHook                 `json:"hooks,omitempty"`
 }
 
 // ProxyConfig contains proxy configuration settings
@@ -63,6 +64,13 @@ type KubernetesConfig struct {
 	AllNamespaces string `json:"allNamespaces,omitempty"`
 }
 
+// EventHook is a local command run by `docker events` for every event that
+// matches its filters
+type EventHook struct {
+	Exec    string   `json:"exec"`
+	Filters []string `json:"filters,omitempty"`
+}
+
 // New initializes an empty configuration file for the given filename 'fn'
 func New(fn string) *ConfigFile {
 	return &ConfigFile{
@@ -22,8 +22,14 @@ type Store interface {
 	Get(listRef reference.Reference, manifest reference.Reference) (types.ImageManifest, error)
 	GetList(listRef reference.Reference) ([]types.ImageManifest, error)
 	Save(listRef reference.Reference, manifest reference.Reference, image types.ImageManifest) error
+	GetListOptions(listRef reference.Reference) (types.ListOptions, error)
+	SaveListOptions(listRef reference.Reference, options types.ListOptions) error
 }
 
+// listOptionsFilename is the file in a manifest list directory holding the
+// list options. Manifest filenames never start with a dot.
+const listOptionsFilename = ".list-options.json"
+
 // fsStore manages manifest files stored on the local filesystem
 type fsStore struct {
 	root string
@@ -122,6 +128,9 @@ func (s *fsStore) listManifests(transaction string) ([]string, error) {
 
 	filenames := []string{}
 	for _, info := range fileInfos {
+		if strings.HasPrefix(info.Name(), ".") {
+			continue
+		}
 		filenames = append(filenames, info.Name())
 	}
 	return filenames, nil
@@ -140,6 +149,35 @@ func (s *fsStore) Save(listRef reference.Reference, manifest reference.Reference
 	return ioutil.WriteFile(filename, bytes, 0644)
 }
 
+// GetListOptions returns the options of a local manifest list. The zero
+// value is returned if no options were saved.
+func (s *fsStore) GetListOptions(listRef reference.Reference) (types.ListOptions, error) {
+	var options types.ListOptions
+	filename := filepath.Join(s.root, makeFilesafeName(listRef.String()), listOptionsFilename)
+	bytes, err := ioutil.ReadFile(filename)
+	switch {
+	case os.IsNotExist(err):
+		return options, nil
+	case err != nil:
+		return options, err
+	}
+	err = json.Unmarshal(bytes, &options)
+	return options, err
+}
+
+// SaveListOptions saves the options of a local manifest list
+func (s *fsStore) SaveListOptions(listRef reference.Reference, options types.ListOptions) error {
+	if err := s.createManifestListDirectory(listRef.String()); err != nil {
+		return err
+	}
+	bytes, err := json.Marshal(options)
+	if err != nil {
+		return err
+	}
+	filename := filepath.Join(s.root, makeFilesafeName(listRef.String()), listOptionsFilename)
+	return ioutil.WriteFile(filename, bytes, 0644)
+}
+
 func (s *fsStore) createManifestListDirectory(transaction string) error {
 	path := filepath.Join(s.root, makeFilesafeName(transaction))
 	return os.MkdirAll(path, 0755)
@@ -134,3 +134,26 @@ func TestStoreGetListDoesNotExist(t *testing.T) {
 	assert.Error(t, err, "No such manifest: list")
 	assert.Check(t, IsNotFound(err))
 }
+
+func TestStoreListOptions(t *testing.T) {
+	store, cleanup := newTestStore(t)
+	defer cleanup()
+
+	listRef := ref("list")
+	options, err := store.GetListOptions(listRef)
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(types.ListOptions{}, options))
+
+	expected := types.ListOptions{OCI: true, Annotations: map[string]string{"org.opencontainers.image.version": "1.0"}}
+	assert.NilError(t, store.SaveListOptions(listRef, expected))
+	assert.NilError(t, store.Save(listRef, ref("first"), types.ImageManifest{Ref: sref(t, "first")}))
+
+	options, err = store.GetListOptions(listRef)
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(expected, options))
+
+	// The options are not listed as a manifest
+	list, err := store.GetList(listRef)
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(list, 1))
+}
@@ -0,0 +1,76 @@
+package types
+
+import (
+	"encoding/json"
+
+	"github.com/yuyangjack/distribution"
+	"github.com/yuyangjack/distribution/manifest/manifestlist"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// OCIIndex is an OCI image index. Unlike manifestlist.DeserializedManifestList
+// it keeps the index-level annotations.
+type OCIIndex struct {
+	ocispec.Index
+
+	// canonical is the canonical byte representation of the index
+	canonical []byte
+}
+
+// NewOCIIndex returns an OCI image index for the given manifests
+func NewOCIIndex(manifests []manifestlist.ManifestDescriptor, annotations map[string]string) (*OCIIndex, error) {
+	index := ocispec.Index{
+		Versioned:   specs.Versioned{SchemaVersion: 2},
+		Manifests:   make([]ocispec.Descriptor, 0, len(manifests)),
+		Annotations: annotations,
+	}
+	for _, m := range manifests {
+		platform := m.Platform
+		index.Manifests = append(index.Manifests, ocispec.Descriptor{
+			MediaType: m.MediaType,
+			Digest:    m.Digest,
+			Size:      m.Size,
+			URLs:      m.URLs,
+			Platform:  OCIPlatform(&platform),
+		})
+	}
+
+	// Indent the same way as manifestlist.FromDescriptors
+	canonical, err := json.MarshalIndent(index, "", "   ")
+	if err != nil {
+		return nil, err
+	}
+	return &OCIIndex{Index: index, canonical: canonical}, nil
+}
+
+// References returns the descriptors of the manifests in the index
+func (i *OCIIndex) References() []distribution.Descriptor {
+	references := make([]distribution.Descriptor, 0, len(i.Manifests))
+	for _, m := range i.Manifests {
+		references = append(references, distribution.Descriptor{
+			MediaType: m.MediaType,
+			Digest:    m.Digest,
+			Size:      m.Size,
+			URLs:      m.URLs,
+		})
+	}
+	return references
+}
+
+// Payload returns the media type and the canonical bytes of the index
+func (i *OCIIndex) Payload() (string, []byte, error) {
+	return ocispec.MediaTypeImageIndex, i.canonical, nil
+}
+
+// UnmarshalJSON populates the index from its canonical bytes
+func (i *OCIIndex) UnmarshalJSON(b []byte) error {
+	i.canonical = make([]byte, len(b))
+	copy(i.canonical, b)
+	return json.Unmarshal(i.canonical, &i.Index)
+}
+
+// MarshalJSON returns the canonical bytes of the index
+func (i *OCIIndex) MarshalJSON() ([]byte, error) {
+	return i.canonical, nil
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/yuyangjack/distribution"
 	"github.com/yuyangjack/distribution/manifest/manifestlist"
+	"github.com/yuyangjack/distribution/manifest/ocischema"
 	"github.com/yuyangjack/distribution/manifest/schema2"
 	"github.com/yuyangjack/distribution/reference"
 	"github.com/opencontainers/go-digest"
@@ -20,6 +21,18 @@ type ImageManifest struct {
 	// SchemaV2Manifest is used for inspection
 	// TODO: Deprecate this and store manifest blobs
 	SchemaV2Manifest *schema2.DeserializedManifest `json:",omitempty"`
+
+	// OCIManifest is used for inspection of OCI image manifests
+	OCIManifest *ocischema.DeserializedManifest `json:",omitempty"`
+}
+
+// ListOptions are the settings of a local manifest list that apply to the
+// list as a whole
+type ListOptions struct {
+	// OCI pushes the list as an OCI image index
+	OCI bool `json:",omitempty"`
+	// Annotations are the index-level annotations of an OCI image index
+	Annotations map[string]string `json:",omitempty"`
 }
 
 // OCIPlatform creates an OCI platform from a manifest list platform spec
@@ -53,7 +66,7 @@ func PlatformSpecFromOCI(p *ocispec.Platform) *manifestlist.PlatformSpec {
 // Blobs returns the digests for all the blobs referenced by this manifest
 func (i ImageManifest) Blobs() []digest.Digest {
 	digests := []digest.Digest{}
-	for _, descriptor := range i.SchemaV2Manifest.References() {
+	for _, descriptor := range i.References() {
 		digests = append(digests, descriptor.Digest)
 	}
 	return digests
@@ -65,6 +78,8 @@ func (i ImageManifest) Payload() (string, []byte, error) {
 	switch {
 	case i.SchemaV2Manifest != nil:
 		return i.SchemaV2Manifest.Payload()
+	case i.OCIManifest != nil:
+		return i.OCIManifest.Payload()
 	default:
 		return "", nil, errors.Errorf("%s has no payload", i.Ref)
 	}
@@ -76,6 +91,8 @@ func (i ImageManifest) References() []distribution.Descriptor {
 	switch {
 	case i.SchemaV2Manifest != nil:
 		return i.SchemaV2Manifest.References()
+	case i.OCIManifest != nil:
+		return i.OCIManifest.References()
 	default:
 		return nil
 	}
@@ -91,6 +108,16 @@ func NewImageManifest(ref reference.Named, desc ocispec.Descriptor, manifest *sc
 	}
 }
 
+// NewOCIImageManifest returns a new ImageManifest object for an OCI image
+// manifest
+func NewOCIImageManifest(ref reference.Named, desc ocispec.Descriptor, manifest *ocischema.DeserializedManifest) ImageManifest {
+	return ImageManifest{
+		Ref:         &SerializableNamed{Named: ref},
+		Descriptor:  desc,
+		OCIManifest: manifest,
+	}
+}
+
 // SerializableNamed is a reference.Named that can be serialzied and deserialized
 // from JSON
 type SerializableNamed struct {
@@ -3,6 +3,7 @@ package client
 import (
 	"context"
 	"fmt"
+	"io"
 	"net/http"
 	"strings"
 
@@ -11,6 +12,7 @@ import (
 	"github.com/yuyangjack/distribution"
 	"github.com/yuyangjack/distribution/reference"
 	distributionclient "github.com/yuyangjack/distribution/registry/client"
+	"github.com/yuyangjack/distribution/registry/client/auth"
 	"github.com/yuyangjack/moby/api/types"
 	registrytypes "github.com/yuyangjack/moby/api/types/registry"
 	"github.com/opencontainers/go-digest"
@@ -18,6 +20,9 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// catalogPageSize is the number of repositories requested per catalog page
+const catalogPageSize = 100
+
 // RegistryClient is a client used to communicate with a Docker distribution
 // registry
 type RegistryClient interface {
@@ -26,6 +31,13 @@ type RegistryClient interface {
 	MountBlob(ctx context.Context, source reference.Canonical, target reference.Named) error
 	PutManifest(ctx context.Context, ref reference.Named, manifest distribution.Manifest) (digest.Digest, error)
 	GetTags(ctx context.Context, ref reference.Named) ([]string, error)
+	GetDistributionManifest(ctx context.Context, ref reference.Named) (distribution.Manifest, error)
+	CopyBlob(ctx context.Context, source reference.Named, target reference.Named, desc distribution.Descriptor) error
+	GetImageConfig(ctx context.Context, ref reference.Named) (manifesttypes.ImageManifest, []byte, error)
+	GetCatalog(ctx context.Context, domain string) ([]string, error)
+	DeleteManifest(ctx context.Context, ref reference.Canonical) error
+	GetBlob(ctx context.Context, ref reference.Named, dgst digest.Digest) (io.ReadCloser, error)
+	PutBlob(ctx context.Context, ref reference.Named, mediaType string, content []byte) (distribution.Descriptor, error)
 }
 
 // NewRegistryClient returns a new RegistryClient with a resolver
@@ -137,8 +149,209 @@ func (c *client) GetTags(ctx context.Context, ref reference.Named) ([]string, er
 	return repo.Tags(ctx).All(ctx)
 }
 
+// GetDistributionManifest returns the manifest or manifest list for the
+// reference exactly as it is stored in the registry
+func (c *client) GetDistributionManifest(ctx context.Context, ref reference.Named) (distribution.Manifest, error) {
+	var result distribution.Manifest
+	fetch := func(ctx context.Context, repo distribution.Repository, ref reference.Named) (bool, error) {
+		var err error
+		result, err = getManifest(ctx, repo, ref)
+		return result != nil, err
+	}
+
+	err := c.iterateEndpoints(ctx, ref, fetch)
+	return result, err
+}
+
+// GetImageConfig returns the manifest and the raw image config for an image
+// manifest reference
+func (c *client) GetImageConfig(ctx context.Context, ref reference.Named) (manifesttypes.ImageManifest, []byte, error) {
+	var (
+		result manifesttypes.ImageManifest
+		config []byte
+	)
+	fetch := func(ctx context.Context, repo distribution.Repository, ref reference.Named) (bool, error) {
+		var err error
+		result, config, err = fetchImageConfig(ctx, repo, ref)
+		return result.Ref != nil, err
+	}
+
+	err := c.iterateEndpoints(ctx, ref, fetch)
+	return result, config, err
+}
+
+// CopyBlob copies a blob from the source repository to the target repository.
+// Nothing is transferred if the target already has the blob. If both
+// repositories are on the same registry, a cross-repository mount is tried
+// before falling back to streaming the blob. The token for the target is
+// requested with pull access to the source, which registries require to
+// mount blobs.
+func (c *client) CopyBlob(ctx context.Context, source reference.Named, target reference.Named, desc distribution.Descriptor) error {
+	targetEndpoint, err := newDefaultRepositoryEndpoint(target, c.insecureRegistry)
+	if err != nil {
+		return err
+	}
+	sameRegistry := reference.Domain(source) == reference.Domain(target)
+	scopes := []auth.Scope{auth.RepositoryScope{Repository: targetEndpoint.Name(), Actions: []string{"push", "pull"}}}
+	if sameRegistry {
+		sourceEndpoint, err := newDefaultRepositoryEndpoint(source, c.insecureRegistry)
+		if err != nil {
+			return err
+		}
+		if sourceEndpoint.Name() != targetEndpoint.Name() {
+			scopes = append(scopes, auth.RepositoryScope{Repository: sourceEndpoint.Name(), Actions: []string{"pull"}})
+		}
+	}
+	targetRepo, err := c.getRepositoryWithScopes(ctx, target, targetEndpoint, scopes...)
+	if err != nil {
+		return err
+	}
+	targetBlobs := targetRepo.Blobs(ctx)
+	if _, err := targetBlobs.Stat(ctx, desc.Digest); err == nil {
+		logrus.Debugf("blob %s already exists in %s", desc.Digest, target)
+		return nil
+	}
+
+	var createOpts []distribution.BlobCreateOption
+	if sameRegistry {
+		canonical, err := reference.WithDigest(reference.TrimNamed(source), desc.Digest)
+		if err != nil {
+			return err
+		}
+		createOpts = append(createOpts, distributionclient.WithMountFrom(canonical))
+	}
+	writer, err := targetBlobs.Create(ctx, createOpts...)
+	switch err.(type) {
+	case distribution.ErrBlobMounted:
+		logrus.Debugf("mount of blob %s from %s succeeded", desc.Digest, source)
+		return nil
+	case nil:
+	default:
+		return errors.Wrapf(err, "failed to start upload of blob %s to %s", desc.Digest, target)
+	}
+	defer writer.Close()
+
+	sourceRepo, err := c.getRepository(ctx, source)
+	if err != nil {
+		writer.Cancel(ctx)
+		return err
+	}
+	reader, err := sourceRepo.Blobs(ctx).Open(ctx, desc.Digest)
+	if err != nil {
+		writer.Cancel(ctx)
+		return errors.Wrapf(err, "failed to open blob %s in %s", desc.Digest, source)
+	}
+	defer reader.Close()
+
+	if _, err := io.Copy(writer, reader); err != nil {
+		writer.Cancel(ctx)
+		return errors.Wrapf(err, "failed to copy blob %s to %s", desc.Digest, target)
+	}
+	_, err = writer.Commit(ctx, desc)
+	return errors.Wrapf(err, "failed to commit blob %s to %s", desc.Digest, target)
+}
+
+// GetCatalog returns the names of all repositories in a registry
+func (c *client) GetCatalog(ctx context.Context, domain string) ([]string, error) {
+	repoEndpoint, err := newRegistryEndpoint(domain, c.insecureRegistry)
+	if err != nil {
+		return nil, err
+	}
+	httpTransport, err := getHTTPTransport(
+		c.authConfigResolver(ctx, repoEndpoint.info.Index),
+		repoEndpoint.endpoint,
+		[]auth.Scope{auth.RegistryScope{Name: "catalog", Actions: []string{"*"}}},
+		c.userAgent)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to configure transport")
+	}
+	reg, err := distributionclient.NewRegistry(repoEndpoint.BaseURL(), httpTransport)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		repositories []string
+		last         string
+	)
+	for {
+		entries := make([]string, catalogPageSize)
+		n, err := reg.Repositories(ctx, entries, last)
+		repositories = append(repositories, entries[:n]...)
+		switch {
+		case err == io.EOF:
+			return repositories, nil
+		case err != nil:
+			return nil, errors.Wrapf(err, "failed to list repositories in %s", domain)
+		case n == 0:
+			return repositories, nil
+		}
+		last = entries[n-1]
+	}
+}
+
+// DeleteManifest deletes a manifest by digest. All tags referring to the
+// manifest are removed with it.
+func (c *client) DeleteManifest(ctx context.Context, ref reference.Canonical) error {
+	repoEndpoint, err := newDefaultRepositoryEndpoint(ref, c.insecureRegistry)
+	if err != nil {
+		return err
+	}
+	repo, err := c.getRepositoryWithActions(ctx, ref, repoEndpoint, "pull", "push", "delete")
+	if err != nil {
+		return err
+	}
+	manifestService, err := repo.Manifests(ctx)
+	if err != nil {
+		return err
+	}
+	return errors.Wrapf(manifestService.Delete(ctx, ref.Digest()), "failed to delete manifest %s", ref)
+}
+
+// GetBlob returns a reader for the content of a blob in the repository of ref
+func (c *client) GetBlob(ctx context.Context, ref reference.Named, dgst digest.Digest) (io.ReadCloser, error) {
+	repoEndpoint, err := newDefaultRepositoryEndpoint(ref, c.insecureRegistry)
+	if err != nil {
+		return nil, err
+	}
+	repo, err := c.getRepositoryWithActions(ctx, ref, repoEndpoint, "pull")
+	if err != nil {
+		return nil, err
+	}
+	reader, err := repo.Blobs(ctx).Open(ctx, dgst)
+	return reader, errors.Wrapf(err, "failed to open blob %s in %s", dgst, reference.FamiliarName(ref))
+}
+
+// PutBlob uploads content as a blob to the repository of ref
+func (c *client) PutBlob(ctx context.Context, ref reference.Named, mediaType string, content []byte) (distribution.Descriptor, error) {
+	repo, err := c.getRepository(ctx, ref)
+	if err != nil {
+		return distribution.Descriptor{}, err
+	}
+	desc, err := repo.Blobs(ctx).Put(ctx, mediaType, content)
+	return desc, errors.Wrapf(err, "failed to upload blob to %s", reference.FamiliarName(ref))
+}
+
+func (c *client) getRepository(ctx context.Context, ref reference.Named) (distribution.Repository, error) {
+	repoEndpoint, err := newDefaultRepositoryEndpoint(ref, c.insecureRegistry)
+	if err != nil {
+		return nil, err
+	}
+	return c.getRepositoryForReference(ctx, ref, repoEndpoint)
+}
+
 func (c *client) getRepositoryForReference(ctx context.Context, ref reference.Named, repoEndpoint repositoryEndpoint) (distribution.Repository, error) {
-	httpTransport, err := c.getHTTPTransportForRepoEndpoint(ctx, repoEndpoint)
+	return c.getRepositoryWithActions(ctx, ref, repoEndpoint, "push", "pull")
+}
+
+func (c *client) getRepositoryWithActions(ctx context.Context, ref reference.Named, repoEndpoint repositoryEndpoint, actions ...string) (distribution.Repository, error) {
+	return c.getRepositoryWithScopes(ctx, ref, repoEndpoint, auth.RepositoryScope{Repository: repoEndpoint.Name(), Actions: actions})
+}
+
+// getRepositoryWithScopes returns the repository of repoEndpoint, with a
+// token requested for all scopes
+func (c *client) getRepositoryWithScopes(ctx context.Context, ref reference.Named, repoEndpoint repositoryEndpoint, scopes ...auth.Scope) (distribution.Repository, error) {
+	httpTransport, err := c.getHTTPTransportForRepoEndpoint(ctx, repoEndpoint, scopes...)
 	if err != nil {
 		if strings.Contains(err.Error(), "server gave HTTP response to HTTPS client") {
 			return nil, ErrHTTPProto{OrigErr: err.Error()}
@@ -151,11 +364,11 @@ func (c *client) getRepositoryForReference(ctx context.Context, ref reference.Na
 	return distributionclient.NewRepository(repoName, repoEndpoint.BaseURL(), httpTransport)
 }
 
-func (c *client) getHTTPTransportForRepoEndpoint(ctx context.Context, repoEndpoint repositoryEndpoint) (http.RoundTripper, error) {
+func (c *client) getHTTPTransportForRepoEndpoint(ctx context.Context, repoEndpoint repositoryEndpoint, scopes ...auth.Scope) (http.RoundTripper, error) {
 	httpTransport, err := getHTTPTransport(
 		c.authConfigResolver(ctx, repoEndpoint.info.Index),
 		repoEndpoint.endpoint,
-		repoEndpoint.Name(),
+		scopes,
 		c.userAgent)
 	return httpTransport, errors.Wrap(err, "failed to configure transport")
 }
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/yuyangjack/distribution/reference"
@@ -73,8 +74,9 @@ func getDefaultEndpointFromRepoInfo(repoInfo *registry.RepositoryInfo) (registry
 	return endpoint, nil
 }
 
-// getHTTPTransport builds a transport for use in communicating with a registry
-func getHTTPTransport(authConfig authtypes.AuthConfig, endpoint registry.APIEndpoint, repoName string, userAgent string) (http.RoundTripper, error) {
+// getHTTPTransport builds a transport for use in communicating with a registry.
+// Tokens are requested for the given scope.
+func getHTTPTransport(authConfig authtypes.AuthConfig, endpoint registry.APIEndpoint, scopes []auth.Scope, userAgent string) (http.RoundTripper, error) {
 	// get the http transport, this will be used in a client to upload manifest
 	base := &http.Transport{
 		Proxy: http.ProxyFromEnvironment,
@@ -102,13 +104,34 @@ func getHTTPTransport(authConfig authtypes.AuthConfig, endpoint registry.APIEndp
 		modifiers = append(modifiers, auth.NewAuthorizer(challengeManager, passThruTokenHandler))
 	} else {
 		creds := registry.NewStaticCredentialStore(&authConfig)
-		tokenHandler := auth.NewTokenHandler(authTransport, creds, repoName, "push", "pull")
+		tokenHandler := auth.NewTokenHandlerWithOptions(auth.TokenHandlerOptions{
+			Transport:   authTransport,
+			Credentials: creds,
+			Scopes:      scopes,
+		})
 		basicHandler := auth.NewBasicHandler(creds)
 		modifiers = append(modifiers, auth.NewAuthorizer(challengeManager, tokenHandler, basicHandler))
 	}
 	return transport.NewTransport(base, modifiers...), nil
 }
 
+// newRegistryEndpoint returns the default endpoint for a registry, such as
+// "registry.example.com:5000", for operations that are not scoped to a
+// repository.
+func newRegistryEndpoint(domain string, insecure bool) (repositoryEndpoint, error) {
+	if domain == "" || strings.Contains(domain, "/") {
+		return repositoryEndpoint{}, errors.Errorf("invalid registry %q", domain)
+	}
+	// The index and endpoint are resolved through a placeholder repository so
+	// that registry wide operations use the same configuration as repository
+	// operations.
+	ref, err := reference.ParseNormalizedNamed(domain + "/placeholder")
+	if err != nil {
+		return repositoryEndpoint{}, errors.Wrapf(err, "invalid registry %q", domain)
+	}
+	return newDefaultRepositoryEndpoint(ref, insecure)
+}
+
 // RepoNameForReference returns the repository name from a reference
 func RepoNameForReference(ref reference.Named) (string, error) {
 	// insecure is fine since this only returns the name
@@ -8,6 +8,7 @@ import (
 	"github.com/yuyangjack/dockercli/cli/manifest/types"
 	"github.com/yuyangjack/distribution"
 	"github.com/yuyangjack/distribution/manifest/manifestlist"
+	"github.com/yuyangjack/distribution/manifest/ocischema"
 	"github.com/yuyangjack/distribution/manifest/schema2"
 	"github.com/yuyangjack/distribution/reference"
 	"github.com/yuyangjack/distribution/registry/api/errcode"
@@ -36,6 +37,8 @@ func fetchManifest(ctx context.Context, repo distribution.Repository, ref refere
 			return types.ImageManifest{}, err
 		}
 		return imageManifest, nil
+	case *ocischema.DeserializedManifest:
+		return pullManifestOCISchema(ctx, ref, repo, *v)
 	case *manifestlist.DeserializedManifestList:
 		return types.ImageManifest{}, errors.Errorf("%s is a manifest list", ref)
 	}
@@ -73,14 +76,61 @@ func getManifest(ctx context.Context, repo distribution.Repository, ref referenc
 	return manSvc.Get(ctx, dgst, opts...)
 }
 
+// fetchImageConfig pulls a manifest and its image config from a registry.
+// An error is returned if ref is a manifest list.
+func fetchImageConfig(ctx context.Context, repo distribution.Repository, ref reference.Named) (types.ImageManifest, []byte, error) {
+	manifest, err := getManifest(ctx, repo, ref)
+	if err != nil {
+		return types.ImageManifest{}, nil, err
+	}
+
+	switch v := manifest.(type) {
+	case *schema2.DeserializedManifest:
+		return pullManifestSchemaV2WithConfig(ctx, ref, repo, *v)
+	case *ocischema.DeserializedManifest:
+		return pullManifestOCISchemaWithConfig(ctx, ref, repo, *v)
+	case *manifestlist.DeserializedManifestList:
+		return types.ImageManifest{}, nil, errors.Errorf("%s is a manifest list", ref)
+	}
+	return types.ImageManifest{}, nil, errors.Errorf("%s is not a manifest", ref)
+}
+
 func pullManifestSchemaV2(ctx context.Context, ref reference.Named, repo distribution.Repository, mfst schema2.DeserializedManifest) (types.ImageManifest, error) {
+	imageManifest, _, err := pullManifestSchemaV2WithConfig(ctx, ref, repo, mfst)
+	return imageManifest, err
+}
+
+func pullManifestSchemaV2WithConfig(ctx context.Context, ref reference.Named, repo distribution.Repository, mfst schema2.DeserializedManifest) (types.ImageManifest, []byte, error) {
+	manifestDesc, configJSON, err := pullImageManifestConfig(ctx, ref, repo, mfst, mfst.Target().Digest)
+	if err != nil {
+		return types.ImageManifest{}, nil, err
+	}
+	return types.NewImageManifest(ref, manifestDesc, &mfst), configJSON, nil
+}
+
+func pullManifestOCISchema(ctx context.Context, ref reference.Named, repo distribution.Repository, mfst ocischema.DeserializedManifest) (types.ImageManifest, error) {
+	imageManifest, _, err := pullManifestOCISchemaWithConfig(ctx, ref, repo, mfst)
+	return imageManifest, err
+}
+
+func pullManifestOCISchemaWithConfig(ctx context.Context, ref reference.Named, repo distribution.Repository, mfst ocischema.DeserializedManifest) (types.ImageManifest, []byte, error) {
+	manifestDesc, configJSON, err := pullImageManifestConfig(ctx, ref, repo, mfst, mfst.Target().Digest)
+	if err != nil {
+		return types.ImageManifest{}, nil, err
+	}
+	return types.NewOCIImageManifest(ref, manifestDesc, &mfst), configJSON, nil
+}
+
+// pullImageManifestConfig returns the descriptor of an image manifest, with
+// the platform filled in from the image config, and the image config itself.
+func pullImageManifestConfig(ctx context.Context, ref reference.Named, repo distribution.Repository, mfst distribution.Manifest, config digest.Digest) (ocispec.Descriptor, []byte, error) {
 	manifestDesc, err := validateManifestDigest(ref, mfst)
 	if err != nil {
-		return types.ImageManifest{}, err
+		return ocispec.Descriptor{}, nil, err
 	}
-	configJSON, err := pullManifestSchemaV2ImageConfig(ctx, mfst.Target().Digest, repo)
+	configJSON, err := pullManifestSchemaV2ImageConfig(ctx, config, repo)
 	if err != nil {
-		return types.ImageManifest{}, err
+		return ocispec.Descriptor{}, nil, err
 	}
 
 	if manifestDesc.Platform == nil {
@@ -89,10 +139,9 @@ func pullManifestSchemaV2(ctx context.Context, ref reference.Named, repo distrib
 
 	// Fill in os and architecture fields from config JSON
 	if err := json.Unmarshal(configJSON, manifestDesc.Platform); err != nil {
-		return types.ImageManifest{}, err
+		return ocispec.Descriptor{}, nil, err
 	}
-
-	return types.NewImageManifest(ref, manifestDesc, &mfst), nil
+	return manifestDesc, configJSON, nil
 }
 
 func pullManifestSchemaV2ImageConfig(ctx context.Context, dgst digest.Digest, repo distribution.Repository) ([]byte, error) {
@@ -157,16 +206,19 @@ func pullManifestList(ctx context.Context, ref reference.Named, repo distributio
 		if err != nil {
 			return nil, err
 		}
-		v, ok := manifest.(*schema2.DeserializedManifest)
-		if !ok {
-			return nil, fmt.Errorf("unsupported manifest format: %v", v)
-		}
-
 		manifestRef, err := reference.WithDigest(ref, manifestDescriptor.Digest)
 		if err != nil {
 			return nil, err
 		}
-		imageManifest, err := pullManifestSchemaV2(ctx, manifestRef, repo, *v)
+		var imageManifest types.ImageManifest
+		switch v := manifest.(type) {
+		case *schema2.DeserializedManifest:
+			imageManifest, err = pullManifestSchemaV2(ctx, manifestRef, repo, *v)
+		case *ocischema.DeserializedManifest:
+			imageManifest, err = pullManifestOCISchema(ctx, manifestRef, repo, *v)
+		default:
+			return nil, fmt.Errorf("unsupported manifest format: %v", v)
+		}
 		if err != nil {
 			return nil, err
 		}
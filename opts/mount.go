@@ -4,6 +4,7 @@ import (
 	"encoding/csv"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 
@@ -11,6 +12,18 @@ import (
 	"github.com/docker/go-units"
 )
 
+const (
+	// MountTypeSync is a client-side mount type. The CLI creates a volume,
+	// populates it from the local directory given as source, and mounts that
+	// volume at the target. It is meant for daemons that cannot see the
+	// client's filesystem.
+	MountTypeSync mounttypes.Type = "sync"
+
+	// SyncWatchLabel is set on the volume created for a sync mount when the
+	// CLI should keep propagating local changes while the container runs.
+	SyncWatchLabel = "com.docker.cli.sync.watch"
+)
+
 // MountOpt is a Value type for parsing mounts
 type MountOpt struct {
 	values []mounttypes.Mount
@@ -76,6 +89,9 @@ func (m *MountOpt) Set(value string) error {
 			case "volume-nocopy":
 				volumeOptions().NoCopy = true
 				continue
+			case "sync-watch":
+				volumeOptions().Labels[SyncWatchLabel] = "true"
+				continue
 			}
 		}
 
@@ -114,6 +130,14 @@ func (m *MountOpt) Set(value string) error {
 				volumeOptions().DriverConfig.Options = make(map[string]string)
 			}
 			setValueOnMap(volumeOptions().DriverConfig.Options, value)
+		case "sync-watch":
+			watch, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("invalid value for sync-watch: %s", value)
+			}
+			if watch {
+				volumeOptions().Labels[SyncWatchLabel] = "true"
+			}
 		case "tmpfs-size":
 			sizeBytes, err := units.RAMInBytes(value)
 			if err != nil {
@@ -139,7 +163,18 @@ func (m *MountOpt) Set(value string) error {
 		return fmt.Errorf("target is required")
 	}
 
-	if mount.VolumeOptions != nil && mount.Type != mounttypes.TypeVolume {
+	if mount.Type == MountTypeSync {
+		if mount.Source == "" {
+			return fmt.Errorf("source is required for mount type '%s'", mount.Type)
+		}
+		if mount.Source, err = filepath.Abs(mount.Source); err != nil {
+			return fmt.Errorf("invalid source for mount type '%s': %s", mount.Type, err)
+		}
+	} else if mount.VolumeOptions != nil && mount.VolumeOptions.Labels[SyncWatchLabel] != "" {
+		return fmt.Errorf("cannot mix 'sync-*' options with mount type '%s'", mount.Type)
+	}
+
+	if mount.VolumeOptions != nil && mount.Type != mounttypes.TypeVolume && mount.Type != MountTypeSync {
 		return fmt.Errorf("cannot mix 'volume-*' options with mount type '%s'", mount.Type)
 	}
 	if mount.BindOptions != nil && mount.Type != mounttypes.TypeBind {
@@ -2,6 +2,7 @@ package opts
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
 
 	mounttypes "github.com/yuyangjack/moby/api/types/mount"
@@ -183,3 +184,25 @@ func TestMountOptSetTmpfsError(t *testing.T) {
 	assert.ErrorContains(t, m.Set("type=tmpfs,target=/foo,tmpfs-mode=foo"), "invalid value for tmpfs-mode")
 	assert.ErrorContains(t, m.Set("type=tmpfs"), "target is required")
 }
+
+func TestMountOptSetSyncNoError(t *testing.T) {
+	wd, err := os.Getwd()
+	assert.NilError(t, err)
+
+	var mount MountOpt
+	assert.NilError(t, mount.Set("type=sync,source=./src,target=/app,sync-watch"))
+
+	mounts := mount.Value()
+	assert.Assert(t, is.Len(mounts, 1))
+	assert.Check(t, is.Equal(MountTypeSync, mounts[0].Type))
+	assert.Check(t, is.Equal(filepath.Join(wd, "src"), mounts[0].Source))
+	assert.Check(t, is.Equal("/app", mounts[0].Target))
+	assert.Check(t, is.Equal("true", mounts[0].VolumeOptions.Labels[SyncWatchLabel]))
+}
+
+func TestMountOptSetSyncError(t *testing.T) {
+	var m MountOpt
+	assert.ErrorContains(t, m.Set("type=sync,target=/app"), "source is required")
+	assert.ErrorContains(t, m.Set("type=sync,source=./src,target=/app,sync-watch=foo"), "invalid value for sync-watch")
+	assert.ErrorContains(t, m.Set("type=volume,source=foo,target=/app,sync-watch"), "cannot mix")
+}